@@ -1,18 +1,38 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"database/sql"
+	"errors"
+	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
 	_ "modernc.org/sqlite" // registers the "sqlite" driver
 
 	"user-management-api/internal/config"
 	"user-management-api/internal/handler"
+	"user-management-api/internal/idp"
+	"user-management-api/internal/ldap"
+	"user-management-api/internal/mail"
 	"user-management-api/internal/middleware"
+	"user-management-api/internal/migrate"
+	"user-management-api/internal/model"
+	"user-management-api/internal/password"
+	"user-management-api/internal/ratelimit"
 	"user-management-api/internal/repository"
+	"user-management-api/internal/reqctx"
 	"user-management-api/internal/service"
+	"user-management-api/internal/signing"
 )
 
 func main() {
@@ -29,33 +49,330 @@ func main() {
 	}
 	defer db.Close()
 
-	if err := runMigrations(db); err != nil {
+	migrator, err := migrate.New(db)
+	if err != nil {
+		log.Fatalf("migrate: %v", err)
+	}
+
+	// `main migrate up|down [n]|status` manages the schema and exits.
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrateCommand(migrator, os.Args[2:]); err != nil {
+			log.Fatalf("migrate: %v", err)
+		}
+		return
+	}
+
+	ran, err := migrator.Up(context.Background())
+	if err != nil {
 		log.Fatalf("migrate: %v", err)
 	}
+	for _, m := range ran {
+		log.Printf("applied migration %04d_%s", m.Version, m.Name)
+	}
+
+	keys, err := loadSigningKeys(cfg)
+	if err != nil {
+		log.Fatalf("load signing keys: %v", err)
+	}
+
+	policy, err := loadPasswordPolicy(cfg)
+	if err != nil {
+		log.Fatalf("password policy: %v", err)
+	}
+
+	hasher, err := newPasswordHasher(cfg)
+	if err != nil {
+		log.Fatalf("password hasher: %v", err)
+	}
+
+	mailer, err := newMailer(cfg)
+	if err != nil {
+		log.Fatalf("mailer: %v", err)
+	}
+
+	emails, err := emailScope(cfg)
+	if err != nil {
+		log.Fatalf("email uniqueness: %v", err)
+	}
 
 	// Dependency wiring — pure constructor injection, no global state.
-	userRepo := repository.NewUserRepository(db)
-	userSvc := service.NewUserService(userRepo, cfg.JWTSecret, cfg.JWTExpiry)
+	userRepo := repository.NewUserRepository(db, emails)
+	roleRepo := repository.NewRoleRepository(db)
+	actionRepo := repository.NewActionTokenRepository(db)
+	refreshRepo := repository.NewRefreshTokenRepository(db)
+	revokedRepo := repository.NewRevokedTokenRepository(db)
+	mfaRepo := repository.NewMFARepository(db)
+	webauthnRepo := repository.NewWebAuthnRepository(db)
+	failureRepo := repository.NewSignInFailureRepository(db)
+	patRepo := repository.NewPersonalTokenRepository(db)
+	clientRepo := repository.NewOAuthClientRepository(db)
+	oidcRepo := repository.NewOIDCRepository(db)
+	fedRepo := repository.NewFederationRepository(db)
+	sessionRepo := repository.NewSessionRepository(db)
+	auditRepo := repository.NewAuditRepository(db)
+	orgRepo := repository.NewOrganizationRepository(db)
+	memberRepo := repository.NewMembershipRepository(db)
+	if err := userRepo.ApplyEmailScope(context.Background()); err != nil {
+		log.Fatalf("email uniqueness %s: %v", emails, err)
+	}
+	tokenSvc := service.NewTokenService(userRepo, refreshRepo, revokedRepo, roleRepo, patRepo, clientRepo, sessionRepo, memberRepo, service.TokenConfig{
+		Keys:          keys,
+		JWTExpiry:     cfg.JWTExpiry,
+		RefreshExpiry: cfg.RefreshExpiry,
+		Issuer:        cfg.OIDCIssuer,
+
+		MFARequiredRoles: cfg.MFARequiredRoles,
+	})
+	verificationSvc := service.NewEmailVerificationService(userRepo, actionRepo, mailer, service.VerificationConfig{
+		TokenExpiry: cfg.EmailVerificationExpiry,
+		VerifyURL:   cfg.AppBaseURL + "/verify-email",
+		Dispatch:    service.InBackground,
+	})
+	mfaSvc := service.NewMFAService(userRepo, mfaRepo, actionRepo, tokenSvc, service.MFAConfig{
+		Issuer:          cfg.MFAIssuer,
+		ChallengeExpiry: cfg.MFAChallengeExpiry,
+	})
+	webauthnSvc := service.NewWebAuthnService(userRepo, webauthnRepo, actionRepo, service.WebAuthnConfig{
+		RPID:    cfg.WebAuthnRPID,
+		RPName:  cfg.WebAuthnRPName,
+		Origins: cfg.WebAuthnOrigins,
+		Timeout: cfg.WebAuthnTimeout,
+	})
+	lockoutSvc := service.NewLockoutService(userRepo, failureRepo, service.LockoutConfig{
+		Threshold:   cfg.LockoutThreshold,
+		Duration:    cfg.LockoutDuration,
+		MaxDuration: cfg.LockoutMaxDuration,
+		IPThreshold: cfg.IPFailureThreshold,
+		IPWindow:    cfg.IPFailureWindow,
+	})
+	providers := make([]*idp.Provider, 0, len(cfg.IdentityProviders))
+	for _, pc := range cfg.IdentityProviders {
+		if pc.ID == service.LDAPProviderID {
+			log.Fatalf("identity provider ID %q is reserved for the LDAP directory", pc.ID)
+		}
+		p, err := idp.New(pc, nil)
+		if err != nil {
+			log.Fatalf("identity provider %s: %v", pc.ID, err)
+		}
+		providers = append(providers, p)
+	}
+	federationSvc := service.NewFederationService(fedRepo, userRepo, roleRepo, webauthnRepo, verificationSvc, service.FederationConfig{
+		Providers:   providers,
+		ReturnURL:   cfg.IDPReturnURL,
+		StateExpiry: cfg.IDPStateExpiry,
+	})
+	magicLinkSvc := service.NewMagicLinkService(userRepo, actionRepo, mailer, service.MagicLinkConfig{
+		Expiry:     cfg.MagicLinkExpiry,
+		SignInURL:  cfg.AppBaseURL + "/magic-link",
+		PerAddress: cfg.RateLimitMagicLinkEmail,
+		Dispatch:   service.InBackground,
+	})
+	authenticator, err := newAuthenticator(cfg, userRepo, roleRepo, fedRepo, hasher)
+	if err != nil {
+		log.Fatalf("auth backends: %v", err)
+	}
+	userSvc := service.NewUserService(userRepo, roleRepo, tokenSvc, verificationSvc, mfaSvc, webauthnSvc, lockoutSvc, federationSvc, magicLinkSvc, service.UserConfig{
+		PurgeGrace:           cfg.UserPurgeGrace,
+		RequireVerifiedEmail: cfg.RequireVerifiedEmail,
+		Policy:               policy,
+		Hasher:               hasher,
+		Authenticator:        authenticator,
+	})
+	passwordSvc := service.NewPasswordService(userRepo, actionRepo, tokenSvc, lockoutSvc, mailer, service.PasswordConfig{
+		ResetTokenExpiry: cfg.ResetTokenExpiry,
+		ResetURL:         cfg.AppBaseURL + "/reset-password",
+		Policy:           policy,
+		Hasher:           hasher,
+		Dispatch:         service.InBackground,
+	})
+	patSvc := service.NewPersonalTokenService(patRepo)
+	auditSvc := service.NewAuditService(auditRepo)
+	impersonationSvc := service.NewImpersonationService(userRepo, roleRepo, tokenSvc, auditSvc, service.ImpersonationConfig{
+		Expiry: cfg.ImpersonationExpiry,
+	})
+	orgSvc := service.NewOrganizationService(orgRepo, memberRepo, userRepo)
+	oauthSvc := service.NewOAuthService(clientRepo, tokenSvc)
+	oidcSvc := service.NewOIDCService(clientRepo, oidcRepo, userRepo, tokenSvc, service.OIDCConfig{
+		Issuer:           cfg.OIDCIssuer,
+		LoginURL:         cfg.OIDCLoginURL,
+		CodeExpiry:       cfg.OIDCCodeExpiry,
+		SigningAlgorithm: keys.SigningAlgorithm(),
+	})
 	authHandler := handler.NewAuthHandler(userSvc)
+	passwordHandler := handler.NewPasswordHandler(passwordSvc)
+	verificationHandler := handler.NewVerificationHandler(verificationSvc)
+	mfaHandler := handler.NewMFAHandler(mfaSvc)
+	webauthnHandler := handler.NewWebAuthnHandler(webauthnSvc, userSvc)
+	federationHandler := handler.NewFederationHandler(federationSvc, userSvc)
+	magicLinkHandler := handler.NewMagicLinkHandler(magicLinkSvc, userSvc)
 	userHandler := handler.NewUserHandler(userSvc)
+	adminHandler := handler.NewAdminHandler(userSvc)
+	patHandler := handler.NewPersonalTokenHandler(patSvc)
+	sessionHandler := handler.NewSessionHandler(tokenSvc)
+	impersonationHandler := handler.NewImpersonationHandler(impersonationSvc)
+	auditHandler := handler.NewAuditHandler(auditSvc)
+	orgHandler := handler.NewOrganizationHandler(orgSvc, userSvc)
+	oauthHandler := handler.NewOAuthHandler(oauthSvc, oidcSvc)
+	oidcHandler := handler.NewOIDCHandler(oidcSvc)
+	jwksHandler := handler.NewJWKSHandler(keys)
+
+	if err := userSvc.EnsureAdmins(reqctx.WithTenant(context.Background(), model.DefaultTenantID), cfg.AdminEmails); err != nil {
+		log.Fatalf("bootstrap admins: %v", err)
+	}
+
+	go every(cfg.TokenPurgeInterval, "purge expired tokens", func(ctx context.Context) error {
+		if err := tokenSvc.PurgeExpired(ctx); err != nil {
+			return err
+		}
+		if _, err := actionRepo.DeleteExpired(ctx, time.Now()); err != nil {
+			return err
+		}
+		if err := oidcSvc.PurgeExpired(ctx); err != nil {
+			return err
+		}
+		if err := federationSvc.PurgeExpired(ctx); err != nil {
+			return err
+		}
+		return lockoutSvc.PurgeStale(ctx)
+	})
+	go every(cfg.UserPurgeInterval, "purge deleted users", func(ctx context.Context) error {
+		n, err := userSvc.PurgeDeleted(ctx)
+		if n > 0 {
+			log.Printf("purged %d deleted user(s)", n)
+		}
+		return err
+	})
 
 	r := gin.Default()
-	r.SetTrustedProxies(nil) //nolint:errcheck
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("trusted proxies: %v", err)
+	}
+	r.Use(middleware.ClientInfo(), middleware.Organization(orgSvc), middleware.AuditImpersonation(auditSvc))
+	if cfg.SessionCookies {
+		cookies, err := newCookieConfig(cfg)
+		if err != nil {
+			log.Fatalf("session cookies: %v", err)
+		}
+		r.Use(middleware.SessionCookies(cookies), middleware.CSRF(cookies))
+	}
+
+	// Buckets live in this process; swap in a shared ratelimit.Store when
+	// running more than one instance.
+	limits := ratelimit.NewMemoryStore()
+	go every(time.Minute, "sweep rate limits", limits.Sweep)
+	authLimit := middleware.RateLimit(limits, "auth", cfg.RateLimitAuth, middleware.ByIP)
+	apiLimit := middleware.RateLimit(limits, "api", cfg.RateLimitAPI, middleware.ByUser)
+
+	r.GET("/.well-known/jwks.json", jwksHandler.JWKS)
+	r.GET("/.well-known/openid-configuration", oidcHandler.Discovery)
 
 	v1 := r.Group("/api/v1")
 	{
-		auth := v1.Group("/auth")
+		auth := v1.Group("/auth", authLimit)
 		{
-			auth.POST("/register", authHandler.Register)
+			auth.POST("/register", middleware.RateLimit(limits, "register", cfg.RateLimitRegister, middleware.ByIP), authHandler.Register)
 			auth.POST("/signin", authHandler.SignIn)
+			auth.POST("/refresh", authHandler.Refresh)
+			auth.POST("/signout", middleware.JWTAuth(tokenSvc), authHandler.SignOut)
+			auth.POST("/signout-all", middleware.JWTAuth(tokenSvc), middleware.RequireSession(), authHandler.SignOutAll)
+			auth.POST("/password/forgot", passwordHandler.ForgotPassword)
+			auth.POST("/password/reset", passwordHandler.ResetPassword)
+			auth.POST("/email/verify", verificationHandler.VerifyEmail)
+			auth.POST("/email/resend", verificationHandler.ResendVerification)
+			auth.POST("/magic-link", middleware.RateLimit(limits, "magic-link", cfg.RateLimitMagicLink, middleware.ByIP), magicLinkHandler.Send)
+			auth.POST("/magic-link/verify", magicLinkHandler.Verify)
+			auth.POST("/mfa/verify", mfaHandler.Verify)
+			auth.POST("/webauthn/login/begin", webauthnHandler.BeginLogin)
+			auth.POST("/webauthn/login/finish", webauthnHandler.FinishLogin)
+			auth.GET("/idp", federationHandler.ListProviders)
+			auth.GET("/idp/:provider/login", federationHandler.Login)
+			auth.GET("/idp/:provider/callback", federationHandler.Callback)
+			auth.POST("/idp/signin", federationHandler.SignIn)
+		}
+
+		// OAuth 2.0 token endpoint; answers in RFC 6749 format.
+		v1.POST("/oauth/token", authLimit, oauthHandler.Token)
+
+		// OpenID Connect. Clients send the browser to GET /oauth/authorize,
+		// which forwards it to the login page; that page posts back with the
+		// user's token.
+		oauth := v1.Group("/oauth")
+		{
+			oauth.GET("/authorize", authLimit, oidcHandler.BeginAuthorize)
+			oauth.POST("/authorize", middleware.JWTAuth(tokenSvc), middleware.RequireSession(), apiLimit, oidcHandler.Authorize)
+			oauth.GET("/userinfo", middleware.JWTAuth(tokenSvc), apiLimit, oidcHandler.UserInfo)
+			oauth.POST("/userinfo", middleware.JWTAuth(tokenSvc), apiLimit, oidcHandler.UserInfo)
+		}
+
+		webauthn := v1.Group("/auth/webauthn", middleware.JWTAuth(tokenSvc), middleware.RequireSession(), apiLimit)
+		{
+			webauthn.POST("/register/begin", webauthnHandler.BeginRegistration)
+			webauthn.POST("/register/finish", webauthnHandler.FinishRegistration)
+			webauthn.GET("/credentials", webauthnHandler.ListCredentials)
+			webauthn.DELETE("/credentials/:id", webauthnHandler.DeleteCredential)
+		}
+
+		mfa := v1.Group("/auth/mfa", middleware.JWTAuth(tokenSvc), middleware.RequireSession(), apiLimit)
+		{
+			mfa.GET("", mfaHandler.Status)
+			mfa.POST("/totp", mfaHandler.EnrollTOTP)
+			mfa.POST("/totp/confirm", mfaHandler.ConfirmTOTP)
+			mfa.DELETE("/totp", mfaHandler.DisableTOTP)
+			mfa.POST("/recovery-codes", mfaHandler.RegenerateRecoveryCodes)
 		}
 
-		// All /users routes require a valid JWT.
-		users := v1.Group("/users", middleware.JWTAuth(cfg.JWTSecret))
+		// All /users routes require a valid JWT, and only reach users of the
+		// caller's organization.
+		users := v1.Group("/users", middleware.JWTAuth(tokenSvc), middleware.SameTenant(userSvc), apiLimit)
 		{
-			users.GET("", userHandler.ListUsers)
-			users.GET("/:id", userHandler.GetUser)
+			users.GET("", middleware.RequirePermission(model.PermUsersRead), userHandler.ListUsers)
+			users.GET("/:id", middleware.RequirePermission(model.PermUsersRead), userHandler.GetUser)
+			// Self-or-users:write is checked in the handler.
 			users.PUT("/:id", userHandler.UpdateUser)
+			// Self-or-users:delete is checked in the handler.
+			users.DELETE("/:id", userHandler.DeleteUser)
+			// Self only; checked in the handler.
+			users.PUT("/:id/password", middleware.RequireSession(), passwordHandler.ChangePassword)
+			// Self-or-users:write is checked in the handler; creating is self only.
+			users.GET("/:id/tokens", patHandler.ListTokens)
+			users.POST("/:id/tokens", middleware.RequireSession(), patHandler.CreateToken)
+			users.DELETE("/:id/tokens/:tokenId", patHandler.RevokeToken)
+			// Self-or-users:write is checked in the handler.
+			users.GET("/:id/sessions", sessionHandler.ListSessions)
+			users.DELETE("/:id/sessions/:sid", sessionHandler.RevokeSession)
+			// Self-or-users:write is checked in the handler; linking is self only.
+			users.GET("/:id/identities", federationHandler.ListIdentities)
+			users.POST("/:id/identities", middleware.RequireSession(), federationHandler.LinkIdentity)
+			users.DELETE("/:id/identities/:identityId", middleware.RequireSession(), federationHandler.UnlinkIdentity)
+		}
+
+		admin := v1.Group("/admin", middleware.JWTAuth(tokenSvc), apiLimit)
+		{
+			admin.GET("/users/:id/roles", middleware.RequirePermission(model.PermUsersRead), adminHandler.GetRoles)
+			admin.PUT("/users/:id/roles", middleware.RequirePermission(model.PermRolesWrite), adminHandler.SetRoles)
+			admin.POST("/users/:id/unlock", middleware.RequirePermission(model.PermUsersWrite), adminHandler.UnlockUser)
+			admin.POST("/users/:id/restore", middleware.RequirePermission(model.PermUsersDelete), adminHandler.RestoreUser)
+			admin.DELETE("/users/:id/purge", middleware.RequirePermission(model.PermUsersDelete), adminHandler.PurgeUser)
+			admin.POST("/users/:id/impersonate", middleware.RequireSession(), middleware.RequirePermission(model.PermUsersImpersonate), impersonationHandler.Impersonate)
+			admin.GET("/audit", middleware.RequirePermission(model.PermAuditRead), auditHandler.ListEvents)
+
+			orgs := admin.Group("/organizations", middleware.RequirePermission(model.PermOrgsWrite))
+			orgs.GET("", orgHandler.List)
+			orgs.POST("", orgHandler.Create)
+			orgs.GET("/:id", orgHandler.Get)
+			orgs.POST("/:id/users", orgHandler.CreateUser)
+			orgs.GET("/:id/members", orgHandler.ListMembers)
+			orgs.POST("/:id/members", orgHandler.AddMember)
+			orgs.PUT("/:id/members/:userId", orgHandler.SetMemberRole)
+			orgs.DELETE("/:id/members/:userId", orgHandler.RemoveMember)
+
+			clients := admin.Group("/oauth/clients", middleware.RequirePermission(model.PermClientsWrite))
+			clients.GET("", oauthHandler.ListClients)
+			clients.POST("", oauthHandler.CreateClient)
+			clients.GET("/:id", oauthHandler.GetClient)
+			clients.PUT("/:id", oauthHandler.UpdateClient)
+			clients.POST("/:id/secret", oauthHandler.RotateClientSecret)
+			clients.DELETE("/:id", oauthHandler.DeleteClient)
 		}
 	}
 
@@ -65,17 +382,260 @@ func main() {
 	}
 }
 
-// runMigrations creates the schema on first run. Idempotent.
-func runMigrations(db *sql.DB) error {
-	_, err := db.Exec(`
-		CREATE TABLE IF NOT EXISTS users (
-			id            TEXT PRIMARY KEY,
-			name          TEXT NOT NULL,
-			email         TEXT NOT NULL UNIQUE,
-			password_hash TEXT NOT NULL,
-			created_at    TEXT NOT NULL,
-			updated_at    TEXT NOT NULL
-		)
-	`)
-	return err
+// emailScope parses EMAIL_UNIQUENESS.
+func emailScope(cfg *config.Config) (repository.EmailScope, error) {
+	switch scope := repository.EmailScope(cfg.EmailUniqueness); scope {
+	case repository.EmailsGlobal, repository.EmailsPerTenant:
+		return scope, nil
+	}
+	return "", fmt.Errorf("unknown EMAIL_UNIQUENESS %q", cfg.EmailUniqueness)
+}
+
+// loadSigningKeys uses the configured PEM keys, falling back to HS256 with
+// JWT_SECRET when no signing key file is set.
+func loadSigningKeys(cfg *config.Config) (*signing.KeySet, error) {
+	if cfg.JWTSigningKeyFile == "" {
+		return signing.NewHMACKeySet(cfg.JWTSecret), nil
+	}
+	return signing.LoadKeySet(cfg.JWTSigningKeyFile, cfg.JWTVerifyKeyFiles)
+}
+
+// loadPasswordPolicy builds the password policy, reading the breached
+// password file if one is configured.
+func loadPasswordPolicy(cfg *config.Config) (*password.Policy, error) {
+	blocklist := password.NewBlocklist()
+	if cfg.PasswordBlocklistFile != "" {
+		var err error
+		if blocklist, err = password.LoadBlocklistFile(cfg.PasswordBlocklistFile); err != nil {
+			return nil, err
+		}
+	}
+	return &password.Policy{
+		MinLength:   cfg.PasswordMinLength,
+		MaxLength:   cfg.PasswordMaxLength,
+		MinClasses:  cfg.PasswordMinClasses,
+		MaxRepeated: cfg.PasswordMaxRepeated,
+		MinStrength: cfg.PasswordMinStrength,
+		Blocklist:   blocklist,
+	}, nil
+}
+
+// newPasswordHasher hashes with PASSWORD_HASHER and keeps the other
+// algorithm around to verify, and upgrade, hashes it made.
+func newPasswordHasher(cfg *config.Config) (password.Hasher, error) {
+	// argon2.IDKey panics on zero rounds or threads, so bad settings must
+	// stop startup rather than the first sign-up.
+	switch {
+	case cfg.Argon2Time < 1:
+		return nil, fmt.Errorf("ARGON2_TIME must be at least 1, got %d", cfg.Argon2Time)
+	case cfg.Argon2Threads < 1 || cfg.Argon2Threads > 255:
+		return nil, fmt.Errorf("ARGON2_THREADS must be between 1 and 255, got %d", cfg.Argon2Threads)
+	case cfg.Argon2Memory < 8*cfg.Argon2Threads:
+		return nil, fmt.Errorf("ARGON2_MEMORY must be at least 8 KiB per thread (%d), got %d", 8*cfg.Argon2Threads, cfg.Argon2Memory)
+	case cfg.BcryptCost < bcrypt.MinCost || cfg.BcryptCost > bcrypt.MaxCost:
+		return nil, fmt.Errorf("BCRYPT_COST must be between %d and %d, got %d", bcrypt.MinCost, bcrypt.MaxCost, cfg.BcryptCost)
+	}
+	argon := &password.Argon2id{
+		Memory:  uint32(cfg.Argon2Memory),
+		Time:    uint32(cfg.Argon2Time),
+		Threads: uint8(cfg.Argon2Threads),
+		SaltLen: 16,
+		KeyLen:  32,
+	}
+	if cfg.PasswordPepper != "" {
+		argon.Pepper = password.NewPepper([]byte(cfg.PasswordPepper))
+	}
+	for _, old := range cfg.PasswordOldPeppers {
+		argon.OldPeppers = append(argon.OldPeppers, password.NewPepper([]byte(old)))
+	}
+	bcryptHasher := password.Bcrypt{Cost: cfg.BcryptCost}
+
+	switch cfg.PasswordHasher {
+	case "argon2id":
+		return password.Chain(argon, bcryptHasher), nil
+	case "bcrypt":
+		if cfg.PasswordPepper != "" {
+			return nil, errors.New("PASSWORD_PEPPER requires PASSWORD_HASHER=argon2id")
+		}
+		return password.Chain(bcryptHasher, argon), nil
+	}
+	return nil, fmt.Errorf("unknown PASSWORD_HASHER %q", cfg.PasswordHasher)
+}
+
+// newAuthenticator chains the password checks named by AUTH_BACKENDS.
+func newAuthenticator(
+	cfg *config.Config,
+	users *repository.UserRepository,
+	roles *repository.RoleRepository,
+	identities *repository.FederationRepository,
+	hasher password.Hasher,
+) (service.Authenticator, error) {
+	var auths []service.Authenticator
+	for _, name := range cfg.AuthBackends {
+		switch name {
+		case "local":
+			auths = append(auths, service.NewLocalAuthenticator(users, hasher))
+		case "ldap":
+			dir, err := newDirectory(cfg)
+			if err != nil {
+				return nil, err
+			}
+			auths = append(auths, service.NewLDAPAuthenticator(dir, users, roles, identities))
+		default:
+			return nil, fmt.Errorf("unknown auth backend %q", name)
+		}
+	}
+	if len(auths) == 0 {
+		return nil, errors.New("AUTH_BACKENDS is empty")
+	}
+	return service.ChainAuthenticators(auths...), nil
+}
+
+// newDirectory configures the LDAP directory, trusting LDAP_CA_FILE on top
+// of the system roots.
+func newDirectory(cfg *config.Config) (*ldap.Directory, error) {
+	var tlsConfig *tls.Config
+	if cfg.LDAPCAFile != "" {
+		pem, err := os.ReadFile(cfg.LDAPCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s: no PEM certificates", cfg.LDAPCAFile)
+		}
+		tlsConfig = &tls.Config{RootCAs: pool}
+	}
+	return ldap.New(ldap.Config{
+		URL:            cfg.LDAPURL,
+		StartTLS:       cfg.LDAPStartTLS,
+		TLS:            tlsConfig,
+		BindDN:         cfg.LDAPBindDN,
+		BindPassword:   cfg.LDAPBindPassword,
+		BaseDN:         cfg.LDAPBaseDN,
+		UserFilter:     cfg.LDAPUserFilter,
+		IDAttribute:    cfg.LDAPIDAttribute,
+		NameAttribute:  cfg.LDAPNameAttribute,
+		EmailAttribute: cfg.LDAPEmailAttribute,
+		Timeout:        cfg.LDAPTimeout,
+	})
+}
+
+// newCookieConfig describes the cookies of cookie sessions. The refresh
+// cookie is only sent to /api/v1/auth, where refresh and sign-out live.
+func newCookieConfig(cfg *config.Config) (*middleware.CookieConfig, error) {
+	cookies := &middleware.CookieConfig{
+		AccessName:  cfg.AccessCookieName,
+		RefreshName: cfg.RefreshCookieName,
+		CSRFName:    cfg.CSRFCookieName,
+		Domain:      cfg.CookieDomain,
+		Secure:      cfg.CookieSecure,
+		RefreshPath: "/api/v1/auth",
+		MaxAge:      cfg.RefreshExpiry,
+	}
+	switch strings.ToLower(cfg.CookieSameSite) {
+	case "strict":
+		cookies.SameSite = http.SameSiteStrictMode
+	case "lax":
+		cookies.SameSite = http.SameSiteLaxMode
+	case "none":
+		if !cfg.CookieSecure {
+			return nil, errors.New("COOKIE_SAMESITE=none requires COOKIE_SECURE=true")
+		}
+		cookies.SameSite = http.SameSiteNoneMode
+	default:
+		return nil, fmt.Errorf("unknown COOKIE_SAMESITE %q", cfg.CookieSameSite)
+	}
+	return cookies, nil
+}
+
+// newMailer picks the Mailer implementation named by MAIL_DRIVER.
+func newMailer(cfg *config.Config) (mail.Mailer, error) {
+	switch cfg.MailDriver {
+	case "smtp":
+		return mail.NewSMTPMailer(mail.SMTPConfig{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.MailFrom,
+		}), nil
+	case "file":
+		return mail.NewFileMailer(cfg.MailDir, cfg.MailFrom)
+	case "log":
+		return mail.NewLogMailer(cfg.MailFrom), nil
+	}
+	return nil, fmt.Errorf("unknown MAIL_DRIVER %q", cfg.MailDriver)
+}
+
+// every runs job on a fixed interval for the lifetime of the process,
+// logging failures under name.
+func every(interval time.Duration, name string, job func(context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := job(context.Background()); err != nil {
+			log.Printf("%s: %v", name, err)
+		}
+	}
+}
+
+const migrateUsage = "usage: migrate up | down [steps] | status"
+
+// runMigrateCommand implements the `migrate` subcommand.
+func runMigrateCommand(m *migrate.Migrator, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		ran, err := m.Up(ctx)
+		for _, mig := range ran {
+			fmt.Printf("applied  %04d_%s\n", mig.Version, mig.Name)
+		}
+		if err == nil && len(ran) == 0 {
+			fmt.Println("schema is up to date")
+		}
+		return err
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return errors.New("steps must be a positive integer")
+			}
+			steps = n
+		}
+		reverted, err := m.Down(ctx, steps)
+		for _, mig := range reverted {
+			fmt.Printf("reverted %04d_%s\n", mig.Version, mig.Name)
+		}
+		return err
+
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT\tSTATE")
+		for _, st := range statuses {
+			appliedAt, state := "-", "pending"
+			if st.AppliedAt != nil {
+				appliedAt, state = st.AppliedAt.Format("2006-01-02 15:04:05"), "applied"
+				if st.Modified {
+					state = "MODIFIED"
+				}
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\t%s\n", st.Version, st.Name, appliedAt, state)
+		}
+		return w.Flush()
+	}
+	return errors.New(migrateUsage)
 }
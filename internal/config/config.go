@@ -1,29 +1,336 @@
 package config
 
 import (
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
+
+	"user-management-api/internal/idp"
+	"user-management-api/internal/ratelimit"
 )
 
 type Config struct {
-	Port      string
-	DBPath    string
-	JWTSecret string
-	JWTExpiry time.Duration
+	Port          string
+	DBPath        string
+	JWTSecret     string
+	JWTExpiry     time.Duration
+	RefreshExpiry time.Duration
+
+	// JWTSigningKeyFile, when set, switches token signing from HS256 with
+	// JWTSecret to the RSA or Ed25519 private key in this PEM file.
+	JWTSigningKeyFile string
+	// JWTVerifyKeyFiles are extra PEM keys (public or private) still accepted
+	// for verification, typically the previous signing key after a rotation.
+	JWTVerifyKeyFiles []string
+
+	// SessionCookies turns on cookie sessions for browser clients: sign-ins
+	// put the tokens in HttpOnly cookies instead of the response body,
+	// JWTAuth accepts the access cookie, and state-changing requests that
+	// rely on the cookies must echo the CSRF cookie in an X-CSRF-Token
+	// header. Bearer tokens keep working alongside.
+	SessionCookies bool
+	// CookieDomain, when set, shares the cookies with its subdomains.
+	CookieDomain string
+	CookieSecure bool
+	// CookieSameSite is "strict", "lax" or "none"; "none" needs CookieSecure.
+	CookieSameSite    string
+	AccessCookieName  string
+	RefreshCookieName string
+	CSRFCookieName    string
+
+	// AppBaseURL is the public URL of the front end, used to build links in emails.
+	AppBaseURL       string
+	ResetTokenExpiry time.Duration
+
+	// EmailVerificationExpiry is how long a verification link stays valid.
+	EmailVerificationExpiry time.Duration
+	// RequireVerifiedEmail blocks sign-in until the user confirms their email.
+	RequireVerifiedEmail bool
+	// MagicLinkExpiry is how long a mailed sign-in link stays valid.
+	MagicLinkExpiry time.Duration
+
+	// MailDriver selects the Mailer: "smtp", "file" (writes .eml files to
+	// MailDir) or "log" (prints to the server log).
+	MailDriver   string
+	MailFrom     string
+	MailDir      string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+
+	// AdminEmails are granted the admin role at startup if the account
+	// exists in the default organization.
+	AdminEmails []string
+	// EmailUniqueness is "global" (one account per address across all
+	// organizations) or "tenant" (one per address in each organization).
+	EmailUniqueness string
+
+	// MFAIssuer labels accounts in authenticator apps.
+	MFAIssuer string
+	// MFAChallengeExpiry is how long a sign-in has to present its second factor.
+	MFAChallengeExpiry time.Duration
+	// MFARequiredRoles are withheld from sessions that signed in without a
+	// second factor. Defaults to admin.
+	MFARequiredRoles []string
+
+	// WebAuthnRPID is the domain passkeys are bound to; it defaults to the
+	// host of AppBaseURL. WebAuthnOrigins defaults to AppBaseURL itself.
+	WebAuthnRPID    string
+	WebAuthnRPName  string
+	WebAuthnOrigins []string
+	WebAuthnTimeout time.Duration
+
+	// PasswordMinLength and the other Password* fields make up the policy
+	// new passwords must meet; see password.Policy. PasswordBlocklistFile
+	// adds SHA-1 hashes of breached passwords to the built-in list.
+	PasswordMinLength     int
+	PasswordMaxLength     int
+	PasswordMinClasses    int
+	PasswordMaxRepeated   int
+	PasswordMinStrength   int
+	PasswordBlocklistFile string
+
+	// PasswordHasher is "argon2id" or "bcrypt". Hashes made by the other
+	// still verify and are upgraded when their owner signs in, as are hashes
+	// made with older Argon2*/BcryptCost settings.
+	PasswordHasher string
+	Argon2Memory   int // KiB
+	Argon2Time     int
+	Argon2Threads  int
+	BcryptCost     int
+	// PasswordPepper is a secret mixed into Argon2id hashes; it lives outside
+	// the database so a stolen copy of it can't be cracked offline.
+	// PasswordOldPeppers keep hashes made before a rotation verifiable.
+	PasswordPepper     string
+	PasswordOldPeppers []string
+
+	// LockoutThreshold consecutive wrong passwords lock an account for
+	// LockoutDuration, doubling with each further failure up to
+	// LockoutMaxDuration. Zero disables account lockout.
+	LockoutThreshold   int
+	LockoutDuration    time.Duration
+	LockoutMaxDuration time.Duration
+	// IPFailureThreshold failures from one client IP within IPFailureWindow
+	// start blocking that IP. Zero disables it.
+	IPFailureThreshold int
+	IPFailureWindow    time.Duration
+
+	// RateLimitAuth caps each client IP on the public /auth routes,
+	// RateLimitRegister additionally caps registrations per IP, and
+	// RateLimitAPI caps each user across the authenticated routes.
+	// RateLimitMagicLink caps sign-in link requests per IP, and
+	// RateLimitMagicLinkEmail the links mailed to any one account.
+	RateLimitAuth           ratelimit.Limit
+	RateLimitRegister       ratelimit.Limit
+	RateLimitAPI            ratelimit.Limit
+	RateLimitMagicLink      ratelimit.Limit
+	RateLimitMagicLinkEmail ratelimit.Limit
+
+	// TrustedProxies are the proxy addresses or CIDRs whose X-Forwarded-For
+	// is believed when working out the client IP. Empty trusts none.
+	TrustedProxies []string
+
+	// OIDCIssuer is the public URL of this API, used as the iss of ID tokens
+	// and the base of the discovery document. OIDCLoginURL is the front-end
+	// page that signs users in and asks for consent; it defaults to
+	// AppBaseURL + "/authorize".
+	OIDCIssuer     string
+	OIDCLoginURL   string
+	OIDCCodeExpiry time.Duration
+
+	// AuthBackends are asked in order to check passwords at sign-in:
+	// "local" for the hashes stored here, "ldap" for a bind to the
+	// directory below. The first that knows the email decides.
+	AuthBackends []string
+	// LDAPURL is ldap://host[:port] or ldaps://host[:port]. LDAPBindDN is
+	// the service account that searches LDAPBaseDN with LDAPUserFilter, in
+	// which {login} stands for the email address signing in. LDAPCAFile
+	// adds a PEM CA to trust for ldaps:// and LDAPStartTLS.
+	LDAPURL            string
+	LDAPStartTLS       bool
+	LDAPCAFile         string
+	LDAPBindDN         string
+	LDAPBindPassword   string
+	LDAPBaseDN         string
+	LDAPUserFilter     string
+	LDAPIDAttribute    string
+	LDAPNameAttribute  string
+	LDAPEmailAttribute string
+	LDAPTimeout        time.Duration
+
+	// IdentityProviders are the upstream OpenID Connect providers users can
+	// sign in with, listed in IDP_PROVIDERS and configured by IDP_<ID>_*
+	// variables. IDPReturnURL is the front-end page the callback hands the
+	// result to; it defaults to AppBaseURL + "/sso/callback".
+	IdentityProviders []idp.Config
+	IDPReturnURL      string
+	IDPStateExpiry    time.Duration
+
+	// ImpersonationExpiry is how long an admin's token for acting as another
+	// user lasts. It can't be refreshed.
+	ImpersonationExpiry time.Duration
+
+	// UserPurgeGrace is how long a soft-deleted user can be restored before
+	// it becomes eligible for a hard purge.
+	UserPurgeGrace time.Duration
+	// UserPurgeInterval controls how often eligible users are purged.
+	UserPurgeInterval time.Duration
+
+	// TokenPurgeInterval controls how often expired revocations and refresh tokens are deleted.
+	TokenPurgeInterval time.Duration
 }
 
 func Load() *Config {
 	// .env is optional; falls back to environment variables and defaults.
 	_ = godotenv.Load()
 
-	return &Config{
-		Port:      getEnv("PORT", "8080"),
-		DBPath:    getEnv("DB_PATH", "./data/users.db"),
-		JWTSecret: getEnv("JWT_SECRET", "change-me-in-production"),
-		JWTExpiry: 24 * time.Hour,
+	cfg := &Config{
+		Port:          getEnv("PORT", "8080"),
+		DBPath:        getEnv("DB_PATH", "./data/users.db"),
+		JWTSecret:     getEnv("JWT_SECRET", "change-me-in-production"),
+		JWTExpiry:     getDuration("JWT_EXPIRY", 15*time.Minute),
+		RefreshExpiry: getDuration("REFRESH_TOKEN_EXPIRY", 30*24*time.Hour),
+
+		JWTSigningKeyFile: getEnv("JWT_SIGNING_KEY_FILE", ""),
+		JWTVerifyKeyFiles: getList("JWT_VERIFY_KEY_FILES"),
+
+		SessionCookies:    getBool("SESSION_COOKIES", false),
+		CookieDomain:      getEnv("COOKIE_DOMAIN", ""),
+		CookieSecure:      getBool("COOKIE_SECURE", true),
+		CookieSameSite:    getEnv("COOKIE_SAMESITE", "lax"),
+		AccessCookieName:  getEnv("COOKIE_ACCESS_NAME", "access_token"),
+		RefreshCookieName: getEnv("COOKIE_REFRESH_NAME", "refresh_token"),
+		CSRFCookieName:    getEnv("COOKIE_CSRF_NAME", "csrf_token"),
+
+		AppBaseURL:       getEnv("APP_BASE_URL", "http://localhost:8080"),
+		ResetTokenExpiry: getDuration("RESET_TOKEN_EXPIRY", time.Hour),
+
+		EmailVerificationExpiry: getDuration("EMAIL_VERIFICATION_EXPIRY", 48*time.Hour),
+		RequireVerifiedEmail:    getBool("REQUIRE_VERIFIED_EMAIL", false),
+		MagicLinkExpiry:         getDuration("MAGIC_LINK_EXPIRY", 15*time.Minute),
+
+		MailDriver:   getEnv("MAIL_DRIVER", "log"),
+		MailFrom:     getEnv("MAIL_FROM", "no-reply@localhost"),
+		MailDir:      getEnv("MAIL_DIR", "./data/mail"),
+		SMTPHost:     getEnv("SMTP_HOST", "localhost"),
+		SMTPPort:     getEnv("SMTP_PORT", "587"),
+		SMTPUsername: getEnv("SMTP_USERNAME", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+
+		AdminEmails:     getList("ADMIN_EMAILS"),
+		EmailUniqueness: getEnv("EMAIL_UNIQUENESS", "global"),
+
+		MFAIssuer:          getEnv("MFA_ISSUER", "User Management API"),
+		MFAChallengeExpiry: getDuration("MFA_CHALLENGE_EXPIRY", 5*time.Minute),
+		MFARequiredRoles:   getListOr("MFA_REQUIRED_ROLES", []string{"admin"}),
+
+		WebAuthnRPID:    getEnv("WEBAUTHN_RP_ID", ""),
+		WebAuthnRPName:  getEnv("WEBAUTHN_RP_NAME", "User Management API"),
+		WebAuthnOrigins: getList("WEBAUTHN_ORIGINS"),
+		WebAuthnTimeout: getDuration("WEBAUTHN_TIMEOUT", 5*time.Minute),
+
+		PasswordMinLength:     getInt("PASSWORD_MIN_LENGTH", 8),
+		PasswordMaxLength:     getInt("PASSWORD_MAX_LENGTH", 72),
+		PasswordMinClasses:    getInt("PASSWORD_MIN_CLASSES", 2),
+		PasswordMaxRepeated:   getInt("PASSWORD_MAX_REPEATED", 3),
+		PasswordMinStrength:   getInt("PASSWORD_MIN_STRENGTH", 2),
+		PasswordBlocklistFile: getEnv("PASSWORD_BLOCKLIST_FILE", ""),
+
+		PasswordHasher:     getEnv("PASSWORD_HASHER", "argon2id"),
+		Argon2Memory:       getInt("ARGON2_MEMORY", 19*1024),
+		Argon2Time:         getInt("ARGON2_TIME", 2),
+		Argon2Threads:      getInt("ARGON2_THREADS", 1),
+		BcryptCost:         getInt("BCRYPT_COST", 10),
+		PasswordPepper:     getEnv("PASSWORD_PEPPER", ""),
+		PasswordOldPeppers: getList("PASSWORD_OLD_PEPPERS"),
+
+		LockoutThreshold:   getInt("LOCKOUT_THRESHOLD", 5),
+		LockoutDuration:    getDuration("LOCKOUT_DURATION", 15*time.Minute),
+		LockoutMaxDuration: getDuration("LOCKOUT_MAX_DURATION", 24*time.Hour),
+		IPFailureThreshold: getInt("IP_FAILURE_THRESHOLD", 20),
+		IPFailureWindow:    getDuration("IP_FAILURE_WINDOW", 15*time.Minute),
+
+		RateLimitAuth:           getLimit("RATE_LIMIT_AUTH", ratelimit.Limit{Requests: 20, Period: time.Minute}),
+		RateLimitRegister:       getLimit("RATE_LIMIT_REGISTER", ratelimit.Limit{Requests: 5, Period: time.Hour}),
+		RateLimitAPI:            getLimit("RATE_LIMIT_API", ratelimit.Limit{Requests: 120, Period: time.Minute}),
+		RateLimitMagicLink:      getLimit("RATE_LIMIT_MAGIC_LINK", ratelimit.Limit{Requests: 10, Period: time.Hour}),
+		RateLimitMagicLinkEmail: getLimit("RATE_LIMIT_MAGIC_LINK_EMAIL", ratelimit.Limit{Requests: 3, Period: 15 * time.Minute}),
+
+		TrustedProxies: getList("TRUSTED_PROXIES"),
+
+		OIDCIssuer:     getEnv("OIDC_ISSUER", ""),
+		OIDCLoginURL:   getEnv("OIDC_LOGIN_URL", ""),
+		OIDCCodeExpiry: getDuration("OIDC_CODE_EXPIRY", time.Minute),
+
+		AuthBackends:       getListOr("AUTH_BACKENDS", []string{"local"}),
+		LDAPURL:            getEnv("LDAP_URL", ""),
+		LDAPStartTLS:       getBool("LDAP_START_TLS", false),
+		LDAPCAFile:         getEnv("LDAP_CA_FILE", ""),
+		LDAPBindDN:         getEnv("LDAP_BIND_DN", ""),
+		LDAPBindPassword:   getEnv("LDAP_BIND_PASSWORD", ""),
+		LDAPBaseDN:         getEnv("LDAP_BASE_DN", ""),
+		LDAPUserFilter:     getEnv("LDAP_USER_FILTER", "(&(objectClass=person)(mail={login}))"),
+		LDAPIDAttribute:    getEnv("LDAP_ID_ATTRIBUTE", "entryUUID"),
+		LDAPNameAttribute:  getEnv("LDAP_NAME_ATTRIBUTE", "cn"),
+		LDAPEmailAttribute: getEnv("LDAP_EMAIL_ATTRIBUTE", "mail"),
+		LDAPTimeout:        getDuration("LDAP_TIMEOUT", 10*time.Second),
+
+		IDPReturnURL:   getEnv("IDP_RETURN_URL", ""),
+		IDPStateExpiry: getDuration("IDP_STATE_EXPIRY", 10*time.Minute),
+
+		ImpersonationExpiry: getDuration("IMPERSONATION_EXPIRY", 15*time.Minute),
+
+		UserPurgeGrace:    getDuration("USER_PURGE_GRACE", 30*24*time.Hour),
+		UserPurgeInterval: getDuration("USER_PURGE_INTERVAL", time.Hour),
+
+		TokenPurgeInterval: getDuration("TOKEN_PURGE_INTERVAL", time.Hour),
+	}
+
+	if cfg.WebAuthnRPID == "" {
+		if u, err := url.Parse(cfg.AppBaseURL); err == nil {
+			cfg.WebAuthnRPID = u.Hostname()
+		}
+	}
+	if len(cfg.WebAuthnOrigins) == 0 {
+		cfg.WebAuthnOrigins = []string{strings.TrimRight(cfg.AppBaseURL, "/")}
 	}
+	if cfg.OIDCIssuer == "" {
+		cfg.OIDCIssuer = "http://localhost:" + cfg.Port
+	}
+	if cfg.OIDCLoginURL == "" {
+		cfg.OIDCLoginURL = strings.TrimRight(cfg.AppBaseURL, "/") + "/authorize"
+	}
+	if cfg.IDPReturnURL == "" {
+		cfg.IDPReturnURL = strings.TrimRight(cfg.AppBaseURL, "/") + "/sso/callback"
+	}
+	cfg.IdentityProviders = loadIdentityProviders(strings.TrimRight(cfg.OIDCIssuer, "/"))
+	return cfg
+}
+
+// loadIdentityProviders reads IDP_<ID>_ISSUER, _CLIENT_ID, _CLIENT_SECRET,
+// _NAME, _SCOPES and _SIGNUP for each ID in IDP_PROVIDERS. Their callbacks
+// live under baseURL, which must be registered with the provider.
+func loadIdentityProviders(baseURL string) []idp.Config {
+	var providers []idp.Config
+	for _, id := range getList("IDP_PROVIDERS") {
+		prefix := "IDP_" + strings.ToUpper(strings.ReplaceAll(id, "-", "_")) + "_"
+		providers = append(providers, idp.Config{
+			ID:           id,
+			Name:         getEnv(prefix+"NAME", id),
+			Issuer:       getEnv(prefix+"ISSUER", ""),
+			ClientID:     getEnv(prefix+"CLIENT_ID", ""),
+			ClientSecret: getEnv(prefix+"CLIENT_SECRET", ""),
+			Scopes:       getList(prefix + "SCOPES"),
+			RedirectURL:  baseURL + "/api/v1/auth/idp/" + id + "/callback",
+			AllowSignUp:  getBool(prefix+"SIGNUP", true),
+		})
+	}
+	return providers
 }
 
 func getEnv(key, fallback string) string {
@@ -32,3 +339,67 @@ func getEnv(key, fallback string) string {
 	}
 	return fallback
 }
+
+// getList splits a comma-separated variable, dropping empty entries.
+func getList(key string) []string {
+	var out []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// getListOr is getList with a default for when the variable is unset. Set it
+// to "," for an explicitly empty list.
+func getListOr(key string, fallback []string) []string {
+	if _, set := os.LookupEnv(key); !set {
+		return fallback
+	}
+	return getList(key)
+}
+
+// getDuration parses a Go duration string (e.g. "15m", "720h").
+// Unset or malformed values fall back to the default.
+func getDuration(key string, fallback time.Duration) time.Duration {
+	if v := os.Getenv(key); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			return d
+		}
+	}
+	return fallback
+}
+
+// getInt parses a non-negative integer.
+// Unset or malformed values fall back to the default.
+func getInt(key string, fallback int) int {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			return n
+		}
+	}
+	return fallback
+}
+
+// getLimit parses a rate limit such as "20/1m", or "off".
+// Unset or malformed values fall back to the default.
+func getLimit(key string, fallback ratelimit.Limit) ratelimit.Limit {
+	if v := os.Getenv(key); v != "" {
+		if l, err := ratelimit.ParseLimit(v); err == nil {
+			return l
+		}
+	}
+	return fallback
+}
+
+// getBool parses a boolean ("true", "1", "false", "0", ...).
+// Unset or malformed values fall back to the default.
+func getBool(key string, fallback bool) bool {
+	if v := os.Getenv(key); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+
+	"user-management-api/internal/model"
+	"user-management-api/internal/service"
+)
+
+// AdminHandler serves the /admin routes. Every route is guarded by
+// middleware.RequirePermission in main, so handlers do no authorization.
+type AdminHandler struct {
+	svc      *service.UserService
+	validate *validator.Validate
+}
+
+func NewAdminHandler(svc *service.UserService) *AdminHandler {
+	return &AdminHandler{svc: svc, validate: validator.New()}
+}
+
+func (h *AdminHandler) GetRoles(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_id", "message": "user ID must be a valid UUID"})
+		return
+	}
+
+	roles, err := h.svc.GetRoles(c.Request.Context(), id)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	ok(c, gin.H{"roles": roles})
+}
+
+func (h *AdminHandler) SetRoles(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_id", "message": "user ID must be a valid UUID"})
+		return
+	}
+
+	var req model.SetRolesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation_error", "message": firstValidationError(err)})
+		return
+	}
+
+	roles, err := h.svc.SetRoles(c.Request.Context(), id, &req)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	ok(c, gin.H{"roles": roles})
+}
+
+func (h *AdminHandler) RestoreUser(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_id", "message": "user ID must be a valid UUID"})
+		return
+	}
+
+	u, err := h.svc.RestoreUser(c.Request.Context(), id)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	ok(c, u)
+}
+
+// UnlockUser lifts a sign-in lockout before it runs out.
+func (h *AdminHandler) UnlockUser(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_id", "message": "user ID must be a valid UUID"})
+		return
+	}
+
+	u, err := h.svc.UnlockUser(c.Request.Context(), id)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	ok(c, u)
+}
+
+// PurgeUser hard-deletes a soft-deleted user whose grace period has passed.
+func (h *AdminHandler) PurgeUser(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_id", "message": "user ID must be a valid UUID"})
+		return
+	}
+
+	if err := h.svc.PurgeUser(c.Request.Context(), id); err != nil {
+		fail(c, err)
+		return
+	}
+	noContent(c)
+}
@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+
+	"user-management-api/internal/model"
+	"user-management-api/internal/service"
+)
+
+// AuditHandler serves GET /admin/audit. The route is guarded by
+// middleware.RequirePermission in main.
+type AuditHandler struct {
+	svc      *service.AuditService
+	validate *validator.Validate
+}
+
+func NewAuditHandler(svc *service.AuditService) *AuditHandler {
+	return &AuditHandler{svc: svc, validate: validator.New()}
+}
+
+// ListEvents pages through the audit log, filtered by actor_id, user_id
+// and action.
+func (h *AuditHandler) ListEvents(c *gin.Context) {
+	var q model.ListAuditQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_query", "message": err.Error()})
+		return
+	}
+	if err := h.validate.Struct(q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation_error", "message": firstValidationError(err)})
+		return
+	}
+
+	events, err := h.svc.List(c.Request.Context(), &q)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	ok(c, events)
+}
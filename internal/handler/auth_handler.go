@@ -1,11 +1,14 @@
 package handler
 
 import (
+	"errors"
+	"io"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 
+	"user-management-api/internal/middleware"
 	"user-management-api/internal/model"
 	"user-management-api/internal/service"
 )
@@ -35,7 +38,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		fail(c, err)
 		return
 	}
-	created(c, resp)
+	session(c, http.StatusCreated, resp)
 }
 
 func (h *AuthHandler) SignIn(c *gin.Context) {
@@ -54,5 +57,60 @@ func (h *AuthHandler) SignIn(c *gin.Context) {
 		fail(c, err)
 		return
 	}
-	ok(c, resp)
+	session(c, http.StatusOK, resp)
+}
+
+// Refresh rotates the posted refresh token or, with cookie sessions, the
+// one in the refresh cookie.
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req model.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+	if req.RefreshToken == "" {
+		req.RefreshToken = refreshCookie(c)
+	}
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation_error", "message": firstValidationError(err)})
+		return
+	}
+
+	resp, err := h.svc.Refresh(c.Request.Context(), &req)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	session(c, http.StatusOK, resp)
+}
+
+// SignOut revokes the caller's access token and, when a refresh_token is
+// posted or in the refresh cookie, its refresh token family. The body is
+// optional.
+func (h *AuthHandler) SignOut(c *gin.Context) {
+	var req model.SignOutRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+	if req.RefreshToken == "" {
+		req.RefreshToken = refreshCookie(c)
+	}
+
+	if err := h.svc.SignOut(c.Request.Context(), middleware.CurrentPrincipal(c), &req); err != nil {
+		fail(c, err)
+		return
+	}
+	endSession(c)
+	noContent(c)
+}
+
+// SignOutAll revokes every access and refresh token the caller holds.
+func (h *AuthHandler) SignOutAll(c *gin.Context) {
+	if err := h.svc.SignOutAll(c.Request.Context(), middleware.CurrentPrincipal(c)); err != nil {
+		fail(c, err)
+		return
+	}
+	endSession(c)
+	noContent(c)
 }
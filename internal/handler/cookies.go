@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"user-management-api/internal/middleware"
+	"user-management-api/internal/model"
+)
+
+// session answers a request that may have started or refreshed a session.
+// For clients using cookie sessions, the tokens go into HttpOnly cookies
+// alongside a fresh CSRF token, and are left out of the body.
+func session(c *gin.Context, status int, resp *model.AuthResponse) {
+	cfg := middleware.SessionInCookies(c)
+	if cfg == nil || resp.Token == "" {
+		c.JSON(status, gin.H{"data": resp})
+		return
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		fail(c, err)
+		return
+	}
+	csrf := base64.RawURLEncoding.EncodeToString(b)
+
+	setCookie(c, cfg, cfg.AccessName, resp.Token, "/", time.Duration(resp.ExpiresIn)*time.Second, true)
+	setCookie(c, cfg, cfg.RefreshName, resp.RefreshToken, cfg.RefreshPath, cfg.MaxAge, true)
+	// Scripts read this one to echo it in the CSRF header.
+	setCookie(c, cfg, cfg.CSRFName, csrf, "/", cfg.MaxAge, false)
+
+	body := *resp
+	body.Token, body.RefreshToken, body.CSRFToken = "", "", csrf
+	c.JSON(status, gin.H{"data": &body})
+}
+
+// endSession clears the cookies of a cookie session, if there is one.
+func endSession(c *gin.Context) {
+	cfg := middleware.Cookies(c)
+	if cfg == nil {
+		return
+	}
+	setCookie(c, cfg, cfg.AccessName, "", "/", -1, true)
+	setCookie(c, cfg, cfg.RefreshName, "", cfg.RefreshPath, -1, true)
+	setCookie(c, cfg, cfg.CSRFName, "", "/", -1, false)
+}
+
+// refreshCookie returns the refresh token of a cookie session, or "".
+func refreshCookie(c *gin.Context) string {
+	cfg := middleware.Cookies(c)
+	if cfg == nil {
+		return ""
+	}
+	v, _ := c.Cookie(cfg.RefreshName)
+	return v
+}
+
+// setCookie writes a session cookie. A negative maxAge deletes it.
+func setCookie(c *gin.Context, cfg *middleware.CookieConfig, name, value, path string, maxAge time.Duration, httpOnly bool) {
+	seconds := int(maxAge.Seconds())
+	if maxAge < 0 {
+		seconds = -1
+	}
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Domain:   cfg.Domain,
+		MaxAge:   seconds,
+		Secure:   cfg.Secure,
+		HttpOnly: httpOnly,
+		SameSite: cfg.SameSite,
+	})
+}
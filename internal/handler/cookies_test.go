@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"user-management-api/internal/middleware"
+	"user-management-api/internal/model"
+)
+
+func TestSession_CookiesOnlyWhenAskedFor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &middleware.CookieConfig{
+		AccessName: "access", RefreshName: "refresh", CSRFName: "csrf",
+		RefreshPath: "/api/v1/auth", MaxAge: time.Hour, SameSite: http.SameSiteLaxMode,
+	}
+	r := gin.New()
+	r.Use(middleware.SessionCookies(cfg))
+	r.POST("/signin", func(c *gin.Context) {
+		session(c, http.StatusOK, &model.AuthResponse{Token: "jwt", RefreshToken: "rt", ExpiresIn: 900})
+	})
+
+	for _, tc := range []struct {
+		name       string
+		header     string
+		cookie     *http.Cookie
+		wantCookie bool
+	}{
+		{name: "bearer client"},
+		{name: "opted in", header: "cookie", wantCookie: true},
+		{name: "cookie session refresh", cookie: &http.Cookie{Name: "refresh", Value: "rt0"}, wantCookie: true},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/signin", nil)
+		if tc.header != "" {
+			req.Header.Set(middleware.SessionModeHeader, tc.header)
+		}
+		if tc.cookie != nil {
+			req.AddCookie(tc.cookie)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		var body struct{ Data model.AuthResponse }
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s: decode: %v", tc.name, err)
+		}
+		gotCookie := len(w.Result().Cookies()) > 0
+		if gotCookie != tc.wantCookie || (body.Data.Token == "") != tc.wantCookie {
+			t.Errorf("%s: cookies %v, token in body %q", tc.name, gotCookie, body.Data.Token)
+		}
+	}
+}
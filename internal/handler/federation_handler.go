@@ -0,0 +1,172 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+
+	"user-management-api/internal/middleware"
+	"user-management-api/internal/model"
+	"user-management-api/internal/service"
+)
+
+// stateCookie binds a federated sign-in to the browser that started it, so a
+// callback URL lured from someone else's browser is refused.
+const (
+	stateCookie     = "idp_state"
+	stateCookiePath = "/api/v1/auth/idp"
+)
+
+// FederationHandler serves sign-in through upstream identity providers and
+// the /users/:id/identities routes.
+type FederationHandler struct {
+	svc      *service.FederationService
+	users    *service.UserService
+	validate *validator.Validate
+}
+
+func NewFederationHandler(svc *service.FederationService, users *service.UserService) *FederationHandler {
+	return &FederationHandler{svc: svc, users: users, validate: validator.New()}
+}
+
+// ListProviders lists the providers for the login page's buttons.
+func (h *FederationHandler) ListProviders(c *gin.Context) {
+	ok(c, h.svc.Providers())
+}
+
+// Login sends the browser to the provider. The optional state query
+// parameter is returned unchanged to the front end's return URL.
+func (h *FederationHandler) Login(c *gin.Context) {
+	redirect, state, err := h.svc.BeginLogin(c.Request.Context(), c.Param("provider"), c.Query("state"))
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(stateCookie, state, 0, stateCookiePath, "", secureRequest(c), true)
+	c.Redirect(http.StatusFound, redirect)
+}
+
+// Callback is where the provider sends the browser back. It redirects on to
+// the front end with a ticket or an error.
+func (h *FederationHandler) Callback(c *gin.Context) {
+	state := c.Query("state")
+	if cookie, err := c.Cookie(stateCookie); err != nil || state == "" || cookie != state {
+		fail(c, service.ErrInvalidLoginState)
+		return
+	}
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(stateCookie, "", -1, stateCookiePath, "", secureRequest(c), true)
+
+	redirect, err := h.svc.Callback(c.Request.Context(), c.Param("provider"), state, c.Query("code"), c.Query("error"))
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	c.Redirect(http.StatusFound, redirect)
+}
+
+// SignIn redeems a ticket and answers like /auth/signin.
+func (h *FederationHandler) SignIn(c *gin.Context) {
+	var req model.FederatedTicketRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation_error", "message": firstValidationError(err)})
+		return
+	}
+
+	resp, err := h.users.SignInWithProvider(c.Request.Context(), &req)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	session(c, http.StatusOK, resp)
+}
+
+// ListIdentities shows a user's linked identities. Self or users:write.
+func (h *FederationHandler) ListIdentities(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_id", "message": "user ID must be a valid UUID"})
+		return
+	}
+
+	if c.MustGet(middleware.UserIDKey).(uuid.UUID) != id && !middleware.HasPermission(c, model.PermUsersWrite) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden", "message": "you can only list your own identities"})
+		return
+	}
+
+	identities, err := h.svc.ListIdentities(c.Request.Context(), id)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	ok(c, identities)
+}
+
+// LinkIdentity redeems a ticket to link its identity to the caller. Self only.
+func (h *FederationHandler) LinkIdentity(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_id", "message": "user ID must be a valid UUID"})
+		return
+	}
+
+	if c.MustGet(middleware.UserIDKey).(uuid.UUID) != id {
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden", "message": "you can only link identities to yourself"})
+		return
+	}
+
+	var req model.FederatedTicketRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation_error", "message": firstValidationError(err)})
+		return
+	}
+
+	identity, err := h.svc.Link(c.Request.Context(), id, req.Ticket)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	created(c, identity)
+}
+
+// UnlinkIdentity removes a linked identity. Self or users:write.
+func (h *FederationHandler) UnlinkIdentity(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_id", "message": "user ID must be a valid UUID"})
+		return
+	}
+	identityID, err := uuid.Parse(c.Param("identityId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_id", "message": "identity ID must be a valid UUID"})
+		return
+	}
+
+	if c.MustGet(middleware.UserIDKey).(uuid.UUID) != id && !middleware.HasPermission(c, model.PermUsersWrite) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden", "message": "you can only unlink your own identities"})
+		return
+	}
+
+	if err := h.svc.Unlink(c.Request.Context(), id, identityID); err != nil {
+		fail(c, err)
+		return
+	}
+	noContent(c)
+}
+
+// secureRequest reports whether the client reached us over HTTPS, directly
+// or through a proxy, so cookies can be marked Secure.
+func secureRequest(c *gin.Context) bool {
+	return c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+}
@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"user-management-api/internal/middleware"
+	"user-management-api/internal/service"
+)
+
+// ImpersonationHandler serves POST /admin/users/:id/impersonate. The route
+// is guarded by middleware.RequirePermission and RequireSession in main.
+type ImpersonationHandler struct {
+	svc *service.ImpersonationService
+}
+
+func NewImpersonationHandler(svc *service.ImpersonationService) *ImpersonationHandler {
+	return &ImpersonationHandler{svc: svc}
+}
+
+// Impersonate issues the caller a short-lived token acting as the user.
+func (h *ImpersonationHandler) Impersonate(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_id", "message": "user ID must be a valid UUID"})
+		return
+	}
+
+	resp, err := h.svc.Impersonate(c.Request.Context(), middleware.CurrentPrincipal(c), id)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	created(c, resp)
+}
@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+
+	"user-management-api/internal/model"
+	"user-management-api/internal/service"
+)
+
+type MagicLinkHandler struct {
+	svc      *service.MagicLinkService
+	users    *service.UserService
+	validate *validator.Validate
+}
+
+func NewMagicLinkHandler(svc *service.MagicLinkService, users *service.UserService) *MagicLinkHandler {
+	return &MagicLinkHandler{svc: svc, users: users, validate: validator.New()}
+}
+
+// Send always answers 202 once the request is well-formed, like
+// ForgotPassword, so the endpoint can't be used to enumerate accounts.
+func (h *MagicLinkHandler) Send(c *gin.Context) {
+	var req model.MagicLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation_error", "message": firstValidationError(err)})
+		return
+	}
+
+	h.svc.Send(c.Request.Context(), &req)
+	accepted(c, gin.H{"message": "if the account exists, a sign-in link has been sent"})
+}
+
+// Verify exchanges a sign-in link for a session, or an MFA challenge.
+func (h *MagicLinkHandler) Verify(c *gin.Context) {
+	var req model.MagicLinkVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation_error", "message": firstValidationError(err)})
+		return
+	}
+
+	resp, err := h.users.SignInWithMagicLink(c.Request.Context(), &req)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	session(c, http.StatusOK, resp)
+}
@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+
+	"user-management-api/internal/middleware"
+	"user-management-api/internal/model"
+	"user-management-api/internal/service"
+)
+
+type MFAHandler struct {
+	svc      *service.MFAService
+	validate *validator.Validate
+}
+
+func NewMFAHandler(svc *service.MFAService) *MFAHandler {
+	return &MFAHandler{svc: svc, validate: validator.New()}
+}
+
+// Status reports the caller's MFA enrollment.
+func (h *MFAHandler) Status(c *gin.Context) {
+	st, err := h.svc.Status(c.Request.Context(), middleware.CurrentPrincipal(c).UserID)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	ok(c, st)
+}
+
+// EnrollTOTP returns a new TOTP secret and otpauth:// URI for the caller.
+func (h *MFAHandler) EnrollTOTP(c *gin.Context) {
+	enrollment, err := h.svc.EnrollTOTP(c.Request.Context(), middleware.CurrentPrincipal(c).UserID)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	created(c, enrollment)
+}
+
+// ConfirmTOTP enables the pending factor and returns the recovery codes.
+func (h *MFAHandler) ConfirmTOTP(c *gin.Context) {
+	var req model.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation_error", "message": firstValidationError(err)})
+		return
+	}
+
+	codes, err := h.svc.ConfirmTOTP(c.Request.Context(), middleware.CurrentPrincipal(c).UserID, &req)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	ok(c, codes)
+}
+
+func (h *MFAHandler) DisableTOTP(c *gin.Context) {
+	var req model.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation_error", "message": firstValidationError(err)})
+		return
+	}
+
+	if err := h.svc.DisableTOTP(c.Request.Context(), middleware.CurrentPrincipal(c).UserID, &req); err != nil {
+		fail(c, err)
+		return
+	}
+	noContent(c)
+}
+
+func (h *MFAHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req model.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation_error", "message": firstValidationError(err)})
+		return
+	}
+
+	codes, err := h.svc.RegenerateRecoveryCodes(c.Request.Context(), middleware.CurrentPrincipal(c).UserID, &req)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	ok(c, codes)
+}
+
+// Verify exchanges the mfa_token from sign-in plus a code for a token pair.
+func (h *MFAHandler) Verify(c *gin.Context) {
+	var req model.MFAVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation_error", "message": firstValidationError(err)})
+		return
+	}
+
+	resp, err := h.svc.Verify(c.Request.Context(), &req)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	session(c, http.StatusOK, resp)
+}
@@ -0,0 +1,221 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+
+	"user-management-api/internal/middleware"
+	"user-management-api/internal/model"
+	"user-management-api/internal/service"
+)
+
+// OAuthHandler serves the token endpoint and the /admin/oauth/clients
+// routes. The admin routes are guarded by middleware.RequirePermission in main.
+type OAuthHandler struct {
+	svc      *service.OAuthService
+	oidc     *service.OIDCService
+	validate *validator.Validate
+}
+
+func NewOAuthHandler(svc *service.OAuthService, oidc *service.OIDCService) *OAuthHandler {
+	return &OAuthHandler{svc: svc, oidc: oidc, validate: validator.New()}
+}
+
+// Token implements the client_credentials grant of RFC 6749 §4.4 and the
+// authorization_code grant of §4.1, with PKCE. The request is form-encoded,
+// the client authenticates with HTTP Basic or with client_id and
+// client_secret in the body, and both success and error bodies follow §5
+// rather than this API's usual envelope.
+func (h *OAuthHandler) Token(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	if c.ContentType() != "application/x-www-form-urlencoded" {
+		oauthError(c, http.StatusBadRequest, "invalid_request", "request body must be application/x-www-form-urlencoded")
+		return
+	}
+
+	clientID, secret, basic := c.Request.BasicAuth()
+	if basic {
+		// §2.3.1: Basic credentials are form-encoded before base64.
+		var err1, err2 error
+		clientID, err1 = url.QueryUnescape(clientID)
+		secret, err2 = url.QueryUnescape(secret)
+		if err1 != nil || err2 != nil {
+			oauthError(c, http.StatusBadRequest, "invalid_request", "malformed client credentials")
+			return
+		}
+		if c.PostForm("client_secret") != "" {
+			oauthError(c, http.StatusBadRequest, "invalid_request", "use only one client authentication method")
+			return
+		}
+	} else {
+		clientID, secret = c.PostForm("client_id"), c.PostForm("client_secret")
+	}
+
+	grant := c.PostForm("grant_type")
+	switch grant {
+	case "":
+		oauthError(c, http.StatusBadRequest, "invalid_request", "grant_type is required")
+		return
+	case model.GrantClientCredentials:
+	case model.GrantAuthorizationCode:
+		if c.PostForm("code") == "" || c.PostForm("redirect_uri") == "" || c.PostForm("code_verifier") == "" {
+			oauthError(c, http.StatusBadRequest, "invalid_request", "code, redirect_uri and code_verifier are required")
+			return
+		}
+	default:
+		oauthError(c, http.StatusBadRequest, "unsupported_grant_type", "only client_credentials and authorization_code are supported")
+		return
+	}
+	if clientID == "" || secret == "" {
+		h.invalidClient(c, basic)
+		return
+	}
+
+	var (
+		resp *model.OAuthTokenResponse
+		err  error
+	)
+	if grant == model.GrantAuthorizationCode {
+		resp, err = h.oidc.Exchange(c.Request.Context(), clientID, secret,
+			c.PostForm("code"), c.PostForm("redirect_uri"), c.PostForm("code_verifier"))
+	} else {
+		resp, err = h.svc.ClientCredentials(c.Request.Context(), clientID, secret, c.PostForm("scope"))
+	}
+	switch {
+	case errors.Is(err, service.ErrInvalidClient):
+		h.invalidClient(c, basic)
+	case errors.Is(err, service.ErrInvalidGrant):
+		oauthError(c, http.StatusBadRequest, "invalid_grant", "authorization code is invalid, expired or already used")
+	case errors.Is(err, service.ErrInvalidScope):
+		oauthError(c, http.StatusBadRequest, "invalid_scope", "requested scope is not allowed for this client")
+	case err != nil:
+		oauthError(c, http.StatusInternalServerError, "server_error", "")
+	default:
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// invalidClient answers 401, challenging for Basic when the client used it
+// (§5.2).
+func (h *OAuthHandler) invalidClient(c *gin.Context, basic bool) {
+	if basic {
+		c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+	}
+	oauthError(c, http.StatusUnauthorized, "invalid_client", "client authentication failed")
+}
+
+func oauthError(c *gin.Context, status int, code, description string) {
+	body := gin.H{"error": code}
+	if description != "" {
+		body["error_description"] = description
+	}
+	c.JSON(status, body)
+}
+
+// --- client administration ---
+
+func (h *OAuthHandler) ListClients(c *gin.Context) {
+	clients, err := h.svc.ListClients(c.Request.Context())
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	ok(c, clients)
+}
+
+// CreateClient registers a client and returns its secret, once.
+func (h *OAuthHandler) CreateClient(c *gin.Context) {
+	var req model.CreateOAuthClientRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation_error", "message": firstValidationError(err)})
+		return
+	}
+
+	client, err := h.svc.CreateClient(c.Request.Context(), middleware.CurrentPrincipal(c), &req)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	created(c, client)
+}
+
+func (h *OAuthHandler) GetClient(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_id", "message": "client ID must be a valid UUID"})
+		return
+	}
+
+	client, err := h.svc.GetClient(c.Request.Context(), id)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	ok(c, client)
+}
+
+func (h *OAuthHandler) UpdateClient(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_id", "message": "client ID must be a valid UUID"})
+		return
+	}
+
+	var req model.UpdateOAuthClientRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation_error", "message": firstValidationError(err)})
+		return
+	}
+
+	client, err := h.svc.UpdateClient(c.Request.Context(), middleware.CurrentPrincipal(c), id, &req)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	ok(c, client)
+}
+
+// RotateClientSecret returns a new secret; the old one stops working.
+func (h *OAuthHandler) RotateClientSecret(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_id", "message": "client ID must be a valid UUID"})
+		return
+	}
+
+	client, err := h.svc.RotateClientSecret(c.Request.Context(), id)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	ok(c, client)
+}
+
+func (h *OAuthHandler) DeleteClient(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_id", "message": "client ID must be a valid UUID"})
+		return
+	}
+
+	if err := h.svc.DeleteClient(c.Request.Context(), id); err != nil {
+		fail(c, err)
+		return
+	}
+	noContent(c)
+}
@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+
+	"user-management-api/internal/middleware"
+	"user-management-api/internal/model"
+	"user-management-api/internal/service"
+)
+
+// OIDCHandler serves the OpenID Connect discovery, authorization and
+// userinfo endpoints. The token endpoint is OAuthHandler.Token.
+type OIDCHandler struct {
+	svc      *service.OIDCService
+	validate *validator.Validate
+}
+
+func NewOIDCHandler(svc *service.OIDCService) *OIDCHandler {
+	return &OIDCHandler{svc: svc, validate: validator.New()}
+}
+
+// Discovery publishes the provider metadata. Like the JWKS it is not wrapped
+// in the usual envelope, so client libraries can read it directly.
+func (h *OIDCHandler) Discovery(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.svc.Discovery())
+}
+
+// BeginAuthorize is where clients send the browser. A valid request is passed
+// on to the login page; errors go back to the client's redirect URI, unless
+// the client or redirect URI is the problem.
+func (h *OIDCHandler) BeginAuthorize(c *gin.Context) {
+	var req model.AuthorizeRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+
+	_, _, err := h.svc.CheckAuthorize(c.Request.Context(), &req)
+	var authErr *service.AuthorizeError
+	switch {
+	case errors.As(err, &authErr):
+		c.Redirect(http.StatusFound, h.svc.ErrorRedirect(&req, authErr))
+	case err != nil:
+		fail(c, err)
+	default:
+		c.Redirect(http.StatusFound, h.svc.LoginRedirect(c.Request.URL.Query()))
+	}
+}
+
+// Authorize is posted by the login page once the user is signed in. The
+// response tells it where to send the browser, or that consent is needed.
+func (h *OIDCHandler) Authorize(c *gin.Context) {
+	var req model.AuthorizeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation_error", "message": firstValidationError(err)})
+		return
+	}
+
+	resp, err := h.svc.Authorize(c.Request.Context(), middleware.CurrentPrincipal(c), &req)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	ok(c, resp)
+}
+
+// UserInfo returns claims about the token's user (OpenID Connect Core §5.3),
+// outside the usual envelope.
+func (h *OIDCHandler) UserInfo(c *gin.Context) {
+	claims, err := h.svc.UserInfo(c.Request.Context(), middleware.CurrentPrincipal(c))
+	if errors.Is(err, service.ErrInsufficientScope) {
+		c.Header("WWW-Authenticate", `Bearer error="insufficient_scope", scope="openid"`)
+	}
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, claims)
+}
@@ -0,0 +1,197 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+
+	"user-management-api/internal/model"
+	"user-management-api/internal/service"
+)
+
+// OrganizationHandler serves /admin/organizations. The routes are guarded
+// by middleware.RequirePermission in main.
+type OrganizationHandler struct {
+	svc      *service.OrganizationService
+	users    *service.UserService
+	validate *validator.Validate
+}
+
+func NewOrganizationHandler(svc *service.OrganizationService, users *service.UserService) *OrganizationHandler {
+	return &OrganizationHandler{svc: svc, users: users, validate: validator.New()}
+}
+
+func (h *OrganizationHandler) Create(c *gin.Context) {
+	var req model.CreateOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation_error", "message": firstValidationError(err)})
+		return
+	}
+
+	o, err := h.svc.Create(c.Request.Context(), &req)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	created(c, o)
+}
+
+func (h *OrganizationHandler) List(c *gin.Context) {
+	var q model.ListOrganizationsQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_query", "message": err.Error()})
+		return
+	}
+
+	orgs, err := h.svc.List(c.Request.Context(), &q)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	ok(c, orgs)
+}
+
+func (h *OrganizationHandler) Get(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_id", "message": "organization ID must be a valid UUID"})
+		return
+	}
+
+	o, err := h.svc.Get(c.Request.Context(), id)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	ok(c, o)
+}
+
+// CreateUser adds an account to the organization. Self sign-up always lands
+// in the default organization, so this is how anyone joins another.
+func (h *OrganizationHandler) CreateUser(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_id", "message": "organization ID must be a valid UUID"})
+		return
+	}
+	var req model.RegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation_error", "message": firstValidationError(err)})
+		return
+	}
+
+	if _, err := h.svc.Get(c.Request.Context(), id); err != nil {
+		fail(c, err)
+		return
+	}
+	u, err := h.users.CreateInOrganization(c.Request.Context(), id, &req)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	created(c, u)
+}
+
+func (h *OrganizationHandler) ListMembers(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_id", "message": "organization ID must be a valid UUID"})
+		return
+	}
+	var q model.ListMembersQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_query", "message": err.Error()})
+		return
+	}
+
+	members, err := h.svc.ListMembers(c.Request.Context(), id, &q)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	ok(c, members)
+}
+
+// AddMember lets a user of another organization act in this one with a
+// role of its own.
+func (h *OrganizationHandler) AddMember(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_id", "message": "organization ID must be a valid UUID"})
+		return
+	}
+	var req model.AddMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation_error", "message": firstValidationError(err)})
+		return
+	}
+
+	m, err := h.svc.AddMember(c.Request.Context(), id, &req)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	created(c, m)
+}
+
+func (h *OrganizationHandler) SetMemberRole(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_id", "message": "organization ID must be a valid UUID"})
+		return
+	}
+	userID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_id", "message": "user ID must be a valid UUID"})
+		return
+	}
+	var req model.SetMemberRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation_error", "message": firstValidationError(err)})
+		return
+	}
+
+	m, err := h.svc.SetMemberRole(c.Request.Context(), id, userID, &req)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	ok(c, m)
+}
+
+func (h *OrganizationHandler) RemoveMember(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_id", "message": "organization ID must be a valid UUID"})
+		return
+	}
+	userID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_id", "message": "user ID must be a valid UUID"})
+		return
+	}
+
+	if err := h.svc.RemoveMember(c.Request.Context(), id, userID); err != nil {
+		fail(c, err)
+		return
+	}
+	noContent(c)
+}
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+
+	"user-management-api/internal/middleware"
+	"user-management-api/internal/model"
+	"user-management-api/internal/service"
+)
+
+type PasswordHandler struct {
+	svc      *service.PasswordService
+	validate *validator.Validate
+}
+
+func NewPasswordHandler(svc *service.PasswordService) *PasswordHandler {
+	return &PasswordHandler{svc: svc, validate: validator.New()}
+}
+
+// ForgotPassword always answers 202 once the request is well-formed. The
+// email is looked up and mailed after the response, so neither the answer
+// nor its timing says whether an account exists.
+func (h *PasswordHandler) ForgotPassword(c *gin.Context) {
+	var req model.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation_error", "message": firstValidationError(err)})
+		return
+	}
+
+	h.svc.ForgotPassword(c.Request.Context(), &req)
+	accepted(c, gin.H{"message": "if the account exists, a reset link has been sent"})
+}
+
+func (h *PasswordHandler) ResetPassword(c *gin.Context) {
+	var req model.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation_error", "message": firstValidationError(err)})
+		return
+	}
+
+	if err := h.svc.ResetPassword(c.Request.Context(), &req); err != nil {
+		fail(c, err)
+		return
+	}
+	noContent(c)
+}
+
+// ChangePassword lets a signed-in user change their own password. It returns
+// 204, or 200 with a fresh token pair when revoke_other_sessions is set
+// (the caller's current token is revoked along with the rest).
+func (h *PasswordHandler) ChangePassword(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_id", "message": "user ID must be a valid UUID"})
+		return
+	}
+
+	p := middleware.CurrentPrincipal(c)
+	if p.UserID != id {
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden", "message": "you can only change your own password"})
+		return
+	}
+
+	var req model.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation_error", "message": firstValidationError(err)})
+		return
+	}
+
+	resp, err := h.svc.ChangePassword(c.Request.Context(), p, &req)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	if resp == nil {
+		noContent(c)
+		return
+	}
+	session(c, http.StatusOK, resp)
+}
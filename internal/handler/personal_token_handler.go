@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+
+	"user-management-api/internal/middleware"
+	"user-management-api/internal/model"
+	"user-management-api/internal/service"
+)
+
+// PersonalTokenHandler serves /users/:id/tokens.
+type PersonalTokenHandler struct {
+	svc      *service.PersonalTokenService
+	validate *validator.Validate
+}
+
+func NewPersonalTokenHandler(svc *service.PersonalTokenService) *PersonalTokenHandler {
+	return &PersonalTokenHandler{svc: svc, validate: validator.New()}
+}
+
+// CreateToken mints a token for the caller; nobody can mint one for someone else.
+func (h *PersonalTokenHandler) CreateToken(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_id", "message": "user ID must be a valid UUID"})
+		return
+	}
+
+	p := middleware.CurrentPrincipal(c)
+	if p.UserID != id {
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden", "message": "you can only create tokens for yourself"})
+		return
+	}
+
+	var req model.CreatePersonalTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation_error", "message": firstValidationError(err)})
+		return
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation_error", "message": "expires_at must be in the future"})
+		return
+	}
+
+	t, err := h.svc.Create(c.Request.Context(), p, &req)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	created(c, t)
+}
+
+// ListTokens shows a user's tokens, never the secrets. Self or users:write.
+func (h *PersonalTokenHandler) ListTokens(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_id", "message": "user ID must be a valid UUID"})
+		return
+	}
+
+	if c.MustGet(middleware.UserIDKey).(uuid.UUID) != id && !middleware.HasPermission(c, model.PermUsersWrite) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden", "message": "you can only list your own tokens"})
+		return
+	}
+
+	tokens, err := h.svc.List(c.Request.Context(), id)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	ok(c, tokens)
+}
+
+// RevokeToken deletes a token. Self from a signed-in session, or
+// users:write so admins can kill a leaked token. A token revokes itself
+// through /auth/signout.
+func (h *PersonalTokenHandler) RevokeToken(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_id", "message": "user ID must be a valid UUID"})
+		return
+	}
+	tokenID, err := uuid.Parse(c.Param("tokenId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_id", "message": "token ID must be a valid UUID"})
+		return
+	}
+
+	if !middleware.IsSelf(c, id) && !middleware.HasPermission(c, model.PermUsersWrite) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden", "message": "you can only revoke your own tokens"})
+		return
+	}
+
+	if err := h.svc.Revoke(c.Request.Context(), id, tokenID); err != nil {
+		fail(c, err)
+		return
+	}
+	noContent(c)
+}
@@ -2,11 +2,15 @@ package handler
 
 import (
 	"errors"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 
+	"user-management-api/internal/password"
 	"user-management-api/internal/repository"
 	"user-management-api/internal/service"
 )
@@ -19,16 +23,113 @@ func created(c *gin.Context, data any) {
 	c.JSON(http.StatusCreated, gin.H{"data": data})
 }
 
+func accepted(c *gin.Context, data any) {
+	c.JSON(http.StatusAccepted, gin.H{"data": data})
+}
+
+func noContent(c *gin.Context) {
+	c.Status(http.StatusNoContent)
+}
+
 // fail maps a domain error to the appropriate HTTP status and error code.
 // All error-to-HTTP mapping lives here — handlers stay free of switch/if chains.
 func fail(c *gin.Context, err error) {
+	var retry *service.RetryAfterError
+	if errors.As(err, &retry) {
+		secs := int(math.Ceil(time.Until(retry.Until).Seconds()))
+		c.Header("Retry-After", strconv.Itoa(max(secs, 1)))
+	}
+	var weak *password.PolicyError
+
 	switch {
 	case errors.Is(err, repository.ErrNotFound):
 		c.JSON(http.StatusNotFound, gin.H{"error": "not_found", "message": "user not found"})
+	case errors.Is(err, repository.ErrCredentialNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "not_found", "message": "credential not found"})
+	case errors.Is(err, repository.ErrPersonalTokenNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "not_found", "message": "token not found"})
+	case errors.Is(err, repository.ErrSessionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "not_found", "message": "session not found"})
+	case errors.Is(err, repository.ErrClientNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "not_found", "message": "client not found"})
+	case errors.Is(err, repository.ErrOrganizationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "not_found", "message": "organization not found"})
+	case errors.Is(err, repository.ErrSlugTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": "conflict", "message": "organization slug already in use"})
+	case errors.Is(err, service.ErrInvalidSlug):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation_error", "message": err.Error()})
+	case errors.Is(err, repository.ErrMembershipNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "not_found", "message": "membership not found"})
+	case errors.Is(err, repository.ErrAlreadyMember), errors.Is(err, service.ErrOwnOrganization):
+		c.JSON(http.StatusConflict, gin.H{"error": "conflict", "message": err.Error()})
+	case errors.Is(err, repository.ErrIdentityNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "not_found", "message": "identity not found"})
+	case errors.Is(err, repository.ErrIdentityExists):
+		c.JSON(http.StatusConflict, gin.H{"error": "conflict", "message": "this identity is already linked to an account"})
+	case errors.Is(err, service.ErrUnknownProvider):
+		c.JSON(http.StatusNotFound, gin.H{"error": "not_found", "message": "identity provider not found"})
+	case errors.Is(err, service.ErrInvalidLoginState):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_state", "message": "sign-in state is invalid or expired; start again"})
+	case errors.Is(err, service.ErrInvalidLoginTicket):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_token", "message": "sign-in ticket is invalid, expired or already used"})
+	case errors.Is(err, service.ErrIdentityNotLinked):
+		c.JSON(http.StatusForbidden, gin.H{"error": "identity_not_linked", "message": "no account is linked to this identity; sign in another way and link it"})
+	case errors.Is(err, service.ErrImpersonationNotAllowed):
+		c.JSON(http.StatusForbidden, gin.H{"error": "impersonation_not_allowed", "message": "you can't impersonate yourself or a user with permissions you don't hold"})
+	case errors.Is(err, service.ErrLastSignInMethod):
+		c.JSON(http.StatusConflict, gin.H{"error": "last_sign_in_method", "message": "set a password or link another identity before removing this one"})
+	case errors.Is(err, repository.ErrCredentialExists):
+		c.JSON(http.StatusConflict, gin.H{"error": "conflict", "message": "passkey already registered"})
+	case errors.Is(err, repository.ErrUnknownRole):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown_role", "message": "one or more roles do not exist"})
 	case errors.Is(err, repository.ErrEmailTaken):
 		c.JSON(http.StatusConflict, gin.H{"error": "conflict", "message": "email already in use"})
+	case errors.As(err, &weak):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation_error", "message": weak.Error(), "violations": weak.Violations})
+	case errors.Is(err, service.ErrInvalidScope):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_scope", "message": err.Error()})
+	case errors.Is(err, service.ErrInvalidRedirectURI):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_redirect_uri", "message": err.Error()})
+	case errors.Is(err, service.ErrInvalidClient):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_client", "message": "unknown client_id"})
+	case errors.Is(err, service.ErrInsufficientScope):
+		c.JSON(http.StatusForbidden, gin.H{"error": "insufficient_scope", "message": "token lacks the openid scope"})
 	case errors.Is(err, service.ErrInvalidCredentials):
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_credentials", "message": "email or password is incorrect"})
+	case errors.Is(err, service.ErrDirectoryUnavailable):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "directory_unavailable", "message": "the sign-in directory can't be reached; try again later"})
+	case errors.Is(err, service.ErrAccountLocked):
+		c.JSON(http.StatusLocked, gin.H{"error": "account_locked", "message": "too many failed sign-ins; try again later or reset your password"})
+	case errors.Is(err, service.ErrTooManyAttempts):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "too_many_attempts", "message": "too many failed sign-ins from this address; try again later"})
+	case errors.Is(err, service.ErrPurgeTooEarly):
+		c.JSON(http.StatusConflict, gin.H{"error": "purge_too_early", "message": "user is still within the restore grace period"})
+	case errors.Is(err, service.ErrIncorrectPassword):
+		c.JSON(http.StatusForbidden, gin.H{"error": "incorrect_password", "message": "current password is incorrect"})
+	case errors.Is(err, service.ErrInvalidResetToken):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_token", "message": "reset token is invalid, expired or already used"})
+	case errors.Is(err, service.ErrEmailNotVerified):
+		c.JSON(http.StatusForbidden, gin.H{"error": "email_not_verified", "message": "confirm your email address before signing in"})
+	case errors.Is(err, service.ErrInvalidVerificationToken):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_token", "message": "verification token is invalid, expired or already used"})
+	case errors.Is(err, service.ErrInvalidMagicLink):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_token", "message": "sign-in link is invalid, expired or already used"})
+	case errors.Is(err, service.ErrMFAAlreadyEnabled):
+		c.JSON(http.StatusConflict, gin.H{"error": "mfa_already_enabled", "message": "an authenticator is already enabled"})
+	case errors.Is(err, service.ErrMFANotEnabled):
+		c.JSON(http.StatusConflict, gin.H{"error": "mfa_not_enabled", "message": "no authenticator is enrolled"})
+	case errors.Is(err, service.ErrInvalidMFACode):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_mfa_code", "message": "code is invalid or has already been used"})
+	case errors.Is(err, service.ErrInvalidMFAToken):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_mfa_token", "message": "mfa token is invalid, expired or already used; sign in again"})
+	case errors.Is(err, service.ErrPasskeyRegistration):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_registration", "message": err.Error()})
+	case errors.Is(err, service.ErrInvalidPasskey):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_passkey", "message": "passkey sign-in could not be verified"})
+	case errors.Is(err, service.ErrPasskeyCloned):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "passkey_cloned", "message": "passkey may have been copied; use another sign-in method"})
+	case errors.Is(err, service.ErrInvalidRefreshToken):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_refresh_token", "message": "refresh token is invalid, expired or revoked"})
 	default:
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal_error"})
 	}
@@ -46,6 +147,8 @@ func firstValidationError(err error) string {
 			return f.Field() + " must be a valid email address"
 		case "min":
 			return f.Field() + " must be at least " + f.Param() + " characters"
+		case "nefield":
+			return f.Field() + " must differ from " + f.Param()
 		}
 		return f.Field() + " is invalid"
 	}
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"user-management-api/internal/middleware"
+	"user-management-api/internal/model"
+	"user-management-api/internal/service"
+)
+
+// SessionHandler serves /users/:id/sessions.
+type SessionHandler struct {
+	tokens *service.TokenService
+}
+
+func NewSessionHandler(tokens *service.TokenService) *SessionHandler {
+	return &SessionHandler{tokens: tokens}
+}
+
+// ListSessions shows the devices a user is signed in on. Self or users:write.
+func (h *SessionHandler) ListSessions(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_id", "message": "user ID must be a valid UUID"})
+		return
+	}
+
+	p := middleware.CurrentPrincipal(c)
+	if p.UserID != id && !middleware.HasPermission(c, model.PermUsersWrite) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden", "message": "you can only list your own sessions"})
+		return
+	}
+
+	var current uuid.UUID
+	if p.UserID == id {
+		current = p.SessionID
+	}
+	sessions, err := h.tokens.ListSessions(c.Request.Context(), id, current)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	ok(c, sessions)
+}
+
+// RevokeSession signs a device out. Self from a signed-in session, or
+// users:write so admins can end a session on a lost device.
+func (h *SessionHandler) RevokeSession(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_id", "message": "user ID must be a valid UUID"})
+		return
+	}
+	sessionID, err := uuid.Parse(c.Param("sid"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_id", "message": "session ID must be a valid UUID"})
+		return
+	}
+
+	if !middleware.IsSelf(c, id) && !middleware.HasPermission(c, model.PermUsersWrite) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden", "message": "you can only revoke your own sessions"})
+		return
+	}
+
+	if err := h.tokens.RevokeSession(c.Request.Context(), id, sessionID); err != nil {
+		fail(c, err)
+		return
+	}
+	noContent(c)
+}
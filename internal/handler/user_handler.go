@@ -36,6 +36,8 @@ func (h *UserHandler) GetUser(c *gin.Context) {
 	ok(c, u)
 }
 
+// UpdateUser changes a user's name or email. Users may update themselves
+// from a signed-in session; anything else requires users:write.
 func (h *UserHandler) UpdateUser(c *gin.Context) {
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
@@ -43,7 +45,7 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 		return
 	}
 
-	if c.MustGet(middleware.UserIDKey).(uuid.UUID) != id {
+	if !middleware.IsSelf(c, id) && !middleware.HasPermission(c, model.PermUsersWrite) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden", "message": "you can only update your own profile"})
 		return
 	}
@@ -70,6 +72,27 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 	ok(c, u)
 }
 
+// DeleteUser soft-deletes a user. Users may delete themselves from a
+// signed-in session; anything else requires users:delete.
+func (h *UserHandler) DeleteUser(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_id", "message": "user ID must be a valid UUID"})
+		return
+	}
+
+	if !middleware.IsSelf(c, id) && !middleware.HasPermission(c, model.PermUsersDelete) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden", "message": "you can only delete your own account"})
+		return
+	}
+
+	if err := h.svc.DeleteUser(c.Request.Context(), id); err != nil {
+		fail(c, err)
+		return
+	}
+	noContent(c)
+}
+
 func (h *UserHandler) ListUsers(c *gin.Context) {
 	var q model.ListUsersQuery
 	if err := c.ShouldBindQuery(&q); err != nil {
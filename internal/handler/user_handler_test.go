@@ -0,0 +1,75 @@
+package handler_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"user-management-api/internal/handler"
+	"user-management-api/internal/middleware"
+	"user-management-api/internal/model"
+)
+
+// newRouter serves the self-or-permission routes as p. Requests refused
+// before reaching a service never touch the nil services.
+func newRouter(p *model.Principal) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set(middleware.UserIDKey, p.UserID)
+		c.Set(middleware.PrincipalKey, p)
+	})
+	users := handler.NewUserHandler(nil)
+	r.PUT("/users/:id", users.UpdateUser)
+	r.DELETE("/users/:id", users.DeleteUser)
+	r.DELETE("/users/:id/sessions/:sid", handler.NewSessionHandler(nil).RevokeSession)
+	r.DELETE("/users/:id/tokens/:tokenId", handler.NewPersonalTokenHandler(nil).RevokeToken)
+	return r
+}
+
+func serve(r *gin.Engine, method, path, body string) int {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestSelfRoutes_ScopedTokensCannotActAsOwner(t *testing.T) {
+	id := uuid.New()
+	readOnly := []string{model.PermUsersRead}
+	for name, p := range map[string]*model.Principal{
+		"personal access token": {UserID: id, PersonalTokenID: uuid.New(), Permissions: readOnly},
+		"delegated token":       {UserID: id, AuthorizedParty: "third-party", Scopes: []string{model.ScopeOpenID}},
+		"impersonation":         {UserID: id, ActorID: uuid.New(), Permissions: readOnly},
+	} {
+		r := newRouter(p)
+		for _, tc := range []struct{ method, path, body string }{
+			{http.MethodPut, "/users/" + id.String(), `{"email":"mallory@example.com"}`},
+			{http.MethodDelete, "/users/" + id.String(), ""},
+			{http.MethodDelete, "/users/" + id.String() + "/sessions/" + uuid.NewString(), ""},
+			{http.MethodDelete, "/users/" + id.String() + "/tokens/" + uuid.NewString(), ""},
+		} {
+			if code := serve(r, tc.method, tc.path, tc.body); code != http.StatusForbidden {
+				t.Errorf("%s: %s %s: expected 403, got %d", name, tc.method, tc.path, code)
+			}
+		}
+	}
+}
+
+func TestSelfRoutes_SessionActsAsOwner(t *testing.T) {
+	id := uuid.New()
+	r := newRouter(&model.Principal{UserID: id, SessionID: uuid.New(), Permissions: []string{model.PermUsersRead}})
+
+	// Past the ownership check, an empty update is rejected on its merits.
+	if code := serve(r, http.MethodPut, "/users/"+id.String(), `{}`); code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", code)
+	}
+	if code := serve(r, http.MethodPut, "/users/"+uuid.NewString(), `{}`); code != http.StatusForbidden {
+		t.Errorf("someone else: expected 403, got %d", code)
+	}
+}
@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+
+	"user-management-api/internal/model"
+	"user-management-api/internal/service"
+)
+
+type VerificationHandler struct {
+	svc      *service.EmailVerificationService
+	validate *validator.Validate
+}
+
+func NewVerificationHandler(svc *service.EmailVerificationService) *VerificationHandler {
+	return &VerificationHandler{svc: svc, validate: validator.New()}
+}
+
+// VerifyEmail confirms the address the token was sent to and returns the
+// updated user.
+func (h *VerificationHandler) VerifyEmail(c *gin.Context) {
+	var req model.VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation_error", "message": firstValidationError(err)})
+		return
+	}
+
+	u, err := h.svc.Verify(c.Request.Context(), &req)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	ok(c, u)
+}
+
+// ResendVerification always answers 202 once the request is well-formed,
+// and looks the address up only after responding, as ForgotPassword does.
+func (h *VerificationHandler) ResendVerification(c *gin.Context) {
+	var req model.ResendVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation_error", "message": firstValidationError(err)})
+		return
+	}
+
+	h.svc.Resend(c.Request.Context(), &req)
+	accepted(c, gin.H{"message": "if the account needs verification, a new link has been sent"})
+}
@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+
+	"user-management-api/internal/middleware"
+	"user-management-api/internal/model"
+	"user-management-api/internal/service"
+)
+
+type WebAuthnHandler struct {
+	svc      *service.WebAuthnService
+	users    *service.UserService
+	validate *validator.Validate
+}
+
+func NewWebAuthnHandler(svc *service.WebAuthnService, users *service.UserService) *WebAuthnHandler {
+	return &WebAuthnHandler{svc: svc, users: users, validate: validator.New()}
+}
+
+// BeginRegistration returns PublicKeyCredentialCreationOptions for the caller.
+func (h *WebAuthnHandler) BeginRegistration(c *gin.Context) {
+	opts, err := h.svc.BeginRegistration(c.Request.Context(), middleware.CurrentPrincipal(c).UserID)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	ok(c, opts)
+}
+
+func (h *WebAuthnHandler) FinishRegistration(c *gin.Context) {
+	var req model.WebAuthnRegistrationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation_error", "message": firstValidationError(err)})
+		return
+	}
+
+	cred, err := h.svc.FinishRegistration(c.Request.Context(), middleware.CurrentPrincipal(c).UserID, &req)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	created(c, cred)
+}
+
+// BeginLogin returns PublicKeyCredentialRequestOptions for a passkey sign-in.
+func (h *WebAuthnHandler) BeginLogin(c *gin.Context) {
+	opts, err := h.svc.BeginLogin(c.Request.Context())
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	ok(c, opts)
+}
+
+// FinishLogin verifies the assertion and answers like /auth/signin.
+func (h *WebAuthnHandler) FinishLogin(c *gin.Context) {
+	var req model.WebAuthnLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "message": err.Error()})
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation_error", "message": firstValidationError(err)})
+		return
+	}
+
+	resp, err := h.users.SignInWithPasskey(c.Request.Context(), &req)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	session(c, http.StatusOK, resp)
+}
+
+func (h *WebAuthnHandler) ListCredentials(c *gin.Context) {
+	creds, err := h.svc.ListCredentials(c.Request.Context(), middleware.CurrentPrincipal(c).UserID)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	ok(c, creds)
+}
+
+func (h *WebAuthnHandler) DeleteCredential(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_id", "message": "credential ID must be a valid UUID"})
+		return
+	}
+
+	if err := h.svc.DeleteCredential(c.Request.Context(), middleware.CurrentPrincipal(c).UserID, id); err != nil {
+		fail(c, err)
+		return
+	}
+	noContent(c)
+}
@@ -0,0 +1,128 @@
+package idp_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+
+	"user-management-api/internal/idp"
+	"user-management-api/internal/idp/idptest"
+)
+
+const (
+	callback = "https://api.test/api/v1/auth/idp/corp/callback"
+	verifier = "dBjftJeZ4CVP-mJ92K9cQmzzY1eBXK3ABj_xoIL0Bd2fmQ"
+)
+
+var alice = idptest.Identity{Subject: "u-123", Email: "alice@corp.test", EmailVerified: true, Name: "Alice"}
+
+func newProvider(t *testing.T) (*idp.Provider, *idptest.Issuer) {
+	t.Helper()
+	iss := idptest.NewIssuer("our-client", "our secret")
+	t.Cleanup(iss.Close)
+	p, err := idp.New(idp.Config{
+		ID:           "corp",
+		Issuer:       iss.URL,
+		ClientID:     iss.ClientID,
+		ClientSecret: iss.ClientSecret,
+		RedirectURL:  callback,
+	}, nil)
+	if err != nil {
+		t.Fatalf("new provider: %v", err)
+	}
+	return p, iss
+}
+
+// signIn runs the browser leg and returns the code from the callback.
+func signIn(t *testing.T, p *idp.Provider, iss *idptest.Issuer) string {
+	t.Helper()
+	authURL, err := p.AuthCodeURL(context.Background(), "st", "n-1", verifier)
+	if err != nil {
+		t.Fatalf("auth code url: %v", err)
+	}
+	back, err := iss.Authorize(authURL, alice)
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	u, _ := url.Parse(back)
+	if u.Query().Get("state") != "st" {
+		t.Fatalf("expected state to round-trip, got %s", back)
+	}
+	return u.Query().Get("code")
+}
+
+func TestExchange(t *testing.T) {
+	p, iss := newProvider(t)
+	code := signIn(t, p, iss)
+
+	id, err := p.Exchange(context.Background(), code, verifier, "n-1")
+	if err != nil {
+		t.Fatalf("exchange: %v", err)
+	}
+	if id.Subject != alice.Subject || id.Email != alice.Email || !id.EmailVerified || id.Name != alice.Name {
+		t.Errorf("unexpected identity %+v", id)
+	}
+}
+
+func TestExchange_ChecksNonce(t *testing.T) {
+	p, iss := newProvider(t)
+	code := signIn(t, p, iss)
+
+	if _, err := p.Exchange(context.Background(), code, verifier, "other"); !errors.Is(err, idp.ErrInvalidIDToken) {
+		t.Errorf("expected ErrInvalidIDToken, got %v", err)
+	}
+}
+
+func TestExchange_Rejects(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("wrong verifier", func(t *testing.T) {
+		p, iss := newProvider(t)
+		code := signIn(t, p, iss)
+		if _, err := p.Exchange(ctx, code, verifier[1:]+"x", "n-1"); !errors.Is(err, idp.ErrExchange) {
+			t.Errorf("expected ErrExchange, got %v", err)
+		}
+	})
+	t.Run("reused code", func(t *testing.T) {
+		p, iss := newProvider(t)
+		code := signIn(t, p, iss)
+		if _, err := p.Exchange(ctx, code, verifier, "n-1"); err != nil {
+			t.Fatalf("exchange: %v", err)
+		}
+		if _, err := p.Exchange(ctx, code, verifier, "n-1"); !errors.Is(err, idp.ErrExchange) {
+			t.Errorf("expected ErrExchange, got %v", err)
+		}
+	})
+	t.Run("wrong secret", func(t *testing.T) {
+		p, iss := newProvider(t)
+		code := signIn(t, p, iss)
+		iss.ClientSecret = "changed"
+		if _, err := p.Exchange(ctx, code, verifier, "n-1"); !errors.Is(err, idp.ErrExchange) {
+			t.Errorf("expected ErrExchange, got %v", err)
+		}
+	})
+}
+
+func TestDiscovery_RequiresMatchingIssuer(t *testing.T) {
+	iss := idptest.NewIssuer("our-client", "secret")
+	defer iss.Close()
+	p, err := idp.New(idp.Config{ID: "corp", Issuer: iss.URL + "/", ClientID: "our-client", RedirectURL: callback}, nil)
+	if err != nil {
+		t.Fatalf("new provider: %v", err)
+	}
+	if _, err := p.AuthCodeURL(context.Background(), "st", "n", verifier); err == nil {
+		t.Error("expected an issuer mismatch to fail discovery")
+	}
+}
+
+func TestNew_ChecksConfig(t *testing.T) {
+	for name, cfg := range map[string]idp.Config{
+		"bad id":    {ID: "Corp IdP", Issuer: "https://idp.test", ClientID: "c", RedirectURL: callback},
+		"no issuer": {ID: "corp", ClientID: "c", RedirectURL: callback},
+	} {
+		if _, err := idp.New(cfg, nil); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
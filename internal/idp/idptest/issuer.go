@@ -0,0 +1,242 @@
+// Package idptest runs an in-process OpenID Connect provider for driving
+// idp sign-ins from tests, in the spirit of net/http/httptest.
+package idptest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Identity is the user who signs in at the fake provider.
+type Identity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Issuer serves discovery, JWKS and token endpoints over HTTP and signs ES256
+// ID tokens. The authorization endpoint isn't served: Authorize plays the
+// user's visit to it.
+type Issuer struct {
+	URL          string
+	ClientID     string
+	ClientSecret string
+
+	server *httptest.Server
+
+	mu    sync.Mutex
+	key   *ecdsa.PrivateKey
+	kid   int
+	codes map[string]*grant
+}
+
+type grant struct {
+	id          Identity
+	redirectURI string
+	challenge   string
+	nonce       string
+}
+
+// NewIssuer starts a provider that knows one client. Call Close when done.
+func NewIssuer(clientID, clientSecret string) *Issuer {
+	i := &Issuer{ClientID: clientID, ClientSecret: clientSecret, codes: map[string]*grant{}}
+	i.RotateKey()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", i.discovery)
+	mux.HandleFunc("/jwks", i.jwks)
+	mux.HandleFunc("/token", i.token)
+	i.server = httptest.NewServer(mux)
+	i.URL = i.server.URL
+	return i
+}
+
+func (i *Issuer) Close() {
+	i.server.Close()
+}
+
+// RotateKey replaces the signing key with one under a new kid, as a provider
+// does on rotation.
+func (i *Issuer) RotateKey() {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.key = key
+	i.kid++
+}
+
+// Authorize signs id in for the authorization request at authURL, checking
+// it as the provider would, and returns the redirect back to the relying
+// party carrying the code and state.
+func (i *Issuer) Authorize(authURL string, id Identity) (string, error) {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	switch {
+	case u.Scheme+"://"+u.Host != i.URL || u.Path != "/authorize":
+		return "", fmt.Errorf("idptest: %s is not this provider's authorization endpoint", authURL)
+	case q.Get("response_type") != "code":
+		return "", errors.New("idptest: response_type must be code")
+	case q.Get("client_id") != i.ClientID:
+		return "", errors.New("idptest: unknown client_id")
+	case q.Get("code_challenge") == "" || q.Get("code_challenge_method") != "S256":
+		return "", errors.New("idptest: PKCE with S256 is required")
+	case !strings.Contains(" "+q.Get("scope")+" ", " openid "):
+		return "", errors.New("idptest: openid scope is required")
+	}
+
+	code := randomString()
+	i.mu.Lock()
+	i.codes[code] = &grant{
+		id:          id,
+		redirectURI: q.Get("redirect_uri"),
+		challenge:   q.Get("code_challenge"),
+		nonce:       q.Get("nonce"),
+	}
+	i.mu.Unlock()
+	return callback(q, url.Values{"code": {code}})
+}
+
+// Deny returns the redirect for a user who declined to sign in.
+func (i *Issuer) Deny(authURL string) (string, error) {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		return "", err
+	}
+	return callback(u.Query(), url.Values{"error": {"access_denied"}})
+}
+
+func callback(req, params url.Values) (string, error) {
+	u, err := url.Parse(req.Get("redirect_uri"))
+	if err != nil || !u.IsAbs() {
+		return "", errors.New("idptest: invalid redirect_uri")
+	}
+	q := u.Query()
+	for k, v := range params {
+		q[k] = v
+	}
+	q.Set("state", req.Get("state"))
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+func (i *Issuer) discovery(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                i.URL,
+		"authorization_endpoint":                i.URL + "/authorize",
+		"token_endpoint":                        i.URL + "/token",
+		"jwks_uri":                              i.URL + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"ES256"},
+	})
+}
+
+func (i *Issuer) jwks(w http.ResponseWriter, _ *http.Request) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	pub := i.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]any{"keys": []map[string]string{{
+		"kty": "EC",
+		"crv": "P-256",
+		"kid": i.kidString(),
+		"use": "sig",
+		"alg": "ES256",
+		"x":   base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, 32))),
+		"y":   base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, 32))),
+	}}})
+}
+
+func (i *Issuer) token(w http.ResponseWriter, r *http.Request) {
+	id, secret, _ := r.BasicAuth()
+	id, _ = url.QueryUnescape(id)
+	secret, _ = url.QueryUnescape(secret)
+	if id != i.ClientID || secret != i.ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	if r.PostFormValue("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+
+	i.mu.Lock()
+	g := i.codes[r.PostFormValue("code")]
+	delete(i.codes, r.PostFormValue("code"))
+	i.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	if g == nil || g.redirectURI != r.PostFormValue("redirect_uri") ||
+		base64.RawURLEncoding.EncodeToString(sum[:]) != g.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":            i.URL,
+		"sub":            g.id.Subject,
+		"aud":            i.ClientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"email":          g.id.Email,
+		"email_verified": g.id.EmailVerified,
+		"name":           g.id.Name,
+	}
+	if g.nonce != "" {
+		claims["nonce"] = g.nonce
+	}
+	i.mu.Lock()
+	t := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	t.Header["kid"] = i.kidString()
+	signed, err := t.SignedString(i.key)
+	i.mu.Unlock()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     signed,
+	})
+}
+
+func (i *Issuer) kidString() string {
+	return fmt.Sprintf("key-%d", i.kid)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func randomString() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
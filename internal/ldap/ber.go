@@ -0,0 +1,196 @@
+package ldap
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// The subset of BER (X.690) that LDAP messages use: single-byte tags,
+// definite lengths, and INTEGER, BOOLEAN, ENUMERATED and OCTET STRING
+// primitives inside SEQUENCE and SET.
+
+// BER class and form bits, and the universal tags LDAP uses.
+const (
+	classApplication = 0x40
+	classContext     = 0x80
+	constructed      = 0x20
+
+	tagBoolean     = 0x01
+	tagInteger     = 0x02
+	tagOctetString = 0x04
+	tagEnumerated  = 0x0a
+	tagSequence    = 0x30
+	tagSet         = 0x31
+)
+
+// maxPacket bounds a single message, so a broken server can't make us
+// allocate without limit.
+const maxPacket = 4 << 20
+
+var errMalformed = errors.New("ldap: malformed message")
+
+// packet is one BER element. Constructed elements keep their children.
+type packet struct {
+	tag      byte
+	value    []byte // primitive contents
+	children []*packet
+}
+
+func newPacket(tag byte, children ...*packet) *packet {
+	return &packet{tag: tag, children: children}
+}
+
+func octetString(tag byte, s string) *packet {
+	return &packet{tag: tag, value: []byte(s)}
+}
+
+func integer(tag byte, n int64) *packet {
+	// Minimal two's-complement encoding.
+	var b []byte
+	for {
+		b = append([]byte{byte(n)}, b...)
+		n >>= 8
+		if (n == 0 && b[0]&0x80 == 0) || (n == -1 && b[0]&0x80 != 0) {
+			break
+		}
+	}
+	return &packet{tag: tag, value: b}
+}
+
+func boolean(v bool) *packet {
+	if v {
+		return &packet{tag: tagBoolean, value: []byte{0xff}}
+	}
+	return &packet{tag: tagBoolean, value: []byte{0}}
+}
+
+func (p *packet) constructed() bool { return p.tag&constructed != 0 }
+
+func (p *packet) bytes() []byte {
+	var content []byte
+	if p.constructed() {
+		for _, c := range p.children {
+			content = append(content, c.bytes()...)
+		}
+	} else {
+		content = p.value
+	}
+	out := []byte{p.tag}
+	out = append(out, encodeLength(len(content))...)
+	return append(out, content...)
+}
+
+func encodeLength(n int) []byte {
+	if n < 0x80 {
+		return []byte{byte(n)}
+	}
+	var b []byte
+	for ; n > 0; n >>= 8 {
+		b = append([]byte{byte(n)}, b...)
+	}
+	return append([]byte{0x80 | byte(len(b))}, b...)
+}
+
+// int returns the value of an INTEGER or ENUMERATED.
+func (p *packet) int() (int64, error) {
+	if p.constructed() || len(p.value) == 0 || len(p.value) > 8 {
+		return 0, errMalformed
+	}
+	n := int64(int8(p.value[0]))
+	for _, b := range p.value[1:] {
+		n = n<<8 | int64(b)
+	}
+	return n, nil
+}
+
+func (p *packet) str() string { return string(p.value) }
+
+// child returns the i'th child, or nil when there is none.
+func (p *packet) child(i int) *packet {
+	if i < len(p.children) {
+		return p.children[i]
+	}
+	return nil
+}
+
+// readPacket reads one element from r.
+func readPacket(r *bufio.Reader) (*packet, error) {
+	tag, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	if tag&0x1f == 0x1f {
+		return nil, fmt.Errorf("%w: multi-byte tags are not supported", errMalformed)
+	}
+	n, err := readLength(r)
+	if err != nil {
+		return nil, err
+	}
+	content := make([]byte, n)
+	if _, err := io.ReadFull(r, content); err != nil {
+		return nil, err
+	}
+	return parsePacket(tag, content)
+}
+
+func readLength(r *bufio.Reader) (int, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	if b < 0x80 {
+		return int(b), nil
+	}
+	size := int(b & 0x7f)
+	if size == 0 || size > 4 {
+		return 0, fmt.Errorf("%w: unsupported length", errMalformed)
+	}
+	n := 0
+	for i := 0; i < size; i++ {
+		if b, err = r.ReadByte(); err != nil {
+			return 0, err
+		}
+		n = n<<8 | int(b)
+	}
+	if n > maxPacket {
+		return 0, fmt.Errorf("%w: %d-byte message is too large", errMalformed, n)
+	}
+	return n, nil
+}
+
+func parsePacket(tag byte, content []byte) (*packet, error) {
+	p := &packet{tag: tag}
+	if !p.constructed() {
+		p.value = content
+		return p, nil
+	}
+	for len(content) > 0 {
+		if len(content) < 2 || content[0]&0x1f == 0x1f {
+			return nil, errMalformed
+		}
+		ctag, n, hdr := content[0], int(content[1]), 2
+		if n >= 0x80 {
+			size := n & 0x7f
+			if size == 0 || size > 4 || len(content) < 2+size {
+				return nil, errMalformed
+			}
+			n = 0
+			for _, b := range content[2 : 2+size] {
+				n = n<<8 | int(b)
+			}
+			hdr += size
+		}
+		if n < 0 || len(content) < hdr+n {
+			return nil, errMalformed
+		}
+		c, err := parsePacket(ctag, content[hdr:hdr+n])
+		if err != nil {
+			return nil, err
+		}
+		p.children = append(p.children, c)
+		content = content[hdr+n:]
+	}
+	return p, nil
+}
@@ -0,0 +1,181 @@
+package ldap
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Filter choice tags (RFC 4511 §4.5.1.7).
+const (
+	filterAnd            = classContext | constructed | 0
+	filterOr             = classContext | constructed | 1
+	filterNot            = classContext | constructed | 2
+	filterEquality       = classContext | constructed | 3
+	filterSubstrings     = classContext | constructed | 4
+	filterGreaterOrEqual = classContext | constructed | 5
+	filterLessOrEqual    = classContext | constructed | 6
+	filterPresent        = classContext | 7
+
+	substringInitial = classContext | 0
+	substringAny     = classContext | 1
+	substringFinal   = classContext | 2
+)
+
+// EscapeFilter escapes s for use as a value in a filter string, so
+// user input can't change the filter's meaning (RFC 4515 §3).
+func EscapeFilter(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '*', '(', ')', '\\', 0:
+			fmt.Fprintf(&b, `\%02x`, c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// compileFilter parses an RFC 4515 filter string. Approximate and
+// extensible matches are not supported.
+func compileFilter(s string) (*packet, error) {
+	p, rest, err := parseFilter(s)
+	if err != nil {
+		return nil, err
+	}
+	if rest != "" {
+		return nil, fmt.Errorf("ldap: filter %q: unexpected %q", s, rest)
+	}
+	return p, nil
+}
+
+func parseFilter(s string) (*packet, string, error) {
+	if !strings.HasPrefix(s, "(") {
+		return nil, "", fmt.Errorf("ldap: filter %q: expected (", s)
+	}
+	s = s[1:]
+	if s == "" {
+		return nil, "", fmt.Errorf("ldap: filter is not closed")
+	}
+
+	var p *packet
+	switch s[0] {
+	case '&', '|':
+		tag := byte(filterAnd)
+		if s[0] == '|' {
+			tag = filterOr
+		}
+		p = newPacket(tag)
+		s = s[1:]
+		for strings.HasPrefix(s, "(") {
+			c, rest, err := parseFilter(s)
+			if err != nil {
+				return nil, "", err
+			}
+			p.children = append(p.children, c)
+			s = rest
+		}
+	case '!':
+		c, rest, err := parseFilter(s[1:])
+		if err != nil {
+			return nil, "", err
+		}
+		p, s = newPacket(filterNot, c), rest
+	default:
+		end := strings.IndexByte(s, ')')
+		if end < 0 {
+			return nil, "", fmt.Errorf("ldap: filter is not closed")
+		}
+		var err error
+		if p, err = parseItem(s[:end]); err != nil {
+			return nil, "", err
+		}
+		s = s[end:]
+	}
+
+	if !strings.HasPrefix(s, ")") {
+		return nil, "", fmt.Errorf("ldap: filter is not closed")
+	}
+	return p, s[1:], nil
+}
+
+// parseItem parses a simple filter such as mail=a*@example.com.
+func parseItem(s string) (*packet, error) {
+	eq := strings.IndexByte(s, '=')
+	if eq < 1 {
+		return nil, fmt.Errorf("ldap: filter item %q has no attribute", s)
+	}
+	attr, value := s[:eq], s[eq+1:]
+
+	switch attr[len(attr)-1] {
+	case '>', '<':
+		tag := byte(filterGreaterOrEqual)
+		if attr[len(attr)-1] == '<' {
+			tag = filterLessOrEqual
+		}
+		v, err := unescapeFilter(value)
+		if err != nil {
+			return nil, err
+		}
+		return newPacket(tag, octetString(tagOctetString, attr[:len(attr)-1]), octetString(tagOctetString, v)), nil
+	case '~', ':':
+		return nil, fmt.Errorf("ldap: filter item %q: approximate and extensible matches are not supported", s)
+	}
+
+	if value == "*" {
+		return octetString(filterPresent, attr), nil
+	}
+	if !strings.Contains(value, "*") {
+		v, err := unescapeFilter(value)
+		if err != nil {
+			return nil, err
+		}
+		return newPacket(filterEquality, octetString(tagOctetString, attr), octetString(tagOctetString, v)), nil
+	}
+
+	parts := strings.Split(value, "*")
+	subs := newPacket(tagSequence)
+	for i, part := range parts {
+		if part == "" {
+			continue
+		}
+		v, err := unescapeFilter(part)
+		if err != nil {
+			return nil, err
+		}
+		tag := byte(substringAny)
+		switch i {
+		case 0:
+			tag = substringInitial
+		case len(parts) - 1:
+			tag = substringFinal
+		}
+		subs.children = append(subs.children, octetString(tag, v))
+	}
+	return newPacket(filterSubstrings, octetString(tagOctetString, attr), subs), nil
+}
+
+// unescapeFilter decodes the \XX escapes in a filter value.
+func unescapeFilter(s string) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+3 > len(s) {
+			return "", fmt.Errorf("ldap: filter value %q has a short escape", s)
+		}
+		c, err := hex.DecodeString(s[i+1 : i+3])
+		if err != nil {
+			return "", fmt.Errorf("ldap: filter value %q has a bad escape", s)
+		}
+		b.Write(c)
+		i += 2
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,69 @@
+package ldap
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCompileFilter(t *testing.T) {
+	// (&(objectClass=person)(mail=a*@x)) with a present item inside a not.
+	got, err := compileFilter(`(&(objectClass=person)(mail=a*@x)(!(nsAccountLock=*)))`)
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	want := []byte{
+		0xa0, 0x39,
+		0xa3, 0x15, 0x04, 0x0b, 'o', 'b', 'j', 'e', 'c', 't', 'C', 'l', 'a', 's', 's', 0x04, 0x06, 'p', 'e', 'r', 's', 'o', 'n',
+		0xa4, 0x0f, 0x04, 0x04, 'm', 'a', 'i', 'l', 0x30, 0x07, 0x80, 0x01, 'a', 0x82, 0x02, '@', 'x',
+		0xa2, 0x0f, 0x87, 0x0d, 'n', 's', 'A', 'c', 'c', 'o', 'u', 'n', 't', 'L', 'o', 'c', 'k',
+	}
+	if b := got.bytes(); !bytes.Equal(b, want) {
+		t.Errorf("encoding\n got % x\nwant % x", b, want)
+	}
+}
+
+func TestCompileFilter_Rejects(t *testing.T) {
+	for _, f := range []string{
+		"mail=a",
+		"(mail=a",
+		"(mail=a))",
+		"(=a)",
+		"(cn~=bob)",
+		`(cn=\4)`,
+		`(cn=\zz)`,
+	} {
+		if _, err := compileFilter(f); err == nil {
+			t.Errorf("%s: expected an error", f)
+		}
+	}
+}
+
+func TestEscapeFilter(t *testing.T) {
+	in := `*)(uid=*))(|(uid=*`
+	p, err := compileFilter("(mail=" + EscapeFilter(in) + ")")
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	if p.tag != filterEquality || p.children[1].str() != in {
+		t.Errorf("expected an equality match on the literal input, got %#x %q", p.tag, p.children[1].value)
+	}
+}
+
+func TestPacketRoundTrip(t *testing.T) {
+	big := bytes.Repeat([]byte{'x'}, 300)
+	p := newPacket(tagSequence, integer(tagInteger, -129), integer(tagInteger, 128), &packet{tag: tagOctetString, value: big})
+	b := p.bytes()
+	got, err := parsePacket(b[0], b[4:])
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if n, _ := got.child(0).int(); n != -129 {
+		t.Errorf("expected -129, got %d", n)
+	}
+	if n, _ := got.child(1).int(); n != 128 {
+		t.Errorf("expected 128, got %d", n)
+	}
+	if !bytes.Equal(got.child(2).value, big) {
+		t.Error("long octet string did not round-trip")
+	}
+}
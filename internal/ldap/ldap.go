@@ -0,0 +1,466 @@
+// Package ldap checks passwords against an LDAP directory the usual way:
+// search for the user's entry, then bind as that entry with the password.
+//
+// Only what that needs of LDAPv3 (RFC 4511) is implemented: simple bind,
+// search, StartTLS and unbind, over ldap:// or ldaps://.
+package ldap
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	// ErrInvalidCredentials is returned when the directory refuses the password.
+	ErrInvalidCredentials = errors.New("ldap: invalid credentials")
+	// ErrUserNotFound is returned when no entry matches the login.
+	ErrUserNotFound = errors.New("ldap: user not found")
+	// ErrUnavailable wraps failures to reach or talk to the directory,
+	// including a refused service account.
+	ErrUnavailable = errors.New("ldap: directory unavailable")
+)
+
+// Placeholder is replaced in Config.UserFilter by the escaped login.
+const Placeholder = "{login}"
+
+// Protocol operation tags (RFC 4511 §4.2–4.14).
+const (
+	opBindRequest       = classApplication | constructed | 0
+	opBindResponse      = classApplication | constructed | 1
+	opUnbindRequest     = classApplication | 2
+	opSearchRequest     = classApplication | constructed | 3
+	opSearchEntry       = classApplication | constructed | 4
+	opSearchDone        = classApplication | constructed | 5
+	opSearchReference   = classApplication | constructed | 19
+	opExtendedRequest   = classApplication | constructed | 23
+	opExtendedResponse  = classApplication | constructed | 24
+	authSimple          = classContext | 0
+	extendedRequestName = classContext | 0
+)
+
+const (
+	resultSuccess            = 0
+	resultSizeLimitExceeded  = 4
+	resultInvalidCredentials = 49
+
+	scopeWholeSubtree = 2
+	derefNever        = 0
+
+	startTLSOID = "1.3.6.1.4.1.1466.20037"
+)
+
+// ResultError is a result code other than success from the directory.
+type ResultError struct {
+	Code    int
+	Message string
+}
+
+func (e *ResultError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("ldap: result code %d", e.Code)
+	}
+	return fmt.Sprintf("ldap: result code %d: %s", e.Code, e.Message)
+}
+
+// Entry is a search result.
+type Entry struct {
+	DN         string
+	Attributes map[string][]string // keyed by lowercased name
+}
+
+// Get returns the first value of attr, or "".
+func (e *Entry) Get(attr string) string {
+	if v := e.Attributes[strings.ToLower(attr)]; len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// Conn is a connection to a directory. It is not safe for concurrent use.
+type Conn struct {
+	conn   net.Conn
+	r      *bufio.Reader
+	host   string // for checking the certificate after StartTLS
+	nextID int64
+}
+
+// Dial connects to an ldap:// or ldaps:// URL. The context's deadline, if
+// any, applies to the whole connection.
+func Dial(ctx context.Context, rawURL string, tlsConfig *tls.Config) (*Conn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("ldap: %w", err)
+	}
+	host := u.Host
+	if u.Port() == "" {
+		switch u.Scheme {
+		case "ldap":
+			host = net.JoinHostPort(u.Hostname(), "389")
+		case "ldaps":
+			host = net.JoinHostPort(u.Hostname(), "636")
+		}
+	}
+
+	var d net.Dialer
+	nc, err := d.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = nc.SetDeadline(deadline)
+	}
+	switch u.Scheme {
+	case "ldap":
+	case "ldaps":
+		tc := tls.Client(nc, clientTLS(tlsConfig, u.Hostname()))
+		if err := tc.HandshakeContext(ctx); err != nil {
+			nc.Close()
+			return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+		}
+		nc = tc
+	default:
+		nc.Close()
+		return nil, fmt.Errorf("ldap: unsupported scheme %q", u.Scheme)
+	}
+	return &Conn{conn: nc, r: bufio.NewReader(nc), host: u.Hostname()}, nil
+}
+
+func clientTLS(cfg *tls.Config, host string) *tls.Config {
+	if cfg == nil {
+		cfg = &tls.Config{}
+	}
+	cfg = cfg.Clone()
+	if cfg.ServerName == "" {
+		cfg.ServerName = host
+	}
+	return cfg
+}
+
+// StartTLS upgrades a plain ldap:// connection before anything is sent on
+// it (RFC 4511 §4.14).
+func (c *Conn) StartTLS(tlsConfig *tls.Config) error {
+	resp, err := c.roundTrip(newPacket(opExtendedRequest, octetString(extendedRequestName, startTLSOID)), opExtendedResponse)
+	if err != nil {
+		return err
+	}
+	if err := resultOf(resp); err != nil {
+		return err
+	}
+	tc := tls.Client(c.conn, clientTLS(tlsConfig, c.host))
+	if err := tc.Handshake(); err != nil {
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	c.conn, c.r = tc, bufio.NewReader(tc)
+	return nil
+}
+
+// Bind authenticates the connection as dn. A refused password is
+// ErrInvalidCredentials. An empty password is refused here, since servers
+// treat it as an anonymous bind and report success (RFC 4513 §5.1.2).
+func (c *Conn) Bind(dn, password string) error {
+	if password == "" {
+		return ErrInvalidCredentials
+	}
+	resp, err := c.roundTrip(newPacket(opBindRequest,
+		integer(tagInteger, 3),
+		octetString(tagOctetString, dn),
+		octetString(authSimple, password),
+	), opBindResponse)
+	if err != nil {
+		return err
+	}
+	err = resultOf(resp)
+	var re *ResultError
+	if errors.As(err, &re) && re.Code == resultInvalidCredentials {
+		return ErrInvalidCredentials
+	}
+	return err
+}
+
+// SearchRequest is a subtree search.
+type SearchRequest struct {
+	BaseDN     string
+	Filter     string
+	Attributes []string
+	// SizeLimit caps the entries returned; zero means the server's limit.
+	SizeLimit int
+}
+
+// Search returns the entries matching req. Hitting SizeLimit is not an
+// error; the entries found so far are returned.
+func (c *Conn) Search(req SearchRequest) ([]*Entry, error) {
+	filter, err := compileFilter(req.Filter)
+	if err != nil {
+		return nil, err
+	}
+	attrs := newPacket(tagSequence)
+	for _, a := range req.Attributes {
+		attrs.children = append(attrs.children, octetString(tagOctetString, a))
+	}
+	id, err := c.send(newPacket(opSearchRequest,
+		octetString(tagOctetString, req.BaseDN),
+		integer(tagEnumerated, scopeWholeSubtree),
+		integer(tagEnumerated, derefNever),
+		integer(tagInteger, int64(req.SizeLimit)),
+		integer(tagInteger, 0),
+		boolean(false),
+		filter,
+		attrs,
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*Entry
+	for {
+		op, err := c.receive(id)
+		if err != nil {
+			return nil, err
+		}
+		switch op.tag {
+		case opSearchEntry:
+			e, err := parseEntry(op)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, e)
+		case opSearchReference:
+			// Referrals to other servers are not followed.
+		case opSearchDone:
+			err := resultOf(op)
+			var re *ResultError
+			if errors.As(err, &re) && re.Code == resultSizeLimitExceeded {
+				err = nil
+			}
+			return entries, err
+		default:
+			return nil, fmt.Errorf("%w: unexpected operation %#x", errMalformed, op.tag)
+		}
+	}
+}
+
+// Close unbinds and closes the connection.
+func (c *Conn) Close() error {
+	_, _ = c.send(&packet{tag: opUnbindRequest})
+	return c.conn.Close()
+}
+
+func (c *Conn) roundTrip(op *packet, want byte) (*packet, error) {
+	id, err := c.send(op)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.receive(id)
+	if err != nil {
+		return nil, err
+	}
+	if resp.tag != want {
+		return nil, fmt.Errorf("%w: unexpected operation %#x", errMalformed, resp.tag)
+	}
+	return resp, nil
+}
+
+func (c *Conn) send(op *packet) (int64, error) {
+	c.nextID++
+	msg := newPacket(tagSequence, integer(tagInteger, c.nextID), op)
+	if _, err := c.conn.Write(msg.bytes()); err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	return c.nextID, nil
+}
+
+// receive reads the next message, which must answer id, and returns its
+// protocol operation.
+func (c *Conn) receive(id int64) (*packet, error) {
+	msg, err := readPacket(c.r)
+	if err != nil {
+		if errors.Is(err, errMalformed) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	if msg.tag != tagSequence || len(msg.children) < 2 {
+		return nil, errMalformed
+	}
+	got, err := msg.children[0].int()
+	if err != nil {
+		return nil, err
+	}
+	if got == 0 {
+		// An unsolicited notification, which only ever announces that the
+		// server is hanging up.
+		return nil, fmt.Errorf("%w: server closed the connection", ErrUnavailable)
+	}
+	if got != id {
+		return nil, fmt.Errorf("%w: reply to message %d, want %d", errMalformed, got, id)
+	}
+	return msg.children[1], nil
+}
+
+// resultOf reads the LDAPResult at the start of a response.
+func resultOf(op *packet) error {
+	if len(op.children) < 3 {
+		return errMalformed
+	}
+	code, err := op.children[0].int()
+	if err != nil {
+		return err
+	}
+	if code == resultSuccess {
+		return nil
+	}
+	return &ResultError{Code: int(code), Message: op.children[2].str()}
+}
+
+func parseEntry(op *packet) (*Entry, error) {
+	if len(op.children) < 2 {
+		return nil, errMalformed
+	}
+	e := &Entry{DN: op.children[0].str(), Attributes: map[string][]string{}}
+	for _, attr := range op.children[1].children {
+		if len(attr.children) < 2 {
+			return nil, errMalformed
+		}
+		name := strings.ToLower(attr.children[0].str())
+		for _, v := range attr.children[1].children {
+			e.Attributes[name] = append(e.Attributes[name], v.str())
+		}
+	}
+	return e, nil
+}
+
+// --- directory ---
+
+// Config describes a directory and where its users are.
+type Config struct {
+	// URL is ldap://host[:port] or ldaps://host[:port].
+	URL string
+	// StartTLS upgrades an ldap:// connection before binding.
+	StartTLS bool
+	// TLS is used for ldaps:// and StartTLS; nil trusts the system roots.
+	TLS *tls.Config
+	// BindDN and BindPassword are the service account that searches for
+	// users. Leave them empty to search anonymously.
+	BindDN       string
+	BindPassword string
+	BaseDN       string
+	// UserFilter finds a user's entry; Placeholder stands for the login.
+	UserFilter string
+	// IDAttribute holds a stable identifier such as entryUUID; the DN is
+	// used when it is empty or missing from the entry.
+	IDAttribute    string
+	NameAttribute  string
+	EmailAttribute string
+	// Timeout bounds each sign-in's conversation with the directory.
+	Timeout time.Duration
+}
+
+// User is what the directory says about the user who signed in.
+type User struct {
+	DN    string
+	ID    string
+	Name  string
+	Email string
+}
+
+// Directory authenticates users against one directory, with a fresh
+// connection for each sign-in.
+type Directory struct {
+	cfg Config
+}
+
+// New checks cfg and returns a Directory. Nothing is dialled yet.
+func New(cfg Config) (*Directory, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil || (u.Scheme != "ldap" && u.Scheme != "ldaps") || u.Host == "" {
+		return nil, fmt.Errorf("ldap: URL %q must be ldap://host or ldaps://host", cfg.URL)
+	}
+	if cfg.StartTLS && u.Scheme == "ldaps" {
+		return nil, errors.New("ldap: StartTLS can't be combined with ldaps://")
+	}
+	if cfg.BaseDN == "" {
+		return nil, errors.New("ldap: a base DN is required")
+	}
+	if !strings.Contains(cfg.UserFilter, Placeholder) {
+		return nil, fmt.Errorf("ldap: user filter %q must contain %s", cfg.UserFilter, Placeholder)
+	}
+	if _, err := compileFilter(strings.ReplaceAll(cfg.UserFilter, Placeholder, "x")); err != nil {
+		return nil, err
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	return &Directory{cfg: cfg}, nil
+}
+
+// Authenticate finds login's entry and binds as it with password. It
+// returns ErrUserNotFound when no entry matches, ErrInvalidCredentials when
+// the bind is refused, and an error wrapping ErrUnavailable when the
+// directory can't be asked.
+func (d *Directory) Authenticate(ctx context.Context, login, password string) (*User, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.cfg.Timeout)
+	defer cancel()
+
+	c, err := Dial(ctx, d.cfg.URL, d.cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	if d.cfg.StartTLS {
+		if err := c.StartTLS(d.cfg.TLS); err != nil {
+			return nil, err
+		}
+	}
+	if d.cfg.BindDN != "" {
+		if err := c.Bind(d.cfg.BindDN, d.cfg.BindPassword); err != nil {
+			return nil, fmt.Errorf("%w: service account bind: %v", ErrUnavailable, err)
+		}
+	}
+
+	var attrs []string
+	for _, a := range []string{d.cfg.IDAttribute, d.cfg.NameAttribute, d.cfg.EmailAttribute} {
+		if a != "" {
+			attrs = append(attrs, a)
+		}
+	}
+	entries, err := c.Search(SearchRequest{
+		BaseDN:     d.cfg.BaseDN,
+		Filter:     strings.ReplaceAll(d.cfg.UserFilter, Placeholder, EscapeFilter(login)),
+		Attributes: attrs,
+		SizeLimit:  2,
+	})
+	if err != nil {
+		return nil, err
+	}
+	switch len(entries) {
+	case 0:
+		return nil, ErrUserNotFound
+	case 1:
+	default:
+		return nil, fmt.Errorf("ldap: more than one entry matches %q; tighten the user filter", login)
+	}
+	e := entries[0]
+
+	if err := c.Bind(e.DN, password); err != nil {
+		return nil, err
+	}
+
+	u := &User{DN: e.DN, ID: e.DN, Name: e.Get(d.cfg.NameAttribute), Email: e.Get(d.cfg.EmailAttribute)}
+	if id := e.Get(d.cfg.IDAttribute); d.cfg.IDAttribute != "" && id != "" {
+		// Binary identifiers such as Active Directory's objectGUID.
+		if !utf8.ValidString(id) {
+			id = hex.EncodeToString([]byte(id))
+		}
+		u.ID = id
+	}
+	return u, nil
+}
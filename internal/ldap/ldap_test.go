@@ -0,0 +1,157 @@
+package ldap_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"user-management-api/internal/ldap"
+	"user-management-api/internal/ldap/ldaptest"
+)
+
+const base = "ou=people,dc=corp,dc=test"
+
+func newDirectory(t *testing.T, mutate func(*ldap.Config, *ldaptest.Server)) (*ldap.Directory, *ldaptest.Server) {
+	t.Helper()
+	srv := ldaptest.NewServer(
+		&ldaptest.Entry{DN: "cn=reader,dc=corp,dc=test", Password: "reader pw"},
+		&ldaptest.Entry{
+			DN:       "uid=alice," + base,
+			Password: "alice pw",
+			Attributes: map[string][]string{
+				"objectClass": {"inetOrgPerson"},
+				"uid":         {"alice"},
+				"cn":          {"Alice Smith"},
+				"mail":        {"alice@corp.test"},
+				"entryUUID":   {"0b6e0b7a-7c3b-4d7e-9a61-3f0e5f1e8c11"},
+			},
+		},
+	)
+	t.Cleanup(srv.Close)
+
+	cfg := ldap.Config{
+		URL:            srv.URL,
+		BindDN:         "cn=reader,dc=corp,dc=test",
+		BindPassword:   "reader pw",
+		BaseDN:         base,
+		UserFilter:     "(&(objectClass=inetOrgPerson)(mail={login}))",
+		IDAttribute:    "entryUUID",
+		NameAttribute:  "cn",
+		EmailAttribute: "mail",
+	}
+	if mutate != nil {
+		mutate(&cfg, srv)
+	}
+	d, err := ldap.New(cfg)
+	if err != nil {
+		t.Fatalf("new directory: %v", err)
+	}
+	return d, srv
+}
+
+func TestAuthenticate(t *testing.T) {
+	d, _ := newDirectory(t, nil)
+
+	u, err := d.Authenticate(context.Background(), "alice@corp.test", "alice pw")
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	want := ldap.User{
+		DN:    "uid=alice," + base,
+		ID:    "0b6e0b7a-7c3b-4d7e-9a61-3f0e5f1e8c11",
+		Name:  "Alice Smith",
+		Email: "alice@corp.test",
+	}
+	if *u != want {
+		t.Errorf("got %+v, want %+v", *u, want)
+	}
+}
+
+func TestAuthenticate_StartTLS(t *testing.T) {
+	d, _ := newDirectory(t, func(c *ldap.Config, srv *ldaptest.Server) {
+		c.StartTLS, c.TLS = true, srv.TLS
+		c.IDAttribute = ""
+	})
+
+	u, err := d.Authenticate(context.Background(), "alice@corp.test", "alice pw")
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	if u.ID != u.DN {
+		t.Errorf("without an ID attribute the DN is the ID, got %q", u.ID)
+	}
+}
+
+func TestAuthenticate_StartTLSChecksCertificate(t *testing.T) {
+	d, _ := newDirectory(t, func(c *ldap.Config, _ *ldaptest.Server) { c.StartTLS = true })
+	if _, err := d.Authenticate(context.Background(), "alice@corp.test", "alice pw"); !errors.Is(err, ldap.ErrUnavailable) {
+		t.Errorf("untrusted certificate: expected ErrUnavailable, got %v", err)
+	}
+}
+
+func TestAuthenticate_Rejects(t *testing.T) {
+	ctx := context.Background()
+	d, srv := newDirectory(t, nil)
+
+	if _, err := d.Authenticate(ctx, "alice@corp.test", "wrong"); !errors.Is(err, ldap.ErrInvalidCredentials) {
+		t.Errorf("wrong password: expected ErrInvalidCredentials, got %v", err)
+	}
+	// The server accepts an empty password as an anonymous bind.
+	if _, err := d.Authenticate(ctx, "alice@corp.test", ""); !errors.Is(err, ldap.ErrInvalidCredentials) {
+		t.Errorf("empty password: expected ErrInvalidCredentials, got %v", err)
+	}
+	if _, err := d.Authenticate(ctx, "bob@corp.test", "alice pw"); !errors.Is(err, ldap.ErrUserNotFound) {
+		t.Errorf("unknown user: expected ErrUserNotFound, got %v", err)
+	}
+	if _, err := d.Authenticate(ctx, "*", "alice pw"); !errors.Is(err, ldap.ErrUserNotFound) {
+		t.Errorf("wildcard login: expected ErrUserNotFound, got %v", err)
+	}
+	before := srv.Binds()
+	if _, err := d.Authenticate(ctx, "alice@corp.test", "alice pw"); err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	if srv.Binds() != before+2 {
+		t.Errorf("expected a service bind and a user bind, got %d", srv.Binds()-before)
+	}
+}
+
+func TestAuthenticate_ServiceAccountRefused(t *testing.T) {
+	d, _ := newDirectory(t, func(c *ldap.Config, _ *ldaptest.Server) { c.BindPassword = "stale" })
+	if _, err := d.Authenticate(context.Background(), "alice@corp.test", "alice pw"); !errors.Is(err, ldap.ErrUnavailable) {
+		t.Errorf("expected ErrUnavailable, got %v", err)
+	}
+}
+
+func TestAuthenticate_Unreachable(t *testing.T) {
+	d, srv := newDirectory(t, nil)
+	srv.Close()
+	if _, err := d.Authenticate(context.Background(), "alice@corp.test", "alice pw"); !errors.Is(err, ldap.ErrUnavailable) {
+		t.Errorf("expected ErrUnavailable, got %v", err)
+	}
+}
+
+func TestAuthenticate_AmbiguousFilter(t *testing.T) {
+	d, srv := newDirectory(t, func(c *ldap.Config, _ *ldaptest.Server) {
+		c.UserFilter = "(|(mail={login})(objectClass=inetOrgPerson))"
+	})
+	srv.Add(&ldaptest.Entry{DN: "uid=bob," + base, Password: "bob pw", Attributes: map[string][]string{"objectClass": {"inetOrgPerson"}}})
+
+	_, err := d.Authenticate(context.Background(), "alice@corp.test", "alice pw")
+	if err == nil || errors.Is(err, ldap.ErrInvalidCredentials) || errors.Is(err, ldap.ErrUserNotFound) {
+		t.Errorf("expected an ambiguity error, got %v", err)
+	}
+}
+
+func TestNew_ChecksConfig(t *testing.T) {
+	for name, cfg := range map[string]ldap.Config{
+		"bad scheme":     {URL: "http://dir", BaseDN: base, UserFilter: "(mail={login})"},
+		"no base":        {URL: "ldap://dir", UserFilter: "(mail={login})"},
+		"no placeholder": {URL: "ldap://dir", BaseDN: base, UserFilter: "(mail=alice)"},
+		"bad filter":     {URL: "ldap://dir", BaseDN: base, UserFilter: "mail={login}"},
+		"starttls+ldaps": {URL: "ldaps://dir", StartTLS: true, BaseDN: base, UserFilter: "(mail={login})"},
+	} {
+		if _, err := ldap.New(cfg); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
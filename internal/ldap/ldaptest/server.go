@@ -0,0 +1,437 @@
+// Package ldaptest runs an in-process LDAP directory for testing sign-ins
+// against, in the spirit of net/http/httptest. It understands simple bind,
+// subtree search, StartTLS and unbind, and nothing else.
+package ldaptest
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const startTLSOID = "1.3.6.1.4.1.1466.20037"
+
+// Result codes the server answers with.
+const (
+	success                 = 0
+	protocolError           = 2
+	sizeLimitExceeded       = 4
+	invalidCredentials      = 49
+	insufficientAccessRight = 50
+	unwillingToPerform      = 53
+)
+
+// Entry is a directory entry. Password, when set, lets clients bind as it.
+type Entry struct {
+	DN         string
+	Password   string
+	Attributes map[string][]string
+}
+
+// Server is a directory listening on a local port. Connections must bind
+// before they can search, as with most real directories.
+type Server struct {
+	// URL is ldap://127.0.0.1:port.
+	URL string
+	// TLS is a client configuration that trusts the server's certificate
+	// for StartTLS.
+	TLS *tls.Config
+
+	ln        net.Listener
+	serverTLS *tls.Config
+
+	mu      sync.Mutex
+	entries []*Entry
+	binds   int
+	wg      sync.WaitGroup
+}
+
+// NewServer starts a directory with the given entries. Call Close when done.
+func NewServer(entries ...*Entry) *Server {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+	s := &Server{URL: "ldap://" + ln.Addr().String(), ln: ln, entries: entries}
+	s.serverTLS, s.TLS = selfSigned()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				s.serve(c)
+			}()
+		}
+	}()
+	return s
+}
+
+// Close stops the server and waits for its connections to finish.
+func (s *Server) Close() {
+	s.ln.Close()
+	s.wg.Wait()
+}
+
+// Add adds an entry.
+func (s *Server) Add(e *Entry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, e)
+}
+
+// Binds counts the successful password binds so far.
+func (s *Server) Binds() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.binds
+}
+
+func (s *Server) serve(nc net.Conn) {
+	defer nc.Close()
+	_ = nc.SetDeadline(time.Now().Add(10 * time.Second))
+	r := bufio.NewReader(nc)
+	bound := false
+
+	for {
+		msg, err := read(r)
+		if err != nil || msg.tag != 0x30 || len(msg.children) < 2 {
+			return
+		}
+		id := msg.children[0].value
+		op := msg.children[1]
+		reply := func(tag byte, children ...*element) {
+			_, _ = nc.Write(seq(0x30, &element{tag: 0x02, value: id}, seq(tag, children...)).encode())
+		}
+
+		switch op.tag {
+		case 0x60: // BindRequest
+			if len(op.children) < 3 || op.children[2].tag != 0x80 {
+				reply(0x61, result(protocolError, "only simple bind is supported")...)
+				continue
+			}
+			dn, pw := string(op.children[1].value), string(op.children[2].value)
+			switch {
+			case pw == "":
+				// An unauthenticated bind succeeds, as RFC 4513 allows;
+				// clients must not take it as proof of a password.
+				bound = false
+				reply(0x61, result(success, "")...)
+			case s.checkPassword(dn, pw):
+				bound = true
+				reply(0x61, result(success, "")...)
+			default:
+				bound = false
+				reply(0x61, result(invalidCredentials, "")...)
+			}
+		case 0x63: // SearchRequest
+			if !bound {
+				reply(0x65, result(insufficientAccessRight, "bind first")...)
+				continue
+			}
+			s.search(op, reply)
+		case 0x77: // ExtendedRequest
+			if len(op.children) == 0 || string(op.children[0].value) != startTLSOID {
+				reply(0x78, result(protocolError, "unsupported extended operation")...)
+				continue
+			}
+			reply(0x78, result(success, "")...)
+			tc := tls.Server(nc, s.serverTLS)
+			if err := tc.Handshake(); err != nil {
+				return
+			}
+			nc, r = tc, bufio.NewReader(tc)
+		case 0x42: // UnbindRequest
+			return
+		default:
+			reply(0x65, result(unwillingToPerform, "unsupported operation")...)
+		}
+	}
+}
+
+func (s *Server) checkPassword(dn, pw string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.entries {
+		if strings.EqualFold(e.DN, dn) && e.Password != "" && e.Password == pw {
+			s.binds++
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) search(op *element, reply func(byte, ...*element)) {
+	if len(op.children) < 8 {
+		reply(0x65, result(protocolError, "malformed search")...)
+		return
+	}
+	base := strings.ToLower(string(op.children[0].value))
+	limit := int(toInt(op.children[3].value))
+	filter := op.children[6]
+	var want []string
+	for _, a := range op.children[7].children {
+		want = append(want, strings.ToLower(string(a.value)))
+	}
+
+	s.mu.Lock()
+	var found []*Entry
+	for _, e := range s.entries {
+		dn := strings.ToLower(e.DN)
+		if (dn == base || strings.HasSuffix(dn, ","+base)) && matches(filter, e) {
+			found = append(found, e)
+		}
+	}
+	s.mu.Unlock()
+
+	code := success
+	if limit > 0 && len(found) > limit {
+		found, code = found[:limit], sizeLimitExceeded
+	}
+	for _, e := range found {
+		attrs := seq(0x30)
+		for name, values := range e.Attributes {
+			if len(want) > 0 && !contains(want, strings.ToLower(name)) {
+				continue
+			}
+			vals := seq(0x31)
+			for _, v := range values {
+				vals.children = append(vals.children, str(0x04, v))
+			}
+			attrs.children = append(attrs.children, seq(0x30, str(0x04, name), vals))
+		}
+		reply(0x64, str(0x04, e.DN), attrs)
+	}
+	reply(0x65, result(code, "")...)
+}
+
+// matches evaluates a filter (RFC 4511 §4.5.1.7) with case-insensitive
+// string matching.
+func matches(f *element, e *Entry) bool {
+	values := func(attr string) []string {
+		for name, v := range e.Attributes {
+			if strings.EqualFold(name, attr) {
+				return v
+			}
+		}
+		return nil
+	}
+	switch f.tag {
+	case 0xa0: // and
+		for _, c := range f.children {
+			if !matches(c, e) {
+				return false
+			}
+		}
+		return true
+	case 0xa1: // or
+		for _, c := range f.children {
+			if matches(c, e) {
+				return true
+			}
+		}
+		return false
+	case 0xa2: // not
+		return len(f.children) == 1 && !matches(f.children[0], e)
+	case 0x87: // present
+		return len(values(string(f.value))) > 0
+	case 0xa3, 0xa5, 0xa6: // equality, greaterOrEqual, lessOrEqual
+		if len(f.children) != 2 {
+			return false
+		}
+		want := strings.ToLower(string(f.children[1].value))
+		for _, v := range values(string(f.children[0].value)) {
+			v = strings.ToLower(v)
+			if (f.tag == 0xa3 && v == want) || (f.tag == 0xa5 && v >= want) || (f.tag == 0xa6 && v <= want) {
+				return true
+			}
+		}
+		return false
+	case 0xa4: // substrings
+		if len(f.children) != 2 {
+			return false
+		}
+		for _, v := range values(string(f.children[0].value)) {
+			if substringMatch(strings.ToLower(v), f.children[1].children) {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
+
+func substringMatch(v string, parts []*element) bool {
+	for _, p := range parts {
+		sub := strings.ToLower(string(p.value))
+		switch p.tag {
+		case 0x80: // initial
+			if !strings.HasPrefix(v, sub) {
+				return false
+			}
+			v = v[len(sub):]
+		case 0x81: // any
+			i := strings.Index(v, sub)
+			if i < 0 {
+				return false
+			}
+			v = v[i+len(sub):]
+		case 0x82: // final
+			if !strings.HasSuffix(v, sub) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// --- a little BER ---
+
+type element struct {
+	tag      byte
+	value    []byte
+	children []*element
+}
+
+func seq(tag byte, children ...*element) *element { return &element{tag: tag, children: children} }
+func str(tag byte, s string) *element             { return &element{tag: tag, value: []byte(s)} }
+
+func result(code int, msg string) []*element {
+	return []*element{{tag: 0x0a, value: []byte{byte(code)}}, str(0x04, ""), str(0x04, msg)}
+}
+
+func (e *element) encode() []byte {
+	content := e.value
+	if e.tag&0x20 != 0 {
+		content = nil
+		for _, c := range e.children {
+			content = append(content, c.encode()...)
+		}
+	}
+	out := []byte{e.tag}
+	if n := len(content); n < 0x80 {
+		out = append(out, byte(n))
+	} else {
+		var l []byte
+		for ; n > 0; n >>= 8 {
+			l = append([]byte{byte(n)}, l...)
+		}
+		out = append(append(out, 0x80|byte(len(l))), l...)
+	}
+	return append(out, content...)
+}
+
+func read(r *bufio.Reader) (*element, error) {
+	tag, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	b, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	n := int(b)
+	if b >= 0x80 {
+		if b&0x7f > 4 {
+			return nil, errors.New("ldaptest: length too long")
+		}
+		n = 0
+		for i := 0; i < int(b&0x7f); i++ {
+			c, err := r.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			n = n<<8 | int(c)
+		}
+	}
+	content := make([]byte, n)
+	if _, err := io.ReadFull(r, content); err != nil {
+		return nil, err
+	}
+	return parse(tag, content)
+}
+
+func parse(tag byte, content []byte) (*element, error) {
+	e := &element{tag: tag}
+	if tag&0x20 == 0 {
+		e.value = content
+		return e, nil
+	}
+	br := bufio.NewReader(strings.NewReader(string(content)))
+	for {
+		c, err := read(br)
+		if err == io.EOF {
+			return e, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		e.children = append(e.children, c)
+	}
+}
+
+func toInt(b []byte) int64 {
+	var n int64
+	for _, c := range b {
+		n = n<<8 | int64(c)
+	}
+	return n
+}
+
+// selfSigned makes a certificate for 127.0.0.1 and a client configuration
+// that trusts it.
+func selfSigned() (server, client *tls.Config) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ldaptest"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		panic(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		panic(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}},
+		&tls.Config{RootCAs: pool}
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"log"
+
+	"github.com/gin-gonic/gin"
+
+	"user-management-api/internal/model"
+	"user-management-api/internal/service"
+)
+
+// AuditImpersonation logs every request made with an impersonation token
+// and records it in the audit log, once the request is done. Install it on
+// the engine; it picks up the principal JWTAuth stores further down the
+// chain.
+func AuditImpersonation(audit *service.AuditService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		v, ok := c.Get(PrincipalKey)
+		if !ok {
+			return
+		}
+		p := v.(*model.Principal)
+		if !p.Impersonated() {
+			return
+		}
+		status := c.Writer.Status()
+		log.Printf("impersonation: user %s acting as %s: %s %s -> %d",
+			p.ActorID, p.UserID, c.Request.Method, c.Request.URL.Path, status)
+		err := audit.Record(c.Request.Context(), &model.AuditEvent{
+			Action:  model.AuditImpersonatedRequest,
+			ActorID: p.ActorID,
+			UserID:  p.UserID,
+			Method:  c.Request.Method,
+			Path:    c.Request.URL.Path,
+			Status:  status,
+		})
+		if err != nil {
+			log.Printf("audit impersonated request: %v", err)
+		}
+	}
+}
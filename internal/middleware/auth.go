@@ -1,23 +1,42 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
-	"github.com/google/uuid"
+
+	"user-management-api/internal/model"
+	"user-management-api/internal/service"
 )
 
-// UserIDKey is the gin context key under which the authenticated user's UUID is stored.
-const UserIDKey = "userID"
+const (
+	// UserIDKey is the gin context key under which the authenticated user's UUID is stored.
+	// When impersonating, that is the impersonated user; RealUserIDKey holds
+	// who is actually making the request.
+	UserIDKey     = "userID"
+	RealUserIDKey = "realUserID"
+	// PrincipalKey is the gin context key under which the full *model.Principal is stored.
+	PrincipalKey = "principal"
+	// ClientIDKey is set for OAuth clients, and ScopesKey for any token
+	// issued through OAuth. Scopes other than the OpenID Connect ones are
+	// permission names, so RequirePermission guards them as well.
+	ClientIDKey = "clientID"
+	ScopesKey   = "scopes"
+)
 
-// JWTAuth validates the Bearer token in the Authorization header.
-// On success it sets UserIDKey in the context and calls Next.
-func JWTAuth(secret string) gin.HandlerFunc {
+// JWTAuth validates the Bearer token in the Authorization header: a JWT
+// checked against the signing key named by its kid, including server-side
+// revocation, or a "pat_" personal access token. With cookie sessions on, a
+// request without the header may use the access cookie instead. On success
+// it sets UserIDKey and RealUserIDKey (or ClientIDKey for OAuth clients),
+// ScopesKey for OAuth tokens, and PrincipalKey in the context, scopes the
+// request to the caller's organization and calls Next.
+func JWTAuth(tokens *service.TokenService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		header := c.GetHeader("Authorization")
-		if !strings.HasPrefix(header, "Bearer ") {
+		tokenStr, ok := bearerToken(c)
+		if !ok {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
 				"error":   "unauthorized",
 				"message": "missing or invalid authorization header",
@@ -25,35 +44,59 @@ func JWTAuth(secret string) gin.HandlerFunc {
 			return
 		}
 
-		tokenStr := strings.TrimPrefix(header, "Bearer ")
-		token, err := jwt.Parse(tokenStr, func(t *jwt.Token) (any, error) {
-			if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, jwt.ErrSignatureInvalid
-			}
-			return []byte(secret), nil
-		})
-		if err != nil || !token.Valid {
+		p, err := tokens.Authenticate(c.Request.Context(), tokenStr)
+		switch {
+		case errors.Is(err, service.ErrTokenRevoked):
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error":   "unauthorized",
+				"message": "token has been revoked",
+			})
+			return
+		case errors.Is(err, service.ErrInvalidToken):
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
 				"error":   "unauthorized",
 				"message": "invalid or expired token",
 			})
 			return
-		}
-
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		case err != nil:
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal_error"})
 			return
 		}
 
-		sub, _ := claims["sub"].(string)
-		userID, err := uuid.Parse(sub)
-		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		if p.ClientID != "" {
+			c.Set(ClientIDKey, p.ClientID)
+		}
+		if p.Scopes != nil {
+			c.Set(ScopesKey, p.Scopes)
+		}
+		c.Set(UserIDKey, p.UserID)
+		if p.ClientID == "" {
+			c.Set(RealUserIDKey, p.RealUserID())
+		}
+		c.Set(PrincipalKey, p)
+		if !scopeToTenant(c, tokens, p) {
 			return
 		}
-
-		c.Set(UserIDKey, userID)
 		c.Next()
 	}
 }
+
+// bearerToken reads the token from the Authorization header or, failing
+// that, from the access cookie of a cookie session.
+func bearerToken(c *gin.Context) (string, bool) {
+	if header := c.GetHeader("Authorization"); header != "" {
+		return strings.CutPrefix(header, "Bearer ")
+	}
+	if cfg := Cookies(c); cfg != nil {
+		if v, err := c.Cookie(cfg.AccessName); err == nil && v != "" {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// CurrentPrincipal returns the principal stored by JWTAuth. It panics if the
+// route is not behind JWTAuth, mirroring c.MustGet.
+func CurrentPrincipal(c *gin.Context) *model.Principal {
+	return c.MustGet(PrincipalKey).(*model.Principal)
+}
@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"user-management-api/internal/reqctx"
+)
+
+// ClientInfo stores the client's IP and User-Agent in the request context for
+// services to read with reqctx.ClientFrom. The IP honours the engine's
+// trusted proxies.
+func ClientInfo() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := reqctx.WithClient(c.Request.Context(), reqctx.Client{
+			IP:        c.ClientIP(),
+			UserAgent: c.Request.UserAgent(),
+		})
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"user-management-api/internal/model"
+)
+
+// RequirePermission aborts with 403 unless the authenticated principal holds
+// perm. It must run after JWTAuth.
+func RequirePermission(perm string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !HasPermission(c, perm) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error":   "forbidden",
+				"message": "missing permission " + perm,
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// HasPermission reports whether the authenticated principal holds perm, for
+// handlers that combine a permission with an ownership check.
+func HasPermission(c *gin.Context, perm string) bool {
+	p, ok := c.Get(PrincipalKey)
+	return ok && p.(*model.Principal).Can(perm)
+}
+
+// IsSelf reports whether the caller is user id acting through a session
+// they signed in to, as RequireSession demands. Self-or-permission checks on
+// routes that change or remove an account use it: a personal access token
+// or a token delegated to another app is held to its scopes, so being the
+// account's owner is not enough.
+func IsSelf(c *gin.Context, id uuid.UUID) bool {
+	p := CurrentPrincipal(c)
+	return p.UserID == id && isSession(p) && !p.Impersonated()
+}
+
+// isSession reports whether p came from signing in here, rather than from a
+// token for scripts and clients.
+func isSession(p *model.Principal) bool {
+	return p.PersonalTokenID == uuid.Nil && p.ClientID == "" && p.AuthorizedParty == ""
+}
+
+// RequireSession aborts with 403 unless the caller is a user who signed in
+// here, rather than a personal access token, an OAuth client, a token
+// handed to another app through OpenID Connect or someone impersonating
+// the user. It guards routes that change how the account itself is secured,
+// which a leaked token must never reach.
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := CurrentPrincipal(c)
+		if !isSession(p) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error":   "session_required",
+				"message": "only a signed-in user can do this; tokens for scripts and clients cannot",
+			})
+			return
+		}
+		if p.Impersonated() {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error":   "session_required",
+				"message": "only the user themselves can do this, not someone impersonating them",
+			})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"user-management-api/internal/model"
+	"user-management-api/internal/repository"
+	"user-management-api/internal/reqctx"
+	"user-management-api/internal/service"
+)
+
+// OrganizationHeader names the organization, by slug or ID, that a request
+// is for.
+const OrganizationHeader = "X-Organization"
+
+// OrganizationKey is the gin context key under which the *model.Organization
+// named by OrganizationHeader is stored.
+const OrganizationKey = "organization"
+
+// Organization resolves OrganizationHeader, when present, and scopes the
+// request context to that organization. Unauthenticated routes such as
+// sign-in thereby act within it, though registration always signs up to the
+// default organization; JWTAuth later rescopes authenticated requests to the
+// caller's own organization.
+func Organization(orgs *service.OrganizationService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ref := c.GetHeader(OrganizationHeader)
+		if ref == "" {
+			c.Next()
+			return
+		}
+		o, err := orgs.Resolve(c.Request.Context(), ref)
+		switch {
+		case errors.Is(err, repository.ErrOrganizationNotFound):
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error":   "unknown_organization",
+				"message": "no organization matches the " + OrganizationHeader + " header",
+			})
+			return
+		case err != nil:
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal_error"})
+			return
+		}
+		c.Set(OrganizationKey, o)
+		c.Request = c.Request.WithContext(reqctx.WithTenant(c.Request.Context(), o.ID))
+		c.Next()
+	}
+}
+
+// scopeToTenant scopes an authenticated request to the caller's
+// organization, or to the one named by OrganizationHeader for callers who
+// manage organizations or are members of it. Members act with their role
+// there, so PrincipalKey is replaced. It reports false after aborting the
+// request.
+func scopeToTenant(c *gin.Context, tokens *service.TokenService, p *model.Principal) bool {
+	tenantID := p.TenantID
+	if v, ok := c.Get(OrganizationKey); ok {
+		o := v.(*model.Organization)
+		if o.ID != p.TenantID && !p.Can(model.PermOrgsWrite) {
+			member, err := tokens.InOrganization(c.Request.Context(), p, o.ID)
+			switch {
+			case errors.Is(err, service.ErrNotMember):
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+					"error":   "forbidden",
+					"message": "you are not a member of this organization",
+				})
+				return false
+			case err != nil:
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal_error"})
+				return false
+			}
+			c.Set(PrincipalKey, member)
+		}
+		tenantID = o.ID
+	}
+	c.Request = c.Request.WithContext(reqctx.WithTenant(c.Request.Context(), tenantID))
+	return true
+}
+
+// SameTenant answers 404 for /users/:id routes naming a user outside the
+// organization the request is scoped to, as if they didn't exist. It guards
+// the per-user data (sessions, tokens, identities) that isn't itself kept
+// per organization. It must run after JWTAuth.
+func SameTenant(users *service.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			// No user in the path, or a malformed ID for the handler to reject.
+			c.Next()
+			return
+		}
+		_, err = users.GetByID(c.Request.Context(), id)
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "not_found", "message": "user not found"})
+			return
+		case err != nil:
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal_error"})
+			return
+		}
+		c.Next()
+	}
+}
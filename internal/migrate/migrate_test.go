@@ -0,0 +1,165 @@
+package migrate_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	_ "modernc.org/sqlite"
+
+	"user-management-api/internal/migrate"
+)
+
+func openDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func testFS() fstest.MapFS {
+	return fstest.MapFS{
+		"0001_widgets.up.sql":    {Data: []byte(`CREATE TABLE widgets (id INTEGER PRIMARY KEY);`)},
+		"0001_widgets.down.sql":  {Data: []byte(`DROP TABLE widgets;`)},
+		"0002_add_name.up.sql":   {Data: []byte(`ALTER TABLE widgets ADD COLUMN name TEXT;`)},
+		"0002_add_name.down.sql": {Data: []byte(`ALTER TABLE widgets DROP COLUMN name;`)},
+		"README.md":              {Data: []byte(`ignored`)},
+	}
+}
+
+func tableExists(t *testing.T, db *sql.DB, name string) bool {
+	t.Helper()
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, name).Scan(&n); err != nil {
+		t.Fatalf("query sqlite_master: %v", err)
+	}
+	return n > 0
+}
+
+func TestEmbeddedMigrations_UpAndDownCleanly(t *testing.T) {
+	db := openDB(t)
+	m, err := migrate.New(db)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	ctx := context.Background()
+
+	ran, err := m.Up(ctx)
+	if err != nil {
+		t.Fatalf("up: %v", err)
+	}
+	if len(ran) == 0 {
+		t.Fatal("expected embedded migrations to run")
+	}
+	if _, err := m.Down(ctx, len(ran)); err != nil {
+		t.Fatalf("down: %v", err)
+	}
+	if tableExists(t, db, "users") {
+		t.Error("expected users table to be dropped")
+	}
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("up again: %v", err)
+	}
+}
+
+func TestUp_IsIdempotent(t *testing.T) {
+	db := openDB(t)
+	m, err := migrate.NewFromFS(db, testFS())
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	ctx := context.Background()
+
+	ran, err := m.Up(ctx)
+	if err != nil {
+		t.Fatalf("up: %v", err)
+	}
+	if len(ran) != 2 {
+		t.Fatalf("expected 2 migrations, got %d", len(ran))
+	}
+
+	ran, err = m.Up(ctx)
+	if err != nil {
+		t.Fatalf("second up: %v", err)
+	}
+	if len(ran) != 0 {
+		t.Errorf("expected nothing to run, got %d", len(ran))
+	}
+}
+
+func TestDown_RevertsInReverseOrder(t *testing.T) {
+	db := openDB(t)
+	m, _ := migrate.NewFromFS(db, testFS())
+	ctx := context.Background()
+
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+	reverted, err := m.Down(ctx, 1)
+	if err != nil {
+		t.Fatalf("down: %v", err)
+	}
+	if len(reverted) != 1 || reverted[0].Version != 2 {
+		t.Fatalf("expected to revert version 2, got %+v", reverted)
+	}
+
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if statuses[0].AppliedAt == nil || statuses[1].AppliedAt != nil {
+		t.Errorf("expected 0001 applied and 0002 pending, got %+v", statuses)
+	}
+	if !tableExists(t, db, "widgets") {
+		t.Error("expected widgets table to survive")
+	}
+}
+
+func TestUp_DetectsModifiedMigration(t *testing.T) {
+	db := openDB(t)
+	fsys := testFS()
+	m, _ := migrate.NewFromFS(db, fsys)
+	ctx := context.Background()
+
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+
+	fsys["0001_widgets.up.sql"] = &fstest.MapFile{Data: []byte(`CREATE TABLE widgets (id TEXT PRIMARY KEY);`)}
+	m, _ = migrate.NewFromFS(db, fsys)
+
+	if _, err := m.Up(ctx); !errors.Is(err, migrate.ErrChecksumMismatch) {
+		t.Errorf("expected ErrChecksumMismatch, got %v", err)
+	}
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if !statuses[0].Modified {
+		t.Error("expected status to flag 0001 as modified")
+	}
+}
+
+func TestUp_RollsBackFailedMigration(t *testing.T) {
+	db := openDB(t)
+	fsys := testFS()
+	fsys["0003_broken.up.sql"] = &fstest.MapFile{Data: []byte(`CREATE TABLE gadgets (id INTEGER); NOT VALID SQL;`)}
+	m, _ := migrate.NewFromFS(db, fsys)
+
+	ran, err := m.Up(context.Background())
+	if err == nil {
+		t.Fatal("expected an error from the broken migration")
+	}
+	if len(ran) != 2 {
+		t.Errorf("expected the two good migrations to be applied, got %d", len(ran))
+	}
+	if tableExists(t, db, "gadgets") {
+		t.Error("expected the failed migration to be rolled back")
+	}
+}
@@ -0,0 +1,7 @@
+CREATE TABLE revoked_tokens (
+    jti        TEXT PRIMARY KEY,
+    user_id    TEXT NOT NULL,
+    expires_at TEXT NOT NULL
+);
+
+ALTER TABLE users ADD COLUMN tokens_valid_after TEXT;
@@ -0,0 +1,30 @@
+CREATE TABLE roles (
+    name        TEXT PRIMARY KEY,
+    description TEXT NOT NULL
+);
+
+CREATE TABLE role_permissions (
+    role       TEXT NOT NULL REFERENCES roles(name),
+    permission TEXT NOT NULL,
+    PRIMARY KEY (role, permission)
+);
+
+CREATE TABLE user_roles (
+    user_id TEXT NOT NULL,
+    role    TEXT NOT NULL REFERENCES roles(name),
+    PRIMARY KEY (user_id, role)
+);
+
+INSERT INTO roles (name, description) VALUES
+    ('admin', 'Full access to all users and roles'),
+    ('user',  'Regular account; may read users and edit itself');
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('admin', 'users:read'),
+    ('admin', 'users:write'),
+    ('admin', 'roles:write'),
+    ('user',  'users:read');
+
+-- Accounts created before roles existed become regular users.
+INSERT INTO user_roles (user_id, role)
+SELECT id, 'user' FROM users;
@@ -0,0 +1,3 @@
+DELETE FROM role_permissions WHERE permission = 'users:delete';
+
+ALTER TABLE users DROP COLUMN deleted_at;
@@ -0,0 +1,3 @@
+ALTER TABLE users ADD COLUMN deleted_at TEXT;
+
+INSERT INTO role_permissions (role, permission) VALUES ('admin', 'users:delete');
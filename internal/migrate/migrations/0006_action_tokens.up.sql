@@ -0,0 +1,14 @@
+-- Single-use tokens mailed to users (password reset, and later other
+-- email-confirmed actions). Only the SHA-256 of the token is stored.
+CREATE TABLE action_tokens (
+    id         TEXT PRIMARY KEY,
+    user_id    TEXT NOT NULL,
+    purpose    TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    email      TEXT NOT NULL,
+    expires_at TEXT NOT NULL,
+    created_at TEXT NOT NULL,
+    used_at    TEXT
+);
+
+CREATE INDEX idx_action_tokens_user_purpose ON action_tokens(user_id, purpose);
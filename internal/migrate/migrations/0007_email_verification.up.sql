@@ -0,0 +1,4 @@
+-- Existing accounts start unverified. Enabling REQUIRE_VERIFIED_EMAIL on an
+-- existing install means those users verify through /auth/email/resend.
+ALTER TABLE users ADD COLUMN email_verified_at TEXT;
+ALTER TABLE users ADD COLUMN pending_email TEXT;
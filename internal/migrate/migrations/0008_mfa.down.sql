@@ -0,0 +1,3 @@
+ALTER TABLE refresh_tokens DROP COLUMN amr;
+DROP TABLE recovery_codes;
+DROP TABLE totp_factors;
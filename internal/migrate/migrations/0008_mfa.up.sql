@@ -0,0 +1,23 @@
+-- confirmed_at stays NULL until the user proves the authenticator works.
+-- last_step is the most recent accepted time step, so a code can't be replayed.
+CREATE TABLE totp_factors (
+    user_id      TEXT PRIMARY KEY,
+    secret       TEXT NOT NULL,
+    confirmed_at TEXT,
+    last_step    INTEGER NOT NULL DEFAULT 0,
+    created_at   TEXT NOT NULL
+);
+
+CREATE TABLE recovery_codes (
+    id         TEXT PRIMARY KEY,
+    user_id    TEXT NOT NULL,
+    code_hash  TEXT NOT NULL,
+    created_at TEXT NOT NULL,
+    used_at    TEXT
+);
+
+CREATE INDEX idx_recovery_codes_user ON recovery_codes(user_id);
+
+-- Authentication methods (RFC 8176) of the sign-in that started each refresh
+-- family, carried into every access token minted from it.
+ALTER TABLE refresh_tokens ADD COLUMN amr TEXT NOT NULL DEFAULT 'pwd';
@@ -0,0 +1,15 @@
+-- Passkeys. credential_id is the authenticator's ID, base64url-encoded;
+-- public_key is the COSE_Key it returned at registration.
+CREATE TABLE webauthn_credentials (
+    id            TEXT PRIMARY KEY,
+    user_id       TEXT NOT NULL,
+    credential_id TEXT NOT NULL UNIQUE,
+    public_key    BLOB NOT NULL,
+    sign_count    INTEGER NOT NULL DEFAULT 0,
+    aaguid        TEXT NOT NULL,
+    name          TEXT NOT NULL,
+    created_at    TEXT NOT NULL,
+    last_used_at  TEXT
+);
+
+CREATE INDEX idx_webauthn_credentials_user ON webauthn_credentials(user_id);
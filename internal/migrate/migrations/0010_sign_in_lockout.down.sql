@@ -0,0 +1,3 @@
+DROP TABLE ip_sign_in_failures;
+ALTER TABLE users DROP COLUMN locked_until;
+ALTER TABLE users DROP COLUMN failed_sign_ins;
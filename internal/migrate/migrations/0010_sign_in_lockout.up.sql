@@ -0,0 +1,13 @@
+-- failed_sign_ins counts consecutive bad passwords; a successful sign-in or an
+-- admin unlock resets it. locked_until blocks sign-in until it passes.
+ALTER TABLE users ADD COLUMN failed_sign_ins INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN locked_until TEXT;
+
+-- Failed sign-ins per client IP within a sliding window, whatever account
+-- they targeted. Rows are keyed by IP, not user, so purging a user leaves them.
+CREATE TABLE ip_sign_in_failures (
+    ip             TEXT PRIMARY KEY,
+    failures       INTEGER NOT NULL,
+    last_failed_at TEXT NOT NULL,
+    blocked_until  TEXT
+);
@@ -0,0 +1,15 @@
+-- scopes is a space-separated list of permissions. A NULL expires_at never
+-- expires; last_used_* is refreshed at most once a minute.
+CREATE TABLE personal_access_tokens (
+    id           TEXT PRIMARY KEY,
+    user_id      TEXT NOT NULL,
+    name         TEXT NOT NULL,
+    token_hash   TEXT NOT NULL UNIQUE,
+    scopes       TEXT NOT NULL,
+    expires_at   TEXT,
+    created_at   TEXT NOT NULL,
+    last_used_at TEXT,
+    last_used_ip TEXT
+);
+
+CREATE INDEX idx_personal_access_tokens_user ON personal_access_tokens(user_id);
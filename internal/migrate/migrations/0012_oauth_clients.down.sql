@@ -0,0 +1,3 @@
+DELETE FROM role_permissions WHERE permission = 'clients:write';
+
+DROP TABLE oauth_clients;
@@ -0,0 +1,16 @@
+-- OAuth2 clients for the client_credentials grant. client_id is public; only
+-- the SHA-256 of the secret is kept. scopes is a space-separated list of
+-- permissions. Access tokens issued before tokens_valid_after are rejected,
+-- which is how a secret rotation retires them.
+CREATE TABLE oauth_clients (
+    id                 TEXT PRIMARY KEY,
+    client_id          TEXT NOT NULL UNIQUE,
+    name               TEXT NOT NULL,
+    secret_hash        TEXT NOT NULL,
+    scopes             TEXT NOT NULL,
+    created_at         TEXT NOT NULL,
+    updated_at         TEXT NOT NULL,
+    tokens_valid_after TEXT
+);
+
+INSERT INTO role_permissions (role, permission) VALUES ('admin', 'clients:write');
@@ -0,0 +1,5 @@
+DROP TABLE oauth_consents;
+DROP TABLE oauth_codes;
+
+ALTER TABLE oauth_clients DROP COLUMN first_party;
+ALTER TABLE oauth_clients DROP COLUMN redirect_uris;
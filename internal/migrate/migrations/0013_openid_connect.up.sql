@@ -0,0 +1,29 @@
+-- OpenID Connect: clients that send users through /oauth/authorize register
+-- their redirect URIs (space-separated). First-party clients skip consent.
+ALTER TABLE oauth_clients ADD COLUMN redirect_uris TEXT NOT NULL DEFAULT '';
+ALTER TABLE oauth_clients ADD COLUMN first_party INTEGER NOT NULL DEFAULT 0;
+
+-- Authorization codes live for a minute and work once. Only the SHA-256 of
+-- the code is stored, alongside everything needed to answer the exchange.
+CREATE TABLE oauth_codes (
+    code_hash      TEXT PRIMARY KEY,
+    client_id      TEXT NOT NULL,
+    user_id        TEXT NOT NULL,
+    redirect_uri   TEXT NOT NULL,
+    scopes         TEXT NOT NULL,
+    nonce          TEXT NOT NULL,
+    code_challenge TEXT NOT NULL,
+    amr            TEXT NOT NULL,
+    auth_time      TEXT NOT NULL,
+    expires_at     TEXT NOT NULL,
+    used_at        TEXT
+);
+
+-- Scopes a user has agreed to share with a client, so they're asked once.
+CREATE TABLE oauth_consents (
+    user_id    TEXT NOT NULL,
+    client_id  TEXT NOT NULL,
+    scopes     TEXT NOT NULL,
+    granted_at TEXT NOT NULL,
+    PRIMARY KEY (user_id, client_id)
+);
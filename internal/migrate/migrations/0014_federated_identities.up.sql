@@ -0,0 +1,34 @@
+-- External accounts at upstream OpenID Connect providers, linked to users.
+-- A provider's subject identifies the same person for as long as the
+-- provider exists; email is only a hint, kept as it was last seen.
+CREATE TABLE user_identities (
+    id           TEXT PRIMARY KEY,
+    user_id      TEXT NOT NULL,
+    provider     TEXT NOT NULL,
+    subject      TEXT NOT NULL,
+    email        TEXT NOT NULL,
+    created_at   TEXT NOT NULL,
+    last_used_at TEXT,
+    UNIQUE (provider, subject)
+);
+
+CREATE INDEX idx_user_identities_user ON user_identities(user_id);
+
+-- Sign-ins in flight at an upstream provider. A row starts with the state,
+-- nonce and PKCE verifier sent to the provider; the callback fills in who
+-- signed in and the hash of a single-use ticket the front end redeems.
+CREATE TABLE federated_logins (
+    id             TEXT PRIMARY KEY,
+    state_hash     TEXT NOT NULL UNIQUE,
+    provider       TEXT NOT NULL,
+    nonce          TEXT NOT NULL,
+    code_verifier  TEXT NOT NULL,
+    client_state   TEXT NOT NULL,
+    expires_at     TEXT NOT NULL,
+    ticket_hash    TEXT UNIQUE,
+    subject        TEXT NOT NULL DEFAULT '',
+    email          TEXT NOT NULL DEFAULT '',
+    email_verified INTEGER NOT NULL DEFAULT 0,
+    name           TEXT NOT NULL DEFAULT '',
+    used_at        TEXT
+);
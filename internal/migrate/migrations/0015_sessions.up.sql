@@ -0,0 +1,17 @@
+-- One row per sign-in, shared by every token of its refresh token family:
+-- id is the family_id, and access tokens carry it as their sid claim.
+-- ip and last_seen_at are refreshed at most once a minute; expires_at moves
+-- with each refresh. Revoked rows are kept until the next purge, and a sid
+-- with no row is treated as revoked.
+CREATE TABLE sessions (
+    id           TEXT PRIMARY KEY,
+    user_id      TEXT NOT NULL,
+    user_agent   TEXT NOT NULL DEFAULT '',
+    ip           TEXT NOT NULL DEFAULT '',
+    created_at   TEXT NOT NULL,
+    last_seen_at TEXT NOT NULL,
+    expires_at   TEXT NOT NULL,
+    revoked_at   TEXT
+);
+
+CREATE INDEX idx_sessions_user ON sessions(user_id);
@@ -0,0 +1,3 @@
+DELETE FROM role_permissions WHERE permission IN ('users:impersonate', 'audit:read');
+
+DROP TABLE audit_events;
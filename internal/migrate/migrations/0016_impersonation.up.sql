@@ -0,0 +1,21 @@
+-- Security-relevant events, kept when the users involved are purged.
+-- actor_id is who acted; user_id is the account acted as or on. method,
+-- path and status describe the request for request-level events.
+CREATE TABLE audit_events (
+    id          TEXT PRIMARY KEY,
+    occurred_at TEXT NOT NULL,
+    action      TEXT NOT NULL,
+    actor_id    TEXT NOT NULL,
+    user_id     TEXT NOT NULL,
+    method      TEXT NOT NULL DEFAULT '',
+    path        TEXT NOT NULL DEFAULT '',
+    status      INTEGER NOT NULL DEFAULT 0,
+    ip          TEXT NOT NULL DEFAULT '',
+    user_agent  TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX idx_audit_events_actor ON audit_events(actor_id, occurred_at);
+CREATE INDEX idx_audit_events_user ON audit_events(user_id, occurred_at);
+
+INSERT INTO role_permissions (role, permission) VALUES ('admin', 'users:impersonate');
+INSERT INTO role_permissions (role, permission) VALUES ('admin', 'audit:read');
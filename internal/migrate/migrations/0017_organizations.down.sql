@@ -0,0 +1,31 @@
+DELETE FROM role_permissions WHERE permission = 'orgs:write';
+
+ALTER TABLE audit_events DROP COLUMN tenant_id;
+ALTER TABLE oauth_clients DROP COLUMN tenant_id;
+
+-- Fails if the same address is in use in more than one organization.
+CREATE TABLE users_old (
+    id                 TEXT PRIMARY KEY,
+    name               TEXT NOT NULL,
+    email              TEXT NOT NULL UNIQUE,
+    password_hash      TEXT NOT NULL,
+    created_at         TEXT NOT NULL,
+    updated_at         TEXT NOT NULL,
+    tokens_valid_after TEXT,
+    deleted_at         TEXT,
+    email_verified_at  TEXT,
+    pending_email      TEXT,
+    failed_sign_ins    INTEGER NOT NULL DEFAULT 0,
+    locked_until       TEXT
+);
+
+INSERT INTO users_old (id, name, email, password_hash, created_at, updated_at, tokens_valid_after,
+    deleted_at, email_verified_at, pending_email, failed_sign_ins, locked_until)
+SELECT id, name, email, password_hash, created_at, updated_at, tokens_valid_after,
+    deleted_at, email_verified_at, pending_email, failed_sign_ins, locked_until
+FROM users;
+
+DROP TABLE users;
+ALTER TABLE users_old RENAME TO users;
+
+DROP TABLE organizations;
@@ -0,0 +1,51 @@
+-- Organizations (tenants). Every user belongs to exactly one, and their
+-- roles apply within it. Existing data moves into the default organization,
+-- whose ID is fixed so the code can name it.
+CREATE TABLE organizations (
+    id         TEXT PRIMARY KEY,
+    name       TEXT NOT NULL,
+    slug       TEXT NOT NULL UNIQUE,
+    created_at TEXT NOT NULL
+);
+
+INSERT INTO organizations (id, name, slug, created_at)
+VALUES ('00000000-0000-0000-0000-000000000001', 'Default', 'default', strftime('%Y-%m-%dT%H:%M:%SZ', 'now'));
+
+-- users is rebuilt to drop the table-wide UNIQUE on email. Uniqueness is
+-- now per email_scope: '' for every user when addresses are unique across
+-- organizations, or the user's tenant_id when they are unique per
+-- organization (EMAIL_UNIQUENESS).
+CREATE TABLE users_new (
+    id                 TEXT PRIMARY KEY,
+    tenant_id          TEXT NOT NULL REFERENCES organizations(id),
+    name               TEXT NOT NULL,
+    email              TEXT NOT NULL,
+    email_scope        TEXT NOT NULL DEFAULT '',
+    password_hash      TEXT NOT NULL,
+    created_at         TEXT NOT NULL,
+    updated_at         TEXT NOT NULL,
+    tokens_valid_after TEXT,
+    deleted_at         TEXT,
+    email_verified_at  TEXT,
+    pending_email      TEXT,
+    failed_sign_ins    INTEGER NOT NULL DEFAULT 0,
+    locked_until       TEXT,
+    UNIQUE (email_scope, email)
+);
+
+INSERT INTO users_new (id, tenant_id, name, email, password_hash, created_at, updated_at, tokens_valid_after,
+    deleted_at, email_verified_at, pending_email, failed_sign_ins, locked_until)
+SELECT id, '00000000-0000-0000-0000-000000000001', name, email, password_hash, created_at, updated_at, tokens_valid_after,
+    deleted_at, email_verified_at, pending_email, failed_sign_ins, locked_until
+FROM users;
+
+DROP TABLE users;
+ALTER TABLE users_new RENAME TO users;
+
+CREATE INDEX idx_users_tenant ON users(tenant_id, created_at);
+
+ALTER TABLE oauth_clients ADD COLUMN tenant_id TEXT NOT NULL DEFAULT '00000000-0000-0000-0000-000000000001';
+ALTER TABLE audit_events ADD COLUMN tenant_id TEXT NOT NULL DEFAULT '00000000-0000-0000-0000-000000000001';
+
+-- Only takes effect for members of the default organization.
+INSERT INTO role_permissions (role, permission) VALUES ('admin', 'orgs:write');
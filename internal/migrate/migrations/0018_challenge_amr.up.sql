@@ -0,0 +1,4 @@
+-- The authentication methods of the first factor behind an MFA challenge,
+-- space-separated, so the session it completes records how the user really
+-- signed in. Other action tokens leave it empty.
+ALTER TABLE action_tokens ADD COLUMN amr TEXT NOT NULL DEFAULT '';
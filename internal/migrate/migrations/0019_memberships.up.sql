@@ -0,0 +1,12 @@
+-- Memberships let users act in organizations other than their own, with a
+-- role that applies only there. A user's own organization is still
+-- users.tenant_id, where user_roles applies.
+CREATE TABLE memberships (
+    org_id     TEXT NOT NULL REFERENCES organizations(id),
+    user_id    TEXT NOT NULL REFERENCES users(id),
+    role       TEXT NOT NULL REFERENCES roles(name),
+    created_at TEXT NOT NULL,
+    PRIMARY KEY (org_id, user_id)
+);
+
+CREATE INDEX idx_memberships_user ON memberships(user_id);
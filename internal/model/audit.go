@@ -0,0 +1,50 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Audit actions.
+const (
+	AuditImpersonationStart = "impersonation.start"
+	// AuditImpersonatedRequest records a request made with an
+	// impersonation token.
+	AuditImpersonatedRequest = "impersonation.request"
+)
+
+// AuditEvent is an entry in the audit log. ActorID is who acted and UserID
+// the account they acted as or on.
+type AuditEvent struct {
+	ID         uuid.UUID `json:"id"`
+	TenantID   uuid.UUID `json:"tenant_id"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Action     string    `json:"action"`
+	ActorID    uuid.UUID `json:"actor_id"`
+	UserID     uuid.UUID `json:"user_id"`
+	Method     string    `json:"method,omitempty"`
+	Path       string    `json:"path,omitempty"`
+	Status     int       `json:"status,omitempty"`
+	IP         string    `json:"ip,omitempty"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+}
+
+// --- request / response DTOs ---
+
+// ListAuditQuery filters the audit log, newest first.
+type ListAuditQuery struct {
+	ActorID string `form:"actor_id" validate:"omitempty,uuid"`
+	UserID  string `form:"user_id"  validate:"omitempty,uuid"`
+	Action  string `form:"action"`
+	Limit   int    `form:"limit"`
+	Offset  int    `form:"offset"`
+}
+
+// ImpersonationResponse carries an access token for acting as User. It has
+// no refresh token; the actor starts again once it expires.
+type ImpersonationResponse struct {
+	Token     string `json:"token"`
+	ExpiresIn int64  `json:"expires_in"`
+	User      *User  `json:"user"`
+}
@@ -0,0 +1,55 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// IdentityProvider is an upstream OpenID Connect provider users can sign in
+// with, as listed on the login page.
+type IdentityProvider struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// UserIdentity links a user to their account at an upstream provider.
+type UserIdentity struct {
+	ID       uuid.UUID `json:"id"`
+	UserID   uuid.UUID `json:"-"`
+	Provider string    `json:"provider"`
+	// Subject is the provider's stable ID for the account.
+	Subject    string     `json:"subject"`
+	Email      string     `json:"email"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+// FederatedLogin is a sign-in at an upstream provider, from the redirect
+// until the front end redeems its ticket. The identity fields are set by
+// the callback.
+type FederatedLogin struct {
+	ID           uuid.UUID
+	StateHash    string
+	Provider     string
+	Nonce        string
+	CodeVerifier string
+	// ClientState is the front end's own state, handed back to it unchanged.
+	ClientState string
+	ExpiresAt   time.Time
+
+	TicketHash    string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	UsedAt        *time.Time
+}
+
+// --- request DTOs ---
+
+// FederatedTicketRequest redeems the ticket the callback handed the front
+// end, to sign in or to link the identity to the caller's account.
+type FederatedTicketRequest struct {
+	Ticket string `json:"ticket" validate:"required"`
+}
@@ -0,0 +1,69 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Authentication method references (RFC 8176) recorded in the amr claim.
+const (
+	AMRPassword = "pwd"
+	AMROTP      = "otp"
+	// AMRHardwareKey is a passkey. Passkey sign-ins also carry AMRMultiFactor
+	// because the authenticator verified the user with a PIN or biometric.
+	AMRHardwareKey = "hwk"
+	AMRMultiFactor = "mfa"
+	// AMRFederated is a sign-in at an upstream identity provider. It isn't
+	// registered in RFC 8176 but is widely used, and counts as one factor.
+	AMRFederated = "fed"
+	// AMREmail is a sign-in through a link mailed to the user. Like
+	// AMRFederated it is unregistered and counts as one factor.
+	AMREmail = "email"
+)
+
+// MultiFactor reports whether amr records more than one authentication factor.
+func MultiFactor(amr []string) bool {
+	return len(amr) > 1
+}
+
+// TOTPFactor is a user's authenticator app. It only counts towards sign-in
+// once ConfirmedAt is set.
+type TOTPFactor struct {
+	UserID      uuid.UUID
+	Secret      string
+	ConfirmedAt *time.Time
+	LastStep    int64
+	CreatedAt   time.Time
+}
+
+// --- request DTOs ---
+
+// MFACodeRequest carries a TOTP or recovery code to re-confirm a sensitive MFA change.
+type MFACodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+// MFAVerifyRequest completes a sign-in that returned mfa_required.
+type MFAVerifyRequest struct {
+	MFAToken string `json:"mfa_token" validate:"required"`
+	Code     string `json:"code"      validate:"required"`
+}
+
+// --- response DTOs ---
+
+type MFAStatus struct {
+	TOTPEnabled            bool `json:"totp_enabled"`
+	RecoveryCodesRemaining int  `json:"recovery_codes_remaining"`
+}
+
+// TOTPEnrollment is shown once so the user can add the secret to an authenticator app.
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+// RecoveryCodes are shown once; only their hashes are stored.
+type RecoveryCodes struct {
+	Codes []string `json:"recovery_codes"`
+}
@@ -0,0 +1,119 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OAuth2 grants the token endpoint supports.
+const (
+	GrantClientCredentials = "client_credentials"
+	GrantAuthorizationCode = "authorization_code"
+)
+
+// OpenID Connect scopes. They select ID token and userinfo claims rather than
+// permissions, and every client with a redirect URI may ask for them.
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+)
+
+// OAuthClient is a service that authenticates as itself with the
+// client_credentials grant, or signs users in through OpenID Connect when it
+// has redirect URIs. Only a hash of the secret is stored.
+type OAuthClient struct {
+	ID         uuid.UUID `json:"id"`
+	TenantID   uuid.UUID `json:"tenant_id"`
+	ClientID   string    `json:"client_id"`
+	Name       string    `json:"name"`
+	SecretHash string    `json:"-"`
+	// Scopes are the permissions the client may request; narrowing them also
+	// narrows tokens already issued.
+	Scopes       []string `json:"scopes"`
+	RedirectURIs []string `json:"redirect_uris"`
+	// FirstParty clients are our own apps; users aren't asked for consent.
+	FirstParty bool      `json:"first_party"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	// TokensValidAfter is moved forward when the secret is rotated.
+	TokensValidAfter *time.Time `json:"-"`
+}
+
+// --- request / response DTOs ---
+
+type CreateOAuthClientRequest struct {
+	Name         string   `json:"name"          validate:"required,max=100"`
+	Scopes       []string `json:"scopes"        validate:"dive,required"`
+	RedirectURIs []string `json:"redirect_uris" validate:"dive,url"`
+	FirstParty   bool     `json:"first_party"`
+}
+
+// UpdateOAuthClientRequest changes only the fields that are set.
+type UpdateOAuthClientRequest struct {
+	Name         string   `json:"name"          validate:"omitempty,max=100"`
+	Scopes       []string `json:"scopes"        validate:"omitempty,min=1,dive,required"`
+	RedirectURIs []string `json:"redirect_uris" validate:"omitempty,min=1,dive,url"`
+	FirstParty   *bool    `json:"first_party"`
+}
+
+// OAuthClientCreated carries the client secret; it is returned on creation
+// and rotation only.
+type OAuthClientCreated struct {
+	*OAuthClient
+	ClientSecret string `json:"client_secret"`
+}
+
+// OAuthTokenResponse is the RFC 6749 §5.1 access token response. It is sent
+// as is, outside the usual data envelope.
+type OAuthTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+	Scope       string `json:"scope"`
+	IDToken     string `json:"id_token,omitempty"`
+}
+
+// AuthorizationCode is issued by /oauth/authorize and exchanged once at the
+// token endpoint. It remembers how the user signed in, for the ID token.
+type AuthorizationCode struct {
+	CodeHash      string
+	ClientID      string
+	UserID        uuid.UUID
+	RedirectURI   string
+	Scopes        []string
+	Nonce         string
+	CodeChallenge string // S256
+	AMR           []string
+	AuthTime      time.Time
+	ExpiresAt     time.Time
+	UsedAt        *time.Time
+}
+
+// AuthorizeRequest carries the OpenID Connect authentication request
+// parameters. The login page receives them in the query string and posts
+// them back with the user's token and, once asked, their decision.
+type AuthorizeRequest struct {
+	ResponseType        string `form:"response_type"         json:"response_type"`
+	ClientID            string `form:"client_id"             json:"client_id"`
+	RedirectURI         string `form:"redirect_uri"          json:"redirect_uri"`
+	Scope               string `form:"scope"                 json:"scope"`
+	State               string `form:"state"                 json:"state"`
+	Nonce               string `form:"nonce"                 json:"nonce"`
+	CodeChallenge       string `form:"code_challenge"        json:"code_challenge"`
+	CodeChallengeMethod string `form:"code_challenge_method" json:"code_challenge_method"`
+	// Prompt is "none" or "consent"; see OpenID Connect Core §3.1.2.1.
+	Prompt string `form:"prompt" json:"prompt"`
+	// Decision is "allow" or "deny" from the consent screen.
+	Decision string `form:"-" json:"decision" validate:"omitempty,oneof=allow deny"`
+}
+
+// AuthorizeResponse either sends the browser on (RedirectTo) or asks the
+// login page to show a consent screen for Scopes.
+type AuthorizeResponse struct {
+	RedirectTo      string   `json:"redirect_to,omitempty"`
+	ConsentRequired bool     `json:"consent_required,omitempty"`
+	ClientName      string   `json:"client_name,omitempty"`
+	Scopes          []string `json:"scopes,omitempty"`
+}
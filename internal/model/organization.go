@@ -0,0 +1,58 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultTenantID is the organization seeded by the schema migration. Users
+// who sign up without naming an organization join it, and only its members
+// can hold platform permissions such as PermOrgsWrite.
+var DefaultTenantID = uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+// Organization is a tenant: a customer whose users are kept apart from
+// every other organization's.
+type Organization struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	Slug      string    `json:"slug"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Membership lets a user act in an organization other than their own, with
+// Role in place of the roles they hold at home.
+type Membership struct {
+	OrganizationID uuid.UUID `json:"organization_id"`
+	UserID         uuid.UUID `json:"user_id"`
+	Role           string    `json:"role"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// --- request DTOs ---
+
+// CreateOrganizationRequest names a new organization. Slug is how clients
+// refer to it in the X-Organization header.
+type CreateOrganizationRequest struct {
+	Name string `json:"name" validate:"required,max=100"`
+	Slug string `json:"slug" validate:"required,min=2,max=63"`
+}
+
+type ListOrganizationsQuery struct {
+	Limit  int `form:"limit"`
+	Offset int `form:"offset"`
+}
+
+type AddMemberRequest struct {
+	UserID uuid.UUID `json:"user_id" validate:"required"`
+	Role   string    `json:"role" validate:"required"`
+}
+
+type SetMemberRoleRequest struct {
+	Role string `json:"role" validate:"required"`
+}
+
+type ListMembersQuery struct {
+	Limit  int `form:"limit"`
+	Offset int `form:"offset"`
+}
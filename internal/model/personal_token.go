@@ -0,0 +1,43 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PersonalTokenPrefix starts every personal access token, so the auth
+// middleware can tell them from JWTs and secret scanners can spot them.
+const PersonalTokenPrefix = "pat_"
+
+// PersonalAccessToken is a long-lived credential a user mints for scripts
+// and CI. Only a hash of the token itself is stored.
+type PersonalAccessToken struct {
+	ID        uuid.UUID `json:"id"`
+	UserID    uuid.UUID `json:"-"`
+	Name      string    `json:"name"`
+	TokenHash string    `json:"-"`
+	// Scopes caps the token's permissions; the owner's own permissions
+	// still apply on top.
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP string     `json:"last_used_ip,omitempty"`
+}
+
+// --- request / response DTOs ---
+
+type CreatePersonalTokenRequest struct {
+	Name string `json:"name" validate:"required,max=100"`
+	// Scopes defaults to every permission the caller holds.
+	Scopes []string `json:"scopes" validate:"dive,required"`
+	// ExpiresAt is optional; without it the token lasts until revoked.
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// PersonalTokenCreated is the only response that carries the token itself.
+type PersonalTokenCreated struct {
+	*PersonalAccessToken
+	Token string `json:"token"`
+}
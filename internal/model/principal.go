@@ -0,0 +1,65 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Principal is the authenticated caller, resolved from a verified access
+// token or personal access token. An OAuth client is a principal too.
+type Principal struct {
+	UserID    uuid.UUID
+	TokenID   string // jti
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+	AMR       []string // how the session was authenticated, e.g. ["pwd", "otp"]
+	// TenantID is the organization the token belongs to: the user's, or
+	// the OAuth client's.
+	TenantID uuid.UUID
+	// ActorID is the real user behind an impersonation token, from its act
+	// claim (RFC 8693); UserID is then the user being impersonated.
+	ActorID uuid.UUID
+	// SessionID is the sign-in the access token belongs to. It is uuid.Nil
+	// for tokens that aren't tied to a session here.
+	SessionID uuid.UUID
+	// PersonalTokenID is set when the caller presented a personal access
+	// token rather than a session's JWT; TokenID is then empty.
+	PersonalTokenID uuid.UUID
+	// ClientID is set when the caller is an OAuth client acting for itself;
+	// UserID is then uuid.Nil and Permissions are the token's scopes.
+	ClientID string
+	// AuthorizedParty is the OAuth client a user signed in to through
+	// OpenID Connect, when this token was issued to that client.
+	AuthorizedParty string
+	// Scopes are the OAuth scopes granted to a client's or authorized
+	// party's token. Permissions never exceed them.
+	Scopes []string
+
+	Roles       []string
+	Permissions []string // resolved from Roles at authentication time
+}
+
+// Impersonated reports whether the principal is someone acting as UserID.
+func (p *Principal) Impersonated() bool {
+	return p.ActorID != uuid.Nil
+}
+
+// RealUserID is the user actually making the request: the actor when
+// impersonating, UserID otherwise.
+func (p *Principal) RealUserID() uuid.UUID {
+	if p.Impersonated() {
+		return p.ActorID
+	}
+	return p.UserID
+}
+
+// Can reports whether the principal holds perm.
+func (p *Principal) Can(perm string) bool {
+	for _, have := range p.Permissions {
+		if have == perm {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,29 @@
+package model
+
+// Built-in roles, seeded by the schema migration.
+const (
+	RoleAdmin = "admin"
+	RoleUser  = "user"
+)
+
+// Permissions are "<resource>:<action>" strings granted to roles.
+const (
+	PermUsersRead    = "users:read"
+	PermUsersWrite   = "users:write"
+	PermUsersDelete  = "users:delete"
+	PermRolesWrite   = "roles:write"
+	PermClientsWrite = "clients:write"
+	// PermUsersImpersonate lets support staff act as another user.
+	PermUsersImpersonate = "users:impersonate"
+	PermAuditRead        = "audit:read"
+	// PermOrgsWrite manages organizations and works inside any of them. It
+	// is a platform permission: only members of the default organization
+	// can hold it.
+	PermOrgsWrite = "orgs:write"
+)
+
+// --- request DTOs ---
+
+type SetRolesRequest struct {
+	Roles []string `json:"roles" validate:"required,min=1,dive,required"`
+}
@@ -0,0 +1,86 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RefreshToken is a persisted, opaque refresh token. Only the SHA-256 of the
+// raw token is stored. Tokens that descend from the same sign-in share a
+// FamilyID so that replaying a rotated token can revoke the whole chain.
+type RefreshToken struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	FamilyID  uuid.UUID
+	TokenHash string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	RotatedAt *time.Time
+	RevokedAt *time.Time
+	// AMR are the authentication methods of the sign-in that started the family.
+	AMR []string
+}
+
+// ActionToken purposes.
+const (
+	PurposePasswordReset     = "password_reset"
+	PurposeEmailVerification = "email_verification"
+	PurposeMFAChallenge      = "mfa_challenge"
+	PurposeWebAuthnRegister  = "webauthn_register"
+	PurposeWebAuthnLogin     = "webauthn_login"
+	PurposeMagicLink         = "magic_link"
+)
+
+// ActionToken is a single-use token, usually delivered by email. Email records
+// the address it was sent to.
+type ActionToken struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Purpose   string
+	TokenHash string
+	Email     string
+	// AMR are the methods the user has already passed, for an MFA challenge.
+	AMR       []string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	UsedAt    *time.Time
+}
+
+// --- request DTOs ---
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// SignOutRequest optionally names the refresh token to revoke alongside the access token.
+type SignOutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// MagicLinkRequest asks for a sign-in link to be mailed to Email.
+type MagicLinkRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// MagicLinkVerifyRequest redeems the token from a mailed sign-in link.
+type MagicLinkVerifyRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token"    validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
@@ -8,12 +8,27 @@ import (
 
 // User is the core domain type. PasswordHash is never serialised to JSON.
 type User struct {
-	ID           uuid.UUID `json:"id"`
-	Name         string    `json:"name"`
-	Email        string    `json:"email"`
-	PasswordHash string    `json:"-"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID           uuid.UUID  `json:"id"`
+	TenantID     uuid.UUID  `json:"tenant_id"` // the organization the user belongs to
+	Name         string     `json:"name"`
+	Email        string     `json:"email"`
+	PasswordHash string     `json:"-"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	DeletedAt    *time.Time `json:"deleted_at,omitempty"`
+
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	// PendingEmail is a requested new address awaiting confirmation; Email
+	// keeps the old one until then.
+	PendingEmail string `json:"pending_email,omitempty"`
+
+	// TokensValidAfter rejects access tokens issued before it (sign-out everywhere).
+	TokensValidAfter *time.Time `json:"-"`
+
+	// FailedSignIns counts consecutive wrong passwords; LockedUntil is set
+	// once they pass the lockout threshold.
+	FailedSignIns int        `json:"-"`
+	LockedUntil   *time.Time `json:"locked_until,omitempty"`
 }
 
 // --- request DTOs ---
@@ -21,7 +36,7 @@ type User struct {
 type RegisterRequest struct {
 	Name     string `json:"name"     validate:"required,min=2"`
 	Email    string `json:"email"    validate:"required,email"`
-	Password string `json:"password" validate:"required,min=8"`
+	Password string `json:"password" validate:"required"`
 }
 
 type SignInRequest struct {
@@ -34,6 +49,12 @@ type UpdateUserRequest struct {
 	Email string `json:"email" validate:"omitempty,email"`
 }
 
+type ChangePasswordRequest struct {
+	CurrentPassword     string `json:"current_password"      validate:"required"`
+	NewPassword         string `json:"new_password"          validate:"required,nefield=CurrentPassword"`
+	RevokeOtherSessions bool   `json:"revoke_other_sessions"`
+}
+
 type ListUsersQuery struct {
 	Email  string `form:"email"`
 	Limit  int    `form:"limit"`
@@ -42,7 +63,18 @@ type ListUsersQuery struct {
 
 // --- response DTOs ---
 
+// AuthResponse is returned by sign-in style endpoints. Token fields are empty
+// when no session is started, e.g. registering while verification is required,
+// or when a second factor is still needed (MFARequired, with an MFAToken to
+// redeem at /auth/mfa/verify and no User).
 type AuthResponse struct {
-	Token string `json:"token"`
-	User  *User  `json:"user"`
+	Token        string `json:"token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresIn    int64  `json:"expires_in,omitempty"` // access token lifetime in seconds
+	MFARequired  bool   `json:"mfa_required,omitempty"`
+	MFAToken     string `json:"mfa_token,omitempty"`
+	User         *User  `json:"user,omitempty"`
+	// CSRFToken replaces Token and RefreshToken with cookie sessions; it is
+	// also in the CSRF cookie, for clients that can't read that.
+	CSRFToken string `json:"csrf_token,omitempty"`
 }
@@ -0,0 +1,248 @@
+package password
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrUnsupportedHash is returned by Verify for a hash string the hasher
+// doesn't produce.
+var ErrUnsupportedHash = errors.New("password: unsupported hash format")
+
+// Hasher hashes passwords into self-describing strings: each names its
+// algorithm and parameters, so the preferred settings can change while old
+// hashes keep verifying.
+type Hasher interface {
+	Hash(pw string) (string, error)
+	// Verify checks pw against encoded. rehash reports that encoded was made
+	// with settings other than the current ones and should be replaced by a
+	// fresh Hash once pw is known to be right.
+	Verify(encoded, pw string) (ok, rehash bool, err error)
+}
+
+// --- chain ---
+
+type chain struct {
+	preferred Hasher
+	fallbacks []Hasher
+}
+
+// Chain hashes with preferred and verifies with whichever of preferred and
+// fallbacks reads the stored hash. A match found by a fallback is always
+// flagged for rehashing, which moves users to preferred as they sign in.
+func Chain(preferred Hasher, fallbacks ...Hasher) Hasher {
+	return &chain{preferred: preferred, fallbacks: fallbacks}
+}
+
+func (c *chain) Hash(pw string) (string, error) {
+	return c.preferred.Hash(pw)
+}
+
+func (c *chain) Verify(encoded, pw string) (bool, bool, error) {
+	ok, rehash, err := c.preferred.Verify(encoded, pw)
+	if !errors.Is(err, ErrUnsupportedHash) {
+		return ok, rehash, err
+	}
+	for _, h := range c.fallbacks {
+		ok, _, err := h.Verify(encoded, pw)
+		if errors.Is(err, ErrUnsupportedHash) {
+			continue
+		}
+		return ok, ok, err
+	}
+	return false, false, ErrUnsupportedHash
+}
+
+// --- bcrypt ---
+
+// Bcrypt produces standard "$2a$<cost>$..." hashes.
+type Bcrypt struct {
+	Cost int
+}
+
+func (b Bcrypt) Hash(pw string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(pw), b.Cost)
+	if err != nil {
+		return "", fmt.Errorf("password.Bcrypt.Hash: %w", err)
+	}
+	return string(hash), nil
+}
+
+func (b Bcrypt) Verify(encoded, pw string) (bool, bool, error) {
+	if !strings.HasPrefix(encoded, "$2a$") && !strings.HasPrefix(encoded, "$2b$") && !strings.HasPrefix(encoded, "$2y$") {
+		return false, false, ErrUnsupportedHash
+	}
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(pw))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, false, nil
+	}
+	if err != nil {
+		return false, false, fmt.Errorf("password.Bcrypt.Verify: %w", err)
+	}
+	cost, _ := bcrypt.Cost([]byte(encoded))
+	return true, cost != b.Cost, nil
+}
+
+// --- argon2id ---
+
+// Pepper is a server-side secret mixed into Argon2id hashes, so a leaked
+// database alone isn't enough to test guesses. Hashes record its ID, never
+// the key.
+type Pepper struct {
+	ID  string
+	Key []byte
+}
+
+// NewPepper derives the pepper's ID from a digest of key.
+func NewPepper(key []byte) *Pepper {
+	sum := sha256.Sum256(key)
+	return &Pepper{ID: base64.RawURLEncoding.EncodeToString(sum[:6]), Key: key}
+}
+
+func (p *Pepper) apply(pw string) []byte {
+	mac := hmac.New(sha256.New, p.Key)
+	mac.Write([]byte(pw))
+	return mac.Sum(nil)
+}
+
+// Argon2id produces PHC strings such as
+// "$argon2id$v=19$m=19456,t=2,p=1$<salt>$<hash>", with a keyid parameter
+// added when a pepper was used.
+type Argon2id struct {
+	Memory  uint32 // KiB
+	Time    uint32
+	Threads uint8
+	SaltLen uint32
+	KeyLen  uint32
+	// Pepper, if set, goes into new hashes. OldPeppers still verify hashes
+	// made with them, flagging those for rehashing.
+	Pepper     *Pepper
+	OldPeppers []*Pepper
+}
+
+// DefaultArgon2id follows the OWASP baseline of 19 MiB, two passes, one lane.
+func DefaultArgon2id() *Argon2id {
+	return &Argon2id{Memory: 19 * 1024, Time: 2, Threads: 1, SaltLen: 16, KeyLen: 32}
+}
+
+func (a *Argon2id) Hash(pw string) (string, error) {
+	salt := make([]byte, a.SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("password.Argon2id.Hash: %w", err)
+	}
+	params := fmt.Sprintf("m=%d,t=%d,p=%d", a.Memory, a.Time, a.Threads)
+	input := []byte(pw)
+	if a.Pepper != nil {
+		params += ",keyid=" + a.Pepper.ID
+		input = a.Pepper.apply(pw)
+	}
+	key := argon2.IDKey(input, salt, a.Time, a.Memory, a.Threads, a.KeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$%s$%s$%s", argon2.Version, params,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (a *Argon2id) Verify(encoded, pw string) (bool, bool, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != "argon2id" {
+		return false, false, ErrUnsupportedHash
+	}
+	if parts[2] != "v="+strconv.Itoa(argon2.Version) {
+		return false, false, fmt.Errorf("password.Argon2id.Verify: unsupported version %q", parts[2])
+	}
+	p, err := parseArgon2Params(parts[3])
+	if err != nil {
+		return false, false, err
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, false, fmt.Errorf("password.Argon2id.Verify: salt: %w", err)
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return false, false, fmt.Errorf("password.Argon2id.Verify: hash: %w", err)
+	}
+
+	input := []byte(pw)
+	if p.keyID != "" {
+		pepper := a.pepper(p.keyID)
+		if pepper == nil {
+			return false, false, fmt.Errorf("password.Argon2id.Verify: unknown pepper %q", p.keyID)
+		}
+		input = pepper.apply(pw)
+	}
+	got := argon2.IDKey(input, salt, p.time, p.memory, p.threads, uint32(len(want)))
+	if subtle.ConstantTimeCompare(got, want) != 1 {
+		return false, false, nil
+	}
+
+	current := ""
+	if a.Pepper != nil {
+		current = a.Pepper.ID
+	}
+	rehash := p.memory != a.Memory || p.time != a.Time || p.threads != a.Threads ||
+		uint32(len(salt)) != a.SaltLen || uint32(len(want)) != a.KeyLen || p.keyID != current
+	return true, rehash, nil
+}
+
+// pepper finds the current or an old pepper by ID.
+func (a *Argon2id) pepper(id string) *Pepper {
+	if a.Pepper != nil && a.Pepper.ID == id {
+		return a.Pepper
+	}
+	for _, p := range a.OldPeppers {
+		if p.ID == id {
+			return p
+		}
+	}
+	return nil
+}
+
+type argon2Params struct {
+	memory, time uint32
+	threads      uint8
+	keyID        string
+}
+
+func parseArgon2Params(s string) (argon2Params, error) {
+	var p argon2Params
+	for _, kv := range strings.Split(s, ",") {
+		k, v, _ := strings.Cut(kv, "=")
+		var err error
+		switch k {
+		case "m":
+			p.memory, err = parseUint32(v)
+		case "t":
+			p.time, err = parseUint32(v)
+		case "p":
+			var n uint64
+			n, err = strconv.ParseUint(v, 10, 8)
+			p.threads = uint8(n)
+		case "keyid":
+			p.keyID = v
+		default:
+			err = errors.New("unknown parameter")
+		}
+		if err != nil {
+			return p, fmt.Errorf("password.Argon2id.Verify: parameter %q: %w", kv, err)
+		}
+	}
+	if p.memory == 0 || p.time == 0 || p.threads == 0 {
+		return p, fmt.Errorf("password.Argon2id.Verify: missing parameters in %q", s)
+	}
+	return p, nil
+}
+
+func parseUint32(s string) (uint32, error) {
+	n, err := strconv.ParseUint(s, 10, 32)
+	return uint32(n), err
+}
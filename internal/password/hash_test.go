@@ -0,0 +1,99 @@
+package password
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// cheap keeps Argon2id fast enough for tests.
+func cheap() *Argon2id {
+	return &Argon2id{Memory: 64, Time: 1, Threads: 1, SaltLen: 16, KeyLen: 32}
+}
+
+func TestArgon2id_RoundTrip(t *testing.T) {
+	a := cheap()
+	hash, err := a.Hash("secret123")
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Errorf("unexpected PHC string %q", hash)
+	}
+
+	if ok, rehash, err := a.Verify(hash, "secret123"); !ok || rehash || err != nil {
+		t.Errorf("right password: ok=%v rehash=%v err=%v", ok, rehash, err)
+	}
+	if ok, _, err := a.Verify(hash, "secret124"); ok || err != nil {
+		t.Errorf("wrong password: ok=%v err=%v", ok, err)
+	}
+}
+
+func TestArgon2id_RehashOnNewParameters(t *testing.T) {
+	old := cheap()
+	hash, _ := old.Hash("secret123")
+
+	current := cheap()
+	current.Time = 2
+	if ok, rehash, _ := current.Verify(hash, "secret123"); !ok || !rehash {
+		t.Errorf("expected a match flagged for rehash, got ok=%v rehash=%v", ok, rehash)
+	}
+}
+
+func TestArgon2id_Pepper(t *testing.T) {
+	first, second := NewPepper([]byte("first pepper")), NewPepper([]byte("second pepper"))
+
+	a := cheap()
+	a.Pepper = first
+	hash, _ := a.Hash("secret123")
+	if !strings.Contains(hash, ",keyid="+first.ID+"$") {
+		t.Errorf("expected the pepper ID in %q", hash)
+	}
+	if ok, _, _ := cheap().Verify(hash, "secret123"); ok {
+		t.Error("expected a peppered hash not to verify without the pepper")
+	}
+
+	rotated := cheap()
+	rotated.Pepper, rotated.OldPeppers = second, []*Pepper{first}
+	if ok, rehash, err := rotated.Verify(hash, "secret123"); !ok || !rehash || err != nil {
+		t.Errorf("old pepper: ok=%v rehash=%v err=%v", ok, rehash, err)
+	}
+
+	// Adding a pepper also upgrades unpeppered hashes.
+	plain, _ := cheap().Hash("secret123")
+	if ok, rehash, _ := a.Verify(plain, "secret123"); !ok || !rehash {
+		t.Errorf("unpeppered: ok=%v rehash=%v", ok, rehash)
+	}
+}
+
+func TestChain_UpgradesFromBcrypt(t *testing.T) {
+	legacy := Bcrypt{Cost: 4}
+	hash, err := legacy.Hash("secret123")
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+
+	h := Chain(cheap(), legacy)
+	if ok, rehash, err := h.Verify(hash, "secret123"); !ok || !rehash || err != nil {
+		t.Errorf("bcrypt hash: ok=%v rehash=%v err=%v", ok, rehash, err)
+	}
+	if ok, rehash, err := h.Verify(hash, "wrong"); ok || rehash || err != nil {
+		t.Errorf("wrong password: ok=%v rehash=%v err=%v", ok, rehash, err)
+	}
+
+	fresh, _ := h.Hash("secret123")
+	if ok, rehash, _ := h.Verify(fresh, "secret123"); !ok || rehash {
+		t.Errorf("fresh hash: ok=%v rehash=%v", ok, rehash)
+	}
+
+	if _, _, err := h.Verify("$md5$whatever", "secret123"); !errors.Is(err, ErrUnsupportedHash) {
+		t.Errorf("expected ErrUnsupportedHash, got %v", err)
+	}
+}
+
+func TestBcrypt_RehashOnCostChange(t *testing.T) {
+	hash, _ := Bcrypt{Cost: 4}.Hash("secret123")
+	if ok, rehash, _ := (Bcrypt{Cost: 5}).Verify(hash, "secret123"); !ok || !rehash {
+		t.Errorf("expected a match flagged for rehash, got ok=%v rehash=%v", ok, rehash)
+	}
+}
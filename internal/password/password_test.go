@@ -0,0 +1,105 @@
+package password
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// codes returns the violation codes of err, which must be a *PolicyError or nil.
+func codes(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	var pe *PolicyError
+	if !errors.As(err, &pe) {
+		t.Fatalf("expected *PolicyError, got %T", err)
+	}
+	out := make([]string, len(pe.Violations))
+	for i, v := range pe.Violations {
+		out[i] = v.Code
+	}
+	return out
+}
+
+func TestPolicy_Check(t *testing.T) {
+	p := &Policy{
+		MinLength:   8,
+		MaxLength:   72,
+		MinClasses:  2,
+		MaxRepeated: 3,
+		MinStrength: 2,
+		Blocklist:   NewBlocklist(),
+	}
+	cases := []struct {
+		pw   string
+		want []string
+	}{
+		{"correct-horse-battery", nil},
+		{"secret123", nil},
+		{"Ab1!", []string{CodeTooShort, CodeTooWeak}},
+		{strings.Repeat("xY9!", 19), []string{CodeTooLong}},
+		{"onlylowercaseletters", []string{CodeMissingClasses}},
+		{"baaaaad-pass1", []string{CodeRepeatedChars}},
+		{"alice@example.com", []string{CodePersonalInfo}},
+		{"ALICE", []string{CodeTooShort, CodeMissingClasses, CodePersonalInfo, CodeTooWeak}},
+		{"abcd1234", []string{CodeTooWeak, CodeBreached}},
+		{"password1", []string{CodeBreached}},
+	}
+	for _, c := range cases {
+		got := codes(t, p.Check(c.pw, "alice@example.com", "Alice Smith"))
+		if strings.Join(got, ",") != strings.Join(c.want, ",") {
+			t.Errorf("%q: got %v, want %v", c.pw, got, c.want)
+		}
+	}
+}
+
+func TestPolicy_NilAcceptsAnything(t *testing.T) {
+	var p *Policy
+	if err := p.Check(""); err != nil {
+		t.Errorf("expected nil, got %v", err)
+	}
+}
+
+func TestScore(t *testing.T) {
+	cases := map[string]int{
+		"aaaaaaaa":                     0,
+		"12345678":                     0,
+		"abcdefgh":                     0,
+		"secret123":                    2,
+		"Tr0ub4dor&3":                  3,
+		"correct horse battery staple": 4,
+	}
+	for pw, want := range cases {
+		if got := Score(pw); got != want {
+			t.Errorf("%q: score %d (%.1f bits), want %d", pw, got, Entropy(pw), want)
+		}
+	}
+}
+
+func TestBlocklist(t *testing.T) {
+	b := NewBlocklist()
+	// SHA-1("zebra-umbrella-42") with a Pwned Passwords count, and an arbitrary hash.
+	err := b.Load(strings.NewReader("# extra\n\n8531f9b26125aff95e74680f4240b2aed8b98138:12\nA1A4AB31A2A3C4F0A5A1B4F1A21D5ED5C2B1E5FA\n"))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	if !b.Contains("password") {
+		t.Error("expected the embedded list to block \"password\"")
+	}
+	if !b.Contains("zebra-umbrella-42") {
+		t.Error("expected a loaded hash to be blocked")
+	}
+	if b.Contains("correct-horse-battery") {
+		t.Error("expected an uncommon password to pass")
+	}
+	if got := b.Range("A1A4A"); len(got) != 1 || got[0] != "B31A2A3C4F0A5A1B4F1A21D5ED5C2B1E5FA" {
+		t.Errorf("range A1A4A: got %v", got)
+	}
+
+	if err := b.Load(strings.NewReader("not-a-hash\n")); err == nil {
+		t.Error("expected a malformed line to be rejected")
+	}
+}
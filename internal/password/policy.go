@@ -0,0 +1,166 @@
+// Package password decides whether a new password is acceptable (length and
+// composition rules, an entropy-based strength estimate, and a blocklist of
+// passwords known from breaches) and hashes it for storage.
+package password
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Violation is one rule a password broke. Code is stable for clients;
+// Message is for people.
+type Violation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Violation codes.
+const (
+	CodeTooShort       = "too_short"
+	CodeTooLong        = "too_long"
+	CodeMissingClasses = "missing_character_classes"
+	CodeRepeatedChars  = "repeated_characters"
+	CodePersonalInfo   = "matches_personal_info"
+	CodeTooWeak        = "too_weak"
+	CodeBreached       = "breached"
+)
+
+// PolicyError lists every rule a password broke.
+type PolicyError struct {
+	Violations []Violation
+}
+
+func (e *PolicyError) Error() string {
+	msgs := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		msgs[i] = v.Message
+	}
+	return "password " + strings.Join(msgs, "; ")
+}
+
+// Policy is the set of rules new passwords must meet. Zero fields disable
+// their rule; a nil *Policy accepts everything.
+type Policy struct {
+	// MinLength counts characters. MaxLength counts bytes, because that is
+	// what the hash function is limited by.
+	MinLength int
+	MaxLength int
+	// MinClasses is how many of lowercase, uppercase, digits and symbols
+	// must appear.
+	MinClasses int
+	// MaxRepeated is the longest run of one character allowed, e.g. 3
+	// accepts "aaa" but not "aaaa".
+	MaxRepeated int
+	// MinStrength is the lowest acceptable Score, 0–4.
+	MinStrength int
+	// Blocklist rejects known-breached passwords.
+	Blocklist *Blocklist
+}
+
+// Check returns a *PolicyError listing every rule pw breaks, or nil. personal
+// holds the user's own details (email, name), which pw must not equal.
+func (p *Policy) Check(pw string, personal ...string) error {
+	if p == nil {
+		return nil
+	}
+
+	var vs []Violation
+	add := func(code, msg string) { vs = append(vs, Violation{Code: code, Message: msg}) }
+
+	if n := utf8.RuneCountInString(pw); p.MinLength > 0 && n < p.MinLength {
+		add(CodeTooShort, "must be at least "+strconv.Itoa(p.MinLength)+" characters")
+	}
+	if p.MaxLength > 0 && len(pw) > p.MaxLength {
+		add(CodeTooLong, "must be at most "+strconv.Itoa(p.MaxLength)+" bytes")
+	}
+	if p.MinClasses > 0 && classesOf(pw).count() < p.MinClasses {
+		add(CodeMissingClasses, "must mix at least "+strconv.Itoa(p.MinClasses)+
+			" of lowercase letters, uppercase letters, digits and symbols")
+	}
+	if p.MaxRepeated > 0 && longestRun(pw) > p.MaxRepeated {
+		add(CodeRepeatedChars, "must not repeat a character more than "+strconv.Itoa(p.MaxRepeated)+" times in a row")
+	}
+	if matchesPersonal(pw, personal) {
+		add(CodePersonalInfo, "must not be your email address or name")
+	}
+	if p.MinStrength > 0 && Score(pw) < p.MinStrength {
+		add(CodeTooWeak, "is too easy to guess; make it longer or less predictable")
+	}
+	if p.Blocklist.Contains(pw) {
+		add(CodeBreached, "has appeared in a data breach; choose another")
+	}
+
+	if len(vs) > 0 {
+		return &PolicyError{Violations: vs}
+	}
+	return nil
+}
+
+// classSet records which character classes a password uses.
+type classSet struct {
+	lower, upper, digit, symbol bool
+}
+
+func classesOf(pw string) classSet {
+	var c classSet
+	for _, r := range pw {
+		switch {
+		case unicode.IsLower(r):
+			c.lower = true
+		case unicode.IsUpper(r):
+			c.upper = true
+		case unicode.IsDigit(r):
+			c.digit = true
+		default:
+			c.symbol = true
+		}
+	}
+	return c
+}
+
+// count is how many classes are present.
+func (c classSet) count() int {
+	n := 0
+	for _, b := range []bool{c.lower, c.upper, c.digit, c.symbol} {
+		if b {
+			n++
+		}
+	}
+	return n
+}
+
+// longestRun is the length of the longest run of one repeated character.
+func longestRun(pw string) int {
+	longest, run := 0, 0
+	var prev rune = -1
+	for _, r := range pw {
+		if r == prev {
+			run++
+		} else {
+			run = 1
+		}
+		prev = r
+		longest = max(longest, run)
+	}
+	return longest
+}
+
+// matchesPersonal reports whether pw is, ignoring case, one of personal or
+// the local part of an email address among them.
+func matchesPersonal(pw string, personal []string) bool {
+	for _, s := range personal {
+		if s == "" {
+			continue
+		}
+		if strings.EqualFold(pw, s) {
+			return true
+		}
+		if local, _, ok := strings.Cut(s, "@"); ok && strings.EqualFold(pw, local) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package password
+
+import "math"
+
+// Entropy estimates the bits of guessing work pw represents. Each character
+// is worth log2 of the alphabet pw draws from (lowercase 26, uppercase 26,
+// digits 10, symbols 33), except that repeating the previous character or
+// continuing a run like "abc" or "321" is worth a single bit.
+func Entropy(pw string) float64 {
+	c := classesOf(pw)
+	pool := 0
+	if c.lower {
+		pool += 26
+	}
+	if c.upper {
+		pool += 26
+	}
+	if c.digit {
+		pool += 10
+	}
+	if c.symbol {
+		pool += 33
+	}
+	if pool == 0 {
+		return 0
+	}
+
+	perChar := math.Log2(float64(pool))
+	bits := 0.0
+	var prev rune = -1
+	step := 0 // difference between the last two characters, for runs
+	for i, r := range []rune(pw) {
+		d := int(r - prev)
+		switch {
+		case i == 0:
+			bits += perChar
+		case d == 0:
+			bits++
+		case (d == 1 || d == -1) && (i == 1 || d == step):
+			bits++
+		default:
+			bits += perChar
+		}
+		step, prev = d, r
+	}
+	return bits
+}
+
+// Score buckets Entropy into 0 (trivial) to 4 (very strong).
+func Score(pw string) int {
+	bits := Entropy(pw)
+	switch {
+	case bits < 28:
+		return 0
+	case bits < 36:
+		return 1
+	case bits < 60:
+		return 2
+	case bits < 80:
+		return 3
+	}
+	return 4
+}
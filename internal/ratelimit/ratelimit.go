@@ -0,0 +1,139 @@
+// Package ratelimit implements token-bucket request limits. MemoryStore keeps
+// buckets in process; a shared store (e.g. Redis) can be plugged in behind
+// the Store interface when the API runs on more than one instance.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limit allows Requests per Period, in bursts of up to Requests. The zero
+// Limit means no limit.
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// Enabled reports whether l limits anything.
+func (l Limit) Enabled() bool {
+	return l.Requests > 0 && l.Period > 0
+}
+
+// String formats l the way ParseLimit reads it.
+func (l Limit) String() string {
+	if !l.Enabled() {
+		return "off"
+	}
+	return strconv.Itoa(l.Requests) + "/" + l.Period.String()
+}
+
+// ParseLimit reads "<requests>/<period>", such as "20/1m" or "1000/1h".
+// "off" and "0" return the zero Limit.
+func ParseLimit(s string) (Limit, error) {
+	s = strings.TrimSpace(s)
+	if s == "off" || s == "0" {
+		return Limit{}, nil
+	}
+	n, period, ok := strings.Cut(s, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("ratelimit: %q is not <requests>/<period>", s)
+	}
+	requests, err := strconv.Atoi(n)
+	if err != nil || requests < 0 {
+		return Limit{}, fmt.Errorf("ratelimit: bad request count in %q", s)
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("ratelimit: bad period in %q", s)
+	}
+	return Limit{Requests: requests, Period: d}, nil
+}
+
+// Result is the state of a bucket after a request was counted against it.
+type Result struct {
+	Allowed bool
+	// Limit is the bucket size; Remaining is what is left of it.
+	Limit     int
+	Remaining int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long until the next request would be allowed; zero
+	// when Allowed.
+	RetryAfter time.Duration
+}
+
+// Store counts requests against per-key buckets.
+type Store interface {
+	// Take spends one token from key's bucket if it has one.
+	Take(ctx context.Context, key string, l Limit) (Result, error)
+}
+
+// --- in memory ---
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	full   time.Time // when the bucket will have refilled completely
+}
+
+// MemoryStore keeps buckets in a map. Buckets are only meaningful to the
+// process that holds them, so each API instance limits on its own.
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	now     func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*bucket), now: time.Now}
+}
+
+func (s *MemoryStore) Take(_ context.Context, key string, l Limit) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	size := float64(l.Requests)
+	perToken := l.Period / time.Duration(l.Requests)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: size, last: now}
+		s.buckets[key] = b
+	}
+	b.tokens = math.Min(size, b.tokens+float64(now.Sub(b.last))/float64(perToken))
+	b.last = now
+
+	res := Result{Limit: l.Requests}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = time.Duration((1 - b.tokens) * float64(perToken))
+	}
+	res.Remaining = int(b.tokens)
+	res.Reset = time.Duration((size - b.tokens) * float64(perToken))
+	b.full = now.Add(res.Reset)
+	return res, nil
+}
+
+// Sweep drops buckets that have refilled completely, which behave exactly
+// like missing ones, so idle clients don't accumulate.
+func (s *MemoryStore) Sweep(context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for key, b := range s.buckets {
+		if !now.Before(b.full) {
+			delete(s.buckets, key)
+		}
+	}
+	return nil
+}
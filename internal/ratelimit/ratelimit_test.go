@@ -0,0 +1,106 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// fakeClock returns a MemoryStore whose time only moves when advance is called.
+func fakeClock() (*MemoryStore, func(time.Duration)) {
+	now := time.Unix(1_700_000_000, 0)
+	s := NewMemoryStore()
+	s.now = func() time.Time { return now }
+	return s, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestMemoryStore_BurstThenRefill(t *testing.T) {
+	s, advance := fakeClock()
+	ctx := context.Background()
+	l := Limit{Requests: 3, Period: 3 * time.Second}
+
+	for i := 0; i < 3; i++ {
+		res, _ := s.Take(ctx, "k", l)
+		if !res.Allowed {
+			t.Fatalf("request %d: expected to be allowed", i+1)
+		}
+		if want := 2 - i; res.Remaining != want {
+			t.Errorf("request %d: remaining %d, want %d", i+1, res.Remaining, want)
+		}
+	}
+
+	res, _ := s.Take(ctx, "k", l)
+	if res.Allowed {
+		t.Fatal("expected the fourth request to be refused")
+	}
+	if res.RetryAfter != time.Second {
+		t.Errorf("retry after %v, want 1s", res.RetryAfter)
+	}
+	if res.Reset != 3*time.Second {
+		t.Errorf("reset %v, want 3s", res.Reset)
+	}
+
+	advance(time.Second)
+	if res, _ := s.Take(ctx, "k", l); !res.Allowed {
+		t.Error("expected a token after one refill interval")
+	}
+}
+
+func TestMemoryStore_KeysAreIndependent(t *testing.T) {
+	s, _ := fakeClock()
+	ctx := context.Background()
+	l := Limit{Requests: 1, Period: time.Minute}
+
+	s.Take(ctx, "a", l) //nolint:errcheck
+	if res, _ := s.Take(ctx, "a", l); res.Allowed {
+		t.Error("expected a to be exhausted")
+	}
+	if res, _ := s.Take(ctx, "b", l); !res.Allowed {
+		t.Error("expected b to have its own bucket")
+	}
+}
+
+func TestMemoryStore_SweepDropsFullBuckets(t *testing.T) {
+	s, advance := fakeClock()
+	ctx := context.Background()
+	l := Limit{Requests: 2, Period: time.Minute}
+
+	s.Take(ctx, "k", l) //nolint:errcheck
+	s.Sweep(ctx)        //nolint:errcheck
+	if len(s.buckets) != 1 {
+		t.Fatal("expected a partly spent bucket to survive the sweep")
+	}
+
+	advance(30 * time.Second)
+	s.Sweep(ctx) //nolint:errcheck
+	if len(s.buckets) != 0 {
+		t.Error("expected the refilled bucket to be swept")
+	}
+}
+
+func TestParseLimit(t *testing.T) {
+	cases := map[string]Limit{
+		"20/1m":   {Requests: 20, Period: time.Minute},
+		" 5/1h ":  {Requests: 5, Period: time.Hour},
+		"off":     {},
+		"0":       {},
+		"0/1m":    {Period: time.Minute},
+		"100/30s": {Requests: 100, Period: 30 * time.Second},
+	}
+	for in, want := range cases {
+		got, err := ParseLimit(in)
+		if err != nil {
+			t.Errorf("%q: %v", in, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("%q: got %+v, want %+v", in, got, want)
+		}
+	}
+
+	for _, in := range []string{"", "20", "x/1m", "20/x", "20/-1m", "-1/1m"} {
+		if _, err := ParseLimit(in); err == nil {
+			t.Errorf("%q: expected an error", in)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"user-management-api/internal/model"
+)
+
+type ActionTokenRepository struct {
+	db *sql.DB
+}
+
+func NewActionTokenRepository(db *sql.DB) *ActionTokenRepository {
+	return &ActionTokenRepository{db: db}
+}
+
+func (r *ActionTokenRepository) Create(ctx context.Context, t *model.ActionToken) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO action_tokens (id, user_id, purpose, token_hash, email, amr, expires_at, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		t.ID.String(), t.UserID.String(), t.Purpose, t.TokenHash, t.Email, strings.Join(t.AMR, " "),
+		t.ExpiresAt.UTC().Format(time.RFC3339),
+		t.CreatedAt.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("repository.ActionToken.Create: %w", err)
+	}
+	return nil
+}
+
+// GetByHash returns the token with the given hash and purpose.
+func (r *ActionTokenRepository) GetByHash(ctx context.Context, purpose, hash string) (*model.ActionToken, error) {
+	var (
+		t                      model.ActionToken
+		idStr, userStr         string
+		expiresStr, createdStr string
+		usedStr                sql.NullString
+		amr                    string
+	)
+	err := r.db.QueryRowContext(ctx,
+		`SELECT id, user_id, purpose, token_hash, email, amr, expires_at, created_at, used_at
+		 FROM action_tokens WHERE token_hash = ? AND purpose = ?`,
+		hash, purpose,
+	).Scan(&idStr, &userStr, &t.Purpose, &t.TokenHash, &t.Email, &amr, &expiresStr, &createdStr, &usedStr)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTokenNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("repository.ActionToken.GetByHash: %w", err)
+	}
+	t.ID, _ = uuid.Parse(idStr)
+	t.UserID, _ = uuid.Parse(userStr)
+	t.AMR = strings.Fields(amr)
+	t.ExpiresAt, _ = time.Parse(time.RFC3339, expiresStr)
+	t.CreatedAt, _ = time.Parse(time.RFC3339, createdStr)
+	t.UsedAt = parseNullTime(usedStr)
+	return &t, nil
+}
+
+// Consume marks a token used. Like RefreshTokenRepository.MarkRotated it only
+// succeeds once, so a token raced by two requests is honoured for one.
+func (r *ActionTokenRepository) Consume(ctx context.Context, id uuid.UUID, at time.Time) error {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE action_tokens SET used_at = ? WHERE id = ? AND used_at IS NULL`,
+		at.UTC().Format(time.RFC3339), id.String(),
+	)
+	if err != nil {
+		return fmt.Errorf("repository.ActionToken.Consume: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrTokenNotFound
+	}
+	return nil
+}
+
+// InvalidateForUser burns every unused token of a purpose, so only the most
+// recently mailed link works.
+func (r *ActionTokenRepository) InvalidateForUser(ctx context.Context, userID uuid.UUID, purpose string, at time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE action_tokens SET used_at = ? WHERE user_id = ? AND purpose = ? AND used_at IS NULL`,
+		at.UTC().Format(time.RFC3339), userID.String(), purpose,
+	)
+	if err != nil {
+		return fmt.Errorf("repository.ActionToken.InvalidateForUser: %w", err)
+	}
+	return nil
+}
+
+// CountSince counts the tokens of a purpose issued to the user at or after
+// since, used or not.
+func (r *ActionTokenRepository) CountSince(ctx context.Context, userID uuid.UUID, purpose string, since time.Time) (int, error) {
+	var n int
+	err := r.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM action_tokens WHERE user_id = ? AND purpose = ? AND created_at >= ?`,
+		userID.String(), purpose, since.UTC().Format(time.RFC3339),
+	).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("repository.ActionToken.CountSince: %w", err)
+	}
+	return n, nil
+}
+
+// DeleteExpired removes tokens whose expiry is before now.
+func (r *ActionTokenRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx,
+		`DELETE FROM action_tokens WHERE expires_at < ?`,
+		now.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("repository.ActionToken.DeleteExpired: %w", err)
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"user-management-api/internal/model"
+)
+
+const auditColumns = `id, tenant_id, occurred_at, action, actor_id, user_id, method, path, status, ip, user_agent`
+
+type AuditRepository struct {
+	db *sql.DB
+}
+
+func NewAuditRepository(db *sql.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+// Create records e under e.TenantID, or the organization ctx is scoped to,
+// or else the default one.
+func (r *AuditRepository) Create(ctx context.Context, e *model.AuditEvent) error {
+	if e.TenantID == uuid.Nil {
+		e.TenantID = tenantOrDefault(ctx)
+	}
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO audit_events (`+auditColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.ID.String(), e.TenantID.String(), e.OccurredAt.UTC().Format(time.RFC3339), e.Action,
+		e.ActorID.String(), e.UserID.String(),
+		e.Method, e.Path, e.Status, e.IP, e.UserAgent,
+	)
+	if err != nil {
+		return fmt.Errorf("repository.Audit.Create: %w", err)
+	}
+	return nil
+}
+
+// List returns events newest first. Empty filters match everything; a
+// scoped ctx sees only its organization's events.
+func (r *AuditRepository) List(ctx context.Context, actorID, userID, action string, limit, offset int) ([]*model.AuditEvent, error) {
+	clause, args := tenantClause(ctx)
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+auditColumns+` FROM audit_events
+		 WHERE (? = '' OR actor_id = ?) AND (? = '' OR user_id = ?) AND (? = '' OR action = ?)`+clause+`
+		 ORDER BY occurred_at DESC, rowid DESC
+		 LIMIT ? OFFSET ?`,
+		append(append([]any{actorID, actorID, userID, userID, action, action}, args...), limit, offset)...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("repository.Audit.List: %w", err)
+	}
+	defer rows.Close()
+
+	events := []*model.AuditEvent{}
+	for rows.Next() {
+		var (
+			e                 model.AuditEvent
+			idStr, tenantStr  string
+			occurredStr       string
+			actorStr, userStr string
+		)
+		err := rows.Scan(&idStr, &tenantStr, &occurredStr, &e.Action, &actorStr, &userStr, &e.Method, &e.Path, &e.Status, &e.IP, &e.UserAgent)
+		if err != nil {
+			return nil, fmt.Errorf("repository.Audit.List: %w", err)
+		}
+		e.ID, _ = uuid.Parse(idStr)
+		e.TenantID, _ = uuid.Parse(tenantStr)
+		e.OccurredAt, _ = time.Parse(time.RFC3339, occurredStr)
+		e.ActorID, _ = uuid.Parse(actorStr)
+		e.UserID, _ = uuid.Parse(userStr)
+		events = append(events, &e)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,219 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"user-management-api/internal/model"
+)
+
+var (
+	// ErrLoginNotFound is returned for a federated sign-in state or ticket
+	// that doesn't exist or has already moved on.
+	ErrLoginNotFound = errors.New("federated login not found")
+	// ErrIdentityNotFound is returned when no linked identity matches.
+	ErrIdentityNotFound = errors.New("identity not found")
+	// ErrIdentityExists is returned when an external account is already
+	// linked to a user.
+	ErrIdentityExists = errors.New("identity already linked")
+)
+
+const (
+	federatedLoginColumns = `id, state_hash, provider, nonce, code_verifier, client_state, expires_at,
+		ticket_hash, subject, email, email_verified, name, used_at`
+	identityColumns = `id, user_id, provider, subject, email, created_at, last_used_at`
+)
+
+// FederationRepository stores sign-ins in flight at upstream identity
+// providers and the identities linked to users.
+type FederationRepository struct {
+	db *sql.DB
+}
+
+func NewFederationRepository(db *sql.DB) *FederationRepository {
+	return &FederationRepository{db: db}
+}
+
+// --- sign-ins in flight ---
+
+func (r *FederationRepository) CreateLogin(ctx context.Context, l *model.FederatedLogin) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO federated_logins (id, state_hash, provider, nonce, code_verifier, client_state, expires_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		l.ID.String(), l.StateHash, l.Provider, l.Nonce, l.CodeVerifier, l.ClientState,
+		l.ExpiresAt.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("repository.Federation.CreateLogin: %w", err)
+	}
+	return nil
+}
+
+// GetLoginByState returns a sign-in still waiting for its callback.
+func (r *FederationRepository) GetLoginByState(ctx context.Context, stateHash string) (*model.FederatedLogin, error) {
+	l, err := scanFederatedLogin(r.db.QueryRowContext(ctx,
+		`SELECT `+federatedLoginColumns+` FROM federated_logins WHERE state_hash = ? AND ticket_hash IS NULL`, stateHash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrLoginNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("repository.Federation.GetLoginByState: %w", err)
+	}
+	return l, nil
+}
+
+// CompleteLogin records who signed in and the ticket to redeem, once: a
+// second callback for the same state finds nothing to update.
+func (r *FederationRepository) CompleteLogin(ctx context.Context, l *model.FederatedLogin) error {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE federated_logins SET ticket_hash = ?, subject = ?, email = ?, email_verified = ?, name = ?, expires_at = ?
+		 WHERE id = ? AND ticket_hash IS NULL`,
+		l.TicketHash, l.Subject, l.Email, l.EmailVerified, l.Name, l.ExpiresAt.UTC().Format(time.RFC3339), l.ID.String(),
+	)
+	if err != nil {
+		return fmt.Errorf("repository.Federation.CompleteLogin: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrLoginNotFound
+	}
+	return nil
+}
+
+// ConsumeTicket marks a ticket used and returns its sign-in, in one
+// statement so it is honoured once. The caller still checks expiry.
+func (r *FederationRepository) ConsumeTicket(ctx context.Context, ticketHash string, at time.Time) (*model.FederatedLogin, error) {
+	l, err := scanFederatedLogin(r.db.QueryRowContext(ctx,
+		`UPDATE federated_logins SET used_at = ? WHERE ticket_hash = ? AND used_at IS NULL
+		 RETURNING `+federatedLoginColumns,
+		at.UTC().Format(time.RFC3339), ticketHash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrLoginNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("repository.Federation.ConsumeTicket: %w", err)
+	}
+	return l, nil
+}
+
+// DeleteExpiredLogins removes sign-ins past their expiry, finished or not.
+func (r *FederationRepository) DeleteExpiredLogins(ctx context.Context, now time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx,
+		`DELETE FROM federated_logins WHERE expires_at < ?`, now.UTC().Format(time.RFC3339))
+	if err != nil {
+		return 0, fmt.Errorf("repository.Federation.DeleteExpiredLogins: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// --- linked identities ---
+
+func (r *FederationRepository) CreateIdentity(ctx context.Context, i *model.UserIdentity) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO user_identities (id, user_id, provider, subject, email, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		i.ID.String(), i.UserID.String(), i.Provider, i.Subject, i.Email, i.CreatedAt.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrIdentityExists
+		}
+		return fmt.Errorf("repository.Federation.CreateIdentity: %w", err)
+	}
+	return nil
+}
+
+// GetIdentity looks up the account a provider calls subject.
+func (r *FederationRepository) GetIdentity(ctx context.Context, provider, subject string) (*model.UserIdentity, error) {
+	i, err := scanIdentity(r.db.QueryRowContext(ctx,
+		`SELECT `+identityColumns+` FROM user_identities WHERE provider = ? AND subject = ?`, provider, subject))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrIdentityNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("repository.Federation.GetIdentity: %w", err)
+	}
+	return i, nil
+}
+
+func (r *FederationRepository) ListIdentities(ctx context.Context, userID uuid.UUID) ([]*model.UserIdentity, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+identityColumns+` FROM user_identities WHERE user_id = ? ORDER BY created_at`, userID.String())
+	if err != nil {
+		return nil, fmt.Errorf("repository.Federation.ListIdentities: %w", err)
+	}
+	defer rows.Close()
+
+	identities := []*model.UserIdentity{}
+	for rows.Next() {
+		i, err := scanIdentity(rows)
+		if err != nil {
+			return nil, fmt.Errorf("repository.Federation.ListIdentities: %w", err)
+		}
+		identities = append(identities, i)
+	}
+	return identities, rows.Err()
+}
+
+// MarkIdentityUsed records a sign-in and the email the provider now reports.
+func (r *FederationRepository) MarkIdentityUsed(ctx context.Context, id uuid.UUID, email string, at time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE user_identities SET email = ?, last_used_at = ? WHERE id = ?`,
+		email, at.UTC().Format(time.RFC3339), id.String(),
+	)
+	if err != nil {
+		return fmt.Errorf("repository.Federation.MarkIdentityUsed: %w", err)
+	}
+	return nil
+}
+
+// DeleteIdentity unlinks one of userID's identities.
+func (r *FederationRepository) DeleteIdentity(ctx context.Context, userID, id uuid.UUID) error {
+	res, err := r.db.ExecContext(ctx,
+		`DELETE FROM user_identities WHERE id = ? AND user_id = ?`, id.String(), userID.String())
+	if err != nil {
+		return fmt.Errorf("repository.Federation.DeleteIdentity: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrIdentityNotFound
+	}
+	return nil
+}
+
+func scanFederatedLogin(row scanner) (*model.FederatedLogin, error) {
+	var (
+		l                 model.FederatedLogin
+		idStr, expiresStr string
+		ticketHash        sql.NullString
+		usedStr           sql.NullString
+	)
+	err := row.Scan(&idStr, &l.StateHash, &l.Provider, &l.Nonce, &l.CodeVerifier, &l.ClientState, &expiresStr,
+		&ticketHash, &l.Subject, &l.Email, &l.EmailVerified, &l.Name, &usedStr)
+	if err != nil {
+		return nil, err
+	}
+	l.ID, _ = uuid.Parse(idStr)
+	l.ExpiresAt, _ = time.Parse(time.RFC3339, expiresStr)
+	l.TicketHash = ticketHash.String
+	l.UsedAt = parseNullTime(usedStr)
+	return &l, nil
+}
+
+func scanIdentity(row scanner) (*model.UserIdentity, error) {
+	var (
+		i                     model.UserIdentity
+		idStr, userStr, crStr string
+		lastUsedStr           sql.NullString
+	)
+	if err := row.Scan(&idStr, &userStr, &i.Provider, &i.Subject, &i.Email, &crStr, &lastUsedStr); err != nil {
+		return nil, err
+	}
+	i.ID, _ = uuid.Parse(idStr)
+	i.UserID, _ = uuid.Parse(userStr)
+	i.CreatedAt, _ = time.Parse(time.RFC3339, crStr)
+	i.LastUsedAt = parseNullTime(lastUsedStr)
+	return &i, nil
+}
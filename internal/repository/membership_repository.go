@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"user-management-api/internal/model"
+)
+
+var (
+	// ErrMembershipNotFound is returned when the user isn't a member of the organization.
+	ErrMembershipNotFound = errors.New("membership not found")
+	// ErrAlreadyMember is returned when adding a user who is already a member.
+	ErrAlreadyMember = errors.New("user is already a member of this organization")
+)
+
+const membershipColumns = `org_id, user_id, role, created_at`
+
+type MembershipRepository struct {
+	db *sql.DB
+}
+
+func NewMembershipRepository(db *sql.DB) *MembershipRepository {
+	return &MembershipRepository{db: db}
+}
+
+// Add makes the user a member of the organization, failing with
+// ErrUnknownRole for a role that doesn't exist.
+func (r *MembershipRepository) Add(ctx context.Context, m *model.Membership) error {
+	res, err := r.db.ExecContext(ctx,
+		`INSERT INTO memberships (org_id, user_id, role, created_at)
+		 SELECT ?, ?, name, ? FROM roles WHERE name = ?`,
+		m.OrganizationID.String(), m.UserID.String(), m.CreatedAt.UTC().Format(time.RFC3339), m.Role,
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrAlreadyMember
+		}
+		return fmt.Errorf("repository.Membership.Add: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrUnknownRole
+	}
+	return nil
+}
+
+func (r *MembershipRepository) Get(ctx context.Context, orgID, userID uuid.UUID) (*model.Membership, error) {
+	m, err := scanMembership(r.db.QueryRowContext(ctx,
+		`SELECT `+membershipColumns+` FROM memberships WHERE org_id = ? AND user_id = ?`,
+		orgID.String(), userID.String(),
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrMembershipNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("repository.Membership.Get: %w", err)
+	}
+	return m, nil
+}
+
+// List returns the organization's members, longest-standing first.
+func (r *MembershipRepository) List(ctx context.Context, orgID uuid.UUID, limit, offset int) ([]*model.Membership, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+membershipColumns+` FROM memberships WHERE org_id = ?
+		 ORDER BY created_at, rowid LIMIT ? OFFSET ?`,
+		orgID.String(), limit, offset,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("repository.Membership.List: %w", err)
+	}
+	defer rows.Close()
+
+	members := []*model.Membership{}
+	for rows.Next() {
+		m, err := scanMembership(rows)
+		if err != nil {
+			return nil, fmt.Errorf("repository.Membership.List: %w", err)
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+// SetRole replaces a member's role in the organization.
+func (r *MembershipRepository) SetRole(ctx context.Context, orgID, userID uuid.UUID, role string) error {
+	var exists int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM roles WHERE name = ?`, role).Scan(&exists); err != nil {
+		return fmt.Errorf("repository.Membership.SetRole: %w", err)
+	}
+	if exists == 0 {
+		return ErrUnknownRole
+	}
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE memberships SET role = ? WHERE org_id = ? AND user_id = ?`,
+		role, orgID.String(), userID.String(),
+	)
+	if err != nil {
+		return fmt.Errorf("repository.Membership.SetRole: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrMembershipNotFound
+	}
+	return nil
+}
+
+func (r *MembershipRepository) Remove(ctx context.Context, orgID, userID uuid.UUID) error {
+	res, err := r.db.ExecContext(ctx,
+		`DELETE FROM memberships WHERE org_id = ? AND user_id = ?`,
+		orgID.String(), userID.String(),
+	)
+	if err != nil {
+		return fmt.Errorf("repository.Membership.Remove: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrMembershipNotFound
+	}
+	return nil
+}
+
+func scanMembership(row scanner) (*model.Membership, error) {
+	var (
+		m                           model.Membership
+		orgStr, userStr, createdStr string
+	)
+	if err := row.Scan(&orgStr, &userStr, &m.Role, &createdStr); err != nil {
+		return nil, err
+	}
+	m.OrganizationID, _ = uuid.Parse(orgStr)
+	m.UserID, _ = uuid.Parse(userStr)
+	m.CreatedAt, _ = time.Parse(time.RFC3339, createdStr)
+	return &m, nil
+}
@@ -0,0 +1,172 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"user-management-api/internal/model"
+)
+
+// ErrFactorNotFound is returned when the user has no TOTP factor.
+var ErrFactorNotFound = errors.New("mfa factor not found")
+
+type MFARepository struct {
+	db *sql.DB
+}
+
+func NewMFARepository(db *sql.DB) *MFARepository {
+	return &MFARepository{db: db}
+}
+
+// SaveTOTP stores an unconfirmed factor, replacing any earlier one.
+func (r *MFARepository) SaveTOTP(ctx context.Context, f *model.TOTPFactor) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT OR REPLACE INTO totp_factors (user_id, secret, confirmed_at, last_step, created_at)
+		 VALUES (?, ?, NULL, 0, ?)`,
+		f.UserID.String(), f.Secret, f.CreatedAt.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("repository.MFA.SaveTOTP: %w", err)
+	}
+	return nil
+}
+
+func (r *MFARepository) GetTOTP(ctx context.Context, userID uuid.UUID) (*model.TOTPFactor, error) {
+	var (
+		f            model.TOTPFactor
+		confirmedStr sql.NullString
+		createdStr   string
+	)
+	err := r.db.QueryRowContext(ctx,
+		`SELECT secret, confirmed_at, last_step, created_at FROM totp_factors WHERE user_id = ?`,
+		userID.String(),
+	).Scan(&f.Secret, &confirmedStr, &f.LastStep, &createdStr)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrFactorNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("repository.MFA.GetTOTP: %w", err)
+	}
+	f.UserID = userID
+	f.ConfirmedAt = parseNullTime(confirmedStr)
+	f.CreatedAt, _ = time.Parse(time.RFC3339, createdStr)
+	return &f, nil
+}
+
+// UseTOTPStep records step as the latest accepted code. It only succeeds if
+// step is newer than anything accepted before, so each code works once even
+// under concurrent requests; otherwise it returns ErrTokenNotFound.
+func (r *MFARepository) UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) error {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE totp_factors SET last_step = ? WHERE user_id = ? AND last_step < ?`,
+		step, userID.String(), step,
+	)
+	if err != nil {
+		return fmt.Errorf("repository.MFA.UseTOTPStep: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrTokenNotFound
+	}
+	return nil
+}
+
+// ConfirmTOTP enables the factor and replaces the user's recovery codes.
+func (r *MFARepository) ConfirmTOTP(ctx context.Context, userID uuid.UUID, codeHashes []string, at time.Time) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("repository.MFA.ConfirmTOTP: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE totp_factors SET confirmed_at = ? WHERE user_id = ?`,
+		at.UTC().Format(time.RFC3339), userID.String(),
+	)
+	if err != nil {
+		return fmt.Errorf("repository.MFA.ConfirmTOTP: %w", err)
+	}
+	if err := replaceRecoveryCodes(ctx, tx, userID, codeHashes, at); err != nil {
+		return fmt.Errorf("repository.MFA.ConfirmTOTP: %w", err)
+	}
+	return tx.Commit()
+}
+
+// DeleteTOTP removes the factor together with the recovery codes.
+func (r *MFARepository) DeleteTOTP(ctx context.Context, userID uuid.UUID) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("repository.MFA.DeleteTOTP: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	for _, table := range []string{"totp_factors", "recovery_codes"} {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE user_id = ?`, userID.String()); err != nil {
+			return fmt.Errorf("repository.MFA.DeleteTOTP: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// ReplaceRecoveryCodes discards the user's recovery codes in favour of codeHashes.
+func (r *MFARepository) ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codeHashes []string, at time.Time) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("repository.MFA.ReplaceRecoveryCodes: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, codeHashes, at); err != nil {
+		return fmt.Errorf("repository.MFA.ReplaceRecoveryCodes: %w", err)
+	}
+	return tx.Commit()
+}
+
+// UseRecoveryCode marks an unused code as spent, or returns ErrTokenNotFound.
+func (r *MFARepository) UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string, at time.Time) error {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE recovery_codes SET used_at = ?
+		 WHERE user_id = ? AND code_hash = ? AND used_at IS NULL`,
+		at.UTC().Format(time.RFC3339), userID.String(), codeHash,
+	)
+	if err != nil {
+		return fmt.Errorf("repository.MFA.UseRecoveryCode: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrTokenNotFound
+	}
+	return nil
+}
+
+// CountRecoveryCodes returns how many unused recovery codes the user has left.
+func (r *MFARepository) CountRecoveryCodes(ctx context.Context, userID uuid.UUID) (int, error) {
+	var n int
+	err := r.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM recovery_codes WHERE user_id = ? AND used_at IS NULL`,
+		userID.String(),
+	).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("repository.MFA.CountRecoveryCodes: %w", err)
+	}
+	return n, nil
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userID uuid.UUID, codeHashes []string, at time.Time) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = ?`, userID.String()); err != nil {
+		return err
+	}
+	for _, h := range codeHashes {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO recovery_codes (id, user_id, code_hash, created_at) VALUES (?, ?, ?, ?)`,
+			uuid.NewString(), userID.String(), h, at.UTC().Format(time.RFC3339),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"user-management-api/internal/model"
+)
+
+// ErrClientNotFound is returned when no OAuth client matches.
+var ErrClientNotFound = errors.New("oauth client not found")
+
+const oauthClientColumns = `id, tenant_id, client_id, name, secret_hash, scopes, created_at, updated_at, tokens_valid_after,
+	redirect_uris, first_party`
+
+// OAuthClientRepository scopes lookups by ID to the organization in ctx,
+// like UserRepository. GetByClientID is not scoped: a client authenticates
+// before anyone knows which organization it belongs to.
+type OAuthClientRepository struct {
+	db *sql.DB
+}
+
+func NewOAuthClientRepository(db *sql.DB) *OAuthClientRepository {
+	return &OAuthClientRepository{db: db}
+}
+
+// Create adds c to c.TenantID, or the organization ctx is scoped to, or
+// else the default one.
+func (r *OAuthClientRepository) Create(ctx context.Context, c *model.OAuthClient) error {
+	if c.TenantID == uuid.Nil {
+		c.TenantID = tenantOrDefault(ctx)
+	}
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO oauth_clients (id, tenant_id, client_id, name, secret_hash, scopes, created_at, updated_at, redirect_uris, first_party)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		c.ID.String(), c.TenantID.String(), c.ClientID, c.Name, c.SecretHash, strings.Join(c.Scopes, " "),
+		c.CreatedAt.UTC().Format(time.RFC3339), c.UpdatedAt.UTC().Format(time.RFC3339),
+		strings.Join(c.RedirectURIs, " "), c.FirstParty,
+	)
+	if err != nil {
+		return fmt.Errorf("repository.OAuthClient.Create: %w", err)
+	}
+	return nil
+}
+
+func (r *OAuthClientRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.OAuthClient, error) {
+	clause, args := tenantClause(ctx)
+	return r.getOne(ctx, "repository.OAuthClient.GetByID",
+		`SELECT `+oauthClientColumns+` FROM oauth_clients WHERE id = ?`+clause, append([]any{id.String()}, args...)...)
+}
+
+// GetByClientID looks a client up by its public client_id.
+func (r *OAuthClientRepository) GetByClientID(ctx context.Context, clientID string) (*model.OAuthClient, error) {
+	return r.getOne(ctx, "repository.OAuthClient.GetByClientID",
+		`SELECT `+oauthClientColumns+` FROM oauth_clients WHERE client_id = ?`, clientID)
+}
+
+func (r *OAuthClientRepository) List(ctx context.Context) ([]*model.OAuthClient, error) {
+	clause, args := tenantClause(ctx)
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+oauthClientColumns+` FROM oauth_clients WHERE 1 = 1`+clause+` ORDER BY created_at`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("repository.OAuthClient.List: %w", err)
+	}
+	defer rows.Close()
+
+	clients := []*model.OAuthClient{}
+	for rows.Next() {
+		c, err := scanOAuthClient(rows)
+		if err != nil {
+			return nil, fmt.Errorf("repository.OAuthClient.List: %w", err)
+		}
+		clients = append(clients, c)
+	}
+	return clients, rows.Err()
+}
+
+// Update saves the client's name, scopes, redirect URIs and first-party flag.
+func (r *OAuthClientRepository) Update(ctx context.Context, c *model.OAuthClient) error {
+	clause, args := tenantClause(ctx)
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE oauth_clients SET name = ?, scopes = ?, redirect_uris = ?, first_party = ?, updated_at = ? WHERE id = ?`+clause,
+		append([]any{c.Name, strings.Join(c.Scopes, " "), strings.Join(c.RedirectURIs, " "), c.FirstParty,
+			c.UpdatedAt.UTC().Format(time.RFC3339), c.ID.String()}, args...)...,
+	)
+	if err != nil {
+		return fmt.Errorf("repository.OAuthClient.Update: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrClientNotFound
+	}
+	return nil
+}
+
+// RotateSecret replaces the secret hash and rejects access tokens issued
+// before validAfter.
+func (r *OAuthClientRepository) RotateSecret(ctx context.Context, id uuid.UUID, hash string, validAfter time.Time) error {
+	at := validAfter.UTC().Format(time.RFC3339)
+	clause, args := tenantClause(ctx)
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE oauth_clients SET secret_hash = ?, updated_at = ?, tokens_valid_after = ? WHERE id = ?`+clause,
+		append([]any{hash, at, at, id.String()}, args...)...,
+	)
+	if err != nil {
+		return fmt.Errorf("repository.OAuthClient.RotateSecret: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrClientNotFound
+	}
+	return nil
+}
+
+// Delete removes the client along with its pending codes and the consents
+// users gave it.
+func (r *OAuthClientRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("repository.OAuthClient.Delete: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	clause, args := tenantClause(ctx)
+	args = append([]any{id.String()}, args...)
+	for _, table := range []string{"oauth_codes", "oauth_consents"} {
+		if _, err := tx.ExecContext(ctx,
+			`DELETE FROM `+table+` WHERE client_id = (SELECT client_id FROM oauth_clients WHERE id = ?`+clause+`)`, args...,
+		); err != nil {
+			return fmt.Errorf("repository.OAuthClient.Delete: %w", err)
+		}
+	}
+	res, err := tx.ExecContext(ctx, `DELETE FROM oauth_clients WHERE id = ?`+clause, args...)
+	if err != nil {
+		return fmt.Errorf("repository.OAuthClient.Delete: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrClientNotFound
+	}
+	return tx.Commit()
+}
+
+func (r *OAuthClientRepository) getOne(ctx context.Context, op, query string, args ...any) (*model.OAuthClient, error) {
+	c, err := scanOAuthClient(r.db.QueryRowContext(ctx, query, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrClientNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return c, nil
+}
+
+func scanOAuthClient(row scanner) (*model.OAuthClient, error) {
+	var (
+		c                      model.OAuthClient
+		idStr, tenantStr       string
+		scopes                 string
+		createdStr, updatedStr string
+		validAfterStr          sql.NullString
+		redirectURIs           string
+	)
+	err := row.Scan(&idStr, &tenantStr, &c.ClientID, &c.Name, &c.SecretHash, &scopes, &createdStr, &updatedStr, &validAfterStr,
+		&redirectURIs, &c.FirstParty)
+	if err != nil {
+		return nil, err
+	}
+	c.ID, _ = uuid.Parse(idStr)
+	c.TenantID, _ = uuid.Parse(tenantStr)
+	c.Scopes = strings.Fields(scopes)
+	c.CreatedAt, _ = time.Parse(time.RFC3339, createdStr)
+	c.UpdatedAt, _ = time.Parse(time.RFC3339, updatedStr)
+	c.TokensValidAfter = parseNullTime(validAfterStr)
+	c.RedirectURIs = strings.Fields(redirectURIs)
+	return &c, nil
+}
@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"user-management-api/internal/model"
+)
+
+// ErrCodeNotFound is returned for an authorization code that doesn't exist
+// or has already been exchanged.
+var ErrCodeNotFound = errors.New("authorization code not found")
+
+// OIDCRepository stores OpenID Connect authorization codes and the consents
+// users have given to clients.
+type OIDCRepository struct {
+	db *sql.DB
+}
+
+func NewOIDCRepository(db *sql.DB) *OIDCRepository {
+	return &OIDCRepository{db: db}
+}
+
+func (r *OIDCRepository) CreateCode(ctx context.Context, c *model.AuthorizationCode) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO oauth_codes (code_hash, client_id, user_id, redirect_uri, scopes, nonce, code_challenge, amr, auth_time, expires_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		c.CodeHash, c.ClientID, c.UserID.String(), c.RedirectURI, strings.Join(c.Scopes, " "), c.Nonce,
+		c.CodeChallenge, strings.Join(c.AMR, " "), c.AuthTime.UTC().Format(time.RFC3339),
+		c.ExpiresAt.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("repository.OIDC.CreateCode: %w", err)
+	}
+	return nil
+}
+
+// ConsumeCode marks a code used and returns it, in one statement so that two
+// concurrent exchanges can't both succeed. The caller still checks expiry.
+func (r *OIDCRepository) ConsumeCode(ctx context.Context, hash string, at time.Time) (*model.AuthorizationCode, error) {
+	var (
+		c                       model.AuthorizationCode
+		userStr, scopes, amr    string
+		authTimeStr, expiresStr string
+	)
+	err := r.db.QueryRowContext(ctx,
+		`UPDATE oauth_codes SET used_at = ? WHERE code_hash = ? AND used_at IS NULL
+		 RETURNING code_hash, client_id, user_id, redirect_uri, scopes, nonce, code_challenge, amr, auth_time, expires_at`,
+		at.UTC().Format(time.RFC3339), hash,
+	).Scan(&c.CodeHash, &c.ClientID, &userStr, &c.RedirectURI, &scopes, &c.Nonce, &c.CodeChallenge, &amr,
+		&authTimeStr, &expiresStr)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrCodeNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("repository.OIDC.ConsumeCode: %w", err)
+	}
+	c.UserID, _ = uuid.Parse(userStr)
+	c.Scopes = strings.Fields(scopes)
+	c.AMR = strings.Fields(amr)
+	c.AuthTime, _ = time.Parse(time.RFC3339, authTimeStr)
+	c.ExpiresAt, _ = time.Parse(time.RFC3339, expiresStr)
+	c.UsedAt = &at
+	return &c, nil
+}
+
+// DeleteExpiredCodes removes codes past their expiry, used or not.
+func (r *OIDCRepository) DeleteExpiredCodes(ctx context.Context, now time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx,
+		`DELETE FROM oauth_codes WHERE expires_at < ?`, now.UTC().Format(time.RFC3339))
+	if err != nil {
+		return 0, fmt.Errorf("repository.OIDC.DeleteExpiredCodes: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// Consent returns the scopes the user has agreed to share with the client,
+// or nil if they never have.
+func (r *OIDCRepository) Consent(ctx context.Context, userID uuid.UUID, clientID string) ([]string, error) {
+	var scopes string
+	err := r.db.QueryRowContext(ctx,
+		`SELECT scopes FROM oauth_consents WHERE user_id = ? AND client_id = ?`, userID.String(), clientID,
+	).Scan(&scopes)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("repository.OIDC.Consent: %w", err)
+	}
+	return strings.Fields(scopes), nil
+}
+
+// SaveConsent records the scopes the user has agreed to, replacing any
+// earlier consent for the client.
+func (r *OIDCRepository) SaveConsent(ctx context.Context, userID uuid.UUID, clientID string, scopes []string, at time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO oauth_consents (user_id, client_id, scopes, granted_at) VALUES (?, ?, ?, ?)
+		 ON CONFLICT (user_id, client_id) DO UPDATE SET scopes = excluded.scopes, granted_at = excluded.granted_at`,
+		userID.String(), clientID, strings.Join(scopes, " "), at.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("repository.OIDC.SaveConsent: %w", err)
+	}
+	return nil
+}
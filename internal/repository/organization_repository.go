@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"user-management-api/internal/model"
+)
+
+var (
+	// ErrOrganizationNotFound is returned when no organization matches.
+	ErrOrganizationNotFound = errors.New("organization not found")
+	// ErrSlugTaken is returned when another organization already has the slug.
+	ErrSlugTaken = errors.New("organization slug already in use")
+)
+
+const organizationColumns = `id, name, slug, created_at`
+
+type OrganizationRepository struct {
+	db *sql.DB
+}
+
+func NewOrganizationRepository(db *sql.DB) *OrganizationRepository {
+	return &OrganizationRepository{db: db}
+}
+
+func (r *OrganizationRepository) Create(ctx context.Context, o *model.Organization) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO organizations (id, name, slug, created_at) VALUES (?, ?, ?, ?)`,
+		o.ID.String(), o.Name, o.Slug, o.CreatedAt.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrSlugTaken
+		}
+		return fmt.Errorf("repository.Organization.Create: %w", err)
+	}
+	return nil
+}
+
+func (r *OrganizationRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.Organization, error) {
+	return r.getOne(ctx, "repository.Organization.GetByID",
+		`SELECT `+organizationColumns+` FROM organizations WHERE id = ?`, id.String())
+}
+
+func (r *OrganizationRepository) GetBySlug(ctx context.Context, slug string) (*model.Organization, error) {
+	return r.getOne(ctx, "repository.Organization.GetBySlug",
+		`SELECT `+organizationColumns+` FROM organizations WHERE slug = ?`, slug)
+}
+
+// List returns organizations oldest first, so the default one leads.
+func (r *OrganizationRepository) List(ctx context.Context, limit, offset int) ([]*model.Organization, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+organizationColumns+` FROM organizations ORDER BY created_at, rowid LIMIT ? OFFSET ?`,
+		limit, offset,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("repository.Organization.List: %w", err)
+	}
+	defer rows.Close()
+
+	orgs := []*model.Organization{}
+	for rows.Next() {
+		o, err := scanOrganization(rows)
+		if err != nil {
+			return nil, fmt.Errorf("repository.Organization.List: %w", err)
+		}
+		orgs = append(orgs, o)
+	}
+	return orgs, rows.Err()
+}
+
+func (r *OrganizationRepository) getOne(ctx context.Context, op, query string, arg any) (*model.Organization, error) {
+	o, err := scanOrganization(r.db.QueryRowContext(ctx, query, arg))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrOrganizationNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return o, nil
+}
+
+func scanOrganization(row scanner) (*model.Organization, error) {
+	var (
+		o                 model.Organization
+		idStr, createdStr string
+	)
+	if err := row.Scan(&idStr, &o.Name, &o.Slug, &createdStr); err != nil {
+		return nil, err
+	}
+	o.ID, _ = uuid.Parse(idStr)
+	o.CreatedAt, _ = time.Parse(time.RFC3339, createdStr)
+	return &o, nil
+}
@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"user-management-api/internal/model"
+)
+
+// ErrPersonalTokenNotFound is returned when no personal access token matches.
+var ErrPersonalTokenNotFound = errors.New("personal access token not found")
+
+const personalTokenColumns = `id, user_id, name, token_hash, scopes, expires_at, created_at, last_used_at, last_used_ip`
+
+type PersonalTokenRepository struct {
+	db *sql.DB
+}
+
+func NewPersonalTokenRepository(db *sql.DB) *PersonalTokenRepository {
+	return &PersonalTokenRepository{db: db}
+}
+
+func (r *PersonalTokenRepository) Create(ctx context.Context, t *model.PersonalAccessToken) error {
+	var expires sql.NullString
+	if t.ExpiresAt != nil {
+		expires = nullString(t.ExpiresAt.UTC().Format(time.RFC3339))
+	}
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO personal_access_tokens (id, user_id, name, token_hash, scopes, expires_at, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		t.ID.String(), t.UserID.String(), t.Name, t.TokenHash, strings.Join(t.Scopes, " "), expires,
+		t.CreatedAt.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("repository.PersonalToken.Create: %w", err)
+	}
+	return nil
+}
+
+func (r *PersonalTokenRepository) GetByHash(ctx context.Context, hash string) (*model.PersonalAccessToken, error) {
+	t, err := scanPersonalToken(r.db.QueryRowContext(ctx,
+		`SELECT `+personalTokenColumns+` FROM personal_access_tokens WHERE token_hash = ?`, hash,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrPersonalTokenNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("repository.PersonalToken.GetByHash: %w", err)
+	}
+	return t, nil
+}
+
+func (r *PersonalTokenRepository) ListForUser(ctx context.Context, userID uuid.UUID) ([]*model.PersonalAccessToken, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+personalTokenColumns+` FROM personal_access_tokens WHERE user_id = ? ORDER BY created_at`,
+		userID.String(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("repository.PersonalToken.ListForUser: %w", err)
+	}
+	defer rows.Close()
+
+	tokens := []*model.PersonalAccessToken{}
+	for rows.Next() {
+		t, err := scanPersonalToken(rows)
+		if err != nil {
+			return nil, fmt.Errorf("repository.PersonalToken.ListForUser: %w", err)
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
+
+// MarkUsed records when and from where the token was last presented.
+func (r *PersonalTokenRepository) MarkUsed(ctx context.Context, id uuid.UUID, at time.Time, ip string) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE personal_access_tokens SET last_used_at = ?, last_used_ip = ? WHERE id = ?`,
+		at.UTC().Format(time.RFC3339), nullString(ip), id.String(),
+	)
+	if err != nil {
+		return fmt.Errorf("repository.PersonalToken.MarkUsed: %w", err)
+	}
+	return nil
+}
+
+// Delete revokes one of the user's tokens.
+func (r *PersonalTokenRepository) Delete(ctx context.Context, userID, id uuid.UUID) error {
+	res, err := r.db.ExecContext(ctx,
+		`DELETE FROM personal_access_tokens WHERE id = ? AND user_id = ?`, id.String(), userID.String(),
+	)
+	if err != nil {
+		return fmt.Errorf("repository.PersonalToken.Delete: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrPersonalTokenNotFound
+	}
+	return nil
+}
+
+func scanPersonalToken(row scanner) (*model.PersonalAccessToken, error) {
+	var (
+		t                       model.PersonalAccessToken
+		idStr, userStr          string
+		scopes, createdStr      string
+		expiresStr, lastUsedStr sql.NullString
+		lastUsedIP              sql.NullString
+	)
+	err := row.Scan(&idStr, &userStr, &t.Name, &t.TokenHash, &scopes, &expiresStr, &createdStr, &lastUsedStr, &lastUsedIP)
+	if err != nil {
+		return nil, err
+	}
+	t.ID, _ = uuid.Parse(idStr)
+	t.UserID, _ = uuid.Parse(userStr)
+	t.Scopes = strings.Fields(scopes)
+	t.ExpiresAt = parseNullTime(expiresStr)
+	t.CreatedAt, _ = time.Parse(time.RFC3339, createdStr)
+	t.LastUsedAt = parseNullTime(lastUsedStr)
+	t.LastUsedIP = lastUsedIP.String
+	return &t, nil
+}
@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"user-management-api/internal/model"
+)
+
+// ErrTokenNotFound is returned when no refresh token matches the given hash.
+var ErrTokenNotFound = errors.New("token not found")
+
+type RefreshTokenRepository struct {
+	db *sql.DB
+}
+
+func NewRefreshTokenRepository(db *sql.DB) *RefreshTokenRepository {
+	return &RefreshTokenRepository{db: db}
+}
+
+func (r *RefreshTokenRepository) Create(ctx context.Context, t *model.RefreshToken) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO refresh_tokens (id, user_id, family_id, token_hash, expires_at, created_at, amr)
+		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		t.ID.String(), t.UserID.String(), t.FamilyID.String(), t.TokenHash,
+		t.ExpiresAt.UTC().Format(time.RFC3339),
+		t.CreatedAt.UTC().Format(time.RFC3339),
+		strings.Join(t.AMR, " "),
+	)
+	if err != nil {
+		return fmt.Errorf("repository.RefreshToken.Create: %w", err)
+	}
+	return nil
+}
+
+func (r *RefreshTokenRepository) GetByHash(ctx context.Context, hash string) (*model.RefreshToken, error) {
+	var (
+		t                         model.RefreshToken
+		idStr, userStr, familyStr string
+		expiresStr, createdStr    string
+		rotatedStr, revokedStr    sql.NullString
+		amr                       string
+	)
+	err := r.db.QueryRowContext(ctx,
+		`SELECT id, user_id, family_id, token_hash, expires_at, created_at, rotated_at, revoked_at, amr
+		 FROM refresh_tokens WHERE token_hash = ?`,
+		hash,
+	).Scan(&idStr, &userStr, &familyStr, &t.TokenHash, &expiresStr, &createdStr, &rotatedStr, &revokedStr, &amr)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTokenNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("repository.RefreshToken.GetByHash: %w", err)
+	}
+	t.ID, _ = uuid.Parse(idStr)
+	t.UserID, _ = uuid.Parse(userStr)
+	t.FamilyID, _ = uuid.Parse(familyStr)
+	t.ExpiresAt, _ = time.Parse(time.RFC3339, expiresStr)
+	t.CreatedAt, _ = time.Parse(time.RFC3339, createdStr)
+	t.RotatedAt = parseNullTime(rotatedStr)
+	t.RevokedAt = parseNullTime(revokedStr)
+	t.AMR = strings.Fields(amr)
+	return &t, nil
+}
+
+// MarkRotated flags a token as consumed. It only succeeds for a token that is
+// still live, so two concurrent refreshes with the same token cannot both win:
+// the loser gets ErrTokenNotFound and must treat it as a replay.
+func (r *RefreshTokenRepository) MarkRotated(ctx context.Context, id uuid.UUID, at time.Time) error {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE refresh_tokens SET rotated_at = ?
+		 WHERE id = ? AND rotated_at IS NULL AND revoked_at IS NULL`,
+		at.UTC().Format(time.RFC3339), id.String(),
+	)
+	if err != nil {
+		return fmt.Errorf("repository.RefreshToken.MarkRotated: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrTokenNotFound
+	}
+	return nil
+}
+
+// RevokeFamily revokes every not-yet-revoked token descending from the same sign-in.
+func (r *RefreshTokenRepository) RevokeFamily(ctx context.Context, familyID uuid.UUID, at time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE refresh_tokens SET revoked_at = ? WHERE family_id = ? AND revoked_at IS NULL`,
+		at.UTC().Format(time.RFC3339), familyID.String(),
+	)
+	if err != nil {
+		return fmt.Errorf("repository.RefreshToken.RevokeFamily: %w", err)
+	}
+	return nil
+}
+
+// RevokeForUser revokes every live refresh token belonging to the user.
+func (r *RefreshTokenRepository) RevokeForUser(ctx context.Context, userID uuid.UUID, at time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE refresh_tokens SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL`,
+		at.UTC().Format(time.RFC3339), userID.String(),
+	)
+	if err != nil {
+		return fmt.Errorf("repository.RefreshToken.RevokeForUser: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpired removes refresh tokens whose expiry is before now.
+func (r *RefreshTokenRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx,
+		`DELETE FROM refresh_tokens WHERE expires_at < ?`,
+		now.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("repository.RefreshToken.DeleteExpired: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// --- helpers ---
+
+func parseNullTime(s sql.NullString) *time.Time {
+	if !s.Valid {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339, s.String)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RevokedTokenRepository is a deny-list of access token IDs (jti). Entries only
+// need to outlive the token they block, so each carries the token's expiry and
+// is purged once that passes.
+type RevokedTokenRepository struct {
+	db *sql.DB
+}
+
+func NewRevokedTokenRepository(db *sql.DB) *RevokedTokenRepository {
+	return &RevokedTokenRepository{db: db}
+}
+
+func (r *RevokedTokenRepository) Revoke(ctx context.Context, jti string, userID uuid.UUID, expiresAt time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO revoked_tokens (jti, user_id, expires_at) VALUES (?, ?, ?)
+		 ON CONFLICT (jti) DO NOTHING`,
+		jti, userID.String(), expiresAt.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("repository.RevokedToken.Revoke: %w", err)
+	}
+	return nil
+}
+
+func (r *RevokedTokenRepository) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	var one int
+	err := r.db.QueryRowContext(ctx,
+		`SELECT 1 FROM revoked_tokens WHERE jti = ?`, jti,
+	).Scan(&one)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("repository.RevokedToken.IsRevoked: %w", err)
+	}
+	return true, nil
+}
+
+// DeleteExpired drops deny-list entries for tokens that have expired anyway.
+func (r *RevokedTokenRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx,
+		`DELETE FROM revoked_tokens WHERE expires_at < ?`,
+		now.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("repository.RevokedToken.DeleteExpired: %w", err)
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// ErrUnknownRole is returned when assigning a role that does not exist.
+var ErrUnknownRole = errors.New("unknown role")
+
+type RoleRepository struct {
+	db *sql.DB
+}
+
+func NewRoleRepository(db *sql.DB) *RoleRepository {
+	return &RoleRepository{db: db}
+}
+
+// Assign grants a role to a user. Granting a role the user already has is a no-op.
+func (r *RoleRepository) Assign(ctx context.Context, userID uuid.UUID, role string) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO user_roles (user_id, role)
+		 SELECT ?, name FROM roles WHERE name = ?
+		 ON CONFLICT (user_id, role) DO NOTHING`,
+		userID.String(), role,
+	)
+	if err != nil {
+		return fmt.Errorf("repository.Role.Assign: %w", err)
+	}
+	return nil
+}
+
+// SetForUser replaces the user's roles in a single transaction.
+func (r *RoleRepository) SetForUser(ctx context.Context, userID uuid.UUID, roles []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("repository.Role.SetForUser: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_roles WHERE user_id = ?`, userID.String()); err != nil {
+		return fmt.Errorf("repository.Role.SetForUser: %w", err)
+	}
+	for _, role := range roles {
+		var exists int
+		if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM roles WHERE name = ?`, role).Scan(&exists); err != nil {
+			return fmt.Errorf("repository.Role.SetForUser: %w", err)
+		}
+		if exists == 0 {
+			return ErrUnknownRole
+		}
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO user_roles (user_id, role) VALUES (?, ?)
+			 ON CONFLICT (user_id, role) DO NOTHING`,
+			userID.String(), role,
+		); err != nil {
+			return fmt.Errorf("repository.Role.SetForUser: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// ForUser returns the names of the roles granted to the user, sorted.
+func (r *RoleRepository) ForUser(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	return r.queryStrings(ctx, "repository.Role.ForUser",
+		`SELECT role FROM user_roles WHERE user_id = ? ORDER BY role`, userID.String())
+}
+
+// Permissions returns the distinct permissions granted by any of the roles, sorted.
+func (r *RoleRepository) Permissions(ctx context.Context, roles []string) ([]string, error) {
+	if len(roles) == 0 {
+		return nil, nil
+	}
+	args := make([]any, len(roles))
+	for i, role := range roles {
+		args[i] = role
+	}
+	return r.queryStrings(ctx, "repository.Role.Permissions",
+		`SELECT DISTINCT permission FROM role_permissions
+		 WHERE role IN (?`+strings.Repeat(", ?", len(roles)-1)+`)
+		 ORDER BY permission`, args...)
+}
+
+func (r *RoleRepository) queryStrings(ctx context.Context, op, query string, args ...any) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var out []string
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
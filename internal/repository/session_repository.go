@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"user-management-api/internal/model"
+)
+
+// ErrSessionNotFound is returned when no live session matches.
+var ErrSessionNotFound = errors.New("session not found")
+
+const sessionColumns = `id, user_id, user_agent, ip, created_at, last_seen_at, expires_at, revoked_at`
+
+type SessionRepository struct {
+	db *sql.DB
+}
+
+func NewSessionRepository(db *sql.DB) *SessionRepository {
+	return &SessionRepository{db: db}
+}
+
+func (r *SessionRepository) Create(ctx context.Context, s *model.Session) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO sessions (id, user_id, user_agent, ip, created_at, last_seen_at, expires_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		s.ID.String(), s.UserID.String(), s.UserAgent, s.IP,
+		s.CreatedAt.UTC().Format(time.RFC3339),
+		s.LastSeenAt.UTC().Format(time.RFC3339),
+		s.ExpiresAt.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("repository.Session.Create: %w", err)
+	}
+	return nil
+}
+
+// Get returns a session whether or not it is still live.
+func (r *SessionRepository) Get(ctx context.Context, id uuid.UUID) (*model.Session, error) {
+	s, err := scanSession(r.db.QueryRowContext(ctx,
+		`SELECT `+sessionColumns+` FROM sessions WHERE id = ?`, id.String(),
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("repository.Session.Get: %w", err)
+	}
+	return s, nil
+}
+
+// ListForUser returns the user's sessions that are neither revoked nor
+// expired, most recently seen first.
+func (r *SessionRepository) ListForUser(ctx context.Context, userID uuid.UUID, now time.Time) ([]*model.Session, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+sessionColumns+` FROM sessions
+		 WHERE user_id = ? AND revoked_at IS NULL AND expires_at > ?
+		 ORDER BY last_seen_at DESC, created_at DESC`,
+		userID.String(), now.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("repository.Session.ListForUser: %w", err)
+	}
+	defer rows.Close()
+
+	sessions := []*model.Session{}
+	for rows.Next() {
+		s, err := scanSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("repository.Session.ListForUser: %w", err)
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+// Touch records when and from where the session was last used.
+func (r *SessionRepository) Touch(ctx context.Context, id uuid.UUID, at time.Time, ip string) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE sessions SET last_seen_at = ?, ip = COALESCE(NULLIF(?, ''), ip) WHERE id = ?`,
+		at.UTC().Format(time.RFC3339), ip, id.String(),
+	)
+	if err != nil {
+		return fmt.Errorf("repository.Session.Touch: %w", err)
+	}
+	return nil
+}
+
+// Extend moves a live session's expiry, returning ErrSessionNotFound if
+// there is no such session.
+func (r *SessionRepository) Extend(ctx context.Context, id uuid.UUID, expiresAt time.Time) error {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE sessions SET expires_at = ? WHERE id = ? AND revoked_at IS NULL`,
+		expiresAt.UTC().Format(time.RFC3339), id.String(),
+	)
+	if err != nil {
+		return fmt.Errorf("repository.Session.Extend: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// Revoke ends one of the user's live sessions.
+func (r *SessionRepository) Revoke(ctx context.Context, userID, id uuid.UUID, at time.Time) error {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE sessions SET revoked_at = ? WHERE id = ? AND user_id = ? AND revoked_at IS NULL`,
+		at.UTC().Format(time.RFC3339), id.String(), userID.String(),
+	)
+	if err != nil {
+		return fmt.Errorf("repository.Session.Revoke: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// RevokeForUser ends every live session of the user.
+func (r *SessionRepository) RevokeForUser(ctx context.Context, userID uuid.UUID, at time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE sessions SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL`,
+		at.UTC().Format(time.RFC3339), userID.String(),
+	)
+	if err != nil {
+		return fmt.Errorf("repository.Session.RevokeForUser: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpired removes sessions that have expired or been revoked.
+func (r *SessionRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx,
+		`DELETE FROM sessions WHERE expires_at < ? OR revoked_at IS NOT NULL`,
+		now.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("repository.Session.DeleteExpired: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+func scanSession(row scanner) (*model.Session, error) {
+	var (
+		s                                  model.Session
+		idStr, userStr                     string
+		createdStr, lastSeenStr, expiresAt string
+		revokedStr                         sql.NullString
+	)
+	err := row.Scan(&idStr, &userStr, &s.UserAgent, &s.IP, &createdStr, &lastSeenStr, &expiresAt, &revokedStr)
+	if err != nil {
+		return nil, err
+	}
+	s.ID, _ = uuid.Parse(idStr)
+	s.UserID, _ = uuid.Parse(userStr)
+	s.CreatedAt, _ = time.Parse(time.RFC3339, createdStr)
+	s.LastSeenAt, _ = time.Parse(time.RFC3339, lastSeenStr)
+	s.ExpiresAt, _ = time.Parse(time.RFC3339, expiresAt)
+	s.RevokedAt = parseNullTime(revokedStr)
+	return &s, nil
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SignInFailureRepository counts failed sign-ins per client IP. A row's count
+// starts over once its last failure is older than the caller's window, and
+// the row itself is purged after that.
+type SignInFailureRepository struct {
+	db *sql.DB
+}
+
+func NewSignInFailureRepository(db *sql.DB) *SignInFailureRepository {
+	return &SignInFailureRepository{db: db}
+}
+
+// Record adds a failure for ip at now and returns the failures seen within
+// window, including this one.
+func (r *SignInFailureRepository) Record(ctx context.Context, ip string, now time.Time, window time.Duration) (int, error) {
+	var n int
+	err := r.db.QueryRowContext(ctx,
+		`INSERT INTO ip_sign_in_failures (ip, failures, last_failed_at) VALUES (?, 1, ?)
+		 ON CONFLICT (ip) DO UPDATE SET
+		     failures = CASE WHEN last_failed_at < ? THEN 1 ELSE failures + 1 END,
+		     last_failed_at = excluded.last_failed_at
+		 RETURNING failures`,
+		ip, now.UTC().Format(time.RFC3339), now.Add(-window).UTC().Format(time.RFC3339),
+	).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("repository.SignInFailure.Record: %w", err)
+	}
+	return n, nil
+}
+
+// BlockUntil refuses sign-ins from ip until t.
+func (r *SignInFailureRepository) BlockUntil(ctx context.Context, ip string, t time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE ip_sign_in_failures SET blocked_until = ? WHERE ip = ?`,
+		t.UTC().Format(time.RFC3339), ip,
+	)
+	if err != nil {
+		return fmt.Errorf("repository.SignInFailure.BlockUntil: %w", err)
+	}
+	return nil
+}
+
+// BlockedUntil returns when ip's block ends, or nil if it has none.
+func (r *SignInFailureRepository) BlockedUntil(ctx context.Context, ip string) (*time.Time, error) {
+	var until sql.NullString
+	err := r.db.QueryRowContext(ctx,
+		`SELECT blocked_until FROM ip_sign_in_failures WHERE ip = ?`, ip,
+	).Scan(&until)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("repository.SignInFailure.BlockedUntil: %w", err)
+	}
+	return parseNullTime(until), nil
+}
+
+// DeleteStale drops IPs with no failure since cutoff and no block still running.
+func (r *SignInFailureRepository) DeleteStale(ctx context.Context, cutoff, now time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx,
+		`DELETE FROM ip_sign_in_failures
+		 WHERE last_failed_at < ? AND (blocked_until IS NULL OR blocked_until < ?)`,
+		cutoff.UTC().Format(time.RFC3339), now.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("repository.SignInFailure.DeleteStale: %w", err)
+	}
+	return res.RowsAffected()
+}
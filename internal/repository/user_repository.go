@@ -11,6 +11,7 @@ import (
 	"github.com/google/uuid"
 
 	"user-management-api/internal/model"
+	"user-management-api/internal/reqctx"
 )
 
 var (
@@ -18,19 +19,51 @@ var (
 	ErrEmailTaken = errors.New("email already in use")
 )
 
+// userColumns is the column list every SELECT must use so scanOne/scanRow line up.
+const userColumns = `id, tenant_id, name, email, password_hash, created_at, updated_at, tokens_valid_after, deleted_at,
+	email_verified_at, pending_email, failed_sign_ins, locked_until`
+
+// userOwnedTables lists every table keyed by user_id whose rows must go when
+// a user is purged. Tables added later for per-user data belong here too.
+var userOwnedTables = []string{
+	"refresh_tokens", "revoked_tokens", "user_roles", "action_tokens",
+	"totp_factors", "recovery_codes", "webauthn_credentials", "personal_access_tokens",
+	"oauth_codes", "oauth_consents", "user_identities", "sessions", "memberships",
+}
+
+// EmailScope is where an email address must be unique.
+type EmailScope string
+
+const (
+	// EmailsGlobal gives an address to one account across all organizations.
+	EmailsGlobal EmailScope = "global"
+	// EmailsPerTenant lets each organization have its own account for an
+	// address. Users must then name their organization to sign in.
+	EmailsPerTenant EmailScope = "tenant"
+)
+
+// UserRepository keeps the users of every organization. When the context is
+// scoped to an organization (reqctx.WithTenant), every query sees only that
+// organization's users, so callers can't reach across tenants by ID.
 type UserRepository struct {
-	db *sql.DB
+	db     *sql.DB
+	emails EmailScope
 }
 
-func NewUserRepository(db *sql.DB) *UserRepository {
-	return &UserRepository{db: db}
+func NewUserRepository(db *sql.DB, emails EmailScope) *UserRepository {
+	return &UserRepository{db: db, emails: emails}
 }
 
+// Create adds u to u.TenantID, or when that is unset to the organization
+// ctx is scoped to, or else the default one.
 func (r *UserRepository) Create(ctx context.Context, u *model.User) error {
+	if u.TenantID == uuid.Nil {
+		u.TenantID = tenantOrDefault(ctx)
+	}
 	_, err := r.db.ExecContext(ctx,
-		`INSERT INTO users (id, name, email, password_hash, created_at, updated_at)
-		 VALUES (?, ?, ?, ?, ?, ?)`,
-		u.ID.String(), u.Name, u.Email, u.PasswordHash,
+		`INSERT INTO users (id, tenant_id, name, email, email_scope, password_hash, created_at, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		u.ID.String(), u.TenantID.String(), u.Name, u.Email, r.emailScope(u.TenantID), u.PasswordHash,
 		u.CreatedAt.UTC().Format(time.RFC3339),
 		u.UpdatedAt.UTC().Format(time.RFC3339),
 	)
@@ -44,29 +77,79 @@ func (r *UserRepository) Create(ctx context.Context, u *model.User) error {
 }
 
 func (r *UserRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.User, error) {
+	clause, args := tenantClause(ctx)
 	row := r.db.QueryRowContext(ctx,
-		`SELECT id, name, email, password_hash, created_at, updated_at
-		 FROM users WHERE id = ?`,
-		id.String(),
+		`SELECT `+userColumns+`
+		 FROM users WHERE id = ? AND deleted_at IS NULL`+clause,
+		append([]any{id.String()}, args...)...,
 	)
 	return scanOne(row)
 }
 
+// GetDeletedByID returns a soft-deleted user; live users yield ErrNotFound.
+func (r *UserRepository) GetDeletedByID(ctx context.Context, id uuid.UUID) (*model.User, error) {
+	clause, args := tenantClause(ctx)
+	row := r.db.QueryRowContext(ctx,
+		`SELECT `+userColumns+`
+		 FROM users WHERE id = ? AND deleted_at IS NOT NULL`+clause,
+		append([]any{id.String()}, args...)...,
+	)
+	return scanOne(row)
+}
+
+// GetByEmail finds a live user by address. With per-organization addresses
+// and no organization in ctx, it looks in the default organization.
 func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*model.User, error) {
+	clause, args := tenantClause(ctx)
+	if clause == "" && r.emails == EmailsPerTenant {
+		clause, args = " AND tenant_id = ?", []any{model.DefaultTenantID.String()}
+	}
 	row := r.db.QueryRowContext(ctx,
-		`SELECT id, name, email, password_hash, created_at, updated_at
-		 FROM users WHERE email = ?`,
-		email,
+		`SELECT `+userColumns+`
+		 FROM users WHERE email = ? AND deleted_at IS NULL`+clause,
+		append([]any{email}, args...)...,
 	)
 	return scanOne(row)
 }
 
+// EmailInUse reports whether email belongs to another account that it must
+// be unique against, for a user of tenantID. Soft-deleted accounts count:
+// they keep their address until purged.
+func (r *UserRepository) EmailInUse(ctx context.Context, tenantID uuid.UUID, email string) (bool, error) {
+	var used bool
+	err := r.db.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM users WHERE email = ? AND email_scope = ?)`,
+		email, r.emailScope(tenantID),
+	).Scan(&used)
+	if err != nil {
+		return false, fmt.Errorf("repository.EmailInUse: %w", err)
+	}
+	return used, nil
+}
+
+// ApplyEmailScope brings every user in line with the repository's
+// EmailScope, after EMAIL_UNIQUENESS changes. Moving to EmailsGlobal fails
+// with ErrEmailTaken while any address is used in more than one organization.
+func (r *UserRepository) ApplyEmailScope(ctx context.Context) error {
+	scope := "''"
+	if r.emails == EmailsPerTenant {
+		scope = "tenant_id"
+	}
+	_, err := r.db.ExecContext(ctx, `UPDATE users SET email_scope = `+scope+` WHERE email_scope <> `+scope)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrEmailTaken
+		}
+		return fmt.Errorf("repository.ApplyEmailScope: %w", err)
+	}
+	return nil
+}
+
 func (r *UserRepository) Update(ctx context.Context, u *model.User) error {
+	clause, targs := tenantClause(ctx)
 	res, err := r.db.ExecContext(ctx,
-		`UPDATE users SET name = ?, email = ?, updated_at = ? WHERE id = ?`,
-		u.Name, u.Email,
-		u.UpdatedAt.UTC().Format(time.RFC3339),
-		u.ID.String(),
+		`UPDATE users SET name = ?, email = ?, pending_email = ?, updated_at = ? WHERE id = ? AND deleted_at IS NULL`+clause,
+		append([]any{u.Name, u.Email, nullString(u.PendingEmail), u.UpdatedAt.UTC().Format(time.RFC3339), u.ID.String()}, targs...)...,
 	)
 	if err != nil {
 		if isUniqueViolation(err) {
@@ -80,15 +163,216 @@ func (r *UserRepository) Update(ctx context.Context, u *model.User) error {
 	return nil
 }
 
+// MarkEmailVerified sets email as the user's verified address and clears any
+// pending change. Returns ErrEmailTaken if another account claimed it meanwhile.
+func (r *UserRepository) MarkEmailVerified(ctx context.Context, id uuid.UUID, email string, at time.Time) error {
+	clause, targs := tenantClause(ctx)
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE users SET email = ?, email_verified_at = ?, pending_email = NULL, updated_at = ?
+		 WHERE id = ? AND deleted_at IS NULL`+clause,
+		append([]any{email, at.UTC().Format(time.RFC3339), at.UTC().Format(time.RFC3339), id.String()}, targs...)...,
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrEmailTaken
+		}
+		return fmt.Errorf("repository.MarkEmailVerified: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// UpdatePassword replaces the user's password hash.
+func (r *UserRepository) UpdatePassword(ctx context.Context, id uuid.UUID, hash string, at time.Time) error {
+	clause, targs := tenantClause(ctx)
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE users SET password_hash = ?, updated_at = ? WHERE id = ? AND deleted_at IS NULL`+clause,
+		append([]any{hash, at.UTC().Format(time.RFC3339), id.String()}, targs...)...,
+	)
+	if err != nil {
+		return fmt.Errorf("repository.UpdatePassword: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RehashPassword swaps the stored hash for an equivalent one made with newer
+// settings. It is a no-op if the password changed since oldHash was read.
+func (r *UserRepository) RehashPassword(ctx context.Context, id uuid.UUID, oldHash, newHash string) error {
+	clause, targs := tenantClause(ctx)
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE users SET password_hash = ? WHERE id = ? AND password_hash = ?`+clause,
+		append([]any{newHash, id.String(), oldHash}, targs...)...,
+	)
+	if err != nil {
+		return fmt.Errorf("repository.RehashPassword: %w", err)
+	}
+	return nil
+}
+
+// SetTokensValidAfter invalidates every access token for the user issued before t.
+func (r *UserRepository) SetTokensValidAfter(ctx context.Context, id uuid.UUID, t time.Time) error {
+	clause, targs := tenantClause(ctx)
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE users SET tokens_valid_after = ? WHERE id = ?`+clause,
+		append([]any{t.UTC().Format(time.RFC3339), id.String()}, targs...)...,
+	)
+	if err != nil {
+		return fmt.Errorf("repository.SetTokensValidAfter: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RecordSignInFailure bumps the user's consecutive failed sign-ins and
+// returns the new count.
+func (r *UserRepository) RecordSignInFailure(ctx context.Context, id uuid.UUID) (int, error) {
+	var n int
+	clause, targs := tenantClause(ctx)
+	err := r.db.QueryRowContext(ctx,
+		`UPDATE users SET failed_sign_ins = failed_sign_ins + 1 WHERE id = ?`+clause+` RETURNING failed_sign_ins`,
+		append([]any{id.String()}, targs...)...,
+	).Scan(&n)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("repository.RecordSignInFailure: %w", err)
+	}
+	return n, nil
+}
+
+// LockUntil blocks sign-in for the user until t.
+func (r *UserRepository) LockUntil(ctx context.Context, id uuid.UUID, t time.Time) error {
+	clause, targs := tenantClause(ctx)
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE users SET locked_until = ? WHERE id = ?`+clause,
+		append([]any{t.UTC().Format(time.RFC3339), id.String()}, targs...)...,
+	)
+	if err != nil {
+		return fmt.Errorf("repository.LockUntil: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ResetSignInFailures clears the failure count and any lock.
+func (r *UserRepository) ResetSignInFailures(ctx context.Context, id uuid.UUID) error {
+	clause, targs := tenantClause(ctx)
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE users SET failed_sign_ins = 0, locked_until = NULL WHERE id = ?`+clause,
+		append([]any{id.String()}, targs...)...,
+	)
+	if err != nil {
+		return fmt.Errorf("repository.ResetSignInFailures: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SoftDelete hides a live user from every lookup. The row, and with it the
+// email address, is kept until Purge.
+func (r *UserRepository) SoftDelete(ctx context.Context, id uuid.UUID, at time.Time) error {
+	clause, targs := tenantClause(ctx)
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE users SET deleted_at = ?, updated_at = ? WHERE id = ? AND deleted_at IS NULL`+clause,
+		append([]any{at.UTC().Format(time.RFC3339), at.UTC().Format(time.RFC3339), id.String()}, targs...)...,
+	)
+	if err != nil {
+		return fmt.Errorf("repository.SoftDelete: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Restore undoes SoftDelete.
+func (r *UserRepository) Restore(ctx context.Context, id uuid.UUID, at time.Time) error {
+	clause, targs := tenantClause(ctx)
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE users SET deleted_at = NULL, updated_at = ? WHERE id = ? AND deleted_at IS NOT NULL`+clause,
+		append([]any{at.UTC().Format(time.RFC3339), id.String()}, targs...)...,
+	)
+	if err != nil {
+		return fmt.Errorf("repository.Restore: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DeletedBefore returns the IDs of users soft-deleted before cutoff.
+func (r *UserRepository) DeletedBefore(ctx context.Context, cutoff time.Time) ([]uuid.UUID, error) {
+	clause, targs := tenantClause(ctx)
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT id FROM users WHERE deleted_at IS NOT NULL AND deleted_at < ?`+clause,
+		append([]any{cutoff.UTC().Format(time.RFC3339)}, targs...)...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("repository.DeletedBefore: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var idStr string
+		if err := rows.Scan(&idStr); err != nil {
+			return nil, fmt.Errorf("repository.DeletedBefore: %w", err)
+		}
+		id, _ := uuid.Parse(idStr)
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// Purge permanently removes a soft-deleted user and everything they own,
+// freeing their email address. Live users are never purged.
+func (r *UserRepository) Purge(ctx context.Context, id uuid.UUID) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("repository.Purge: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	clause, targs := tenantClause(ctx)
+	res, err := tx.ExecContext(ctx, `DELETE FROM users WHERE id = ? AND deleted_at IS NOT NULL`+clause,
+		append([]any{id.String()}, targs...)...)
+	if err != nil {
+		return fmt.Errorf("repository.Purge: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	for _, table := range userOwnedTables {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE user_id = ?`, id.String()); err != nil {
+			return fmt.Errorf("repository.Purge: %s: %w", table, err)
+		}
+	}
+	return tx.Commit()
+}
+
 // List returns users whose email contains emailFilter (case-insensitive).
 // An empty emailFilter matches all users.
 func (r *UserRepository) List(ctx context.Context, emailFilter string, limit, offset int) ([]*model.User, error) {
+	clause, targs := tenantClause(ctx)
 	rows, err := r.db.QueryContext(ctx,
-		`SELECT id, name, email, password_hash, created_at, updated_at
-		 FROM users WHERE LOWER(email) LIKE LOWER(?)
+		`SELECT `+userColumns+`
+		 FROM users WHERE LOWER(email) LIKE LOWER(?) AND deleted_at IS NULL`+clause+`
 		 ORDER BY created_at DESC
 		 LIMIT ? OFFSET ?`,
-		"%"+emailFilter+"%", limit, offset,
+		append(append([]any{"%" + emailFilter + "%"}, targs...), limit, offset)...,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("repository.List: %w", err)
@@ -108,39 +392,86 @@ func (r *UserRepository) List(ctx context.Context, emailFilter string, limit, of
 
 // --- helpers ---
 
+// scanner is satisfied by both *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
 func scanOne(row *sql.Row) (*model.User, error) {
-	var (
-		u                             model.User
-		idStr, createdStr, updatedStr string
-	)
-	err := row.Scan(&idStr, &u.Name, &u.Email, &u.PasswordHash, &createdStr, &updatedStr)
+	u, err := scanUser(row)
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, ErrNotFound
 	}
 	if err != nil {
 		return nil, fmt.Errorf("repository.scanOne: %w", err)
 	}
-	u.ID, _ = uuid.Parse(idStr)
-	u.CreatedAt, _ = time.Parse(time.RFC3339, createdStr)
-	u.UpdatedAt, _ = time.Parse(time.RFC3339, updatedStr)
-	return &u, nil
+	return u, nil
 }
 
 func scanRow(rows *sql.Rows) (*model.User, error) {
+	u, err := scanUser(rows)
+	if err != nil {
+		return nil, fmt.Errorf("repository.scanRow: %w", err)
+	}
+	return u, nil
+}
+
+func scanUser(sc scanner) (*model.User, error) {
 	var (
-		u                             model.User
-		idStr, createdStr, updatedStr string
+		u                         model.User
+		idStr, tenantStr          string
+		createdStr, updatedStr    string
+		validAfterStr, deletedStr sql.NullString
+		verifiedStr, pendingEmail sql.NullString
+		lockedStr                 sql.NullString
 	)
-	err := rows.Scan(&idStr, &u.Name, &u.Email, &u.PasswordHash, &createdStr, &updatedStr)
+	err := sc.Scan(&idStr, &tenantStr, &u.Name, &u.Email, &u.PasswordHash, &createdStr, &updatedStr, &validAfterStr, &deletedStr,
+		&verifiedStr, &pendingEmail, &u.FailedSignIns, &lockedStr)
 	if err != nil {
-		return nil, fmt.Errorf("repository.scanRow: %w", err)
+		return nil, err
 	}
 	u.ID, _ = uuid.Parse(idStr)
+	u.TenantID, _ = uuid.Parse(tenantStr)
 	u.CreatedAt, _ = time.Parse(time.RFC3339, createdStr)
 	u.UpdatedAt, _ = time.Parse(time.RFC3339, updatedStr)
+	u.TokensValidAfter = parseNullTime(validAfterStr)
+	u.DeletedAt = parseNullTime(deletedStr)
+	u.EmailVerifiedAt = parseNullTime(verifiedStr)
+	u.PendingEmail = pendingEmail.String
+	u.LockedUntil = parseNullTime(lockedStr)
 	return &u, nil
 }
 
+// emailScope is the email_scope of a user in tenantID: addresses are unique
+// among users that share it.
+func (r *UserRepository) emailScope(tenantID uuid.UUID) string {
+	if r.emails == EmailsPerTenant {
+		return tenantID.String()
+	}
+	return ""
+}
+
+// tenantOrDefault is the organization ctx is scoped to, or the default one.
+func tenantOrDefault(ctx context.Context) uuid.UUID {
+	if id, ok := reqctx.TenantFrom(ctx); ok {
+		return id
+	}
+	return model.DefaultTenantID
+}
+
+// tenantClause narrows a query to the organization ctx is scoped to. It
+// returns "" and no arguments when ctx isn't scoped.
+func tenantClause(ctx context.Context) (string, []any) {
+	if id, ok := reqctx.TenantFrom(ctx); ok {
+		return " AND tenant_id = ?", []any{id.String()}
+	}
+	return "", nil
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
 func isUniqueViolation(err error) bool {
 	return strings.Contains(err.Error(), "UNIQUE constraint failed")
 }
@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"user-management-api/internal/model"
+)
+
+var (
+	// ErrCredentialNotFound is returned when no passkey matches.
+	ErrCredentialNotFound = errors.New("credential not found")
+	// ErrCredentialExists is returned when registering a credential ID twice.
+	ErrCredentialExists = errors.New("credential already registered")
+)
+
+const credentialColumns = `id, user_id, credential_id, public_key, sign_count, aaguid, name, created_at, last_used_at`
+
+type WebAuthnRepository struct {
+	db *sql.DB
+}
+
+func NewWebAuthnRepository(db *sql.DB) *WebAuthnRepository {
+	return &WebAuthnRepository{db: db}
+}
+
+func (r *WebAuthnRepository) Create(ctx context.Context, c *model.WebAuthnCredential) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO webauthn_credentials (id, user_id, credential_id, public_key, sign_count, aaguid, name, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		c.ID.String(), c.UserID.String(), c.CredentialID, c.PublicKey, c.SignCount, c.AAGUID, c.Name,
+		c.CreatedAt.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrCredentialExists
+		}
+		return fmt.Errorf("repository.WebAuthn.Create: %w", err)
+	}
+	return nil
+}
+
+func (r *WebAuthnRepository) GetByCredentialID(ctx context.Context, credentialID string) (*model.WebAuthnCredential, error) {
+	c, err := scanCredential(r.db.QueryRowContext(ctx,
+		`SELECT `+credentialColumns+` FROM webauthn_credentials WHERE credential_id = ?`, credentialID,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrCredentialNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("repository.WebAuthn.GetByCredentialID: %w", err)
+	}
+	return c, nil
+}
+
+func (r *WebAuthnRepository) ListForUser(ctx context.Context, userID uuid.UUID) ([]*model.WebAuthnCredential, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+credentialColumns+` FROM webauthn_credentials WHERE user_id = ? ORDER BY created_at`,
+		userID.String(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("repository.WebAuthn.ListForUser: %w", err)
+	}
+	defer rows.Close()
+
+	creds := []*model.WebAuthnCredential{}
+	for rows.Next() {
+		c, err := scanCredential(rows)
+		if err != nil {
+			return nil, fmt.Errorf("repository.WebAuthn.ListForUser: %w", err)
+		}
+		creds = append(creds, c)
+	}
+	return creds, rows.Err()
+}
+
+// UpdateSignCount records a successful sign-in. It only succeeds if the
+// stored counter is still prev, so concurrent assertions can't both move it.
+func (r *WebAuthnRepository) UpdateSignCount(ctx context.Context, id uuid.UUID, prev, next uint32, at time.Time) error {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE webauthn_credentials SET sign_count = ?, last_used_at = ? WHERE id = ? AND sign_count = ?`,
+		next, at.UTC().Format(time.RFC3339), id.String(), prev,
+	)
+	if err != nil {
+		return fmt.Errorf("repository.WebAuthn.UpdateSignCount: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrCredentialNotFound
+	}
+	return nil
+}
+
+// Delete removes one of the user's passkeys.
+func (r *WebAuthnRepository) Delete(ctx context.Context, userID, id uuid.UUID) error {
+	res, err := r.db.ExecContext(ctx,
+		`DELETE FROM webauthn_credentials WHERE id = ? AND user_id = ?`, id.String(), userID.String(),
+	)
+	if err != nil {
+		return fmt.Errorf("repository.WebAuthn.Delete: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrCredentialNotFound
+	}
+	return nil
+}
+
+func scanCredential(row scanner) (*model.WebAuthnCredential, error) {
+	var (
+		c              model.WebAuthnCredential
+		idStr, userStr string
+		createdStr     string
+		lastUsedStr    sql.NullString
+	)
+	err := row.Scan(&idStr, &userStr, &c.CredentialID, &c.PublicKey, &c.SignCount, &c.AAGUID, &c.Name, &createdStr, &lastUsedStr)
+	if err != nil {
+		return nil, err
+	}
+	c.ID, _ = uuid.Parse(idStr)
+	c.UserID, _ = uuid.Parse(userStr)
+	c.CreatedAt, _ = time.Parse(time.RFC3339, createdStr)
+	c.LastUsedAt = parseNullTime(lastUsedStr)
+	return &c, nil
+}
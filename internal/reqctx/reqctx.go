@@ -0,0 +1,52 @@
+// Package reqctx carries facts about the HTTP client, and the organization
+// a request is for, through its context.Context, so services and
+// repositories can use them without depending on gin.
+package reqctx
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// Client describes who is on the other end of the request.
+type Client struct {
+	// IP is the client address, resolved through any trusted proxies.
+	IP        string
+	UserAgent string
+}
+
+type clientKey struct{}
+
+// WithClient returns a copy of ctx carrying c.
+func WithClient(ctx context.Context, c Client) context.Context {
+	return context.WithValue(ctx, clientKey{}, c)
+}
+
+// ClientFrom returns the Client stored in ctx, or the zero Client if there is none.
+func ClientFrom(ctx context.Context) Client {
+	c, _ := ctx.Value(clientKey{}).(Client)
+	return c
+}
+
+type tenantKey struct{}
+
+// WithTenant returns a copy of ctx scoped to the organization tenantID.
+// Repositories that hold per-organization data only see that organization's
+// rows. uuid.Nil lifts the scope.
+func WithTenant(ctx context.Context, tenantID uuid.UUID) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantID)
+}
+
+// WithoutTenant returns a copy of ctx that sees every organization, for
+// lookups that establish which organization a request belongs to.
+func WithoutTenant(ctx context.Context) context.Context {
+	return WithTenant(ctx, uuid.Nil)
+}
+
+// TenantFrom returns the organization ctx is scoped to, and false if it
+// isn't scoped.
+func TenantFrom(ctx context.Context) (uuid.UUID, bool) {
+	id, _ := ctx.Value(tenantKey{}).(uuid.UUID)
+	return id, id != uuid.Nil
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"user-management-api/internal/model"
+	"user-management-api/internal/repository"
+)
+
+// errActionTokenInvalid is mapped by each flow to its own public error.
+var errActionTokenInvalid = errors.New("action token invalid")
+
+// issueActionToken stores a new single-use token for purpose, burning any
+// earlier unused ones so only the latest emailed link works. It returns the
+// raw token for the email.
+func issueActionToken(
+	ctx context.Context,
+	repo *repository.ActionTokenRepository,
+	userID uuid.UUID,
+	purpose, email string,
+	ttl time.Duration,
+) (string, error) {
+	if err := repo.InvalidateForUser(ctx, userID, purpose, time.Now().UTC()); err != nil {
+		return "", err
+	}
+	return newActionToken(ctx, repo, userID, purpose, email, ttl)
+}
+
+// newActionToken stores a new single-use token for purpose alongside any
+// others the user already has.
+func newActionToken(
+	ctx context.Context,
+	repo *repository.ActionTokenRepository,
+	userID uuid.UUID,
+	purpose, email string,
+	ttl time.Duration,
+) (string, error) {
+	return storeActionToken(ctx, repo, &model.ActionToken{UserID: userID, Purpose: purpose, Email: email}, ttl)
+}
+
+// storeActionToken completes t with an ID, a fresh secret and its lifetime,
+// and stores it. It returns the raw secret.
+func storeActionToken(ctx context.Context, repo *repository.ActionTokenRepository, t *model.ActionToken, ttl time.Duration) (string, error) {
+	raw, err := newOpaqueToken()
+	if err != nil {
+		return "", fmt.Errorf("service.storeActionToken: %w", err)
+	}
+	now := time.Now().UTC()
+	t.ID = uuid.New()
+	t.TokenHash = hashToken(raw)
+	t.ExpiresAt = now.Add(ttl)
+	t.CreatedAt = now
+	if err := repo.Create(ctx, t); err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+// redeemActionToken consumes a live token of the given purpose. Unknown,
+// expired and already-used tokens all yield errActionTokenInvalid.
+func redeemActionToken(ctx context.Context, repo *repository.ActionTokenRepository, purpose, raw string) (*model.ActionToken, error) {
+	t, err := findActionToken(ctx, repo, purpose, raw)
+	if err != nil {
+		return nil, err
+	}
+	if err := consumeActionToken(ctx, repo, t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// findActionToken is redeemActionToken without consuming the token, for
+// flows that must validate the rest of the request first.
+func findActionToken(ctx context.Context, repo *repository.ActionTokenRepository, purpose, raw string) (*model.ActionToken, error) {
+	t, err := repo.GetByHash(ctx, purpose, hashToken(raw))
+	if err != nil {
+		if errors.Is(err, repository.ErrTokenNotFound) {
+			return nil, errActionTokenInvalid
+		}
+		return nil, err
+	}
+	if t.UsedAt != nil || time.Now().After(t.ExpiresAt) {
+		return nil, errActionTokenInvalid
+	}
+	return t, nil
+}
+
+// consumeActionToken marks a token found by findActionToken as used. It
+// fails with errActionTokenInvalid if a concurrent request got there first.
+func consumeActionToken(ctx context.Context, repo *repository.ActionTokenRepository, t *model.ActionToken) error {
+	if err := repo.Consume(ctx, t.ID, time.Now().UTC()); err != nil {
+		if errors.Is(err, repository.ErrTokenNotFound) {
+			return errActionTokenInvalid
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"user-management-api/internal/model"
+	"user-management-api/internal/repository"
+	"user-management-api/internal/reqctx"
+)
+
+// AuditService keeps the audit log.
+type AuditService struct {
+	events *repository.AuditRepository
+}
+
+func NewAuditService(events *repository.AuditRepository) *AuditService {
+	return &AuditService{events: events}
+}
+
+// Record adds e to the log, stamping it with an ID, the time and, unless
+// already set, the client's IP and User-Agent. It is filed under the
+// organization ctx is scoped to.
+func (s *AuditService) Record(ctx context.Context, e *model.AuditEvent) error {
+	client := reqctx.ClientFrom(ctx)
+	e.ID = uuid.New()
+	e.OccurredAt = time.Now().UTC()
+	if e.IP == "" {
+		e.IP = client.IP
+	}
+	if e.UserAgent == "" {
+		e.UserAgent = client.UserAgent
+	}
+	return s.events.Create(ctx, e)
+}
+
+// List returns events matching q, newest first.
+func (s *AuditService) List(ctx context.Context, q *model.ListAuditQuery) ([]*model.AuditEvent, error) {
+	if q.Limit <= 0 || q.Limit > 100 {
+		q.Limit = 20
+	}
+	return s.events.List(ctx, q.ActorID, q.UserID, q.Action, q.Limit, q.Offset)
+}
@@ -0,0 +1,249 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"user-management-api/internal/ldap"
+	"user-management-api/internal/model"
+	"user-management-api/internal/password"
+	"user-management-api/internal/repository"
+)
+
+var (
+	// ErrUnknownLogin is returned by an Authenticator that has no account
+	// for the email, so that a chain moves on to the next one.
+	ErrUnknownLogin = errors.New("unknown login")
+	// ErrDirectoryUnavailable is returned when the LDAP directory can't be
+	// asked about a password.
+	ErrDirectoryUnavailable = errors.New("directory unavailable")
+)
+
+// LDAPProviderID is the provider of the identities that link users to their
+// directory entries. Identity providers can't use it as their ID.
+const LDAPProviderID = "ldap"
+
+// Authenticator checks the email and password of a sign-in. u is the local
+// account with that email, or nil when there is none.
+//
+// It returns the account signed in to, ErrInvalidCredentials when it knows
+// the account but not the password, or ErrUnknownLogin when it has no
+// account for the email.
+type Authenticator interface {
+	Authenticate(ctx context.Context, u *model.User, email, pw string) (*model.User, error)
+}
+
+// ChainAuthenticators asks each authenticator in turn until one knows the
+// email. A wrong password ends the sign-in; it doesn't fall through to the
+// next authenticator.
+func ChainAuthenticators(auths ...Authenticator) Authenticator {
+	return authChain(auths)
+}
+
+type authChain []Authenticator
+
+func (c authChain) Authenticate(ctx context.Context, u *model.User, email, pw string) (*model.User, error) {
+	for _, a := range c {
+		signedIn, err := a.Authenticate(ctx, u, email, pw)
+		if !errors.Is(err, ErrUnknownLogin) {
+			return signedIn, err
+		}
+	}
+	return nil, ErrUnknownLogin
+}
+
+// --- local passwords ---
+
+// LocalAuthenticator checks the password hashes stored with users, and
+// upgrades them to the current hasher settings as their owners sign in.
+type LocalAuthenticator struct {
+	users  *repository.UserRepository
+	hasher password.Hasher
+}
+
+func NewLocalAuthenticator(users *repository.UserRepository, hasher password.Hasher) *LocalAuthenticator {
+	return &LocalAuthenticator{users: users, hasher: hasher}
+}
+
+func (a *LocalAuthenticator) Authenticate(ctx context.Context, u *model.User, _, pw string) (*model.User, error) {
+	if u == nil {
+		return nil, ErrUnknownLogin
+	}
+	// Accounts created through an identity provider or the directory have
+	// no password.
+	if u.PasswordHash == "" {
+		return nil, ErrInvalidCredentials
+	}
+	ok, rehash, err := a.hasher.Verify(u.PasswordHash, pw)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+	if rehash {
+		a.rehash(ctx, u, pw)
+	}
+	return u, nil
+}
+
+// rehash upgrades u's stored hash now that the plain password is at hand.
+// Failing to do so costs nothing but the upgrade, so it is logged rather
+// than failing the sign-in.
+func (a *LocalAuthenticator) rehash(ctx context.Context, u *model.User, pw string) {
+	hash, err := a.hasher.Hash(pw)
+	if err == nil {
+		err = a.users.RehashPassword(ctx, u.ID, u.PasswordHash, hash)
+	}
+	if err != nil {
+		log.Printf("rehash password for user %s: %v", u.ID, err)
+	}
+}
+
+// --- LDAP ---
+
+// LDAPAuthenticator checks passwords with a bind to an LDAP directory and
+// keeps a local account for each directory user, linked to their entry as
+// an identity of LDAPProviderID.
+//
+// The first sign-in links the entry to the account with the directory's
+// email address, or creates one. Later sign-ins copy the entry's name and
+// email address to the account. The directory is trusted to vouch for
+// email addresses.
+type LDAPAuthenticator struct {
+	dir        *ldap.Directory
+	users      *repository.UserRepository
+	roles      *repository.RoleRepository
+	identities *repository.FederationRepository
+}
+
+func NewLDAPAuthenticator(
+	dir *ldap.Directory,
+	users *repository.UserRepository,
+	roles *repository.RoleRepository,
+	identities *repository.FederationRepository,
+) *LDAPAuthenticator {
+	return &LDAPAuthenticator{dir: dir, users: users, roles: roles, identities: identities}
+}
+
+func (a *LDAPAuthenticator) Authenticate(ctx context.Context, u *model.User, email, pw string) (*model.User, error) {
+	entry, err := a.dir.Authenticate(ctx, email, pw)
+	switch {
+	case errors.Is(err, ldap.ErrUserNotFound):
+		return nil, ErrUnknownLogin
+	case errors.Is(err, ldap.ErrInvalidCredentials):
+		return nil, ErrInvalidCredentials
+	case errors.Is(err, ldap.ErrUnavailable):
+		log.Printf("ldap sign-in: %v", err)
+		return nil, ErrDirectoryUnavailable
+	case err != nil:
+		return nil, err
+	}
+	if entry.Email == "" {
+		entry.Email = email
+	}
+
+	now := time.Now().UTC()
+	i, err := a.identities.GetIdentity(ctx, LDAPProviderID, entry.ID)
+	switch {
+	case err == nil:
+		if u, err = a.users.GetByID(ctx, i.UserID); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				// The account was deleted here; the directory can't revive it.
+				return nil, ErrInvalidCredentials
+			}
+			return nil, err
+		}
+		if err := a.identities.MarkIdentityUsed(ctx, i.ID, entry.Email, now); err != nil {
+			return nil, err
+		}
+	case errors.Is(err, repository.ErrIdentityNotFound):
+		if u, err = a.link(ctx, u, entry, now); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+	if err := a.sync(ctx, u, entry, now); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// link attaches a directory user seen for the first time to the account
+// with their email address, creating it if there is none.
+func (a *LDAPAuthenticator) link(ctx context.Context, u *model.User, entry *ldap.User, now time.Time) (*model.User, error) {
+	if u == nil || !strings.EqualFold(u.Email, entry.Email) {
+		existing, err := a.users.GetByEmail(ctx, entry.Email)
+		switch {
+		case err == nil:
+			u = existing
+		case errors.Is(err, repository.ErrNotFound):
+			if u, err = a.create(ctx, entry, now); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, err
+		}
+	}
+	err := a.identities.CreateIdentity(ctx, &model.UserIdentity{
+		ID:        uuid.New(),
+		UserID:    u.ID,
+		Provider:  LDAPProviderID,
+		Subject:   entry.ID,
+		Email:     entry.Email,
+		CreatedAt: now,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func (a *LDAPAuthenticator) create(ctx context.Context, entry *ldap.User, now time.Time) (*model.User, error) {
+	u := &model.User{
+		ID:        uuid.New(),
+		Name:      entry.Name,
+		Email:     entry.Email,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if u.Name == "" {
+		u.Name, _, _ = strings.Cut(entry.Email, "@")
+	}
+	if err := a.users.Create(ctx, u); err != nil {
+		return nil, err
+	}
+	if err := a.roles.Assign(ctx, u.ID, model.RoleUser); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// sync copies the entry's name and email address to u. An address another
+// account already uses is left alone.
+func (a *LDAPAuthenticator) sync(ctx context.Context, u *model.User, entry *ldap.User, now time.Time) error {
+	if entry.Name != "" && entry.Name != u.Name {
+		u.Name, u.UpdatedAt = entry.Name, now
+		if err := a.users.Update(ctx, u); err != nil {
+			return err
+		}
+	}
+	if u.EmailVerifiedAt == nil || !strings.EqualFold(entry.Email, u.Email) {
+		err := a.users.MarkEmailVerified(ctx, u.ID, entry.Email, now)
+		if errors.Is(err, repository.ErrEmailTaken) {
+			log.Printf("ldap sign-in: user %s: %s is taken by another account", u.ID, entry.Email)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		u.Email, u.EmailVerifiedAt, u.PendingEmail = entry.Email, &now, ""
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"user-management-api/internal/ldap"
+	"user-management-api/internal/ldap/ldaptest"
+	"user-management-api/internal/model"
+	"user-management-api/internal/service"
+)
+
+const peopleDN = "ou=people,dc=corp,dc=test"
+
+func directoryUser(uid, name, email, pw string) *ldaptest.Entry {
+	return &ldaptest.Entry{
+		DN:       "uid=" + uid + "," + peopleDN,
+		Password: pw,
+		Attributes: map[string][]string{
+			"objectClass": {"inetOrgPerson"},
+			"entryUUID":   {"uuid-" + uid},
+			"cn":          {name},
+			"mail":        {email},
+		},
+	}
+}
+
+// withDirectory puts an LDAP directory with a service account in front of
+// local passwords.
+func withDirectory(t *testing.T, entries ...*ldaptest.Entry) (*ldaptest.Server, func(*testConfig)) {
+	t.Helper()
+	srv := ldaptest.NewServer(append(entries, &ldaptest.Entry{DN: "cn=reader,dc=corp,dc=test", Password: "reader pw"})...)
+	t.Cleanup(srv.Close)
+	dir, err := ldap.New(ldap.Config{
+		URL:            srv.URL,
+		BindDN:         "cn=reader,dc=corp,dc=test",
+		BindPassword:   "reader pw",
+		BaseDN:         peopleDN,
+		UserFilter:     "(&(objectClass=inetOrgPerson)(mail={login}))",
+		IDAttribute:    "entryUUID",
+		NameAttribute:  "cn",
+		EmailAttribute: "mail",
+	})
+	if err != nil {
+		t.Fatalf("new directory: %v", err)
+	}
+	return srv, func(c *testConfig) { c.ldap = dir }
+}
+
+func TestLDAP_SignInCreatesAccount(t *testing.T) {
+	_, opt := withDirectory(t, directoryUser("alice", "Alice Smith", "alice@corp.test", "directory pw"))
+	env := newTestEnv(t, opt)
+	ctx := context.Background()
+
+	resp, err := env.users.SignIn(ctx, &model.SignInRequest{Email: "alice@corp.test", Password: "directory pw"})
+	if err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+	if resp.Token == "" || resp.User.Name != "Alice Smith" || resp.User.EmailVerifiedAt == nil {
+		t.Fatalf("expected tokens for a verified account, got %+v", resp)
+	}
+	identities, err := env.federation.ListIdentities(ctx, resp.User.ID)
+	if err != nil {
+		t.Fatalf("list identities: %v", err)
+	}
+	if len(identities) != 1 || identities[0].Provider != service.LDAPProviderID || identities[0].Subject != "uuid-alice" {
+		t.Errorf("expected the directory entry as an identity, got %+v", identities)
+	}
+
+	again, err := env.users.SignIn(ctx, &model.SignInRequest{Email: "alice@corp.test", Password: "directory pw"})
+	if err != nil {
+		t.Fatalf("sign in again: %v", err)
+	}
+	if again.User.ID != resp.User.ID {
+		t.Errorf("expected the same account, got %s and %s", resp.User.ID, again.User.ID)
+	}
+}
+
+func TestLDAP_SignInFollowsDirectoryChanges(t *testing.T) {
+	srv, opt := withDirectory(t, directoryUser("alice", "Alice Smith", "alice@corp.test", "directory pw"))
+	env := newTestEnv(t, opt)
+	ctx := context.Background()
+
+	first, err := env.users.SignIn(ctx, &model.SignInRequest{Email: "alice@corp.test", Password: "directory pw"})
+	if err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+
+	// The directory renames her entry's attributes; the entryUUID stays.
+	renamed := directoryUser("alice", "Alice Jones", "alice.jones@corp.test", "directory pw")
+	renamed.DN = "uid=ajones," + peopleDN
+	srv.Add(renamed)
+
+	resp, err := env.users.SignIn(ctx, &model.SignInRequest{Email: "alice.jones@corp.test", Password: "directory pw"})
+	if err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+	if resp.User.ID != first.User.ID || resp.User.Name != "Alice Jones" || resp.User.Email != "alice.jones@corp.test" {
+		t.Errorf("expected the account to follow the directory, got %+v", resp.User)
+	}
+}
+
+func TestLDAP_LinksExistingAccount(t *testing.T) {
+	_, opt := withDirectory(t, directoryUser("alice", "Alice Smith", "alice@example.com", "directory pw"))
+	env := newTestEnv(t, opt)
+	ctx := context.Background()
+
+	reg, err := env.users.Register(ctx, &model.RegisterRequest{Name: "Alice", Email: "alice@example.com", Password: "local pw 123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	resp, err := env.users.SignIn(ctx, &model.SignInRequest{Email: "alice@example.com", Password: "directory pw"})
+	if err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+	if resp.User.ID != reg.User.ID {
+		t.Errorf("expected the existing account, got %s", resp.User.ID)
+	}
+
+	// The directory decides for its users; a wrong directory password
+	// doesn't fall back to the local one.
+	_, err = env.users.SignIn(ctx, &model.SignInRequest{Email: "alice@example.com", Password: "local pw 123"})
+	if !errors.Is(err, service.ErrInvalidCredentials) {
+		t.Errorf("local password: expected ErrInvalidCredentials, got %v", err)
+	}
+}
+
+func TestLDAP_FallsBackToLocalPasswords(t *testing.T) {
+	_, opt := withDirectory(t, directoryUser("alice", "Alice Smith", "alice@corp.test", "directory pw"))
+	env := newTestEnv(t, opt)
+	ctx := context.Background()
+
+	reg, err := env.users.Register(ctx, &model.RegisterRequest{Name: "Admin", Email: "admin@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	resp, err := env.users.SignIn(ctx, &model.SignInRequest{Email: "admin@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+	if resp.User.ID != reg.User.ID {
+		t.Errorf("expected the local account, got %s", resp.User.ID)
+	}
+
+	_, err = env.users.SignIn(ctx, &model.SignInRequest{Email: "nobody@example.com", Password: "secret123"})
+	if !errors.Is(err, service.ErrInvalidCredentials) {
+		t.Errorf("unknown everywhere: expected ErrInvalidCredentials, got %v", err)
+	}
+}
+
+func TestLDAP_WrongPasswordCountsTowardsLockout(t *testing.T) {
+	_, opt := withDirectory(t, directoryUser("alice", "Alice Smith", "alice@corp.test", "directory pw"))
+	env := newTestEnv(t, opt, func(c *testConfig) { c.lockout.Threshold = 2 })
+	ctx := context.Background()
+
+	if _, err := env.users.SignIn(ctx, &model.SignInRequest{Email: "alice@corp.test", Password: "directory pw"}); err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		_, err := env.users.SignIn(ctx, &model.SignInRequest{Email: "alice@corp.test", Password: "wrong"})
+		if !errors.Is(err, service.ErrInvalidCredentials) {
+			t.Fatalf("attempt %d: expected ErrInvalidCredentials, got %v", i+1, err)
+		}
+	}
+	_, err := env.users.SignIn(ctx, &model.SignInRequest{Email: "alice@corp.test", Password: "directory pw"})
+	if !errors.Is(err, service.ErrAccountLocked) {
+		t.Errorf("expected ErrAccountLocked, got %v", err)
+	}
+}
+
+func TestLDAP_DirectoryDown(t *testing.T) {
+	srv, opt := withDirectory(t)
+	env := newTestEnv(t, opt)
+	ctx := context.Background()
+
+	if _, err := env.users.Register(ctx, &model.RegisterRequest{Name: "Admin", Email: "admin@example.com", Password: "secret123"}); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	srv.Close()
+	_, err := env.users.SignIn(ctx, &model.SignInRequest{Email: "admin@example.com", Password: "secret123"})
+	if !errors.Is(err, service.ErrDirectoryUnavailable) {
+		t.Errorf("expected ErrDirectoryUnavailable, got %v", err)
+	}
+}
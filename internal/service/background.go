@@ -0,0 +1,22 @@
+package service
+
+import (
+	"context"
+	"log"
+)
+
+// Dispatch hands job off to run after the request that asked for it has been
+// answered. Flows that must not reveal whether an account exists use it so
+// the response takes the same time either way; name labels the job in logs.
+type Dispatch func(ctx context.Context, name string, job func(context.Context) error)
+
+// InBackground runs job on its own goroutine, with a context that keeps ctx's
+// values but outlives its cancellation, and logs any error.
+func InBackground(ctx context.Context, name string, job func(context.Context) error) {
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		if err := job(ctx); err != nil {
+			log.Printf("%s: %v", name, err)
+		}
+	}()
+}
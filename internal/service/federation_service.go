@@ -0,0 +1,347 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"user-management-api/internal/idp"
+	"user-management-api/internal/model"
+	"user-management-api/internal/repository"
+	"user-management-api/internal/reqctx"
+)
+
+var (
+	// ErrUnknownProvider is returned for an identity provider that isn't configured.
+	ErrUnknownProvider = errors.New("unknown identity provider")
+	// ErrInvalidLoginState is returned by the callback for a state that is
+	// unknown, expired, already used or not bound to this browser.
+	ErrInvalidLoginState = errors.New("invalid or expired sign-in state")
+	// ErrInvalidLoginTicket is returned for a ticket that is unknown,
+	// expired or already used.
+	ErrInvalidLoginTicket = errors.New("invalid or expired sign-in ticket")
+	// ErrIdentityNotLinked is returned when nobody has linked the external
+	// account and it can't be given one of its own.
+	ErrIdentityNotLinked = errors.New("no account is linked to this identity")
+	// ErrLastSignInMethod is returned when unlinking would leave the user
+	// no way to sign in.
+	ErrLastSignInMethod = errors.New("cannot remove the last way to sign in")
+)
+
+// federatedTicketExpiry is how long the front end has to redeem the ticket
+// the callback hands it; it does so straight away.
+const federatedTicketExpiry = time.Minute
+
+// FederationConfig holds the tunables for FederationService.
+type FederationConfig struct {
+	Providers []*idp.Provider
+	// ReturnURL is the front-end page the callback sends the browser back
+	// to, with a ticket or an error.
+	ReturnURL string
+	// StateExpiry is how long the user has to sign in at the provider.
+	StateExpiry time.Duration
+}
+
+// FederationService signs users in through upstream OpenID Connect
+// providers and manages the identities linked to their accounts.
+//
+// The browser goes from BeginLogin to the provider and back to Callback,
+// which hands the front end a single-use ticket. Redeeming the ticket with
+// SignIn starts a session; redeeming it with Link attaches the identity to
+// the signed-in user instead.
+type FederationService struct {
+	providers map[string]*idp.Provider
+	logins    *repository.FederationRepository
+	users     *repository.UserRepository
+	roles     *repository.RoleRepository
+	passkeys  *repository.WebAuthnRepository
+	verifier  *EmailVerificationService
+	cfg       FederationConfig
+}
+
+func NewFederationService(
+	logins *repository.FederationRepository,
+	users *repository.UserRepository,
+	roles *repository.RoleRepository,
+	passkeys *repository.WebAuthnRepository,
+	verifier *EmailVerificationService,
+	cfg FederationConfig,
+) *FederationService {
+	providers := make(map[string]*idp.Provider, len(cfg.Providers))
+	for _, p := range cfg.Providers {
+		providers[p.ID()] = p
+	}
+	return &FederationService{
+		providers: providers,
+		logins:    logins,
+		users:     users,
+		roles:     roles,
+		passkeys:  passkeys,
+		verifier:  verifier,
+		cfg:       cfg,
+	}
+}
+
+// Providers lists the configured providers, in configuration order.
+func (s *FederationService) Providers() []model.IdentityProvider {
+	out := make([]model.IdentityProvider, 0, len(s.cfg.Providers))
+	for _, p := range s.cfg.Providers {
+		out = append(out, model.IdentityProvider{ID: p.ID(), Name: p.Name()})
+	}
+	return out
+}
+
+// BeginLogin starts a sign-in at providerID and returns where to send the
+// browser, along with the state the caller binds to the browser. clientState
+// is the front end's own CSRF state, handed back to it at the end.
+func (s *FederationService) BeginLogin(ctx context.Context, providerID, clientState string) (redirect, state string, err error) {
+	p, ok := s.providers[providerID]
+	if !ok {
+		return "", "", ErrUnknownProvider
+	}
+
+	secrets := make([]string, 3) // state, nonce, PKCE verifier
+	for i := range secrets {
+		if secrets[i], err = newOpaqueToken(); err != nil {
+			return "", "", fmt.Errorf("service.Federation.BeginLogin: %w", err)
+		}
+	}
+	state, nonce, verifier := secrets[0], secrets[1], secrets[2]
+
+	redirect, err = p.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		return "", "", err
+	}
+	err = s.logins.CreateLogin(ctx, &model.FederatedLogin{
+		ID:           uuid.New(),
+		StateHash:    hashToken(state),
+		Provider:     providerID,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ClientState:  clientState,
+		ExpiresAt:    time.Now().UTC().Add(s.cfg.StateExpiry),
+	})
+	if err != nil {
+		return "", "", err
+	}
+	return redirect, state, nil
+}
+
+// Callback finishes the provider leg and returns where to send the browser:
+// the return URL with a ticket, or with an error the front end can show.
+// Errors are returned only when the state can't be trusted, since then
+// there is nobody to hand the result to.
+func (s *FederationService) Callback(ctx context.Context, providerID, state, code, providerError string) (string, error) {
+	l, err := s.logins.GetLoginByState(ctx, hashToken(state))
+	if errors.Is(err, repository.ErrLoginNotFound) {
+		return "", ErrInvalidLoginState
+	}
+	if err != nil {
+		return "", err
+	}
+	p, ok := s.providers[providerID]
+	if !ok || l.Provider != providerID || time.Now().After(l.ExpiresAt) {
+		return "", ErrInvalidLoginState
+	}
+
+	switch {
+	case providerError == "access_denied":
+		return s.returnTo(l, url.Values{"error": {"access_denied"}}), nil
+	case providerError != "" || code == "":
+		return s.returnTo(l, url.Values{"error": {"provider_error"}}), nil
+	}
+	id, err := p.Exchange(ctx, code, l.CodeVerifier, l.Nonce)
+	if err != nil {
+		log.Printf("federated sign-in with %s: %v", providerID, err)
+		return s.returnTo(l, url.Values{"error": {"provider_error"}}), nil
+	}
+
+	ticket, err := newOpaqueToken()
+	if err != nil {
+		return "", fmt.Errorf("service.Federation.Callback: %w", err)
+	}
+	l.TicketHash = hashToken(ticket)
+	l.Subject, l.Email, l.EmailVerified, l.Name = id.Subject, id.Email, id.EmailVerified, id.Name
+	l.ExpiresAt = time.Now().UTC().Add(federatedTicketExpiry)
+	if err := s.logins.CompleteLogin(ctx, l); err != nil {
+		if errors.Is(err, repository.ErrLoginNotFound) {
+			return "", ErrInvalidLoginState
+		}
+		return "", err
+	}
+	return s.returnTo(l, url.Values{"ticket": {ticket}, "provider": {providerID}}), nil
+}
+
+// SignIn redeems a ticket for the user linked to its identity. An identity
+// nobody has linked gets a new account when its provider allows sign-up and
+// its email address is free; otherwise the user must sign in another way
+// and link it.
+func (s *FederationService) SignIn(ctx context.Context, ticket string) (*model.User, error) {
+	l, err := s.redeem(ctx, ticket)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	i, err := s.logins.GetIdentity(ctx, l.Provider, l.Subject)
+	if errors.Is(err, repository.ErrIdentityNotFound) {
+		return s.signUp(ctx, l)
+	}
+	if err != nil {
+		return nil, err
+	}
+	u, err := s.users.GetByID(ctx, i.UserID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrIdentityNotLinked
+		}
+		return nil, err
+	}
+	if err := s.logins.MarkIdentityUsed(ctx, i.ID, l.Email, now); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// signUp provisions an account for a first-time federated user. An email
+// address the provider vouches for counts as verified. Like Register, it
+// signs people up to the default organization only.
+func (s *FederationService) signUp(ctx context.Context, l *model.FederatedLogin) (*model.User, error) {
+	if p, ok := s.providers[l.Provider]; !ok || !p.AllowSignUp() || l.Email == "" {
+		return nil, ErrIdentityNotLinked
+	}
+	ctx = reqctx.WithTenant(ctx, model.DefaultTenantID)
+
+	now := time.Now().UTC()
+	u := &model.User{
+		ID:        uuid.New(),
+		Name:      l.Name,
+		Email:     l.Email,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if u.Name == "" {
+		u.Name, _, _ = strings.Cut(l.Email, "@")
+	}
+	// Someone already uses the address. Linking them here would hand their
+	// account to whoever controls the address at the provider.
+	if err := s.users.Create(ctx, u); err != nil {
+		if errors.Is(err, repository.ErrEmailTaken) {
+			return nil, ErrIdentityNotLinked
+		}
+		return nil, err
+	}
+	if err := s.roles.Assign(ctx, u.ID, model.RoleUser); err != nil {
+		return nil, err
+	}
+	if err := s.linkIdentity(ctx, u.ID, l, now); err != nil {
+		return nil, err
+	}
+
+	if l.EmailVerified {
+		if err := s.users.MarkEmailVerified(ctx, u.ID, u.Email, now); err != nil {
+			return nil, err
+		}
+		u.EmailVerifiedAt = &now
+	} else if err := s.verifier.Send(ctx, u, u.Email); err != nil {
+		log.Printf("send verification email to user %s: %v", u.ID, err)
+	}
+	return u, nil
+}
+
+// Link redeems a ticket and links its identity to userID.
+func (s *FederationService) Link(ctx context.Context, userID uuid.UUID, ticket string) (*model.UserIdentity, error) {
+	l, err := s.redeem(ctx, ticket)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.linkIdentity(ctx, userID, l, time.Now().UTC()); err != nil {
+		return nil, err
+	}
+	return s.logins.GetIdentity(ctx, l.Provider, l.Subject)
+}
+
+func (s *FederationService) linkIdentity(ctx context.Context, userID uuid.UUID, l *model.FederatedLogin, now time.Time) error {
+	return s.logins.CreateIdentity(ctx, &model.UserIdentity{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Provider:  l.Provider,
+		Subject:   l.Subject,
+		Email:     l.Email,
+		CreatedAt: now,
+	})
+}
+
+func (s *FederationService) ListIdentities(ctx context.Context, userID uuid.UUID) ([]*model.UserIdentity, error) {
+	return s.logins.ListIdentities(ctx, userID)
+}
+
+// Unlink removes one of userID's identities, unless it is their only way
+// to sign in: no password, no passkey and no other identity.
+func (s *FederationService) Unlink(ctx context.Context, userID, id uuid.UUID) error {
+	u, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	identities, err := s.logins.ListIdentities(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if u.PasswordHash == "" && len(identities) <= 1 {
+		creds, err := s.passkeys.ListForUser(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if len(creds) == 0 {
+			for _, i := range identities {
+				if i.ID == id {
+					return ErrLastSignInMethod
+				}
+			}
+		}
+	}
+	return s.logins.DeleteIdentity(ctx, userID, id)
+}
+
+// PurgeExpired deletes sign-ins whose state or ticket has expired.
+func (s *FederationService) PurgeExpired(ctx context.Context) error {
+	_, err := s.logins.DeleteExpiredLogins(ctx, time.Now())
+	return err
+}
+
+func (s *FederationService) redeem(ctx context.Context, ticket string) (*model.FederatedLogin, error) {
+	l, err := s.logins.ConsumeTicket(ctx, hashToken(ticket), time.Now().UTC())
+	if errors.Is(err, repository.ErrLoginNotFound) {
+		return nil, ErrInvalidLoginTicket
+	}
+	if err != nil {
+		return nil, err
+	}
+	if time.Now().After(l.ExpiresAt) {
+		return nil, ErrInvalidLoginTicket
+	}
+	return l, nil
+}
+
+// returnTo builds the front-end URL the callback redirects to.
+func (s *FederationService) returnTo(l *model.FederatedLogin, params url.Values) string {
+	u, err := url.Parse(s.cfg.ReturnURL)
+	if err != nil {
+		return s.cfg.ReturnURL
+	}
+	q := u.Query()
+	for k, v := range params {
+		q[k] = v
+	}
+	if l.ClientState != "" {
+		q.Set("state", l.ClientState)
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
@@ -0,0 +1,329 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"slices"
+	"testing"
+
+	"user-management-api/internal/idp"
+	"user-management-api/internal/idp/idptest"
+	"user-management-api/internal/model"
+	"user-management-api/internal/repository"
+	"user-management-api/internal/service"
+)
+
+var corpAlice = idptest.Identity{Subject: "u-123", Email: "alice@corp.test", EmailVerified: true, Name: "Alice"}
+
+// withProvider points a test environment at a fake provider called "corp".
+func withProvider(t *testing.T, allowSignUp bool) (*idptest.Issuer, func(*testConfig)) {
+	t.Helper()
+	iss := idptest.NewIssuer("user-api", "idp secret")
+	t.Cleanup(iss.Close)
+	p, err := idp.New(idp.Config{
+		ID:           "corp",
+		Name:         "Corp SSO",
+		Issuer:       iss.URL,
+		ClientID:     iss.ClientID,
+		ClientSecret: iss.ClientSecret,
+		RedirectURL:  "https://api.test/api/v1/auth/idp/corp/callback",
+		AllowSignUp:  allowSignUp,
+	}, nil)
+	if err != nil {
+		t.Fatalf("new provider: %v", err)
+	}
+	return iss, func(c *testConfig) { c.federation.Providers = []*idp.Provider{p} }
+}
+
+// federatedLogin runs the browser's round trip through the provider as id
+// and returns the query the front end's return URL receives.
+func federatedLogin(t *testing.T, env *testEnv, iss *idptest.Issuer, id idptest.Identity) url.Values {
+	t.Helper()
+	ctx := context.Background()
+
+	authURL, state, err := env.federation.BeginLogin(ctx, "corp", "front-end-state")
+	if err != nil {
+		t.Fatalf("begin login: %v", err)
+	}
+	back, err := iss.Authorize(authURL, id)
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	q := redirectQuery(t, back)
+	if q.Get("state") != state {
+		t.Fatalf("expected the provider to return our state, got %s", back)
+	}
+
+	ret, err := env.federation.Callback(ctx, "corp", state, q.Get("code"), q.Get("error"))
+	if err != nil {
+		t.Fatalf("callback: %v", err)
+	}
+	rq := redirectQuery(t, ret)
+	if rq.Get("state") != "front-end-state" {
+		t.Fatalf("expected the front end's state to round-trip, got %s", ret)
+	}
+	return rq
+}
+
+func redirectQuery(t *testing.T, raw string) url.Values {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatalf("parse %s: %v", raw, err)
+	}
+	return u.Query()
+}
+
+func TestFederation_SignUpAndSignIn(t *testing.T) {
+	iss, opt := withProvider(t, true)
+	env := newTestEnv(t, opt)
+	ctx := context.Background()
+
+	q := federatedLogin(t, env, iss, corpAlice)
+	if q.Get("provider") != "corp" || q.Get("ticket") == "" {
+		t.Fatalf("expected a ticket from corp, got %v", q)
+	}
+	resp, err := env.users.SignInWithProvider(ctx, &model.FederatedTicketRequest{Ticket: q.Get("ticket")})
+	if err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+	if resp.Token == "" || resp.User.Email != corpAlice.Email || resp.User.Name != "Alice" || resp.User.EmailVerifiedAt == nil {
+		t.Fatalf("expected a verified account for alice, got %+v", resp.User)
+	}
+	p, err := env.tokens.Authenticate(ctx, resp.Token)
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	if !slices.Equal(p.AMR, []string{model.AMRFederated}) {
+		t.Errorf("expected amr [fed], got %v", p.AMR)
+	}
+
+	// The second visit finds the same account by subject, even after the
+	// provider changes the email address.
+	moved := corpAlice
+	moved.Email = "alice.smith@corp.test"
+	q = federatedLogin(t, env, iss, moved)
+	again, err := env.users.SignInWithProvider(ctx, &model.FederatedTicketRequest{Ticket: q.Get("ticket")})
+	if err != nil {
+		t.Fatalf("sign in again: %v", err)
+	}
+	if again.User.ID != resp.User.ID {
+		t.Errorf("expected the same account, got %s and %s", resp.User.ID, again.User.ID)
+	}
+	identities, err := env.federation.ListIdentities(ctx, resp.User.ID)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(identities) != 1 || identities[0].Email != moved.Email || identities[0].LastUsedAt == nil {
+		t.Errorf("expected one used identity with the new email, got %+v", identities)
+	}
+
+	// Federated accounts have no password to guess.
+	_, err = env.users.SignIn(ctx, &model.SignInRequest{Email: corpAlice.Email, Password: "secret123"})
+	if !errors.Is(err, service.ErrInvalidCredentials) {
+		t.Errorf("password sign-in: expected ErrInvalidCredentials, got %v", err)
+	}
+}
+
+func TestFederation_UnverifiedEmailIsSentAVerification(t *testing.T) {
+	iss, opt := withProvider(t, true)
+	env := newTestEnv(t, opt)
+
+	bob := idptest.Identity{Subject: "u-456", Email: "bob@corp.test"}
+	q := federatedLogin(t, env, iss, bob)
+	resp, err := env.users.SignInWithProvider(context.Background(), &model.FederatedTicketRequest{Ticket: q.Get("ticket")})
+	if err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+	if resp.User.EmailVerifiedAt != nil {
+		t.Error("expected an unverified email to stay unverified")
+	}
+	if env.mailer.count() != 1 {
+		t.Errorf("expected a verification email, got %d messages", env.mailer.count())
+	}
+}
+
+func TestFederation_DoesNotTakeOverExistingEmail(t *testing.T) {
+	iss, opt := withProvider(t, true)
+	env := newTestEnv(t, opt)
+	ctx := context.Background()
+
+	if _, err := env.users.Register(ctx, &model.RegisterRequest{Name: "Alice", Email: corpAlice.Email, Password: "secret123"}); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	q := federatedLogin(t, env, iss, corpAlice)
+	_, err := env.users.SignInWithProvider(ctx, &model.FederatedTicketRequest{Ticket: q.Get("ticket")})
+	if !errors.Is(err, service.ErrIdentityNotLinked) {
+		t.Errorf("expected ErrIdentityNotLinked, got %v", err)
+	}
+}
+
+func TestFederation_SignUpDisabled(t *testing.T) {
+	iss, opt := withProvider(t, false)
+	env := newTestEnv(t, opt)
+
+	q := federatedLogin(t, env, iss, corpAlice)
+	_, err := env.users.SignInWithProvider(context.Background(), &model.FederatedTicketRequest{Ticket: q.Get("ticket")})
+	if !errors.Is(err, service.ErrIdentityNotLinked) {
+		t.Errorf("expected ErrIdentityNotLinked, got %v", err)
+	}
+}
+
+func TestFederation_LinkAndUnlink(t *testing.T) {
+	iss, opt := withProvider(t, false)
+	env := newTestEnv(t, opt)
+	ctx := context.Background()
+
+	reg, err := env.users.Register(ctx, &model.RegisterRequest{Name: "Alice", Email: "alice@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	q := federatedLogin(t, env, iss, corpAlice)
+	identity, err := env.federation.Link(ctx, reg.User.ID, q.Get("ticket"))
+	if err != nil {
+		t.Fatalf("link: %v", err)
+	}
+	if identity.Provider != "corp" || identity.Subject != corpAlice.Subject {
+		t.Errorf("unexpected identity %+v", identity)
+	}
+
+	// Linked, the identity signs in to the existing account.
+	q = federatedLogin(t, env, iss, corpAlice)
+	resp, err := env.users.SignInWithProvider(ctx, &model.FederatedTicketRequest{Ticket: q.Get("ticket")})
+	if err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+	if resp.User.ID != reg.User.ID {
+		t.Errorf("expected alice's account, got %s", resp.User.ID)
+	}
+
+	// Nobody else can claim it.
+	other, err := env.users.Register(ctx, &model.RegisterRequest{Name: "Bob", Email: "bob@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	q = federatedLogin(t, env, iss, corpAlice)
+	if _, err := env.federation.Link(ctx, other.User.ID, q.Get("ticket")); !errors.Is(err, repository.ErrIdentityExists) {
+		t.Errorf("expected ErrIdentityExists, got %v", err)
+	}
+
+	if err := env.federation.Unlink(ctx, reg.User.ID, identity.ID); err != nil {
+		t.Fatalf("unlink: %v", err)
+	}
+	q = federatedLogin(t, env, iss, corpAlice)
+	_, err = env.users.SignInWithProvider(ctx, &model.FederatedTicketRequest{Ticket: q.Get("ticket")})
+	if !errors.Is(err, service.ErrIdentityNotLinked) {
+		t.Errorf("after unlink: expected ErrIdentityNotLinked, got %v", err)
+	}
+}
+
+func TestFederation_KeepsLastSignInMethod(t *testing.T) {
+	iss, opt := withProvider(t, true)
+	env := newTestEnv(t, opt)
+	ctx := context.Background()
+
+	q := federatedLogin(t, env, iss, corpAlice)
+	resp, err := env.users.SignInWithProvider(ctx, &model.FederatedTicketRequest{Ticket: q.Get("ticket")})
+	if err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+	identities, err := env.federation.ListIdentities(ctx, resp.User.ID)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if err := env.federation.Unlink(ctx, resp.User.ID, identities[0].ID); !errors.Is(err, service.ErrLastSignInMethod) {
+		t.Errorf("expected ErrLastSignInMethod, got %v", err)
+	}
+
+	registerPasskey(t, env, resp.User.ID)
+	if err := env.federation.Unlink(ctx, resp.User.ID, identities[0].ID); err != nil {
+		t.Errorf("with a passkey: unlink: %v", err)
+	}
+}
+
+func TestFederation_TicketIsSingleUse(t *testing.T) {
+	iss, opt := withProvider(t, true)
+	env := newTestEnv(t, opt)
+	ctx := context.Background()
+
+	req := &model.FederatedTicketRequest{Ticket: federatedLogin(t, env, iss, corpAlice).Get("ticket")}
+	if _, err := env.users.SignInWithProvider(ctx, req); err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+	if _, err := env.users.SignInWithProvider(ctx, req); !errors.Is(err, service.ErrInvalidLoginTicket) {
+		t.Errorf("reused ticket: expected ErrInvalidLoginTicket, got %v", err)
+	}
+}
+
+func TestFederation_CallbackChecksState(t *testing.T) {
+	iss, opt := withProvider(t, true)
+	env := newTestEnv(t, opt)
+	ctx := context.Background()
+
+	authURL, state, err := env.federation.BeginLogin(ctx, "corp", "")
+	if err != nil {
+		t.Fatalf("begin login: %v", err)
+	}
+	back, err := iss.Authorize(authURL, corpAlice)
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	code := redirectQuery(t, back).Get("code")
+
+	if _, err := env.federation.Callback(ctx, "corp", "forged", code, ""); !errors.Is(err, service.ErrInvalidLoginState) {
+		t.Errorf("forged state: expected ErrInvalidLoginState, got %v", err)
+	}
+	if _, err := env.federation.Callback(ctx, "corp", state, code, ""); err != nil {
+		t.Fatalf("callback: %v", err)
+	}
+	if _, err := env.federation.Callback(ctx, "corp", state, code, ""); !errors.Is(err, service.ErrInvalidLoginState) {
+		t.Errorf("replayed callback: expected ErrInvalidLoginState, got %v", err)
+	}
+	if _, _, err := env.federation.BeginLogin(ctx, "nope", ""); !errors.Is(err, service.ErrUnknownProvider) {
+		t.Errorf("expected ErrUnknownProvider, got %v", err)
+	}
+}
+
+func TestFederation_ProviderDenied(t *testing.T) {
+	iss, opt := withProvider(t, true)
+	env := newTestEnv(t, opt)
+	ctx := context.Background()
+
+	authURL, state, err := env.federation.BeginLogin(ctx, "corp", "")
+	if err != nil {
+		t.Fatalf("begin login: %v", err)
+	}
+	back, err := iss.Deny(authURL)
+	if err != nil {
+		t.Fatalf("deny: %v", err)
+	}
+	q := redirectQuery(t, back)
+	ret, err := env.federation.Callback(ctx, "corp", state, q.Get("code"), q.Get("error"))
+	if err != nil {
+		t.Fatalf("callback: %v", err)
+	}
+	if rq := redirectQuery(t, ret); rq.Get("error") != "access_denied" || rq.Has("ticket") {
+		t.Errorf("expected error=access_denied and no ticket, got %s", ret)
+	}
+}
+
+func TestFederation_MFAUsersAreChallenged(t *testing.T) {
+	iss, opt := withProvider(t, true)
+	env := newTestEnv(t, opt)
+	ctx := context.Background()
+
+	first, err := env.users.SignInWithProvider(ctx, &model.FederatedTicketRequest{Ticket: federatedLogin(t, env, iss, corpAlice).Get("ticket")})
+	if err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+	enableTOTP(t, env, first.User.ID)
+
+	resp, err := env.users.SignInWithProvider(ctx, &model.FederatedTicketRequest{Ticket: federatedLogin(t, env, iss, corpAlice).Get("ticket")})
+	if err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+	if !resp.MFARequired || resp.MFAToken == "" || resp.Token != "" {
+		t.Errorf("expected an MFA challenge, got %+v", resp)
+	}
+}
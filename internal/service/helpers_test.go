@@ -0,0 +1,314 @@
+package service_test
+
+import (
+	"context"
+	"database/sql"
+	"net/url"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	_ "modernc.org/sqlite"
+
+	"user-management-api/internal/ldap"
+	"user-management-api/internal/mail"
+	"user-management-api/internal/migrate"
+	"user-management-api/internal/password"
+	"user-management-api/internal/ratelimit"
+	"user-management-api/internal/repository"
+	"user-management-api/internal/service"
+	"user-management-api/internal/signing"
+)
+
+// testEnv is a fully wired set of services over a fresh in-memory database.
+type testEnv struct {
+	db            *sql.DB
+	users         *service.UserService
+	tokens        *service.TokenService
+	passwords     *service.PasswordService
+	verifier      *service.EmailVerificationService
+	mfa           *service.MFAService
+	passkeys      *service.WebAuthnService
+	lockout       *service.LockoutService
+	pats          *service.PersonalTokenService
+	clients       *service.OAuthService
+	oidc          *service.OIDCService
+	federation    *service.FederationService
+	magicLinks    *service.MagicLinkService
+	audit         *service.AuditService
+	impersonation *service.ImpersonationService
+	orgs          *service.OrganizationService
+	mailer        *captureMailer
+}
+
+// testConfig gathers every service config so a test can tweak just one knob.
+type testConfig struct {
+	token        service.TokenConfig
+	user         service.UserConfig
+	password     service.PasswordConfig
+	verification service.VerificationConfig
+	mfa          service.MFAConfig
+	webauthn     service.WebAuthnConfig
+	lockout      service.LockoutConfig
+	oidc         service.OIDCConfig
+	federation   service.FederationConfig
+	impersonate  service.ImpersonationConfig
+	magicLink    service.MagicLinkConfig
+	// emails is where addresses must be unique.
+	emails repository.EmailScope
+	// ldap, when set, is asked before local passwords at sign-in.
+	ldap *ldap.Directory
+	// policy and hasher are copied into both user and password configs.
+	policy *password.Policy
+	hasher password.Hasher
+}
+
+// newTestEnv spins up a real in-memory SQLite DB, migrated with the
+// production migrations, and wires every service against it. The DB is
+// closed automatically when the test ends.
+func newTestEnv(t *testing.T, opts ...func(*testConfig)) *testEnv {
+	t.Helper()
+
+	cfg := testConfig{
+		token: service.TokenConfig{
+			Keys:          signing.NewHMACKeySet("test-secret"),
+			JWTExpiry:     15 * time.Minute,
+			RefreshExpiry: 24 * time.Hour,
+			Issuer:        "https://id.test",
+		},
+		user: service.UserConfig{PurgeGrace: time.Hour},
+		password: service.PasswordConfig{
+			ResetTokenExpiry: time.Hour,
+			ResetURL:         "http://app.test/reset-password",
+			Dispatch:         runNow(t),
+		},
+		verification: service.VerificationConfig{
+			TokenExpiry: time.Hour,
+			VerifyURL:   "http://app.test/verify-email",
+			Dispatch:    runNow(t),
+		},
+		mfa: service.MFAConfig{Issuer: "Test", ChallengeExpiry: 5 * time.Minute},
+		webauthn: service.WebAuthnConfig{
+			RPID:    "app.test",
+			RPName:  "Test",
+			Origins: []string{"https://app.test"},
+			Timeout: 5 * time.Minute,
+		},
+		lockout: service.LockoutConfig{
+			Threshold:   5,
+			Duration:    15 * time.Minute,
+			MaxDuration: 24 * time.Hour,
+			IPThreshold: 20,
+			IPWindow:    15 * time.Minute,
+		},
+		oidc: service.OIDCConfig{
+			Issuer:           "https://id.test",
+			LoginURL:         "https://app.test/authorize",
+			CodeExpiry:       time.Minute,
+			SigningAlgorithm: "HS256",
+		},
+		federation: service.FederationConfig{
+			ReturnURL:   "https://app.test/sso/callback",
+			StateExpiry: 10 * time.Minute,
+		},
+		impersonate: service.ImpersonationConfig{Expiry: 15 * time.Minute},
+		emails:      repository.EmailsGlobal,
+		magicLink: service.MagicLinkConfig{
+			Expiry:     15 * time.Minute,
+			SignInURL:  "http://app.test/magic-link",
+			PerAddress: ratelimit.Limit{Requests: 3, Period: time.Hour},
+			Dispatch:   runNow(t),
+		},
+		policy: &password.Policy{
+			MinLength:   8,
+			MaxLength:   72,
+			MinClasses:  2,
+			MaxRepeated: 3,
+			MinStrength: 2,
+			Blocklist:   password.NewBlocklist(),
+		},
+		// Argon2id cut down to test speed, with bcrypt to verify legacy hashes.
+		hasher: password.Chain(
+			&password.Argon2id{Memory: 64, Time: 1, Threads: 1, SaltLen: 16, KeyLen: 32},
+			password.Bcrypt{Cost: bcrypt.MinCost},
+		),
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	cfg.user.Policy = cfg.policy
+	cfg.password.Policy = cfg.policy
+	cfg.user.Hasher = cfg.hasher
+	cfg.password.Hasher = cfg.hasher
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	// :memory: databases are per-connection; pin the pool to one.
+	db.SetMaxOpenConns(1)
+
+	m, err := migrate.New(db)
+	if err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if _, err := m.Up(context.Background()); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	repo := repository.NewUserRepository(db, cfg.emails)
+	roles := repository.NewRoleRepository(db)
+	actions := repository.NewActionTokenRepository(db)
+	pats := repository.NewPersonalTokenRepository(db)
+	clients := repository.NewOAuthClientRepository(db)
+	members := repository.NewMembershipRepository(db)
+	mailer := &captureMailer{}
+	tokens := service.NewTokenService(
+		repo,
+		repository.NewRefreshTokenRepository(db),
+		repository.NewRevokedTokenRepository(db),
+		roles,
+		pats,
+		clients,
+		repository.NewSessionRepository(db),
+		members,
+		cfg.token,
+	)
+	verifier := service.NewEmailVerificationService(repo, actions, mailer, cfg.verification)
+	mfa := service.NewMFAService(repo, repository.NewMFARepository(db), actions, tokens, cfg.mfa)
+	passkeyRepo := repository.NewWebAuthnRepository(db)
+	passkeys := service.NewWebAuthnService(repo, passkeyRepo, actions, cfg.webauthn)
+	lockout := service.NewLockoutService(repo, repository.NewSignInFailureRepository(db), cfg.lockout)
+	identities := repository.NewFederationRepository(db)
+	federation := service.NewFederationService(identities, repo, roles, passkeyRepo, verifier, cfg.federation)
+	audit := service.NewAuditService(repository.NewAuditRepository(db))
+	magicLinks := service.NewMagicLinkService(repo, actions, mailer, cfg.magicLink)
+	cfg.user.Authenticator = service.NewLocalAuthenticator(repo, cfg.hasher)
+	if cfg.ldap != nil {
+		cfg.user.Authenticator = service.ChainAuthenticators(
+			service.NewLDAPAuthenticator(cfg.ldap, repo, roles, identities),
+			cfg.user.Authenticator,
+		)
+	}
+
+	return &testEnv{
+		db:            db,
+		users:         service.NewUserService(repo, roles, tokens, verifier, mfa, passkeys, lockout, federation, magicLinks, cfg.user),
+		tokens:        tokens,
+		passwords:     service.NewPasswordService(repo, actions, tokens, lockout, mailer, cfg.password),
+		verifier:      verifier,
+		mfa:           mfa,
+		passkeys:      passkeys,
+		lockout:       lockout,
+		pats:          service.NewPersonalTokenService(pats),
+		clients:       service.NewOAuthService(clients, tokens),
+		oidc:          service.NewOIDCService(clients, repository.NewOIDCRepository(db), repo, tokens, cfg.oidc),
+		federation:    federation,
+		magicLinks:    magicLinks,
+		audit:         audit,
+		impersonation: service.NewImpersonationService(repo, roles, tokens, audit, cfg.impersonate),
+		orgs:          service.NewOrganizationService(repository.NewOrganizationRepository(db), members, repo),
+		mailer:        mailer,
+	}
+}
+
+// setupService returns just the UserService of a fresh test environment.
+func setupService(t *testing.T) *service.UserService {
+	t.Helper()
+	return newTestEnv(t).users
+}
+
+// setupServices is setupService for tests that also need the TokenService.
+func setupServices(t *testing.T) (*service.UserService, *service.TokenService) {
+	t.Helper()
+	env := newTestEnv(t)
+	return env.users, env.tokens
+}
+
+// setupServicesWithConfig is setupServices with a custom UserConfig.
+func setupServicesWithConfig(t *testing.T, userCfg service.UserConfig) (*service.UserService, *service.TokenService) {
+	t.Helper()
+	env := newTestEnv(t, func(c *testConfig) { c.user = userCfg })
+	return env.users, env.tokens
+}
+
+// captureMailer records messages instead of sending them.
+type captureMailer struct {
+	mu   sync.Mutex
+	sent []mail.Message
+}
+
+func (m *captureMailer) Send(_ context.Context, msg mail.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func (m *captureMailer) count() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.sent)
+}
+
+func (m *captureMailer) last(t *testing.T) mail.Message {
+	t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.sent) == 0 {
+		t.Fatal("expected an email to have been sent")
+	}
+	return m.sent[len(m.sent)-1]
+}
+
+var linkToken = regexp.MustCompile(`[?&]token=([^\s&]+)`)
+
+// lastToken extracts the token from the link in the most recent email.
+func (m *captureMailer) lastToken(t *testing.T) string {
+	t.Helper()
+	match := linkToken.FindStringSubmatch(m.last(t).Body)
+	if match == nil {
+		t.Fatal("expected the email to contain a token link")
+	}
+	tok, err := url.QueryUnescape(match[1])
+	if err != nil {
+		t.Fatalf("unescape token: %v", err)
+	}
+	return tok
+}
+
+// runNow stands in for service.InBackground, running each job before the
+// dispatching call returns so tests can check its mail straight away.
+func runNow(t *testing.T) service.Dispatch {
+	return func(ctx context.Context, name string, job func(context.Context) error) {
+		if err := job(ctx); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+// jobQueue stands in for service.InBackground, holding jobs until run.
+type jobQueue struct {
+	names []string
+	jobs  []func(context.Context) error
+}
+
+func (q *jobQueue) dispatch(_ context.Context, name string, job func(context.Context) error) {
+	q.names = append(q.names, name)
+	q.jobs = append(q.jobs, job)
+}
+
+// run runs and clears the queued jobs.
+func (q *jobQueue) run(t *testing.T) {
+	t.Helper()
+	for i, job := range q.jobs {
+		if err := job(context.Background()); err != nil {
+			t.Errorf("%s: %v", q.names[i], err)
+		}
+	}
+	q.names, q.jobs = nil, nil
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+
+	"user-management-api/internal/model"
+	"user-management-api/internal/repository"
+)
+
+// ErrImpersonationNotAllowed is returned when the caller may not act as
+// the requested user.
+var ErrImpersonationNotAllowed = errors.New("impersonation not allowed")
+
+// ImpersonationConfig holds the lifetime of impersonation tokens.
+type ImpersonationConfig struct {
+	Expiry time.Duration
+}
+
+// ImpersonationService lets support staff act as another user through a
+// short-lived access token whose act claim names them. TokenService checks
+// such tokens; RequireSession keeps them away from the routes that secure
+// an account, and the audit middleware records every request made with one.
+type ImpersonationService struct {
+	users  *repository.UserRepository
+	roles  *repository.RoleRepository
+	tokens *TokenService
+	audit  *AuditService
+	cfg    ImpersonationConfig
+}
+
+func NewImpersonationService(
+	users *repository.UserRepository,
+	roles *repository.RoleRepository,
+	tokens *TokenService,
+	audit *AuditService,
+	cfg ImpersonationConfig,
+) *ImpersonationService {
+	return &ImpersonationService{users: users, roles: roles, tokens: tokens, audit: audit, cfg: cfg}
+}
+
+// Impersonate issues p a token acting as the user with id targetID. Nobody
+// can impersonate themselves, or a user holding a permission they don't, so
+// impersonation never widens what the actor can do.
+func (s *ImpersonationService) Impersonate(ctx context.Context, p *model.Principal, targetID uuid.UUID) (*model.ImpersonationResponse, error) {
+	if p.Impersonated() || targetID == p.UserID {
+		return nil, ErrImpersonationNotAllowed
+	}
+	u, err := s.users.GetByID(ctx, targetID)
+	if err != nil {
+		return nil, err
+	}
+	roles, err := s.roles.ForUser(ctx, u.ID)
+	if err != nil {
+		return nil, err
+	}
+	perms, err := s.roles.Permissions(ctx, roles)
+	if err != nil {
+		return nil, err
+	}
+	for _, perm := range perms {
+		if !p.Can(perm) {
+			return nil, ErrImpersonationNotAllowed
+		}
+	}
+
+	token, err := s.tokens.IssueImpersonationToken(ctx, u, p, s.cfg.Expiry)
+	if err != nil {
+		return nil, err
+	}
+	err = s.audit.Record(ctx, &model.AuditEvent{
+		Action:  model.AuditImpersonationStart,
+		ActorID: p.UserID,
+		UserID:  u.ID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("impersonation: user %s started acting as %s", p.UserID, u.ID)
+
+	return &model.ImpersonationResponse{
+		Token:     token,
+		ExpiresIn: int64(s.cfg.Expiry.Seconds()),
+		User:      u,
+	}, nil
+}
@@ -0,0 +1,149 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"user-management-api/internal/model"
+	"user-management-api/internal/repository"
+	"user-management-api/internal/reqctx"
+	"user-management-api/internal/service"
+)
+
+// newCustomer registers a plain user for support staff to impersonate.
+func newCustomer(t *testing.T, env *testEnv) *model.User {
+	t.Helper()
+	reg, err := env.users.Register(context.Background(), &model.RegisterRequest{Name: "Bob", Email: "bob@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	return reg.User
+}
+
+func TestImpersonate(t *testing.T) {
+	env := newTestEnv(t)
+	admin := newTokenOwner(t, env)
+	bob := newCustomer(t, env)
+	ctx := reqctx.WithClient(context.Background(), reqctx.Client{IP: "203.0.113.7", UserAgent: "support console"})
+
+	resp, err := env.impersonation.Impersonate(ctx, admin, bob.ID)
+	if err != nil {
+		t.Fatalf("impersonate: %v", err)
+	}
+	if resp.User.ID != bob.ID || resp.ExpiresIn != 15*60 {
+		t.Errorf("unexpected response %+v", resp)
+	}
+
+	p, err := env.tokens.Authenticate(ctx, resp.Token)
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	if p.UserID != bob.ID || p.ActorID != admin.UserID || p.RealUserID() != admin.UserID {
+		t.Errorf("expected the admin acting as Bob, got %+v", p)
+	}
+	if p.Can(model.PermUsersDelete) || p.SessionID != uuid.Nil {
+		t.Errorf("expected Bob's permissions and no session, got %+v", p)
+	}
+
+	events, err := env.audit.List(ctx, &model.ListAuditQuery{UserID: bob.ID.String()})
+	if err != nil {
+		t.Fatalf("list audit: %v", err)
+	}
+	if len(events) != 1 || events[0].Action != model.AuditImpersonationStart || events[0].ActorID != admin.UserID || events[0].IP != "203.0.113.7" {
+		t.Errorf("expected the start to be audited, got %+v", events)
+	}
+}
+
+func TestImpersonate_NotAllowed(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	admin := newTokenOwner(t, env)
+	bob := newCustomer(t, env)
+
+	if _, err := env.impersonation.Impersonate(ctx, admin, admin.UserID); !errors.Is(err, service.ErrImpersonationNotAllowed) {
+		t.Errorf("self: expected ErrImpersonationNotAllowed, got %v", err)
+	}
+	if _, err := env.impersonation.Impersonate(ctx, admin, uuid.New()); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("unknown user: expected ErrNotFound, got %v", err)
+	}
+
+	// Acting as another admin would be a way around their MFA and audit trail.
+	if _, err := env.users.SetRoles(ctx, bob.ID, &model.SetRolesRequest{Roles: []string{model.RoleAdmin}}); err != nil {
+		t.Fatalf("set roles: %v", err)
+	}
+	carol, err := env.users.Register(ctx, &model.RegisterRequest{Name: "Carol", Email: "carol@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	support := &model.Principal{UserID: carol.User.ID, Permissions: []string{model.PermUsersImpersonate}}
+	if _, err := env.impersonation.Impersonate(ctx, support, bob.ID); !errors.Is(err, service.ErrImpersonationNotAllowed) {
+		t.Errorf("more privileged user: expected ErrImpersonationNotAllowed, got %v", err)
+	}
+
+	resp, err := env.impersonation.Impersonate(ctx, admin, carol.User.ID)
+	if err != nil {
+		t.Fatalf("impersonate: %v", err)
+	}
+	p, err := env.tokens.Authenticate(ctx, resp.Token)
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	if _, err := env.impersonation.Impersonate(ctx, p, bob.ID); !errors.Is(err, service.ErrImpersonationNotAllowed) {
+		t.Errorf("while impersonating: expected ErrImpersonationNotAllowed, got %v", err)
+	}
+}
+
+func TestImpersonate_EndsWithActor(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	admin := newTokenOwner(t, env)
+	bob := newCustomer(t, env)
+
+	resp, err := env.impersonation.Impersonate(ctx, admin, bob.ID)
+	if err != nil {
+		t.Fatalf("impersonate: %v", err)
+	}
+
+	// Demoting the admin ends their impersonation at once.
+	if _, err := env.users.SetRoles(ctx, admin.UserID, &model.SetRolesRequest{Roles: []string{model.RoleUser}}); err != nil {
+		t.Fatalf("set roles: %v", err)
+	}
+	if _, err := env.tokens.Authenticate(ctx, resp.Token); !errors.Is(err, service.ErrTokenRevoked) {
+		t.Errorf("demoted actor: expected ErrTokenRevoked, got %v", err)
+	}
+}
+
+func TestAudit_ListFilters(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	admin := newTokenOwner(t, env)
+	bob := newCustomer(t, env)
+
+	for _, e := range []*model.AuditEvent{
+		{Action: model.AuditImpersonationStart, ActorID: admin.UserID, UserID: bob.ID},
+		{Action: model.AuditImpersonatedRequest, ActorID: admin.UserID, UserID: bob.ID, Method: "GET", Path: "/api/v1/users/" + bob.ID.String(), Status: 200},
+		{Action: model.AuditImpersonatedRequest, ActorID: bob.ID, UserID: admin.UserID, Method: "GET", Path: "/", Status: 404},
+	} {
+		if err := env.audit.Record(ctx, e); err != nil {
+			t.Fatalf("record: %v", err)
+		}
+	}
+
+	events, err := env.audit.List(ctx, &model.ListAuditQuery{ActorID: admin.UserID.String(), Action: model.AuditImpersonatedRequest})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(events) != 1 || events[0].Status != 200 || events[0].UserID != bob.ID {
+		t.Errorf("expected the admin's one request, got %+v", events)
+	}
+	all, err := env.audit.List(ctx, &model.ListAuditQuery{})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(all) != 3 || all[0].ActorID != bob.ID {
+		t.Errorf("expected every event, newest first, got %d", len(all))
+	}
+}
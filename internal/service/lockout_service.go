@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"user-management-api/internal/model"
+	"user-management-api/internal/repository"
+)
+
+var (
+	// ErrAccountLocked is returned by SignIn while the account is locked
+	// after too many wrong passwords.
+	ErrAccountLocked = errors.New("account temporarily locked")
+	// ErrTooManyAttempts is returned by SignIn while the client IP is blocked.
+	ErrTooManyAttempts = errors.New("too many failed sign-in attempts")
+)
+
+// RetryAfterError wraps a throttling error with the time the caller may try again.
+type RetryAfterError struct {
+	Err   error
+	Until time.Time
+}
+
+func (e *RetryAfterError) Error() string { return e.Err.Error() }
+func (e *RetryAfterError) Unwrap() error { return e.Err }
+
+// LockoutConfig holds the tunables for LockoutService.
+type LockoutConfig struct {
+	// Threshold is how many consecutive wrong passwords lock an account.
+	// Zero disables account lockout.
+	Threshold int
+	// Duration is the first lock; every further failure after it doubles
+	// the lock, up to MaxDuration.
+	Duration    time.Duration
+	MaxDuration time.Duration
+
+	// IPThreshold is how many failures from one IP within IPWindow, across
+	// all accounts, start blocking that IP. Blocks start at one second and
+	// double with each further failure, up to IPWindow. Zero disables it.
+	IPThreshold int
+	IPWindow    time.Duration
+}
+
+// LockoutService throttles password guessing, per account and per client IP.
+type LockoutService struct {
+	users    *repository.UserRepository
+	failures *repository.SignInFailureRepository
+	cfg      LockoutConfig
+}
+
+func NewLockoutService(users *repository.UserRepository, failures *repository.SignInFailureRepository, cfg LockoutConfig) *LockoutService {
+	return &LockoutService{users: users, failures: failures, cfg: cfg}
+}
+
+// CheckIP returns ErrTooManyAttempts while ip is blocked. An empty ip (no
+// client information in the context) is never blocked.
+func (s *LockoutService) CheckIP(ctx context.Context, ip string) error {
+	if s.cfg.IPThreshold <= 0 || ip == "" {
+		return nil
+	}
+	until, err := s.failures.BlockedUntil(ctx, ip)
+	if err != nil {
+		return err
+	}
+	if until != nil && time.Now().Before(*until) {
+		return &RetryAfterError{Err: ErrTooManyAttempts, Until: *until}
+	}
+	return nil
+}
+
+// CheckAccount returns ErrAccountLocked while u is locked.
+func (s *LockoutService) CheckAccount(u *model.User) error {
+	if u.LockedUntil != nil && time.Now().Before(*u.LockedUntil) {
+		return &RetryAfterError{Err: ErrAccountLocked, Until: *u.LockedUntil}
+	}
+	return nil
+}
+
+// RecordFailure counts a failed sign-in from ip against u, which is nil when
+// the email matched no account, and locks or blocks once over the thresholds.
+func (s *LockoutService) RecordFailure(ctx context.Context, u *model.User, ip string) error {
+	now := time.Now().UTC()
+	if s.cfg.IPThreshold > 0 && ip != "" {
+		n, err := s.failures.Record(ctx, ip, now, s.cfg.IPWindow)
+		if err != nil {
+			return err
+		}
+		if n >= s.cfg.IPThreshold {
+			until := now.Add(backoff(n, s.cfg.IPThreshold, time.Second, s.cfg.IPWindow))
+			if err := s.failures.BlockUntil(ctx, ip, until); err != nil {
+				return err
+			}
+		}
+	}
+
+	if s.cfg.Threshold > 0 && u != nil {
+		n, err := s.users.RecordSignInFailure(ctx, u.ID)
+		if err != nil {
+			return err
+		}
+		if n >= s.cfg.Threshold {
+			until := now.Add(backoff(n, s.cfg.Threshold, s.cfg.Duration, s.cfg.MaxDuration))
+			if err := s.users.LockUntil(ctx, u.ID, until); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// RecordSuccess clears u's failure count after a correct password.
+func (s *LockoutService) RecordSuccess(ctx context.Context, u *model.User) error {
+	if u.FailedSignIns == 0 && u.LockedUntil == nil {
+		return nil
+	}
+	return s.users.ResetSignInFailures(ctx, u.ID)
+}
+
+// Unlock lifts a lock early and clears the failure count.
+func (s *LockoutService) Unlock(ctx context.Context, id uuid.UUID) error {
+	return s.users.ResetSignInFailures(ctx, id)
+}
+
+// PurgeStale forgets IPs whose failures have aged out of the window.
+func (s *LockoutService) PurgeStale(ctx context.Context) error {
+	now := time.Now().UTC()
+	_, err := s.failures.DeleteStale(ctx, now.Add(-s.cfg.IPWindow), now)
+	return err
+}
+
+// backoff is base for the failure that reaches threshold, doubling with each
+// one after it, capped at max.
+func backoff(n, threshold int, base, max time.Duration) time.Duration {
+	d := base
+	for i := threshold; i < n && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		return max
+	}
+	return d
+}
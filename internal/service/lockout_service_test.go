@@ -0,0 +1,161 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"user-management-api/internal/model"
+	"user-management-api/internal/reqctx"
+	"user-management-api/internal/service"
+)
+
+// newLockoutEnv is a test env with a low account threshold and Alice registered.
+func newLockoutEnv(t *testing.T) (*testEnv, *model.User) {
+	t.Helper()
+	env := newTestEnv(t, func(c *testConfig) {
+		c.lockout.Threshold = 3
+		c.lockout.IPThreshold = 5
+	})
+	resp, err := env.users.Register(context.Background(), &model.RegisterRequest{Name: "Alice", Email: "alice@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	return env, resp.User
+}
+
+func failSignIns(t *testing.T, env *testEnv, ctx context.Context, email string, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		_, err := env.users.SignIn(ctx, &model.SignInRequest{Email: email, Password: "wrongpassword"})
+		if !errors.Is(err, service.ErrInvalidCredentials) {
+			t.Fatalf("attempt %d: expected ErrInvalidCredentials, got %v", i+1, err)
+		}
+	}
+}
+
+// lockedFor asserts that err is ErrAccountLocked and returns the time left.
+func lockedFor(t *testing.T, err error) time.Duration {
+	t.Helper()
+	var retry *service.RetryAfterError
+	if !errors.Is(err, service.ErrAccountLocked) || !errors.As(err, &retry) {
+		t.Fatalf("expected ErrAccountLocked with a retry time, got %v", err)
+	}
+	return time.Until(retry.Until)
+}
+
+func TestSignIn_LocksAccountAfterThreshold(t *testing.T) {
+	env, _ := newLockoutEnv(t)
+	ctx := context.Background()
+
+	failSignIns(t, env, ctx, "alice@example.com", 3)
+
+	_, err := env.users.SignIn(ctx, &model.SignInRequest{Email: "alice@example.com", Password: "secret123"})
+	if left := lockedFor(t, err); left < 14*time.Minute || left > 15*time.Minute {
+		t.Errorf("expected a 15m lock, got %v", left)
+	}
+}
+
+func TestSignIn_LockDoublesAfterItExpires(t *testing.T) {
+	env, u := newLockoutEnv(t)
+	ctx := context.Background()
+
+	failSignIns(t, env, ctx, "alice@example.com", 3)
+	if _, err := env.db.Exec(`UPDATE users SET locked_until = ? WHERE id = ?`,
+		time.Now().Add(-time.Second).UTC().Format(time.RFC3339), u.ID.String()); err != nil {
+		t.Fatalf("expire lock: %v", err)
+	}
+	failSignIns(t, env, ctx, "alice@example.com", 1)
+
+	_, err := env.users.SignIn(ctx, &model.SignInRequest{Email: "alice@example.com", Password: "secret123"})
+	if left := lockedFor(t, err); left < 29*time.Minute || left > 30*time.Minute {
+		t.Errorf("expected a 30m lock, got %v", left)
+	}
+}
+
+func TestSignIn_SuccessResetsFailureCount(t *testing.T) {
+	env, _ := newLockoutEnv(t)
+	ctx := context.Background()
+
+	failSignIns(t, env, ctx, "alice@example.com", 2)
+	if _, err := env.users.SignIn(ctx, &model.SignInRequest{Email: "alice@example.com", Password: "secret123"}); err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+	failSignIns(t, env, ctx, "alice@example.com", 2)
+
+	if _, err := env.users.SignIn(ctx, &model.SignInRequest{Email: "alice@example.com", Password: "secret123"}); err != nil {
+		t.Errorf("expected the count to have started over, got %v", err)
+	}
+}
+
+func TestUnlockUser(t *testing.T) {
+	env, u := newLockoutEnv(t)
+	ctx := context.Background()
+
+	failSignIns(t, env, ctx, "alice@example.com", 3)
+
+	unlocked, err := env.users.UnlockUser(ctx, u.ID)
+	if err != nil {
+		t.Fatalf("unlock: %v", err)
+	}
+	if unlocked.LockedUntil != nil {
+		t.Errorf("expected no lock, got %v", unlocked.LockedUntil)
+	}
+	if _, err := env.users.SignIn(ctx, &model.SignInRequest{Email: "alice@example.com", Password: "secret123"}); err != nil {
+		t.Errorf("sign in after unlock: %v", err)
+	}
+}
+
+func TestResetPassword_LiftsLockout(t *testing.T) {
+	env, _ := newLockoutEnv(t)
+	ctx := context.Background()
+
+	failSignIns(t, env, ctx, "alice@example.com", 3)
+	env.passwords.ForgotPassword(ctx, &model.ForgotPasswordRequest{Email: "alice@example.com"})
+	if err := env.passwords.ResetPassword(ctx, &model.ResetPasswordRequest{Token: env.mailer.lastToken(t), Password: "newsecret456"}); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+
+	if _, err := env.users.SignIn(ctx, &model.SignInRequest{Email: "alice@example.com", Password: "newsecret456"}); err != nil {
+		t.Errorf("sign in after reset: %v", err)
+	}
+}
+
+func TestSignIn_BlocksIPAfterThreshold(t *testing.T) {
+	env, _ := newLockoutEnv(t)
+	attacker := reqctx.WithClient(context.Background(), reqctx.Client{IP: "203.0.113.7"})
+	other := reqctx.WithClient(context.Background(), reqctx.Client{IP: "198.51.100.1"})
+
+	// Spread over unknown accounts, so only the IP counter can catch it.
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com", "e@example.com"} {
+		failSignIns(t, env, attacker, email, 1)
+	}
+
+	_, err := env.users.SignIn(attacker, &model.SignInRequest{Email: "alice@example.com", Password: "secret123"})
+	var retry *service.RetryAfterError
+	if !errors.Is(err, service.ErrTooManyAttempts) || !errors.As(err, &retry) {
+		t.Fatalf("expected ErrTooManyAttempts with a retry time, got %v", err)
+	}
+	if _, err := env.users.SignIn(other, &model.SignInRequest{Email: "alice@example.com", Password: "secret123"}); err != nil {
+		t.Errorf("other IP: %v", err)
+	}
+}
+
+func TestChangePassword_WrongGuessesLockAccount(t *testing.T) {
+	env, alice := newLockoutEnv(t)
+	ctx := context.Background()
+	p := &model.Principal{UserID: alice.ID}
+
+	for i := 0; i < 3; i++ {
+		_, err := env.passwords.ChangePassword(ctx, p, &model.ChangePasswordRequest{CurrentPassword: "wrongpassword", NewPassword: "newsecret456"})
+		if !errors.Is(err, service.ErrIncorrectPassword) {
+			t.Fatalf("attempt %d: expected ErrIncorrectPassword, got %v", i+1, err)
+		}
+	}
+
+	_, err := env.passwords.ChangePassword(ctx, p, &model.ChangePasswordRequest{CurrentPassword: "secret123", NewPassword: "newsecret456"})
+	lockedFor(t, err)
+	_, err = env.users.SignIn(ctx, &model.SignInRequest{Email: "alice@example.com", Password: "secret123"})
+	lockedFor(t, err)
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"user-management-api/internal/mail"
+	"user-management-api/internal/model"
+	"user-management-api/internal/ratelimit"
+	"user-management-api/internal/repository"
+)
+
+// ErrInvalidMagicLink is returned for unknown, expired, used or stale sign-in links.
+var ErrInvalidMagicLink = errors.New("invalid or expired sign-in link")
+
+// MagicLinkConfig holds the tunables for MagicLinkService.
+type MagicLinkConfig struct {
+	Expiry time.Duration
+	// SignInURL is the page that receives the token as ?token=...
+	SignInURL string
+	// PerAddress caps how many links one account is mailed in a period, so
+	// the endpoint can't be used to flood someone's inbox. Requests over the
+	// cap are dropped as silently as those for unknown addresses.
+	PerAddress ratelimit.Limit
+	// Dispatch runs the lookup and mail of Send; nil means InBackground.
+	Dispatch Dispatch
+}
+
+// MagicLinkService mails single-use sign-in links, for users who would
+// rather not keep a password.
+type MagicLinkService struct {
+	users   *repository.UserRepository
+	actions *repository.ActionTokenRepository
+	mailer  mail.Mailer
+	cfg     MagicLinkConfig
+}
+
+func NewMagicLinkService(
+	users *repository.UserRepository,
+	actions *repository.ActionTokenRepository,
+	mailer mail.Mailer,
+	cfg MagicLinkConfig,
+) *MagicLinkService {
+	if cfg.Dispatch == nil {
+		cfg.Dispatch = InBackground
+	}
+	return &MagicLinkService{users: users, actions: actions, mailer: mailer, cfg: cfg}
+}
+
+// Send mails a sign-in link if the email belongs to an account. Like
+// ForgotPassword it returns before looking the address up, so unknown
+// addresses can't be told apart; requesting another link invalidates the
+// previous one.
+func (s *MagicLinkService) Send(ctx context.Context, req *model.MagicLinkRequest) {
+	email := req.Email
+	s.cfg.Dispatch(ctx, "magic link", func(ctx context.Context) error {
+		return s.send(ctx, email)
+	})
+}
+
+func (s *MagicLinkService) send(ctx context.Context, email string) error {
+	u, err := s.users.GetByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	if l := s.cfg.PerAddress; l.Enabled() {
+		n, err := s.actions.CountSince(ctx, u.ID, model.PurposeMagicLink, time.Now().Add(-l.Period))
+		if err != nil {
+			return err
+		}
+		if n >= l.Requests {
+			log.Printf("magic link for user %s not sent: over %s", u.ID, l)
+			return nil
+		}
+	}
+
+	raw, err := issueActionToken(ctx, s.actions, u.ID, model.PurposeMagicLink, u.Email, s.cfg.Expiry)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(ctx, mail.Message{
+		To:      u.Email,
+		Subject: "Your sign-in link",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nOpen the link below within %s to sign in. It works once:\n\n%s\n\n"+
+				"Or submit this token to POST /api/v1/auth/magic-link/verify:\n\n%s\n\n"+
+				"If you didn't ask to sign in, you can ignore this email.\n",
+			u.Name, s.cfg.Expiry, withToken(s.cfg.SignInURL, raw), raw,
+		),
+	})
+}
+
+// Redeem consumes a sign-in link and returns its user. The link is bound to
+// the address it was mailed to, so it stops working if the user's email
+// changes before it is used.
+func (s *MagicLinkService) Redeem(ctx context.Context, req *model.MagicLinkVerifyRequest) (*model.User, error) {
+	t, err := redeemActionToken(ctx, s.actions, model.PurposeMagicLink, req.Token)
+	if err != nil {
+		if errors.Is(err, errActionTokenInvalid) {
+			return nil, ErrInvalidMagicLink
+		}
+		return nil, err
+	}
+
+	u, err := s.users.GetByID(ctx, t.UserID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrInvalidMagicLink
+		}
+		return nil, err
+	}
+	if t.Email != u.Email {
+		return nil, ErrInvalidMagicLink
+	}
+
+	// Using the link proves the address, just as a verification link does.
+	// A pending address change is left for its own link to settle.
+	if u.EmailVerifiedAt == nil && u.PendingEmail == "" {
+		now := time.Now().UTC()
+		if err := s.users.MarkEmailVerified(ctx, u.ID, u.Email, now); err != nil {
+			return nil, err
+		}
+		u.EmailVerifiedAt = &now
+	}
+	return u, nil
+}
@@ -0,0 +1,158 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"user-management-api/internal/model"
+	"user-management-api/internal/service"
+)
+
+// mailMagicLink asks for a sign-in link for email and returns its token.
+func mailMagicLink(t *testing.T, env *testEnv, email string) string {
+	t.Helper()
+	env.magicLinks.Send(context.Background(), &model.MagicLinkRequest{Email: email})
+	return env.mailer.lastToken(t)
+}
+
+func TestMagicLink_SignIn(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	bob := newCustomer(t, env)
+
+	token := mailMagicLink(t, env, "bob@example.com")
+	if msg := env.mailer.last(t); msg.To != "bob@example.com" || msg.Subject != "Your sign-in link" {
+		t.Errorf("unexpected email %+v", msg)
+	}
+
+	resp, err := env.users.SignInWithMagicLink(ctx, &model.MagicLinkVerifyRequest{Token: token})
+	if err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+	if resp.User.ID != bob.ID || resp.Token == "" || resp.RefreshToken == "" {
+		t.Fatalf("expected a session for Bob, got %+v", resp)
+	}
+	if resp.User.EmailVerifiedAt == nil {
+		t.Error("expected using the link to verify the address")
+	}
+	p, err := env.tokens.Authenticate(ctx, resp.Token)
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	if !slices.Equal(p.AMR, []string{model.AMREmail}) {
+		t.Errorf("expected amr [email], got %v", p.AMR)
+	}
+
+	if _, err := env.users.SignInWithMagicLink(ctx, &model.MagicLinkVerifyRequest{Token: token}); !errors.Is(err, service.ErrInvalidMagicLink) {
+		t.Errorf("reused link: expected ErrInvalidMagicLink, got %v", err)
+	}
+}
+
+func TestMagicLink_UnknownEmailIsSilent(t *testing.T) {
+	env := newTestEnv(t)
+
+	env.magicLinks.Send(context.Background(), &model.MagicLinkRequest{Email: "nobody@example.com"})
+	if n := env.mailer.count(); n != 0 {
+		t.Errorf("expected no email, got %d", n)
+	}
+}
+
+func TestMagicLink_KnownAndUnknownEmailsDoTheSameWork(t *testing.T) {
+	jobs := &jobQueue{}
+	env := newTestEnv(t, func(c *testConfig) { c.magicLink.Dispatch = jobs.dispatch })
+	ctx := context.Background()
+	newCustomer(t, env)
+	sent := env.mailer.count()
+
+	for _, email := range []string{"bob@example.com", "nobody@example.com"} {
+		env.magicLinks.Send(ctx, &model.MagicLinkRequest{Email: email})
+	}
+	// Neither call looks anything up or mails before returning; each leaves
+	// one job behind.
+	if len(jobs.jobs) != 2 {
+		t.Fatalf("expected a job per request, got %v", jobs.names)
+	}
+	if n := env.mailer.count(); n != sent {
+		t.Errorf("expected no email before the jobs run, got %d", n-sent)
+	}
+	var issued int
+	if err := env.db.QueryRow(`SELECT COUNT(*) FROM action_tokens WHERE purpose = ?`, model.PurposeMagicLink).Scan(&issued); err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	if issued != 0 {
+		t.Errorf("expected no link before the jobs run, got %d", issued)
+	}
+
+	jobs.run(t)
+	if n := env.mailer.count(); n != sent+1 || env.mailer.last(t).To != "bob@example.com" {
+		t.Errorf("expected one email, to bob@example.com, got %d", n-sent)
+	}
+}
+
+func TestMagicLink_BoundToAddress(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	bob := newCustomer(t, env)
+
+	// Only the latest link works.
+	first := mailMagicLink(t, env, "bob@example.com")
+	second := mailMagicLink(t, env, "bob@example.com")
+	if _, err := env.users.SignInWithMagicLink(ctx, &model.MagicLinkVerifyRequest{Token: first}); !errors.Is(err, service.ErrInvalidMagicLink) {
+		t.Errorf("superseded link: expected ErrInvalidMagicLink, got %v", err)
+	}
+
+	// A link mailed to an address the user has moved away from is stale.
+	if _, err := env.users.UpdateUser(ctx, bob.ID, &model.UpdateUserRequest{Email: "robert@example.com"}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if _, err := env.verifier.Verify(ctx, &model.VerifyEmailRequest{Token: env.mailer.lastToken(t)}); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if _, err := env.users.SignInWithMagicLink(ctx, &model.MagicLinkVerifyRequest{Token: second}); !errors.Is(err, service.ErrInvalidMagicLink) {
+		t.Errorf("old address: expected ErrInvalidMagicLink, got %v", err)
+	}
+}
+
+func TestMagicLink_PerAddressLimit(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	newCustomer(t, env)
+	before := env.mailer.count()
+
+	for i := 0; i < 5; i++ {
+		env.magicLinks.Send(ctx, &model.MagicLinkRequest{Email: "bob@example.com"})
+	}
+	if n := env.mailer.count() - before; n != 3 {
+		t.Errorf("expected 3 emails within the limit, got %d", n)
+	}
+}
+
+func TestMagicLink_MFAUsersAreChallenged(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	bob := newCustomer(t, env)
+	secret, _ := enableTOTP(t, env, bob.ID)
+
+	resp, err := env.users.SignInWithMagicLink(ctx, &model.MagicLinkVerifyRequest{Token: mailMagicLink(t, env, "bob@example.com")})
+	if err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+	if !resp.MFARequired || resp.MFAToken == "" || resp.Token != "" {
+		t.Fatalf("expected an MFA challenge, got %+v", resp)
+	}
+
+	// The session records the link, not a password, as the first factor.
+	resp, err = env.mfa.Verify(ctx, &model.MFAVerifyRequest{MFAToken: resp.MFAToken, Code: nextCode(t, secret)})
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	p, err := env.tokens.Authenticate(ctx, resp.Token)
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	if !slices.Equal(p.AMR, []string{model.AMREmail, model.AMROTP}) {
+		t.Errorf("expected amr [email otp], got %v", p.AMR)
+	}
+}
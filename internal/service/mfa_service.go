@@ -0,0 +1,280 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"user-management-api/internal/model"
+	"user-management-api/internal/repository"
+	"user-management-api/internal/totp"
+)
+
+var (
+	// ErrMFAAlreadyEnabled is returned when enrolling a user whose TOTP factor is already confirmed.
+	ErrMFAAlreadyEnabled = errors.New("mfa already enabled")
+	// ErrMFANotEnabled is returned by operations that need an enrolled (or, for confirm, pending) factor.
+	ErrMFANotEnabled = errors.New("mfa not enabled")
+	// ErrInvalidMFACode is returned for wrong, expired or reused TOTP and recovery codes.
+	ErrInvalidMFACode = errors.New("invalid mfa code")
+	// ErrInvalidMFAToken is returned for unknown, expired or used MFA challenge tokens.
+	ErrInvalidMFAToken = errors.New("invalid or expired mfa token")
+)
+
+const (
+	recoveryCodeCount = 10
+	// totpSkew accepts codes from one step either side of now for clock drift.
+	totpSkew = 1
+)
+
+// MFAConfig holds the tunables for MFAService.
+type MFAConfig struct {
+	// Issuer labels the account in authenticator apps.
+	Issuer string
+	// ChallengeExpiry is how long a sign-in has to present its second factor.
+	ChallengeExpiry time.Duration
+}
+
+// MFAService manages TOTP enrollment and recovery codes, and completes
+// sign-ins that need a second factor.
+type MFAService struct {
+	users   *repository.UserRepository
+	mfa     *repository.MFARepository
+	actions *repository.ActionTokenRepository
+	tokens  *TokenService
+	cfg     MFAConfig
+}
+
+func NewMFAService(
+	users *repository.UserRepository,
+	mfa *repository.MFARepository,
+	actions *repository.ActionTokenRepository,
+	tokens *TokenService,
+	cfg MFAConfig,
+) *MFAService {
+	return &MFAService{users: users, mfa: mfa, actions: actions, tokens: tokens, cfg: cfg}
+}
+
+// Status reports whether the user has a confirmed TOTP factor and how many
+// recovery codes remain.
+func (s *MFAService) Status(ctx context.Context, userID uuid.UUID) (*model.MFAStatus, error) {
+	enabled, err := s.Enabled(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	n, err := s.mfa.CountRecoveryCodes(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &model.MFAStatus{TOTPEnabled: enabled, RecoveryCodesRemaining: n}, nil
+}
+
+// Enabled reports whether sign-in for the user requires a second factor.
+func (s *MFAService) Enabled(ctx context.Context, userID uuid.UUID) (bool, error) {
+	f, err := s.mfa.GetTOTP(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrFactorNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return f.ConfirmedAt != nil, nil
+}
+
+// EnrollTOTP generates a new secret for the user. It has no effect on
+// sign-in until confirmed with ConfirmTOTP; enrolling again before then
+// replaces the secret.
+func (s *MFAService) EnrollTOTP(ctx context.Context, userID uuid.UUID) (*model.TOTPEnrollment, error) {
+	enabled, err := s.Enabled(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if enabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	u, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	err = s.mfa.SaveTOTP(ctx, &model.TOTPFactor{UserID: u.ID, Secret: secret, CreatedAt: time.Now().UTC()})
+	if err != nil {
+		return nil, err
+	}
+	return &model.TOTPEnrollment{Secret: secret, URI: totp.URI(s.cfg.Issuer, u.Email, secret)}, nil
+}
+
+// ConfirmTOTP enables a pending factor once the user shows a valid code from
+// it, and returns a fresh set of recovery codes.
+func (s *MFAService) ConfirmTOTP(ctx context.Context, userID uuid.UUID, req *model.MFACodeRequest) (*model.RecoveryCodes, error) {
+	f, err := s.mfa.GetTOTP(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrFactorNotFound) {
+			return nil, ErrMFANotEnabled
+		}
+		return nil, err
+	}
+	if f.ConfirmedAt != nil {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if err := s.useTOTP(ctx, f, req.Code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.mfa.ConfirmTOTP(ctx, userID, hashes, time.Now().UTC()); err != nil {
+		return nil, err
+	}
+	return &model.RecoveryCodes{Codes: codes}, nil
+}
+
+// DisableTOTP removes the factor and its recovery codes after checking a
+// current code, so a stolen session alone can't switch MFA off.
+func (s *MFAService) DisableTOTP(ctx context.Context, userID uuid.UUID, req *model.MFACodeRequest) error {
+	if err := s.checkCode(ctx, userID, req.Code); err != nil {
+		return err
+	}
+	return s.mfa.DeleteTOTP(ctx, userID)
+}
+
+// RegenerateRecoveryCodes replaces every recovery code, used or not.
+func (s *MFAService) RegenerateRecoveryCodes(ctx context.Context, userID uuid.UUID, req *model.MFACodeRequest) (*model.RecoveryCodes, error) {
+	if err := s.checkCode(ctx, userID, req.Code); err != nil {
+		return nil, err
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.mfa.ReplaceRecoveryCodes(ctx, userID, hashes, time.Now().UTC()); err != nil {
+		return nil, err
+	}
+	return &model.RecoveryCodes{Codes: codes}, nil
+}
+
+// Challenge starts the second step of a sign-in for a user who passed the
+// first factor with the methods in amr, such as a password or a magic link.
+func (s *MFAService) Challenge(ctx context.Context, u *model.User, amr []string) (*model.AuthResponse, error) {
+	if err := s.actions.InvalidateForUser(ctx, u.ID, model.PurposeMFAChallenge, time.Now().UTC()); err != nil {
+		return nil, err
+	}
+	raw, err := storeActionToken(ctx, s.actions, &model.ActionToken{
+		UserID:  u.ID,
+		Purpose: model.PurposeMFAChallenge,
+		AMR:     amr,
+	}, s.cfg.ChallengeExpiry)
+	if err != nil {
+		return nil, err
+	}
+	return &model.AuthResponse{MFARequired: true, MFAToken: raw}, nil
+}
+
+// Verify completes a challenged sign-in. The challenge is single-use: a wrong
+// code burns it and the user has to sign in again, which keeps guessing
+// codes as expensive as guessing passwords.
+func (s *MFAService) Verify(ctx context.Context, req *model.MFAVerifyRequest) (*model.AuthResponse, error) {
+	t, err := redeemActionToken(ctx, s.actions, model.PurposeMFAChallenge, req.MFAToken)
+	if err != nil {
+		if errors.Is(err, errActionTokenInvalid) {
+			return nil, ErrInvalidMFAToken
+		}
+		return nil, err
+	}
+	u, err := s.users.GetByID(ctx, t.UserID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrInvalidMFAToken
+		}
+		return nil, err
+	}
+
+	if err := s.checkCode(ctx, u.ID, req.Code); err != nil {
+		return nil, err
+	}
+	// Challenges issued before the first factor was recorded all followed a
+	// password.
+	amr := t.AMR
+	if len(amr) == 0 {
+		amr = []string{model.AMRPassword}
+	}
+	return s.tokens.IssuePair(ctx, u, append(slices.Clone(amr), model.AMROTP))
+}
+
+// checkCode accepts either a current TOTP code or an unused recovery code
+// for a user with MFA enabled, spending it either way.
+func (s *MFAService) checkCode(ctx context.Context, userID uuid.UUID, code string) error {
+	f, err := s.mfa.GetTOTP(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrFactorNotFound) {
+			return ErrMFANotEnabled
+		}
+		return err
+	}
+	if f.ConfirmedAt == nil {
+		return ErrMFANotEnabled
+	}
+
+	code = strings.TrimSpace(code)
+	if len(code) == totp.Digits {
+		return s.useTOTP(ctx, f, code)
+	}
+	err = s.mfa.UseRecoveryCode(ctx, userID, hashToken(normalizeRecoveryCode(code)), time.Now().UTC())
+	if errors.Is(err, repository.ErrTokenNotFound) {
+		return ErrInvalidMFACode
+	}
+	return err
+}
+
+// useTOTP validates code against f and records its time step so the same
+// code can't be presented twice.
+func (s *MFAService) useTOTP(ctx context.Context, f *model.TOTPFactor, code string) error {
+	step, ok := totp.Validate(f.Secret, code, time.Now(), totpSkew)
+	if !ok || step <= f.LastStep {
+		return ErrInvalidMFACode
+	}
+	if err := s.mfa.UseTOTPStep(ctx, f.UserID, step); err != nil {
+		if errors.Is(err, repository.ErrTokenNotFound) {
+			return ErrInvalidMFACode
+		}
+		return err
+	}
+	return nil
+}
+
+// --- helpers ---
+
+var recoveryEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// newRecoveryCodes returns codes formatted for display (xxxxx-xxxxx, 50
+// bits each) together with the hashes to store.
+func newRecoveryCodes() (codes, hashes []string, err error) {
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, fmt.Errorf("service.newRecoveryCodes: %w", err)
+		}
+		c := recoveryEncoding.EncodeToString(b)[:10]
+		codes = append(codes, c[:5]+"-"+c[5:])
+		hashes = append(hashes, hashToken(c))
+	}
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode accepts codes with or without the dash and in any case.
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
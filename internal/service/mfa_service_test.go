@@ -0,0 +1,244 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"user-management-api/internal/model"
+	"user-management-api/internal/service"
+	"user-management-api/internal/totp"
+)
+
+// enableTOTP enrolls and confirms an authenticator for userID, returning the
+// secret and recovery codes. The confirming code uses the current time step,
+// so the next accepted code must come from a later one.
+func enableTOTP(t *testing.T, env *testEnv, userID uuid.UUID) (string, []string) {
+	t.Helper()
+	ctx := context.Background()
+
+	enrollment, err := env.mfa.EnrollTOTP(ctx, userID)
+	if err != nil {
+		t.Fatalf("enroll: %v", err)
+	}
+	code, err := totp.Code(enrollment.Secret, time.Now())
+	if err != nil {
+		t.Fatalf("code: %v", err)
+	}
+	rc, err := env.mfa.ConfirmTOTP(ctx, userID, &model.MFACodeRequest{Code: code})
+	if err != nil {
+		t.Fatalf("confirm: %v", err)
+	}
+	return enrollment.Secret, rc.Codes
+}
+
+// nextCode returns a code from the step after the current one, which is
+// still inside the accepted skew.
+func nextCode(t *testing.T, secret string) string {
+	t.Helper()
+	code, err := totp.Code(secret, time.Now().Add(totp.Period))
+	if err != nil {
+		t.Fatalf("code: %v", err)
+	}
+	return code
+}
+
+func TestMFA_EnrollConfirmAndSignIn(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	reg, err := env.users.Register(ctx, &model.RegisterRequest{Name: "Alice", Email: "alice@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	enrollment, err := env.mfa.EnrollTOTP(ctx, reg.User.ID)
+	if err != nil {
+		t.Fatalf("enroll: %v", err)
+	}
+	if !strings.HasPrefix(enrollment.URI, "otpauth://totp/") {
+		t.Errorf("unexpected URI %s", enrollment.URI)
+	}
+	// Until confirmed, the factor does not affect sign-in.
+	resp, err := env.users.SignIn(ctx, &model.SignInRequest{Email: "alice@example.com", Password: "secret123"})
+	if err != nil || resp.MFARequired {
+		t.Fatalf("expected a plain sign-in before confirmation, got %+v, %v", resp, err)
+	}
+
+	if _, err := env.mfa.ConfirmTOTP(ctx, reg.User.ID, &model.MFACodeRequest{Code: "000000"}); !errors.Is(err, service.ErrInvalidMFACode) {
+		t.Fatalf("wrong code: expected ErrInvalidMFACode, got %v", err)
+	}
+	code, _ := totp.Code(enrollment.Secret, time.Now())
+	rc, err := env.mfa.ConfirmTOTP(ctx, reg.User.ID, &model.MFACodeRequest{Code: code})
+	if err != nil {
+		t.Fatalf("confirm: %v", err)
+	}
+	if len(rc.Codes) != 10 {
+		t.Errorf("expected 10 recovery codes, got %d", len(rc.Codes))
+	}
+	if _, err := env.mfa.EnrollTOTP(ctx, reg.User.ID); !errors.Is(err, service.ErrMFAAlreadyEnabled) {
+		t.Errorf("re-enroll: expected ErrMFAAlreadyEnabled, got %v", err)
+	}
+
+	challenge, err := env.users.SignIn(ctx, &model.SignInRequest{Email: "alice@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+	if !challenge.MFARequired || challenge.MFAToken == "" || challenge.Token != "" {
+		t.Fatalf("expected an MFA challenge without tokens, got %+v", challenge)
+	}
+
+	resp, err = env.mfa.Verify(ctx, &model.MFAVerifyRequest{MFAToken: challenge.MFAToken, Code: nextCode(t, enrollment.Secret)})
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	p, err := env.tokens.Authenticate(ctx, resp.Token)
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	if !slices.Equal(p.AMR, []string{model.AMRPassword, model.AMROTP}) {
+		t.Errorf("expected amr [pwd otp], got %v", p.AMR)
+	}
+}
+
+func TestMFA_CodeCannotBeReplayed(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	reg, err := env.users.Register(ctx, &model.RegisterRequest{Name: "Alice", Email: "alice@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	secret, _ := enableTOTP(t, env, reg.User.ID)
+	used, _ := totp.Code(secret, time.Now())
+
+	challenge, err := env.users.SignIn(ctx, &model.SignInRequest{Email: "alice@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+	if _, err := env.mfa.Verify(ctx, &model.MFAVerifyRequest{MFAToken: challenge.MFAToken, Code: used}); !errors.Is(err, service.ErrInvalidMFACode) {
+		t.Fatalf("replayed code: expected ErrInvalidMFACode, got %v", err)
+	}
+	// The failed attempt burnt the challenge.
+	if _, err := env.mfa.Verify(ctx, &model.MFAVerifyRequest{MFAToken: challenge.MFAToken, Code: nextCode(t, secret)}); !errors.Is(err, service.ErrInvalidMFAToken) {
+		t.Errorf("burnt challenge: expected ErrInvalidMFAToken, got %v", err)
+	}
+}
+
+func TestMFA_RecoveryCodeIsSingleUse(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	reg, err := env.users.Register(ctx, &model.RegisterRequest{Name: "Alice", Email: "alice@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	_, codes := enableTOTP(t, env, reg.User.ID)
+	signIn := &model.SignInRequest{Email: "alice@example.com", Password: "secret123"}
+
+	challenge, err := env.users.SignIn(ctx, signIn)
+	if err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+	// Codes are accepted regardless of case and dash.
+	typed := strings.ToUpper(strings.ReplaceAll(codes[0], "-", ""))
+	if _, err := env.mfa.Verify(ctx, &model.MFAVerifyRequest{MFAToken: challenge.MFAToken, Code: typed}); err != nil {
+		t.Fatalf("verify with recovery code: %v", err)
+	}
+
+	st, err := env.mfa.Status(ctx, reg.User.ID)
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if !st.TOTPEnabled || st.RecoveryCodesRemaining != 9 {
+		t.Errorf("expected enabled with 9 codes left, got %+v", st)
+	}
+
+	challenge, err = env.users.SignIn(ctx, signIn)
+	if err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+	if _, err := env.mfa.Verify(ctx, &model.MFAVerifyRequest{MFAToken: challenge.MFAToken, Code: codes[0]}); !errors.Is(err, service.ErrInvalidMFACode) {
+		t.Errorf("reused recovery code: expected ErrInvalidMFACode, got %v", err)
+	}
+}
+
+func TestMFA_Disable(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	reg, err := env.users.Register(ctx, &model.RegisterRequest{Name: "Alice", Email: "alice@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	_, codes := enableTOTP(t, env, reg.User.ID)
+
+	if err := env.mfa.DisableTOTP(ctx, reg.User.ID, &model.MFACodeRequest{Code: "aaaaa-aaaaa"}); !errors.Is(err, service.ErrInvalidMFACode) {
+		t.Fatalf("wrong code: expected ErrInvalidMFACode, got %v", err)
+	}
+	if err := env.mfa.DisableTOTP(ctx, reg.User.ID, &model.MFACodeRequest{Code: codes[1]}); err != nil {
+		t.Fatalf("disable: %v", err)
+	}
+
+	resp, err := env.users.SignIn(ctx, &model.SignInRequest{Email: "alice@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+	if resp.MFARequired || resp.Token == "" {
+		t.Errorf("expected a plain sign-in after disabling MFA, got %+v", resp)
+	}
+}
+
+func TestMFA_RequiredForAdminRole(t *testing.T) {
+	env := newTestEnv(t, func(c *testConfig) { c.token.MFARequiredRoles = []string{model.RoleAdmin} })
+	ctx := context.Background()
+
+	reg, err := env.users.Register(ctx, &model.RegisterRequest{Name: "Alice", Email: "alice@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if _, err := env.users.SetRoles(ctx, reg.User.ID, &model.SetRolesRequest{Roles: []string{model.RoleAdmin, model.RoleUser}}); err != nil {
+		t.Fatalf("set roles: %v", err)
+	}
+	signIn := &model.SignInRequest{Email: "alice@example.com", Password: "secret123"}
+
+	resp, err := env.users.SignIn(ctx, signIn)
+	if err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+	p, err := env.tokens.Authenticate(ctx, resp.Token)
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	if p.Can(model.PermUsersWrite) || !p.Can(model.PermUsersRead) {
+		t.Errorf("expected only user permissions without MFA, got %v", p.Permissions)
+	}
+
+	secret, _ := enableTOTP(t, env, reg.User.ID)
+	challenge, err := env.users.SignIn(ctx, signIn)
+	if err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+	resp, err = env.mfa.Verify(ctx, &model.MFAVerifyRequest{MFAToken: challenge.MFAToken, Code: nextCode(t, secret)})
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+
+	// The second factor carries over to refreshed tokens.
+	resp, err = env.users.Refresh(ctx, &model.RefreshRequest{RefreshToken: resp.RefreshToken})
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	p, err = env.tokens.Authenticate(ctx, resp.Token)
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	if !p.Can(model.PermUsersWrite) {
+		t.Errorf("expected admin permissions after MFA, got %v", p.Permissions)
+	}
+}
@@ -0,0 +1,151 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"user-management-api/internal/model"
+	"user-management-api/internal/service"
+)
+
+// newClient registers a client with users:read and users:write, created by
+// an admin.
+func newClient(t *testing.T, env *testEnv) (*model.OAuthClientCreated, *model.Principal) {
+	t.Helper()
+	admin := newTokenOwner(t, env)
+	c, err := env.clients.CreateClient(context.Background(), admin, &model.CreateOAuthClientRequest{
+		Name:   "billing",
+		Scopes: []string{model.PermUsersWrite, model.PermUsersRead},
+	})
+	if err != nil {
+		t.Fatalf("create client: %v", err)
+	}
+	return c, admin
+}
+
+func TestClientCredentials_IssuesScopedToken(t *testing.T) {
+	env := newTestEnv(t)
+	c, _ := newClient(t, env)
+	ctx := context.Background()
+
+	resp, err := env.clients.ClientCredentials(ctx, c.ClientID, c.ClientSecret, model.PermUsersRead)
+	if err != nil {
+		t.Fatalf("client credentials: %v", err)
+	}
+	if resp.TokenType != "Bearer" || resp.Scope != model.PermUsersRead {
+		t.Errorf("unexpected response %+v", resp)
+	}
+
+	p, err := env.tokens.Authenticate(ctx, resp.AccessToken)
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	if p.ClientID != c.ClientID || p.UserID != uuid.Nil {
+		t.Errorf("expected a client principal, got %+v", p)
+	}
+	if !p.Can(model.PermUsersRead) || p.Can(model.PermUsersWrite) {
+		t.Errorf("expected only users:read, got %v", p.Permissions)
+	}
+}
+
+func TestClientCredentials_DefaultsToAllScopes(t *testing.T) {
+	env := newTestEnv(t)
+	c, _ := newClient(t, env)
+
+	resp, err := env.clients.ClientCredentials(context.Background(), c.ClientID, c.ClientSecret, "")
+	if err != nil {
+		t.Fatalf("client credentials: %v", err)
+	}
+	if resp.Scope != "users:read users:write" {
+		t.Errorf("expected every client scope, got %q", resp.Scope)
+	}
+}
+
+func TestClientCredentials_Rejects(t *testing.T) {
+	env := newTestEnv(t)
+	c, _ := newClient(t, env)
+	ctx := context.Background()
+
+	if _, err := env.clients.ClientCredentials(ctx, c.ClientID, "wrong", ""); !errors.Is(err, service.ErrInvalidClient) {
+		t.Errorf("wrong secret: expected ErrInvalidClient, got %v", err)
+	}
+	if _, err := env.clients.ClientCredentials(ctx, "nobody", c.ClientSecret, ""); !errors.Is(err, service.ErrInvalidClient) {
+		t.Errorf("unknown client: expected ErrInvalidClient, got %v", err)
+	}
+	if _, err := env.clients.ClientCredentials(ctx, c.ClientID, c.ClientSecret, model.PermRolesWrite); !errors.Is(err, service.ErrInvalidScope) {
+		t.Errorf("extra scope: expected ErrInvalidScope, got %v", err)
+	}
+}
+
+func TestCreateClient_ScopeBeyondCreator(t *testing.T) {
+	env := newTestEnv(t)
+	admin := newTokenOwner(t, env)
+	admin.Permissions = []string{model.PermUsersRead, model.PermClientsWrite}
+
+	_, err := env.clients.CreateClient(context.Background(), admin, &model.CreateOAuthClientRequest{
+		Name: "billing", Scopes: []string{model.PermUsersDelete},
+	})
+	if !errors.Is(err, service.ErrInvalidScope) {
+		t.Errorf("expected ErrInvalidScope, got %v", err)
+	}
+}
+
+func TestUpdateClient_NarrowsIssuedTokens(t *testing.T) {
+	env := newTestEnv(t)
+	c, admin := newClient(t, env)
+	ctx := context.Background()
+
+	resp, err := env.clients.ClientCredentials(ctx, c.ClientID, c.ClientSecret, "")
+	if err != nil {
+		t.Fatalf("client credentials: %v", err)
+	}
+	if _, err := env.clients.UpdateClient(ctx, admin, c.ID, &model.UpdateOAuthClientRequest{Scopes: []string{model.PermUsersRead}}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	p, err := env.tokens.Authenticate(ctx, resp.AccessToken)
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	if p.Can(model.PermUsersWrite) {
+		t.Errorf("expected users:write to be gone, got %v", p.Permissions)
+	}
+}
+
+func TestRotateClientSecret(t *testing.T) {
+	env := newTestEnv(t)
+	c, _ := newClient(t, env)
+	ctx := context.Background()
+
+	rotated, err := env.clients.RotateClientSecret(ctx, c.ID)
+	if err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	if _, err := env.clients.ClientCredentials(ctx, c.ClientID, c.ClientSecret, ""); !errors.Is(err, service.ErrInvalidClient) {
+		t.Errorf("old secret: expected ErrInvalidClient, got %v", err)
+	}
+	if _, err := env.clients.ClientCredentials(ctx, c.ClientID, rotated.ClientSecret, ""); err != nil {
+		t.Errorf("new secret: %v", err)
+	}
+}
+
+func TestDeleteClient_EndsTokens(t *testing.T) {
+	env := newTestEnv(t)
+	c, _ := newClient(t, env)
+	ctx := context.Background()
+
+	resp, err := env.clients.ClientCredentials(ctx, c.ClientID, c.ClientSecret, "")
+	if err != nil {
+		t.Fatalf("client credentials: %v", err)
+	}
+	if err := env.clients.DeleteClient(ctx, c.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	if _, err := env.tokens.Authenticate(ctx, resp.AccessToken); !errors.Is(err, service.ErrInvalidToken) {
+		t.Errorf("expected ErrInvalidToken, got %v", err)
+	}
+}
@@ -0,0 +1,333 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"user-management-api/internal/model"
+	"user-management-api/internal/repository"
+)
+
+var (
+	// ErrInvalidGrant is returned at the token endpoint for an authorization
+	// code that is unknown, used, expired, or presented with the wrong
+	// client, redirect URI or PKCE verifier.
+	ErrInvalidGrant = errors.New("invalid authorization grant")
+	// ErrInsufficientScope is returned when a token lacks the scope an
+	// endpoint needs, such as openid for userinfo.
+	ErrInsufficientScope = errors.New("insufficient scope")
+)
+
+// AuthorizeError is a problem with an authentication request that is
+// reported to the client through its redirect URI (RFC 6749 §4.1.2.1).
+type AuthorizeError struct {
+	Code        string
+	Description string
+}
+
+func (e *AuthorizeError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+// OIDCConfig configures the OpenID Connect provider.
+type OIDCConfig struct {
+	// Issuer is this API's public base URL. Discovery and the endpoints it
+	// lists live under it.
+	Issuer string
+	// LoginURL is the front-end page that signs the user in, asks for
+	// consent and posts the request back to /oauth/authorize.
+	LoginURL   string
+	CodeExpiry time.Duration
+	// SigningAlgorithm is advertised for ID tokens.
+	SigningAlgorithm string
+}
+
+// OIDCService lets other apps sign users in with the authorization code
+// flow and PKCE, acting as an OpenID Connect provider.
+type OIDCService struct {
+	clients *repository.OAuthClientRepository
+	oidc    *repository.OIDCRepository
+	users   *repository.UserRepository
+	tokens  *TokenService
+	cfg     OIDCConfig
+}
+
+func NewOIDCService(
+	clients *repository.OAuthClientRepository,
+	oidc *repository.OIDCRepository,
+	users *repository.UserRepository,
+	tokens *TokenService,
+	cfg OIDCConfig,
+) *OIDCService {
+	return &OIDCService{clients: clients, oidc: oidc, users: users, tokens: tokens, cfg: cfg}
+}
+
+// Discovery returns the OpenID Provider Metadata document.
+func (s *OIDCService) Discovery() map[string]any {
+	base := strings.TrimRight(s.cfg.Issuer, "/")
+	return map[string]any{
+		"issuer":                                base,
+		"authorization_endpoint":                base + "/api/v1/oauth/authorize",
+		"token_endpoint":                        base + "/api/v1/oauth/token",
+		"userinfo_endpoint":                     base + "/api/v1/oauth/userinfo",
+		"jwks_uri":                              base + "/.well-known/jwks.json",
+		"response_types_supported":              []string{"code"},
+		"grant_types_supported":                 []string{model.GrantAuthorizationCode, model.GrantClientCredentials},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{s.cfg.SigningAlgorithm},
+		"scopes_supported":                      []string{model.ScopeOpenID, model.ScopeProfile, model.ScopeEmail},
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"claims_supported": []string{
+			"iss", "sub", "aud", "azp", "exp", "iat", "auth_time", "nonce", "amr",
+			"name", "updated_at", "email", "email_verified",
+		},
+		"authorization_response_iss_parameter_supported": true,
+	}
+}
+
+// CheckAuthorize validates an authentication request. ErrInvalidClient and
+// ErrInvalidRedirectURI mean the redirect URI can't be trusted, so the user
+// must be shown the error; any other problem is an *AuthorizeError to send
+// back to the client.
+func (s *OIDCService) CheckAuthorize(ctx context.Context, req *model.AuthorizeRequest) (*model.OAuthClient, []string, error) {
+	c, err := s.clients.GetByClientID(ctx, req.ClientID)
+	if err != nil {
+		if errors.Is(err, repository.ErrClientNotFound) {
+			return nil, nil, ErrInvalidClient
+		}
+		return nil, nil, err
+	}
+	if req.RedirectURI == "" || !slices.Contains(c.RedirectURIs, req.RedirectURI) {
+		return nil, nil, ErrInvalidRedirectURI
+	}
+
+	if req.ResponseType != "code" {
+		return nil, nil, &AuthorizeError{"unsupported_response_type", "only response_type=code is supported"}
+	}
+	scopes := strings.Fields(req.Scope)
+	if !slices.Contains(scopes, model.ScopeOpenID) {
+		return nil, nil, &AuthorizeError{"invalid_scope", "the openid scope is required"}
+	}
+	for _, scope := range scopes {
+		if !isOIDCScope(scope) && !slices.Contains(c.Scopes, scope) {
+			return nil, nil, &AuthorizeError{"invalid_scope", "scope " + scope + " is not allowed for this client"}
+		}
+	}
+	if req.CodeChallenge == "" || req.CodeChallengeMethod != "S256" {
+		return nil, nil, &AuthorizeError{"invalid_request", "PKCE with code_challenge_method=S256 is required"}
+	}
+	slices.Sort(scopes)
+	return c, slices.Compact(scopes), nil
+}
+
+// LoginRedirect is where GET /oauth/authorize sends the browser once the
+// request checks out: the login page, with the request in its query string.
+func (s *OIDCService) LoginRedirect(query url.Values) string {
+	return s.cfg.LoginURL + "?" + query.Encode()
+}
+
+// ErrorRedirect sends an *AuthorizeError back to the client.
+func (s *OIDCService) ErrorRedirect(req *model.AuthorizeRequest, e *AuthorizeError) string {
+	return s.clientRedirect(req, url.Values{"error": {e.Code}, "error_description": {e.Description}})
+}
+
+// Authorize answers an authentication request for the signed-in user p. The
+// response either redirects back to the client, with a code or an error, or
+// asks the login page to show a consent screen and post again with a
+// decision. First-party clients never need consent; others need it once for
+// each new scope, or every time with prompt=consent.
+func (s *OIDCService) Authorize(ctx context.Context, p *model.Principal, req *model.AuthorizeRequest) (*model.AuthorizeResponse, error) {
+	c, scopes, err := s.CheckAuthorize(ctx, req)
+	var authErr *AuthorizeError
+	if errors.As(err, &authErr) {
+		return &model.AuthorizeResponse{RedirectTo: s.ErrorRedirect(req, authErr)}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// A client only signs in users of its own organization.
+	if c.TenantID != p.TenantID {
+		return &model.AuthorizeResponse{
+			RedirectTo: s.ErrorRedirect(req, &AuthorizeError{"access_denied", "the user is not a member of the client's organization"}),
+		}, nil
+	}
+	if req.Decision == "deny" {
+		return &model.AuthorizeResponse{
+			RedirectTo: s.ErrorRedirect(req, &AuthorizeError{"access_denied", "the user declined"}),
+		}, nil
+	}
+
+	now := time.Now().UTC()
+	if !c.FirstParty {
+		consented, err := s.oidc.Consent(ctx, p.UserID, c.ClientID)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case req.Decision == "allow":
+			merged := append(consented, scopes...)
+			slices.Sort(merged)
+			if err := s.oidc.SaveConsent(ctx, p.UserID, c.ClientID, slices.Compact(merged), now); err != nil {
+				return nil, err
+			}
+		case req.Prompt == "none" && !containsAll(consented, scopes):
+			return &model.AuthorizeResponse{
+				RedirectTo: s.ErrorRedirect(req, &AuthorizeError{"consent_required", "the user has not agreed to share these scopes"}),
+			}, nil
+		case req.Prompt == "consent" || !containsAll(consented, scopes):
+			return &model.AuthorizeResponse{ConsentRequired: true, ClientName: c.Name, Scopes: scopes}, nil
+		}
+	}
+
+	authTime, err := s.tokens.AuthTime(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := newOpaqueToken()
+	if err != nil {
+		return nil, fmt.Errorf("service.OIDC.Authorize: %w", err)
+	}
+	code := &model.AuthorizationCode{
+		CodeHash:      hashToken(raw),
+		ClientID:      c.ClientID,
+		UserID:        p.UserID,
+		RedirectURI:   req.RedirectURI,
+		Scopes:        scopes,
+		Nonce:         req.Nonce,
+		CodeChallenge: req.CodeChallenge,
+		AMR:           p.AMR,
+		AuthTime:      authTime,
+		ExpiresAt:     now.Add(s.cfg.CodeExpiry),
+	}
+	if err := s.oidc.CreateCode(ctx, code); err != nil {
+		return nil, err
+	}
+	return &model.AuthorizeResponse{RedirectTo: s.clientRedirect(req, url.Values{"code": {raw}})}, nil
+}
+
+// Exchange redeems an authorization code at the token endpoint for an
+// access token and ID token.
+func (s *OIDCService) Exchange(ctx context.Context, clientID, secret, rawCode, redirectURI, verifier string) (*model.OAuthTokenResponse, error) {
+	c, err := authenticateClient(ctx, s.clients, clientID, secret)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	code, err := s.oidc.ConsumeCode(ctx, hashToken(rawCode), now)
+	if err != nil {
+		if errors.Is(err, repository.ErrCodeNotFound) {
+			return nil, ErrInvalidGrant
+		}
+		return nil, err
+	}
+	if code.ClientID != c.ClientID || code.RedirectURI != redirectURI || now.After(code.ExpiresAt) ||
+		!verifyPKCE(code.CodeChallenge, verifier) {
+		return nil, ErrInvalidGrant
+	}
+
+	u, err := s.users.GetByID(ctx, code.UserID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrInvalidGrant
+		}
+		return nil, err
+	}
+	access, err := s.tokens.IssueDelegatedToken(ctx, u, c.ClientID, code.Scopes, code.AMR)
+	if err != nil {
+		return nil, err
+	}
+	idToken, err := s.tokens.IssueIDToken(u, code)
+	if err != nil {
+		return nil, err
+	}
+	return &model.OAuthTokenResponse{
+		AccessToken: access,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(s.tokens.cfg.JWTExpiry.Seconds()),
+		Scope:       strings.Join(code.Scopes, " "),
+		IDToken:     idToken,
+	}, nil
+}
+
+// UserInfo returns the claims about p's user that the token's scopes allow.
+func (s *OIDCService) UserInfo(ctx context.Context, p *model.Principal) (map[string]any, error) {
+	if !slices.Contains(p.Scopes, model.ScopeOpenID) {
+		return nil, ErrInsufficientScope
+	}
+	u, err := s.users.GetByID(ctx, p.UserID)
+	if err != nil {
+		return nil, err
+	}
+	claims := userClaims(u, p.Scopes)
+	claims["sub"] = u.ID.String()
+	return claims, nil
+}
+
+// PurgeExpired deletes authorization codes that can no longer be exchanged.
+func (s *OIDCService) PurgeExpired(ctx context.Context) error {
+	_, err := s.oidc.DeleteExpiredCodes(ctx, time.Now())
+	return err
+}
+
+// clientRedirect adds params, state and iss (RFC 9207) to the request's
+// redirect URI.
+func (s *OIDCService) clientRedirect(req *model.AuthorizeRequest, params url.Values) string {
+	u, _ := url.Parse(req.RedirectURI)
+	q := u.Query()
+	for k, v := range params {
+		q[k] = v
+	}
+	if req.State != "" {
+		q.Set("state", req.State)
+	}
+	q.Set("iss", strings.TrimRight(s.cfg.Issuer, "/"))
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// userClaims are the standard claims about u released by scopes, for ID
+// tokens and userinfo alike.
+func userClaims(u *model.User, scopes []string) map[string]any {
+	claims := map[string]any{}
+	if slices.Contains(scopes, model.ScopeProfile) {
+		claims["name"] = u.Name
+		claims["updated_at"] = u.UpdatedAt.Unix()
+	}
+	if slices.Contains(scopes, model.ScopeEmail) {
+		claims["email"] = u.Email
+		claims["email_verified"] = u.EmailVerifiedAt != nil
+	}
+	return claims
+}
+
+func isOIDCScope(scope string) bool {
+	return scope == model.ScopeOpenID || scope == model.ScopeProfile || scope == model.ScopeEmail
+}
+
+func containsAll(have, want []string) bool {
+	for _, w := range want {
+		if !slices.Contains(have, w) {
+			return false
+		}
+	}
+	return true
+}
+
+// verifyPKCE checks an S256 code_verifier against its challenge (RFC 7636 §4.6).
+func verifyPKCE(challenge, verifier string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	return subtle.ConstantTimeCompare([]byte(base64.RawURLEncoding.EncodeToString(sum[:])), []byte(challenge)) == 1
+}
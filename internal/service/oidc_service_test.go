@@ -0,0 +1,307 @@
+package service_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"user-management-api/internal/model"
+	"user-management-api/internal/service"
+)
+
+const (
+	testRedirect = "https://rp.test/callback"
+	testVerifier = "dBjftJeZ4CVP-mJ92K9cQmzzY1eBXK3ABj_xoIL0Bd2fmQ"
+)
+
+// newRelyingParty registers a client that signs users in with OpenID
+// Connect, and returns it with the signed-in admin who created it.
+func newRelyingParty(t *testing.T, env *testEnv, firstParty bool) (*model.OAuthClientCreated, *model.Principal) {
+	t.Helper()
+	admin := newTokenOwner(t, env)
+	c, err := env.clients.CreateClient(context.Background(), admin, &model.CreateOAuthClientRequest{
+		Name:         "wiki",
+		Scopes:       []string{model.PermUsersRead},
+		RedirectURIs: []string{testRedirect},
+		FirstParty:   firstParty,
+	})
+	if err != nil {
+		t.Fatalf("create client: %v", err)
+	}
+	return c, admin
+}
+
+func authorizeRequest(clientID, scope string) *model.AuthorizeRequest {
+	sum := sha256.Sum256([]byte(testVerifier))
+	return &model.AuthorizeRequest{
+		ResponseType:        "code",
+		ClientID:            clientID,
+		RedirectURI:         testRedirect,
+		Scope:               scope,
+		State:               "xyz",
+		Nonce:               "n-0S6",
+		CodeChallenge:       base64.RawURLEncoding.EncodeToString(sum[:]),
+		CodeChallengeMethod: "S256",
+	}
+}
+
+// redirectParams checks that resp sends the browser back to the client and
+// returns the query it carries.
+func redirectParams(t *testing.T, resp *model.AuthorizeResponse) url.Values {
+	t.Helper()
+	if resp.RedirectTo == "" {
+		t.Fatalf("expected a redirect, got %+v", resp)
+	}
+	u, err := url.Parse(resp.RedirectTo)
+	if err != nil {
+		t.Fatalf("parse redirect: %v", err)
+	}
+	if u.Scheme+"://"+u.Host+u.Path != testRedirect {
+		t.Fatalf("expected a redirect to %s, got %s", testRedirect, resp.RedirectTo)
+	}
+	q := u.Query()
+	if q.Get("state") != "xyz" || q.Get("iss") != "https://id.test" {
+		t.Errorf("expected state and iss in %s", resp.RedirectTo)
+	}
+	return q
+}
+
+func TestOIDC_CodeFlow(t *testing.T) {
+	env := newTestEnv(t)
+	c, user := newRelyingParty(t, env, true)
+	ctx := context.Background()
+
+	resp, err := env.oidc.Authorize(ctx, user, authorizeRequest(c.ClientID, "openid email "+model.PermUsersRead))
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	code := redirectParams(t, resp).Get("code")
+	if code == "" {
+		t.Fatalf("expected a code in %s", resp.RedirectTo)
+	}
+
+	tok, err := env.oidc.Exchange(ctx, c.ClientID, c.ClientSecret, code, testRedirect, testVerifier)
+	if err != nil {
+		t.Fatalf("exchange: %v", err)
+	}
+	if tok.IDToken == "" || tok.Scope != "email openid "+model.PermUsersRead {
+		t.Errorf("unexpected token response %+v", tok)
+	}
+
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(tok.IDToken, claims); err != nil {
+		t.Fatalf("parse id token: %v", err)
+	}
+	if claims["iss"] != "https://id.test" || claims["aud"] != c.ClientID || claims["sub"] != user.UserID.String() {
+		t.Errorf("unexpected id token claims %v", claims)
+	}
+	if claims["nonce"] != "n-0S6" || claims["email"] != "alice@example.com" || claims["name"] != nil {
+		t.Errorf("expected nonce and email but no profile claims, got %v", claims)
+	}
+	if _, err := env.tokens.Authenticate(ctx, tok.IDToken); err == nil {
+		t.Error("expected the ID token not to work as an access token")
+	}
+
+	p, err := env.tokens.Authenticate(ctx, tok.AccessToken)
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	if p.UserID != user.UserID || p.AuthorizedParty != c.ClientID {
+		t.Errorf("expected a delegated principal, got %+v", p)
+	}
+	if !p.Can(model.PermUsersRead) || p.Can(model.PermUsersWrite) {
+		t.Errorf("expected only users:read, got %v", p.Permissions)
+	}
+
+	info, err := env.oidc.UserInfo(ctx, p)
+	if err != nil {
+		t.Fatalf("userinfo: %v", err)
+	}
+	if info["sub"] != user.UserID.String() || info["email"] != "alice@example.com" || info["email_verified"] != false {
+		t.Errorf("unexpected userinfo %v", info)
+	}
+	if _, ok := info["name"]; ok {
+		t.Errorf("expected no profile claims without the profile scope, got %v", info)
+	}
+}
+
+func TestOIDC_CodeIsSingleUse(t *testing.T) {
+	env := newTestEnv(t)
+	c, user := newRelyingParty(t, env, true)
+	ctx := context.Background()
+
+	resp, err := env.oidc.Authorize(ctx, user, authorizeRequest(c.ClientID, "openid"))
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	code := redirectParams(t, resp).Get("code")
+	if _, err := env.oidc.Exchange(ctx, c.ClientID, c.ClientSecret, code, testRedirect, testVerifier); err != nil {
+		t.Fatalf("exchange: %v", err)
+	}
+	if _, err := env.oidc.Exchange(ctx, c.ClientID, c.ClientSecret, code, testRedirect, testVerifier); !errors.Is(err, service.ErrInvalidGrant) {
+		t.Errorf("expected ErrInvalidGrant on reuse, got %v", err)
+	}
+}
+
+func TestOIDC_ExchangeChecksVerifierAndRedirect(t *testing.T) {
+	env := newTestEnv(t)
+	c, user := newRelyingParty(t, env, true)
+	ctx := context.Background()
+
+	for name, tc := range map[string]struct{ redirect, verifier string }{
+		"wrong verifier": {testRedirect, testVerifier[1:] + "x"},
+		"wrong redirect": {"https://rp.test/other", testVerifier},
+	} {
+		resp, err := env.oidc.Authorize(ctx, user, authorizeRequest(c.ClientID, "openid"))
+		if err != nil {
+			t.Fatalf("%s: authorize: %v", name, err)
+		}
+		code := redirectParams(t, resp).Get("code")
+		if _, err := env.oidc.Exchange(ctx, c.ClientID, c.ClientSecret, code, tc.redirect, tc.verifier); !errors.Is(err, service.ErrInvalidGrant) {
+			t.Errorf("%s: expected ErrInvalidGrant, got %v", name, err)
+		}
+	}
+}
+
+func TestOIDC_RejectsUnregisteredRedirect(t *testing.T) {
+	env := newTestEnv(t)
+	c, _ := newRelyingParty(t, env, true)
+
+	req := authorizeRequest(c.ClientID, "openid")
+	req.RedirectURI = "https://evil.test/callback"
+	if _, _, err := env.oidc.CheckAuthorize(context.Background(), req); !errors.Is(err, service.ErrInvalidRedirectURI) {
+		t.Errorf("expected ErrInvalidRedirectURI, got %v", err)
+	}
+}
+
+func TestOIDC_RequiresPKCE(t *testing.T) {
+	env := newTestEnv(t)
+	c, _ := newRelyingParty(t, env, true)
+
+	req := authorizeRequest(c.ClientID, "openid")
+	req.CodeChallengeMethod = "plain"
+	var authErr *service.AuthorizeError
+	if _, _, err := env.oidc.CheckAuthorize(context.Background(), req); !errors.As(err, &authErr) || authErr.Code != "invalid_request" {
+		t.Errorf("expected invalid_request, got %v", err)
+	}
+}
+
+func TestOIDC_ThirdPartyConsent(t *testing.T) {
+	env := newTestEnv(t)
+	c, user := newRelyingParty(t, env, false)
+	ctx := context.Background()
+
+	req := authorizeRequest(c.ClientID, "openid profile")
+	resp, err := env.oidc.Authorize(ctx, user, req)
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	if !resp.ConsentRequired || resp.ClientName != "wiki" || resp.RedirectTo != "" {
+		t.Fatalf("expected a consent prompt, got %+v", resp)
+	}
+
+	req.Prompt = "none"
+	resp, err = env.oidc.Authorize(ctx, user, req)
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	if got := redirectParams(t, resp).Get("error"); got != "consent_required" {
+		t.Errorf("expected consent_required with prompt=none, got %q", got)
+	}
+
+	req.Prompt, req.Decision = "", "allow"
+	resp, err = env.oidc.Authorize(ctx, user, req)
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	if redirectParams(t, resp).Get("code") == "" {
+		t.Fatalf("expected a code once allowed, got %s", resp.RedirectTo)
+	}
+
+	// Consent is remembered, and covers a narrower request.
+	resp, err = env.oidc.Authorize(ctx, user, authorizeRequest(c.ClientID, "openid"))
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	if redirectParams(t, resp).Get("code") == "" {
+		t.Errorf("expected remembered consent, got %+v", resp)
+	}
+}
+
+func TestOIDC_DenyRedirectsWithError(t *testing.T) {
+	env := newTestEnv(t)
+	c, user := newRelyingParty(t, env, false)
+
+	req := authorizeRequest(c.ClientID, "openid")
+	req.Decision = "deny"
+	resp, err := env.oidc.Authorize(context.Background(), user, req)
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	if got := redirectParams(t, resp).Get("error"); got != "access_denied" {
+		t.Errorf("expected access_denied, got %q", got)
+	}
+}
+
+func TestOIDC_UserInfoNeedsOpenIDScope(t *testing.T) {
+	env := newTestEnv(t)
+	user := newTokenOwner(t, env)
+
+	if _, err := env.oidc.UserInfo(context.Background(), user); !errors.Is(err, service.ErrInsufficientScope) {
+		t.Errorf("expected ErrInsufficientScope for a session token, got %v", err)
+	}
+}
+
+func TestOIDC_AuthTimeSurvivesRefresh(t *testing.T) {
+	env := newTestEnv(t)
+	c, _ := newRelyingParty(t, env, true)
+	ctx := context.Background()
+
+	signIn, err := env.users.SignIn(ctx, &model.SignInRequest{Email: "alice@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+	// iat has second precision; let the refreshed token's differ.
+	time.Sleep(1100 * time.Millisecond)
+	refreshed, err := env.users.Refresh(ctx, &model.RefreshRequest{RefreshToken: signIn.RefreshToken})
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	p, err := env.tokens.Authenticate(ctx, refreshed.Token)
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+
+	resp, err := env.oidc.Authorize(ctx, p, authorizeRequest(c.ClientID, "openid"))
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	tok, err := env.oidc.Exchange(ctx, c.ClientID, c.ClientSecret, redirectParams(t, resp).Get("code"), testRedirect, testVerifier)
+	if err != nil {
+		t.Fatalf("exchange: %v", err)
+	}
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(tok.IDToken, claims); err != nil {
+		t.Fatalf("parse id token: %v", err)
+	}
+
+	sessions, err := env.tokens.ListSessions(ctx, p.UserID, p.SessionID)
+	if err != nil {
+		t.Fatalf("list sessions: %v", err)
+	}
+	var signedInAt int64
+	for _, s := range sessions {
+		if s.Current {
+			signedInAt = s.CreatedAt.Unix()
+		}
+	}
+	if authTime, _ := claims["auth_time"].(float64); int64(authTime) != signedInAt || int64(authTime) >= p.IssuedAt.Unix() {
+		t.Errorf("expected auth_time %d, the sign-in, not the refresh at %d; got %v", signedInAt, p.IssuedAt.Unix(), claims["auth_time"])
+	}
+}
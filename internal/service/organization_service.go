@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+
+	"user-management-api/internal/model"
+	"user-management-api/internal/repository"
+	"user-management-api/internal/reqctx"
+)
+
+var (
+	// ErrInvalidSlug is returned for slugs that aren't lowercase words joined by hyphens.
+	ErrInvalidSlug = errors.New("slug must be lowercase letters, digits and hyphens")
+	// ErrOwnOrganization is returned when adding a user as a member of the
+	// organization they already belong to.
+	ErrOwnOrganization = errors.New("user already belongs to this organization")
+)
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9](?:[a-z0-9-]*[a-z0-9])?$`)
+
+// OrganizationService manages the organizations users belong to and their
+// members from other organizations. Scoping requests to one of them is left
+// to middleware.Organization.
+type OrganizationService struct {
+	orgs    *repository.OrganizationRepository
+	members *repository.MembershipRepository
+	users   *repository.UserRepository
+}
+
+func NewOrganizationService(
+	orgs *repository.OrganizationRepository,
+	members *repository.MembershipRepository,
+	users *repository.UserRepository,
+) *OrganizationService {
+	return &OrganizationService{orgs: orgs, members: members, users: users}
+}
+
+func (s *OrganizationService) Create(ctx context.Context, req *model.CreateOrganizationRequest) (*model.Organization, error) {
+	if !slugPattern.MatchString(req.Slug) {
+		return nil, ErrInvalidSlug
+	}
+	o := &model.Organization{
+		ID:        uuid.New(),
+		Name:      req.Name,
+		Slug:      req.Slug,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.orgs.Create(ctx, o); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+func (s *OrganizationService) Get(ctx context.Context, id uuid.UUID) (*model.Organization, error) {
+	return s.orgs.GetByID(ctx, id)
+}
+
+func (s *OrganizationService) List(ctx context.Context, q *model.ListOrganizationsQuery) ([]*model.Organization, error) {
+	if q.Limit <= 0 || q.Limit > 100 {
+		q.Limit = 20
+	}
+	return s.orgs.List(ctx, q.Limit, q.Offset)
+}
+
+// Resolve finds an organization by slug or, failing that, by ID, as named
+// in the X-Organization header.
+func (s *OrganizationService) Resolve(ctx context.Context, ref string) (*model.Organization, error) {
+	o, err := s.orgs.GetBySlug(ctx, ref)
+	if !errors.Is(err, repository.ErrOrganizationNotFound) {
+		return o, err
+	}
+	id, perr := uuid.Parse(ref)
+	if perr != nil {
+		return nil, err
+	}
+	return s.orgs.GetByID(ctx, id)
+}
+
+// AddMember lets a user of another organization act in orgID with
+// req.Role. The user's own organization needs no membership.
+func (s *OrganizationService) AddMember(ctx context.Context, orgID uuid.UUID, req *model.AddMemberRequest) (*model.Membership, error) {
+	if _, err := s.orgs.GetByID(ctx, orgID); err != nil {
+		return nil, err
+	}
+	u, err := s.users.GetByID(reqctx.WithoutTenant(ctx), req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if u.TenantID == orgID {
+		return nil, ErrOwnOrganization
+	}
+	m := &model.Membership{
+		OrganizationID: orgID,
+		UserID:         u.ID,
+		Role:           req.Role,
+		CreatedAt:      time.Now().UTC(),
+	}
+	if err := s.members.Add(ctx, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (s *OrganizationService) ListMembers(ctx context.Context, orgID uuid.UUID, q *model.ListMembersQuery) ([]*model.Membership, error) {
+	if _, err := s.orgs.GetByID(ctx, orgID); err != nil {
+		return nil, err
+	}
+	if q.Limit <= 0 || q.Limit > 100 {
+		q.Limit = 20
+	}
+	return s.members.List(ctx, orgID, q.Limit, q.Offset)
+}
+
+// SetMemberRole changes the role a member acts with in orgID. It applies
+// from the member's next request.
+func (s *OrganizationService) SetMemberRole(ctx context.Context, orgID, userID uuid.UUID, req *model.SetMemberRoleRequest) (*model.Membership, error) {
+	if err := s.members.SetRole(ctx, orgID, userID, req.Role); err != nil {
+		return nil, err
+	}
+	return s.members.Get(ctx, orgID, userID)
+}
+
+func (s *OrganizationService) RemoveMember(ctx context.Context, orgID, userID uuid.UUID) error {
+	return s.members.Remove(ctx, orgID, userID)
+}
@@ -0,0 +1,299 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"user-management-api/internal/model"
+	"user-management-api/internal/repository"
+	"user-management-api/internal/reqctx"
+	"user-management-api/internal/service"
+)
+
+// newOrganization creates an organization and returns a context scoped to it.
+func newOrganization(t *testing.T, env *testEnv, slug string) (*model.Organization, context.Context) {
+	t.Helper()
+	o, err := env.orgs.Create(context.Background(), &model.CreateOrganizationRequest{Name: slug, Slug: slug})
+	if err != nil {
+		t.Fatalf("create organization: %v", err)
+	}
+	return o, reqctx.WithTenant(context.Background(), o.ID)
+}
+
+func TestOrganization_Create(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	acme, _ := newOrganization(t, env, "acme")
+
+	for _, slug := range []string{"Acme", "-acme", "acme corp"} {
+		if _, err := env.orgs.Create(ctx, &model.CreateOrganizationRequest{Name: "Acme", Slug: slug}); !errors.Is(err, service.ErrInvalidSlug) {
+			t.Errorf("slug %q: expected ErrInvalidSlug, got %v", slug, err)
+		}
+	}
+	if _, err := env.orgs.Create(ctx, &model.CreateOrganizationRequest{Name: "Acme 2", Slug: "acme"}); !errors.Is(err, repository.ErrSlugTaken) {
+		t.Errorf("duplicate slug: expected ErrSlugTaken, got %v", err)
+	}
+
+	for _, ref := range []string{"acme", acme.ID.String()} {
+		if o, err := env.orgs.Resolve(ctx, ref); err != nil || o.ID != acme.ID {
+			t.Errorf("resolve %q: got %+v, %v", ref, o, err)
+		}
+	}
+	if _, err := env.orgs.Resolve(ctx, "globex"); !errors.Is(err, repository.ErrOrganizationNotFound) {
+		t.Errorf("unknown slug: expected ErrOrganizationNotFound, got %v", err)
+	}
+
+	orgs, err := env.orgs.List(ctx, &model.ListOrganizationsQuery{})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(orgs) != 2 || orgs[0].ID != model.DefaultTenantID || orgs[1].ID != acme.ID {
+		t.Errorf("expected the default organization then acme, got %+v", orgs)
+	}
+}
+
+func TestOrganization_UsersAreIsolated(t *testing.T) {
+	env := newTestEnv(t)
+	bob := newCustomer(t, env)
+	acme, acmeCtx := newOrganization(t, env, "acme")
+	defaultCtx := reqctx.WithTenant(context.Background(), model.DefaultTenantID)
+
+	wile, err := env.users.CreateInOrganization(context.Background(), acme.ID, &model.RegisterRequest{Name: "Wile", Email: "wile@acme.test", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if wile.TenantID != acme.ID || bob.TenantID != model.DefaultTenantID {
+		t.Fatalf("expected Wile in acme and Bob in the default organization, got %s and %s", wile.TenantID, bob.TenantID)
+	}
+
+	if _, err := env.users.GetByID(defaultCtx, wile.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("cross-tenant get: expected ErrNotFound, got %v", err)
+	}
+	if _, err := env.users.UpdateUser(acmeCtx, bob.ID, &model.UpdateUserRequest{Name: "Mallory"}); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("cross-tenant update: expected ErrNotFound, got %v", err)
+	}
+	if err := env.users.DeleteUser(acmeCtx, bob.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("cross-tenant delete: expected ErrNotFound, got %v", err)
+	}
+	users, err := env.users.ListUsers(acmeCtx, &model.ListUsersQuery{})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(users) != 1 || users[0].ID != wile.ID {
+		t.Errorf("expected only Wile in acme, got %+v", users)
+	}
+
+	// Access tokens say which organization they belong to.
+	reg, err := env.users.SignIn(acmeCtx, &model.SignInRequest{Email: "wile@acme.test", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(reg.Token, claims); err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if claims["tid"] != acme.ID.String() {
+		t.Errorf("expected tid %s, got %v", acme.ID, claims["tid"])
+	}
+	// They are checked the same whatever organization the request names.
+	p, err := env.tokens.Authenticate(defaultCtx, reg.Token)
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	if p.TenantID != acme.ID {
+		t.Errorf("expected principal in acme, got %s", p.TenantID)
+	}
+}
+
+func TestOrganization_EmailUniqueness(t *testing.T) {
+	ctx := context.Background()
+	req := &model.RegisterRequest{Name: "Bob", Email: "bob@example.com", Password: "secret123"}
+
+	global := newTestEnv(t)
+	newCustomer(t, global)
+	acme, _ := newOrganization(t, global, "acme")
+	if _, err := global.users.CreateInOrganization(ctx, acme.ID, req); !errors.Is(err, repository.ErrEmailTaken) {
+		t.Errorf("global: expected ErrEmailTaken, got %v", err)
+	}
+
+	env := newTestEnv(t, func(c *testConfig) { c.emails = repository.EmailsPerTenant })
+	bob := newCustomer(t, env)
+	acme, acmeCtx := newOrganization(t, env, "acme")
+	acmeBob, err := env.users.CreateInOrganization(ctx, acme.ID, req)
+	if err != nil {
+		t.Fatalf("per tenant: create: %v", err)
+	}
+	if _, err := env.users.CreateInOrganization(ctx, acme.ID, req); !errors.Is(err, repository.ErrEmailTaken) {
+		t.Errorf("per tenant: expected ErrEmailTaken within acme, got %v", err)
+	}
+
+	// Each organization signs in its own Bob; naming none means the default.
+	resp, err := env.users.SignIn(acmeCtx, &model.SignInRequest{Email: "bob@example.com", Password: "secret123"})
+	if err != nil || resp.User.ID != acmeBob.ID || resp.User.TenantID != acme.ID {
+		t.Errorf("acme sign-in: got %+v, %v", resp, err)
+	}
+	resp, err = env.users.SignIn(ctx, &model.SignInRequest{Email: "bob@example.com", Password: "secret123"})
+	if err != nil || resp.User.ID != bob.ID {
+		t.Errorf("default sign-in: got %+v, %v", resp, err)
+	}
+}
+
+func TestOrganization_PlatformPermissionsStayInDefault(t *testing.T) {
+	env := newTestEnv(t)
+	admin := newTokenOwner(t, env)
+	if !admin.Can(model.PermOrgsWrite) {
+		t.Fatalf("expected the default organization's admin to manage organizations, got %v", admin.Permissions)
+	}
+
+	acme, acmeCtx := newOrganization(t, env, "acme")
+	wile, err := env.users.CreateInOrganization(context.Background(), acme.ID, &model.RegisterRequest{Name: "Wile", Email: "wile@acme.test", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := env.users.SetRoles(acmeCtx, wile.ID, &model.SetRolesRequest{Roles: []string{model.RoleAdmin}}); err != nil {
+		t.Fatalf("set roles: %v", err)
+	}
+	resp, err := env.users.SignIn(acmeCtx, &model.SignInRequest{Email: "wile@acme.test", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+	p, err := env.tokens.Authenticate(acmeCtx, resp.Token)
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	if p.Can(model.PermOrgsWrite) || !p.Can(model.PermUsersDelete) {
+		t.Errorf("expected acme's admin to run acme but not other organizations, got %v", p.Permissions)
+	}
+}
+
+func TestOrganization_SelfRegistrationStaysInDefault(t *testing.T) {
+	env := newTestEnv(t)
+	acme, acmeCtx := newOrganization(t, env, "acme")
+
+	// Naming acme doesn't get a stranger into it.
+	reg, err := env.users.Register(acmeCtx, &model.RegisterRequest{Name: "Mallory", Email: "mallory@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if reg.User.TenantID != model.DefaultTenantID {
+		t.Errorf("expected Mallory in the default organization, got %s", reg.User.TenantID)
+	}
+	p, err := env.tokens.Authenticate(acmeCtx, reg.Token)
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	if p.TenantID == acme.ID {
+		t.Error("expected Mallory's token not to be acme's")
+	}
+	users, err := env.users.ListUsers(acmeCtx, &model.ListUsersQuery{})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(users) != 0 {
+		t.Errorf("expected acme to have no users, got %+v", users)
+	}
+}
+
+func TestOrganization_Members(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	bob := newCustomer(t, env)
+	acme, _ := newOrganization(t, env, "acme")
+	globex, _ := newOrganization(t, env, "globex")
+	wile, err := env.users.CreateInOrganization(ctx, acme.ID, &model.RegisterRequest{Name: "Wile", Email: "wile@acme.test", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	if _, err := env.orgs.AddMember(ctx, acme.ID, &model.AddMemberRequest{UserID: bob.ID, Role: model.RoleUser}); err != nil {
+		t.Fatalf("add member: %v", err)
+	}
+	for name, tc := range map[string]struct {
+		req  model.AddMemberRequest
+		want error
+	}{
+		"twice":        {model.AddMemberRequest{UserID: bob.ID, Role: model.RoleAdmin}, repository.ErrAlreadyMember},
+		"own":          {model.AddMemberRequest{UserID: wile.ID, Role: model.RoleUser}, service.ErrOwnOrganization},
+		"unknown user": {model.AddMemberRequest{UserID: uuid.New(), Role: model.RoleUser}, repository.ErrNotFound},
+		"unknown role": {model.AddMemberRequest{UserID: bob.ID, Role: "owner"}, repository.ErrUnknownRole},
+	} {
+		if _, err := env.orgs.AddMember(ctx, acme.ID, &tc.req); !errors.Is(err, tc.want) {
+			t.Errorf("%s: expected %v, got %v", name, tc.want, err)
+		}
+	}
+	members, err := env.orgs.ListMembers(ctx, acme.ID, &model.ListMembersQuery{})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(members) != 1 || members[0].UserID != bob.ID || members[0].Role != model.RoleUser {
+		t.Errorf("expected Bob as a user of acme, got %+v", members)
+	}
+
+	// Bob's session acts in acme with his role there, and nowhere else.
+	resp, err := env.users.SignIn(ctx, &model.SignInRequest{Email: "bob@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+	p, err := env.tokens.Authenticate(ctx, resp.Token)
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	if _, err := env.tokens.InOrganization(ctx, p, globex.ID); !errors.Is(err, service.ErrNotMember) {
+		t.Errorf("globex: expected ErrNotMember, got %v", err)
+	}
+	pat := *p
+	pat.PersonalTokenID = uuid.New()
+	if _, err := env.tokens.InOrganization(ctx, &pat, acme.ID); !errors.Is(err, service.ErrNotMember) {
+		t.Errorf("personal access token: expected ErrNotMember, got %v", err)
+	}
+
+	if _, err := env.orgs.SetMemberRole(ctx, acme.ID, bob.ID, &model.SetMemberRoleRequest{Role: model.RoleAdmin}); err != nil {
+		t.Fatalf("set role: %v", err)
+	}
+	member, err := env.tokens.InOrganization(ctx, p, acme.ID)
+	if err != nil {
+		t.Fatalf("in acme: %v", err)
+	}
+	if !member.Can(model.PermUsersDelete) || member.Can(model.PermOrgsWrite) || p.Can(model.PermUsersDelete) {
+		t.Errorf("expected Bob to run acme only, got %v in acme and %v at home", member.Permissions, p.Permissions)
+	}
+
+	if err := env.orgs.RemoveMember(ctx, acme.ID, bob.ID); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if _, err := env.tokens.InOrganization(ctx, p, acme.ID); !errors.Is(err, service.ErrNotMember) {
+		t.Errorf("after removal: expected ErrNotMember, got %v", err)
+	}
+	if err := env.orgs.RemoveMember(ctx, acme.ID, bob.ID); !errors.Is(err, repository.ErrMembershipNotFound) {
+		t.Errorf("remove twice: expected ErrMembershipNotFound, got %v", err)
+	}
+}
+
+func TestOrganization_MembersRefreshWithTheirHeader(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	bob := newCustomer(t, env)
+	acme, acmeCtx := newOrganization(t, env, "acme")
+	if _, err := env.orgs.AddMember(ctx, acme.ID, &model.AddMemberRequest{UserID: bob.ID, Role: model.RoleUser}); err != nil {
+		t.Fatalf("add member: %v", err)
+	}
+	resp, err := env.users.SignIn(ctx, &model.SignInRequest{Email: "bob@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+
+	// Twice, so a token burned by the first would trip reuse detection.
+	for i := range 2 {
+		resp, err = env.users.Refresh(acmeCtx, &model.RefreshRequest{RefreshToken: resp.RefreshToken})
+		if err != nil {
+			t.Fatalf("refresh %d: %v", i, err)
+		}
+		if resp.User.ID != bob.ID || resp.User.TenantID != model.DefaultTenantID {
+			t.Errorf("refresh %d: expected Bob of the default organization, got %+v", i, resp.User)
+		}
+	}
+}
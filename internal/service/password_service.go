@@ -0,0 +1,207 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"user-management-api/internal/mail"
+	"user-management-api/internal/model"
+	"user-management-api/internal/password"
+	"user-management-api/internal/repository"
+	"user-management-api/internal/reqctx"
+)
+
+var (
+	// ErrInvalidResetToken is returned for unknown, expired or already-used reset tokens.
+	ErrInvalidResetToken = errors.New("invalid or expired reset token")
+	// ErrIncorrectPassword is returned when the current password supplied for a change is wrong.
+	ErrIncorrectPassword = errors.New("current password is incorrect")
+)
+
+// PasswordConfig holds the tunables for PasswordService.
+type PasswordConfig struct {
+	ResetTokenExpiry time.Duration
+	// ResetURL is the page that receives the token as ?token=..., typically
+	// part of the front end.
+	ResetURL string
+	// Policy is what new passwords must meet.
+	Policy *password.Policy
+	Hasher password.Hasher
+	// Dispatch runs the lookup and mail of ForgotPassword; nil means
+	// InBackground.
+	Dispatch Dispatch
+}
+
+// PasswordService implements the forgot/reset password flow.
+type PasswordService struct {
+	users   *repository.UserRepository
+	actions *repository.ActionTokenRepository
+	tokens  *TokenService
+	lockout *LockoutService
+	mailer  mail.Mailer
+	cfg     PasswordConfig
+}
+
+func NewPasswordService(
+	users *repository.UserRepository,
+	actions *repository.ActionTokenRepository,
+	tokens *TokenService,
+	lockout *LockoutService,
+	mailer mail.Mailer,
+	cfg PasswordConfig,
+) *PasswordService {
+	if cfg.Dispatch == nil {
+		cfg.Dispatch = InBackground
+	}
+	return &PasswordService{users: users, actions: actions, tokens: tokens, lockout: lockout, mailer: mailer, cfg: cfg}
+}
+
+// ForgotPassword mails a reset link if the email belongs to an account.
+// The lookup and the mail happen after it returns, so known and unknown
+// emails are answered alike and callers can't probe for accounts.
+func (s *PasswordService) ForgotPassword(ctx context.Context, req *model.ForgotPasswordRequest) {
+	email := req.Email
+	s.cfg.Dispatch(ctx, "forgot password", func(ctx context.Context) error {
+		return s.sendReset(ctx, email)
+	})
+}
+
+func (s *PasswordService) sendReset(ctx context.Context, email string) error {
+	u, err := s.users.GetByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	raw, err := issueActionToken(ctx, s.actions, u.ID, model.PurposePasswordReset, u.Email, s.cfg.ResetTokenExpiry)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(ctx, mail.Message{
+		To:      u.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nSomeone asked to reset the password for your account. "+
+				"If that was you, open the link below within %s:\n\n%s\n\n"+
+				"Or submit this token to POST /api/v1/auth/password/reset:\n\n%s\n\n"+
+				"If you didn't ask for this, you can ignore this email.\n",
+			u.Name, s.cfg.ResetTokenExpiry, withToken(s.cfg.ResetURL, raw), raw,
+		),
+	})
+}
+
+// ResetPassword sets a new password using a mailed token, lifts any sign-in
+// lockout and signs the user out everywhere. A password the policy rejects
+// leaves the token usable for another try.
+func (s *PasswordService) ResetPassword(ctx context.Context, req *model.ResetPasswordRequest) error {
+	t, err := findActionToken(ctx, s.actions, model.PurposePasswordReset, req.Token)
+	if err != nil {
+		if errors.Is(err, errActionTokenInvalid) {
+			return ErrInvalidResetToken
+		}
+		return err
+	}
+	u, err := s.users.GetByID(ctx, t.UserID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrInvalidResetToken
+		}
+		return err
+	}
+	if err := s.cfg.Policy.Check(req.Password, u.Email, u.Name); err != nil {
+		return err
+	}
+	hash, err := s.cfg.Hasher.Hash(req.Password)
+	if err != nil {
+		return err
+	}
+
+	if err := consumeActionToken(ctx, s.actions, t); err != nil {
+		if errors.Is(err, errActionTokenInvalid) {
+			return ErrInvalidResetToken
+		}
+		return err
+	}
+	if err := s.users.UpdatePassword(ctx, u.ID, hash, time.Now().UTC()); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrInvalidResetToken
+		}
+		return err
+	}
+	if err := s.users.ResetSignInFailures(ctx, u.ID); err != nil {
+		return err
+	}
+	return s.tokens.RevokeAll(ctx, u.ID)
+}
+
+// ChangePassword replaces the password of an authenticated user after
+// re-checking the current one. Wrong guesses count towards the sign-in
+// lockout, so a stolen access token can't be used to find the password. With RevokeOtherSessions every existing token
+// is revoked and a fresh pair, authenticated like the caller's session, is
+// returned; otherwise the returned response is nil.
+func (s *PasswordService) ChangePassword(ctx context.Context, p *model.Principal, req *model.ChangePasswordRequest) (*model.AuthResponse, error) {
+	u, err := s.users.GetByID(ctx, p.UserID)
+	if err != nil {
+		return nil, err
+	}
+	// Users without a password (signed up through an identity provider)
+	// set one with a reset link instead.
+	if u.PasswordHash == "" {
+		return nil, ErrIncorrectPassword
+	}
+	if err := s.lockout.CheckAccount(u); err != nil {
+		return nil, err
+	}
+	if ok, _, err := s.cfg.Hasher.Verify(u.PasswordHash, req.CurrentPassword); err != nil {
+		return nil, err
+	} else if !ok {
+		if err := s.lockout.RecordFailure(ctx, u, reqctx.ClientFrom(ctx).IP); err != nil {
+			return nil, err
+		}
+		return nil, ErrIncorrectPassword
+	}
+	if err := s.lockout.RecordSuccess(ctx, u); err != nil {
+		return nil, err
+	}
+	if err := s.cfg.Policy.Check(req.NewPassword, u.Email, u.Name); err != nil {
+		return nil, err
+	}
+
+	hash, err := s.cfg.Hasher.Hash(req.NewPassword)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	if err := s.users.UpdatePassword(ctx, u.ID, hash, now); err != nil {
+		return nil, err
+	}
+	u.PasswordHash, u.UpdatedAt = hash, now
+
+	if !req.RevokeOtherSessions {
+		return nil, nil
+	}
+	if err := s.tokens.RevokeAll(ctx, u.ID); err != nil {
+		return nil, err
+	}
+	return s.tokens.IssuePair(ctx, u, p.AMR)
+}
+
+// --- helpers ---
+
+// withToken appends token as the "token" query parameter of base.
+func withToken(base, token string) string {
+	u, err := url.Parse(base)
+	if err != nil {
+		return base + "?token=" + url.QueryEscape(token)
+	}
+	q := u.Query()
+	q.Set("token", token)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
@@ -0,0 +1,249 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"user-management-api/internal/model"
+	"user-management-api/internal/password"
+	"user-management-api/internal/service"
+)
+
+func TestForgotPassword_UnknownEmailIsSilent(t *testing.T) {
+	env := newTestEnv(t)
+
+	env.passwords.ForgotPassword(context.Background(), &model.ForgotPasswordRequest{Email: "nobody@example.com"})
+	if n := env.mailer.count(); n != 0 {
+		t.Errorf("expected no email, got %d", n)
+	}
+}
+
+func TestForgotPassword_KnownAndUnknownEmailsDoTheSameWork(t *testing.T) {
+	jobs := &jobQueue{}
+	env := newTestEnv(t, func(c *testConfig) { c.password.Dispatch = jobs.dispatch })
+	ctx := context.Background()
+	newCustomer(t, env)
+	sent := env.mailer.count()
+
+	for _, email := range []string{"bob@example.com", "nobody@example.com"} {
+		env.passwords.ForgotPassword(ctx, &model.ForgotPasswordRequest{Email: email})
+	}
+	// Neither call looks anything up or mails before returning; each leaves
+	// one job behind.
+	if len(jobs.jobs) != 2 {
+		t.Fatalf("expected a job per request, got %v", jobs.names)
+	}
+	if n := env.mailer.count(); n != sent {
+		t.Errorf("expected no email before the jobs run, got %d", n-sent)
+	}
+	var issued int
+	if err := env.db.QueryRow(`SELECT COUNT(*) FROM action_tokens WHERE purpose = ?`, model.PurposePasswordReset).Scan(&issued); err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	if issued != 0 {
+		t.Errorf("expected no reset token before the jobs run, got %d", issued)
+	}
+
+	jobs.run(t)
+	if n := env.mailer.count(); n != sent+1 || env.mailer.last(t).To != "bob@example.com" {
+		t.Errorf("expected one email, to bob@example.com, got %d", n-sent)
+	}
+}
+
+func TestResetPassword_Success(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	reg, err := env.users.Register(ctx, &model.RegisterRequest{Name: "Alice", Email: "alice@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	env.passwords.ForgotPassword(ctx, &model.ForgotPasswordRequest{Email: "alice@example.com"})
+	if to := env.mailer.last(t).To; to != "alice@example.com" {
+		t.Errorf("expected mail to alice@example.com, got %s", to)
+	}
+
+	err = env.passwords.ResetPassword(ctx, &model.ResetPasswordRequest{Token: env.mailer.lastToken(t), Password: "newsecret456"})
+	if err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+
+	if _, err := env.users.SignIn(ctx, &model.SignInRequest{Email: "alice@example.com", Password: "secret123"}); !errors.Is(err, service.ErrInvalidCredentials) {
+		t.Errorf("old password: expected ErrInvalidCredentials, got %v", err)
+	}
+	if _, err := env.users.SignIn(ctx, &model.SignInRequest{Email: "alice@example.com", Password: "newsecret456"}); err != nil {
+		t.Errorf("new password: %v", err)
+	}
+	if _, err := env.users.Refresh(ctx, &model.RefreshRequest{RefreshToken: reg.RefreshToken}); !errors.Is(err, service.ErrInvalidRefreshToken) {
+		t.Errorf("expected existing sessions to be revoked, got %v", err)
+	}
+}
+
+func TestResetPassword_TokenIsSingleUse(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	if _, err := env.users.Register(ctx, &model.RegisterRequest{Name: "Alice", Email: "alice@example.com", Password: "secret123"}); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	env.passwords.ForgotPassword(ctx, &model.ForgotPasswordRequest{Email: "alice@example.com"})
+	token := env.mailer.lastToken(t)
+
+	if err := env.passwords.ResetPassword(ctx, &model.ResetPasswordRequest{Token: token, Password: "newsecret456"}); err != nil {
+		t.Fatalf("first reset: %v", err)
+	}
+	err := env.passwords.ResetPassword(ctx, &model.ResetPasswordRequest{Token: token, Password: "another789"})
+	if !errors.Is(err, service.ErrInvalidResetToken) {
+		t.Errorf("expected ErrInvalidResetToken, got %v", err)
+	}
+}
+
+func TestResetPassword_NewRequestInvalidatesOldToken(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	if _, err := env.users.Register(ctx, &model.RegisterRequest{Name: "Alice", Email: "alice@example.com", Password: "secret123"}); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	req := &model.ForgotPasswordRequest{Email: "alice@example.com"}
+	env.passwords.ForgotPassword(ctx, req)
+	first := env.mailer.lastToken(t)
+	env.passwords.ForgotPassword(ctx, req)
+
+	err := env.passwords.ResetPassword(ctx, &model.ResetPasswordRequest{Token: first, Password: "newsecret456"})
+	if !errors.Is(err, service.ErrInvalidResetToken) {
+		t.Errorf("expected ErrInvalidResetToken, got %v", err)
+	}
+}
+
+func TestResetPassword_ExpiredToken(t *testing.T) {
+	env := newTestEnv(t, func(c *testConfig) { c.password.ResetTokenExpiry = -time.Minute })
+	ctx := context.Background()
+
+	if _, err := env.users.Register(ctx, &model.RegisterRequest{Name: "Alice", Email: "alice@example.com", Password: "secret123"}); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	env.passwords.ForgotPassword(ctx, &model.ForgotPasswordRequest{Email: "alice@example.com"})
+
+	err := env.passwords.ResetPassword(ctx, &model.ResetPasswordRequest{Token: env.mailer.lastToken(t), Password: "newsecret456"})
+	if !errors.Is(err, service.ErrInvalidResetToken) {
+		t.Errorf("expected ErrInvalidResetToken, got %v", err)
+	}
+}
+
+func TestChangePassword_Success(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	reg, err := env.users.Register(ctx, &model.RegisterRequest{Name: "Alice", Email: "alice@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	resp, err := env.passwords.ChangePassword(ctx, &model.Principal{UserID: reg.User.ID}, &model.ChangePasswordRequest{
+		CurrentPassword: "secret123",
+		NewPassword:     "newsecret456",
+	})
+	if err != nil {
+		t.Fatalf("change: %v", err)
+	}
+	if resp != nil {
+		t.Error("expected no new tokens without revoke_other_sessions")
+	}
+
+	if _, err := env.users.SignIn(ctx, &model.SignInRequest{Email: "alice@example.com", Password: "newsecret456"}); err != nil {
+		t.Errorf("new password: %v", err)
+	}
+	// Other sessions are untouched.
+	if _, err := env.users.Refresh(ctx, &model.RefreshRequest{RefreshToken: reg.RefreshToken}); err != nil {
+		t.Errorf("expected existing session to survive, got %v", err)
+	}
+}
+
+func TestChangePassword_WrongCurrentPassword(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	reg, err := env.users.Register(ctx, &model.RegisterRequest{Name: "Alice", Email: "alice@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	_, err = env.passwords.ChangePassword(ctx, &model.Principal{UserID: reg.User.ID}, &model.ChangePasswordRequest{
+		CurrentPassword: "wrongpassword",
+		NewPassword:     "newsecret456",
+	})
+	if !errors.Is(err, service.ErrIncorrectPassword) {
+		t.Errorf("expected ErrIncorrectPassword, got %v", err)
+	}
+}
+
+func TestChangePassword_RevokeOtherSessions(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	reg, err := env.users.Register(ctx, &model.RegisterRequest{Name: "Alice", Email: "alice@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	resp, err := env.passwords.ChangePassword(ctx, &model.Principal{UserID: reg.User.ID}, &model.ChangePasswordRequest{
+		CurrentPassword:     "secret123",
+		NewPassword:         "newsecret456",
+		RevokeOtherSessions: true,
+	})
+	if err != nil {
+		t.Fatalf("change: %v", err)
+	}
+	if resp == nil || resp.Token == "" || resp.RefreshToken == "" {
+		t.Fatal("expected a fresh token pair")
+	}
+
+	if _, err := env.users.Refresh(ctx, &model.RefreshRequest{RefreshToken: reg.RefreshToken}); !errors.Is(err, service.ErrInvalidRefreshToken) {
+		t.Errorf("expected old session to be revoked, got %v", err)
+	}
+	if _, err := env.tokens.Authenticate(ctx, resp.Token); err != nil {
+		t.Errorf("expected the fresh token to be valid, got %v", err)
+	}
+}
+
+func TestChangePassword_EnforcesPolicy(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	reg, err := env.users.Register(ctx, &model.RegisterRequest{Name: "Alice", Email: "alice@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	_, err = env.passwords.ChangePassword(ctx, &model.Principal{UserID: reg.User.ID}, &model.ChangePasswordRequest{
+		CurrentPassword: "secret123",
+		NewPassword:     "password1",
+	})
+	var weak *password.PolicyError
+	if !errors.As(err, &weak) || weak.Violations[0].Code != password.CodeBreached {
+		t.Errorf("expected a breached-password violation, got %v", err)
+	}
+}
+
+func TestResetPassword_PolicyFailureKeepsToken(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	if _, err := env.users.Register(ctx, &model.RegisterRequest{Name: "Alice", Email: "alice@example.com", Password: "secret123"}); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	env.passwords.ForgotPassword(ctx, &model.ForgotPasswordRequest{Email: "alice@example.com"})
+	token := env.mailer.lastToken(t)
+
+	err := env.passwords.ResetPassword(ctx, &model.ResetPasswordRequest{Token: token, Password: "alice@example.com"})
+	var weak *password.PolicyError
+	if !errors.As(err, &weak) {
+		t.Fatalf("expected a policy error, got %v", err)
+	}
+	if err := env.passwords.ResetPassword(ctx, &model.ResetPasswordRequest{Token: token, Password: "newsecret456"}); err != nil {
+		t.Errorf("expected the token to survive a rejected password, got %v", err)
+	}
+}
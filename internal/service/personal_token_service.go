@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"user-management-api/internal/model"
+	"user-management-api/internal/repository"
+)
+
+// ErrInvalidScope is returned when a token or client asks for a permission
+// its creator doesn't hold.
+var ErrInvalidScope = errors.New("scope not held by caller")
+
+// PersonalTokenService mints, lists and revokes personal access tokens.
+// TokenService.Authenticate is what accepts them.
+type PersonalTokenService struct {
+	tokens *repository.PersonalTokenRepository
+}
+
+func NewPersonalTokenService(tokens *repository.PersonalTokenRepository) *PersonalTokenService {
+	return &PersonalTokenService{tokens: tokens}
+}
+
+// Create mints a token for the caller. Scopes may only narrow the caller's
+// current permissions, so a session without MFA can't mint its way into
+// roles withheld from it. The raw token is in the response and nowhere else.
+func (s *PersonalTokenService) Create(ctx context.Context, p *model.Principal, req *model.CreatePersonalTokenRequest) (*model.PersonalTokenCreated, error) {
+	requested := req.Scopes
+	if len(requested) == 0 {
+		requested = p.Permissions
+	}
+	scopes, err := grantableScopes(p, requested)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := newOpaqueToken()
+	if err != nil {
+		return nil, fmt.Errorf("service.PersonalToken.Create: %w", err)
+	}
+	raw = model.PersonalTokenPrefix + raw
+
+	t := &model.PersonalAccessToken{
+		ID:        uuid.New(),
+		UserID:    p.UserID,
+		Name:      req.Name,
+		TokenHash: hashToken(raw),
+		Scopes:    scopes,
+		ExpiresAt: req.ExpiresAt,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.tokens.Create(ctx, t); err != nil {
+		return nil, err
+	}
+	return &model.PersonalTokenCreated{PersonalAccessToken: t, Token: raw}, nil
+}
+
+func (s *PersonalTokenService) List(ctx context.Context, userID uuid.UUID) ([]*model.PersonalAccessToken, error) {
+	return s.tokens.ListForUser(ctx, userID)
+}
+
+func (s *PersonalTokenService) Revoke(ctx context.Context, userID, id uuid.UUID) error {
+	return s.tokens.Delete(ctx, userID, id)
+}
@@ -0,0 +1,160 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"user-management-api/internal/model"
+	"user-management-api/internal/reqctx"
+	"user-management-api/internal/service"
+)
+
+// newTokenOwner registers Alice as an admin and returns her session principal.
+func newTokenOwner(t *testing.T, env *testEnv) *model.Principal {
+	t.Helper()
+	ctx := context.Background()
+	reg, err := env.users.Register(ctx, &model.RegisterRequest{Name: "Alice", Email: "alice@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if _, err := env.users.SetRoles(ctx, reg.User.ID, &model.SetRolesRequest{Roles: []string{model.RoleAdmin, model.RoleUser}}); err != nil {
+		t.Fatalf("set roles: %v", err)
+	}
+	resp, err := env.users.SignIn(ctx, &model.SignInRequest{Email: "alice@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+	p, err := env.tokens.Authenticate(ctx, resp.Token)
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	return p
+}
+
+func TestPersonalToken_AuthenticatesWithScopes(t *testing.T) {
+	env := newTestEnv(t)
+	owner := newTokenOwner(t, env)
+	ctx := context.Background()
+
+	created, err := env.pats.Create(ctx, owner, &model.CreatePersonalTokenRequest{Name: "ci", Scopes: []string{model.PermUsersRead}})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if !strings.HasPrefix(created.Token, model.PersonalTokenPrefix) {
+		t.Errorf("expected a %q token, got %q", model.PersonalTokenPrefix, created.Token)
+	}
+
+	p, err := env.tokens.Authenticate(ctx, created.Token)
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	if p.UserID != owner.UserID || p.PersonalTokenID != created.ID {
+		t.Errorf("unexpected principal %+v", p)
+	}
+	if !p.Can(model.PermUsersRead) || p.Can(model.PermUsersWrite) {
+		t.Errorf("expected only users:read, got %v", p.Permissions)
+	}
+}
+
+func TestPersonalToken_ScopesFollowRoleChanges(t *testing.T) {
+	env := newTestEnv(t)
+	owner := newTokenOwner(t, env)
+	ctx := context.Background()
+
+	created, err := env.pats.Create(ctx, owner, &model.CreatePersonalTokenRequest{Name: "ci"})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := env.users.SetRoles(ctx, owner.UserID, &model.SetRolesRequest{Roles: []string{model.RoleUser}}); err != nil {
+		t.Fatalf("set roles: %v", err)
+	}
+
+	p, err := env.tokens.Authenticate(ctx, created.Token)
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	if p.Can(model.PermUsersWrite) {
+		t.Errorf("expected the token to lose admin permissions with the role, got %v", p.Permissions)
+	}
+}
+
+func TestPersonalToken_ScopeBeyondCaller(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	reg, err := env.users.Register(ctx, &model.RegisterRequest{Name: "Bob", Email: "bob@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	p, err := env.tokens.Authenticate(ctx, reg.Token)
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+
+	_, err = env.pats.Create(ctx, p, &model.CreatePersonalTokenRequest{Name: "ci", Scopes: []string{model.PermRolesWrite}})
+	if !errors.Is(err, service.ErrInvalidScope) {
+		t.Errorf("expected ErrInvalidScope, got %v", err)
+	}
+}
+
+func TestPersonalToken_Expired(t *testing.T) {
+	env := newTestEnv(t)
+	owner := newTokenOwner(t, env)
+	ctx := context.Background()
+
+	expires := time.Now().Add(time.Hour)
+	created, err := env.pats.Create(ctx, owner, &model.CreatePersonalTokenRequest{Name: "ci", ExpiresAt: &expires})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := env.db.Exec(`UPDATE personal_access_tokens SET expires_at = ? WHERE id = ?`,
+		time.Now().Add(-time.Second).UTC().Format(time.RFC3339), created.ID.String()); err != nil {
+		t.Fatalf("expire token: %v", err)
+	}
+
+	if _, err := env.tokens.Authenticate(ctx, created.Token); !errors.Is(err, service.ErrInvalidToken) {
+		t.Errorf("expected ErrInvalidToken, got %v", err)
+	}
+}
+
+func TestPersonalToken_RecordsLastUse(t *testing.T) {
+	env := newTestEnv(t)
+	owner := newTokenOwner(t, env)
+	ctx := context.Background()
+
+	created, err := env.pats.Create(ctx, owner, &model.CreatePersonalTokenRequest{Name: "ci"})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := env.tokens.Authenticate(reqctx.WithClient(ctx, reqctx.Client{IP: "203.0.113.7"}), created.Token); err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+
+	tokens, err := env.pats.List(ctx, owner.UserID)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(tokens) != 1 || tokens[0].LastUsedAt == nil || tokens[0].LastUsedIP != "203.0.113.7" {
+		t.Errorf("expected the use to be recorded, got %+v", tokens)
+	}
+}
+
+func TestPersonalToken_Revoke(t *testing.T) {
+	env := newTestEnv(t)
+	owner := newTokenOwner(t, env)
+	ctx := context.Background()
+
+	created, err := env.pats.Create(ctx, owner, &model.CreatePersonalTokenRequest{Name: "ci"})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if err := env.pats.Revoke(ctx, owner.UserID, created.ID); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+
+	if _, err := env.tokens.Authenticate(ctx, created.Token); !errors.Is(err, service.ErrInvalidToken) {
+		t.Errorf("expected ErrInvalidToken, got %v", err)
+	}
+}
@@ -0,0 +1,777 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"user-management-api/internal/model"
+	"user-management-api/internal/repository"
+	"user-management-api/internal/reqctx"
+	"user-management-api/internal/signing"
+)
+
+var (
+	// ErrInvalidRefreshToken is returned for unknown, expired, revoked or replayed refresh tokens.
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	// ErrInvalidToken is returned for access tokens that fail signature or claim validation.
+	ErrInvalidToken = errors.New("invalid or expired token")
+	// ErrTokenRevoked is returned for well-formed access tokens that were signed out.
+	ErrTokenRevoked = errors.New("token has been revoked")
+	// ErrNotMember is returned when a caller may not act in the organization
+	// a request names.
+	ErrNotMember = errors.New("not a member of this organization")
+)
+
+// TokenConfig holds the signing keys and lifetimes used by TokenService.
+type TokenConfig struct {
+	Keys          *signing.KeySet
+	JWTExpiry     time.Duration
+	RefreshExpiry time.Duration
+	// MFARequiredRoles are only put into access tokens for sessions that
+	// signed in with a second factor; otherwise the user acts without them.
+	MFARequiredRoles []string
+	// Issuer is the iss of ID tokens: this API's public base URL.
+	Issuer string
+}
+
+// TokenService issues short-lived JWT access tokens, rotates the opaque
+// refresh tokens that back them, and decides whether a presented access
+// token is still acceptable.
+type TokenService struct {
+	userRepo    *repository.UserRepository
+	refreshRepo *repository.RefreshTokenRepository
+	revokedRepo *repository.RevokedTokenRepository
+	roleRepo    *repository.RoleRepository
+	patRepo     *repository.PersonalTokenRepository
+	clientRepo  *repository.OAuthClientRepository
+	sessionRepo *repository.SessionRepository
+	memberRepo  *repository.MembershipRepository
+	cfg         TokenConfig
+}
+
+func NewTokenService(
+	userRepo *repository.UserRepository,
+	refreshRepo *repository.RefreshTokenRepository,
+	revokedRepo *repository.RevokedTokenRepository,
+	roleRepo *repository.RoleRepository,
+	patRepo *repository.PersonalTokenRepository,
+	clientRepo *repository.OAuthClientRepository,
+	sessionRepo *repository.SessionRepository,
+	memberRepo *repository.MembershipRepository,
+	cfg TokenConfig,
+) *TokenService {
+	return &TokenService{
+		userRepo:    userRepo,
+		refreshRepo: refreshRepo,
+		revokedRepo: revokedRepo,
+		roleRepo:    roleRepo,
+		patRepo:     patRepo,
+		clientRepo:  clientRepo,
+		sessionRepo: sessionRepo,
+		memberRepo:  memberRepo,
+		cfg:         cfg,
+	}
+}
+
+// Authenticate verifies an access token and checks it against the jti
+// deny-list, the owner's tokens_valid_after cut-off, the session named by
+// its sid claim and the actor named by its act claim. Personal access
+// tokens are recognised by their prefix and checked by authenticatePersonal;
+// tokens with a client_id claim belong to OAuth clients.
+//
+// The token itself says which organization it belongs to, so it is checked
+// without regard to any organization ctx is scoped to. Platform permissions
+// only hold inside the default organization.
+func (s *TokenService) Authenticate(ctx context.Context, raw string) (*model.Principal, error) {
+	p, err := s.authenticate(reqctx.WithoutTenant(ctx), raw)
+	if err != nil {
+		return nil, err
+	}
+	if p.TenantID != model.DefaultTenantID {
+		p.Permissions = slices.DeleteFunc(p.Permissions, func(perm string) bool { return perm == model.PermOrgsWrite })
+	}
+	return p, nil
+}
+
+func (s *TokenService) authenticate(ctx context.Context, raw string) (*model.Principal, error) {
+	if strings.HasPrefix(raw, model.PersonalTokenPrefix) {
+		return s.authenticatePersonal(ctx, raw)
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, s.cfg.Keys.Keyfunc,
+		jwt.WithValidMethods(s.cfg.Keys.Algorithms()), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	jti, _ := claims["jti"].(string)
+	if jti == "" {
+		return nil, ErrInvalidToken
+	}
+	iat, err := claims.GetIssuedAt()
+	if err != nil || iat == nil {
+		return nil, ErrInvalidToken
+	}
+	exp, _ := claims.GetExpirationTime()
+
+	revoked, err := s.revokedRepo.IsRevoked(ctx, jti)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrTokenRevoked
+	}
+
+	if clientID, _ := claims["client_id"].(string); clientID != "" {
+		return s.authenticateClient(ctx, clientID, claims, jti, iat.Time, exp.Time)
+	}
+
+	sub, _ := claims["sub"].(string)
+	userID, err := uuid.Parse(sub)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	u, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+	// iat has second precision, so tokens minted in the same second as a
+	// sign-out-everywhere survive it; the caller's own token is deny-listed
+	// explicitly by RevokeAll's callers.
+	if u.TokensValidAfter != nil && iat.Time.Before(*u.TokensValidAfter) {
+		return nil, ErrTokenRevoked
+	}
+	if err := checkTenant(claims, u.TenantID); err != nil {
+		return nil, err
+	}
+
+	var actorID uuid.UUID
+	if act, ok := claims["act"].(map[string]any); ok {
+		sub, _ := act["sub"].(string)
+		if actorID, err = uuid.Parse(sub); err != nil {
+			return nil, ErrInvalidToken
+		}
+		if err := s.checkActor(ctx, actorID, iat.Time); err != nil {
+			return nil, err
+		}
+	}
+
+	var sessionID uuid.UUID
+	if sid, _ := claims["sid"].(string); sid != "" {
+		if sessionID, err = uuid.Parse(sid); err != nil {
+			return nil, ErrInvalidToken
+		}
+		if err := s.checkSession(ctx, userID, sessionID); err != nil {
+			return nil, err
+		}
+	}
+
+	roles := stringsClaim(claims, "roles")
+	perms, err := s.roleRepo.Permissions(ctx, roles)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &model.Principal{
+		UserID:      userID,
+		TenantID:    u.TenantID,
+		SessionID:   sessionID,
+		ActorID:     actorID,
+		TokenID:     jti,
+		IssuedAt:    iat.Time,
+		ExpiresAt:   exp.Time,
+		AMR:         stringsClaim(claims, "amr"),
+		Roles:       roles,
+		Permissions: perms,
+	}
+	if azp, _ := claims["azp"].(string); azp != "" {
+		return s.authorizedParty(ctx, p, azp, claims)
+	}
+	return p, nil
+}
+
+// InOrganization is p acting in orgID, an organization other than its own,
+// through a membership: with the membership's role in place of the roles p
+// holds at home. Only users' sessions act through memberships; personal
+// access tokens, OAuth tokens and impersonation get ErrNotMember.
+func (s *TokenService) InOrganization(ctx context.Context, p *model.Principal, orgID uuid.UUID) (*model.Principal, error) {
+	if p.UserID == uuid.Nil || p.PersonalTokenID != uuid.Nil || p.AuthorizedParty != "" || p.Impersonated() {
+		return nil, ErrNotMember
+	}
+	m, err := s.memberRepo.Get(ctx, orgID, p.UserID)
+	if err != nil {
+		if errors.Is(err, repository.ErrMembershipNotFound) {
+			return nil, ErrNotMember
+		}
+		return nil, err
+	}
+
+	roles := []string{m.Role}
+	if !model.MultiFactor(p.AMR) {
+		roles = withoutRoles(roles, s.cfg.MFARequiredRoles)
+	}
+	perms, err := s.roleRepo.Permissions(ctx, roles)
+	if err != nil {
+		return nil, err
+	}
+	member := *p
+	member.Roles = roles
+	member.Permissions = slices.DeleteFunc(perms, func(perm string) bool { return perm == model.PermOrgsWrite })
+	return &member, nil
+}
+
+// AuthTime is when the user behind p last actually authenticated: when
+// their session began, which refreshing its tokens doesn't change. Tokens
+// without a session fall back to when they were issued.
+func (s *TokenService) AuthTime(ctx context.Context, p *model.Principal) (time.Time, error) {
+	if p.SessionID == uuid.Nil {
+		return p.IssuedAt, nil
+	}
+	sess, err := s.sessionRepo.Get(ctx, p.SessionID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return sess.CreatedAt, nil
+}
+
+// checkSession rejects tokens of a session that was revoked or has since
+// been purged, and notes that the session is in use. Access tokens minted
+// before sessions were recorded have no sid and skip this.
+func (s *TokenService) checkSession(ctx context.Context, userID, id uuid.UUID) error {
+	sess, err := s.sessionRepo.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrSessionNotFound) {
+			return ErrTokenRevoked
+		}
+		return err
+	}
+	if sess.UserID != userID || sess.RevokedAt != nil {
+		return ErrTokenRevoked
+	}
+	// As with personal access tokens, a minute's resolution is plenty.
+	now := time.Now().UTC()
+	if now.Sub(sess.LastSeenAt) >= time.Minute {
+		return s.sessionRepo.Touch(ctx, id, now, reqctx.ClientFrom(ctx).IP)
+	}
+	return nil
+}
+
+// checkActor ends an impersonation token once its actor is deleted, signs
+// out everywhere or loses the right to impersonate.
+func (s *TokenService) checkActor(ctx context.Context, actorID uuid.UUID, iat time.Time) error {
+	actor, err := s.userRepo.GetByID(ctx, actorID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrTokenRevoked
+		}
+		return err
+	}
+	if actor.TokensValidAfter != nil && iat.Before(*actor.TokensValidAfter) {
+		return ErrTokenRevoked
+	}
+	roles, err := s.roleRepo.ForUser(ctx, actorID)
+	if err != nil {
+		return err
+	}
+	perms, err := s.roleRepo.Permissions(ctx, roles)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(perms, model.PermUsersImpersonate) {
+		return ErrTokenRevoked
+	}
+	return nil
+}
+
+// authorizedParty narrows a user's principal to the scopes they let the
+// OAuth client azp have, and to what that client may still be given.
+func (s *TokenService) authorizedParty(ctx context.Context, p *model.Principal, azp string, claims jwt.MapClaims) (*model.Principal, error) {
+	c, err := s.clientRepo.GetByClientID(ctx, azp)
+	if err != nil {
+		if errors.Is(err, repository.ErrClientNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+	scope, _ := claims["scope"].(string)
+	p.AuthorizedParty = azp
+	p.Scopes = strings.Fields(scope)
+	p.Permissions = slices.DeleteFunc(p.Permissions, func(perm string) bool {
+		return !slices.Contains(p.Scopes, perm) || !slices.Contains(c.Scopes, perm)
+	})
+	return p, nil
+}
+
+// authenticatePersonal resolves a personal access token. Its permissions are
+// its scopes narrowed to what the owner's roles grant today. Sign-out
+// everywhere doesn't touch these tokens; they live until revoked or expired.
+func (s *TokenService) authenticatePersonal(ctx context.Context, raw string) (*model.Principal, error) {
+	t, err := s.patRepo.GetByHash(ctx, hashToken(raw))
+	if err != nil {
+		if errors.Is(err, repository.ErrPersonalTokenNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+	now := time.Now().UTC()
+	if t.ExpiresAt != nil && now.After(*t.ExpiresAt) {
+		return nil, ErrInvalidToken
+	}
+	u, err := s.userRepo.GetByID(ctx, t.UserID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+
+	roles, err := s.roleRepo.ForUser(ctx, t.UserID)
+	if err != nil {
+		return nil, err
+	}
+	perms, err := s.roleRepo.Permissions(ctx, roles)
+	if err != nil {
+		return nil, err
+	}
+	perms = slices.DeleteFunc(perms, func(perm string) bool { return !slices.Contains(t.Scopes, perm) })
+
+	// Recording every request would turn reads into writes; a minute's
+	// resolution is plenty for "last used".
+	if t.LastUsedAt == nil || now.Sub(*t.LastUsedAt) >= time.Minute {
+		if err := s.patRepo.MarkUsed(ctx, t.ID, now, reqctx.ClientFrom(ctx).IP); err != nil {
+			return nil, err
+		}
+	}
+
+	p := &model.Principal{
+		UserID:          t.UserID,
+		TenantID:        u.TenantID,
+		PersonalTokenID: t.ID,
+		IssuedAt:        t.CreatedAt,
+		Roles:           roles,
+		Permissions:     perms,
+	}
+	if t.ExpiresAt != nil {
+		p.ExpiresAt = *t.ExpiresAt
+	}
+	return p, nil
+}
+
+// authenticateClient resolves an OAuth client's access token. Its
+// permissions are the granted scopes still allowed by the client today, and
+// a deleted client or a rotated secret ends its tokens.
+func (s *TokenService) authenticateClient(ctx context.Context, clientID string, claims jwt.MapClaims, jti string, iat, exp time.Time) (*model.Principal, error) {
+	c, err := s.clientRepo.GetByClientID(ctx, clientID)
+	if err != nil {
+		if errors.Is(err, repository.ErrClientNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+	if c.TokensValidAfter != nil && iat.Before(*c.TokensValidAfter) {
+		return nil, ErrTokenRevoked
+	}
+	if err := checkTenant(claims, c.TenantID); err != nil {
+		return nil, err
+	}
+
+	scope, _ := claims["scope"].(string)
+	scopes := slices.DeleteFunc(strings.Fields(scope), func(perm string) bool { return !slices.Contains(c.Scopes, perm) })
+	return &model.Principal{
+		ClientID:    clientID,
+		TenantID:    c.TenantID,
+		TokenID:     jti,
+		IssuedAt:    iat,
+		ExpiresAt:   exp,
+		Scopes:      scopes,
+		Permissions: scopes,
+	}, nil
+}
+
+// RevokeAccessToken deny-lists a single access token until it would have
+// expired. For a personal access token it revokes the token outright.
+func (s *TokenService) RevokeAccessToken(ctx context.Context, p *model.Principal) error {
+	if p.PersonalTokenID != uuid.Nil {
+		return s.patRepo.Delete(ctx, p.UserID, p.PersonalTokenID)
+	}
+	return s.revokedRepo.Revoke(ctx, p.TokenID, p.UserID, p.ExpiresAt)
+}
+
+// RevokeRefreshToken revokes the family of the given refresh token, provided it
+// belongs to userID. Unknown tokens are ignored so sign-out stays idempotent.
+func (s *TokenService) RevokeRefreshToken(ctx context.Context, userID uuid.UUID, raw string) error {
+	t, err := s.refreshRepo.GetByHash(ctx, hashToken(raw))
+	if err != nil {
+		if errors.Is(err, repository.ErrTokenNotFound) {
+			return nil
+		}
+		return err
+	}
+	if t.UserID != userID {
+		return nil
+	}
+	return s.endSession(ctx, userID, t.FamilyID, time.Now().UTC())
+}
+
+// ListSessions returns the user's live sessions. current, the caller's own
+// session if any, is marked as such.
+func (s *TokenService) ListSessions(ctx context.Context, userID, current uuid.UUID) ([]*model.Session, error) {
+	sessions, err := s.sessionRepo.ListForUser(ctx, userID, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	for _, sess := range sessions {
+		sess.Current = sess.ID == current
+	}
+	return sessions, nil
+}
+
+// RevokeSession signs one of the user's devices out: its access tokens stop
+// working at once and its refresh tokens are revoked.
+func (s *TokenService) RevokeSession(ctx context.Context, userID, id uuid.UUID) error {
+	now := time.Now().UTC()
+	if err := s.sessionRepo.Revoke(ctx, userID, id, now); err != nil {
+		return err
+	}
+	return s.refreshRepo.RevokeFamily(ctx, id, now)
+}
+
+// endSession revokes a refresh token family and the session it belongs to.
+// Families from before sessions were recorded have none.
+func (s *TokenService) endSession(ctx context.Context, userID, familyID uuid.UUID, now time.Time) error {
+	if err := s.refreshRepo.RevokeFamily(ctx, familyID, now); err != nil {
+		return err
+	}
+	if err := s.sessionRepo.Revoke(ctx, userID, familyID, now); err != nil && !errors.Is(err, repository.ErrSessionNotFound) {
+		return err
+	}
+	return nil
+}
+
+// RevokeAll invalidates every access and refresh token issued to the user
+// so far, and ends all of their sessions.
+func (s *TokenService) RevokeAll(ctx context.Context, userID uuid.UUID) error {
+	now := time.Now().UTC()
+	if err := s.userRepo.SetTokensValidAfter(ctx, userID, now.Truncate(time.Second)); err != nil {
+		return err
+	}
+	if err := s.refreshRepo.RevokeForUser(ctx, userID, now); err != nil {
+		return err
+	}
+	return s.sessionRepo.RevokeForUser(ctx, userID, now)
+}
+
+// InvalidateAccessTokens rejects the user's current access tokens but leaves
+// refresh tokens alone, so clients silently pick up changed claims (such as
+// roles) on their next refresh.
+func (s *TokenService) InvalidateAccessTokens(ctx context.Context, userID uuid.UUID) error {
+	return s.userRepo.SetTokensValidAfter(ctx, userID, time.Now().UTC().Truncate(time.Second))
+}
+
+// PurgeExpired deletes deny-list entries and refresh tokens that have
+// expired, and sessions that have expired or been revoked.
+func (s *TokenService) PurgeExpired(ctx context.Context) error {
+	now := time.Now().UTC()
+	if _, err := s.revokedRepo.DeleteExpired(ctx, now); err != nil {
+		return err
+	}
+	if _, err := s.refreshRepo.DeleteExpired(ctx, now); err != nil {
+		return err
+	}
+	_, err := s.sessionRepo.DeleteExpired(ctx, now)
+	return err
+}
+
+// IssuePair starts a new session for u on the calling device and mints its
+// first access and refresh tokens. amr lists the authentication methods the
+// user just completed.
+func (s *TokenService) IssuePair(ctx context.Context, u *model.User, amr []string) (*model.AuthResponse, error) {
+	client := reqctx.ClientFrom(ctx)
+	now := time.Now().UTC()
+	sess := &model.Session{
+		ID:         uuid.New(),
+		UserID:     u.ID,
+		UserAgent:  client.UserAgent,
+		IP:         client.IP,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(s.cfg.RefreshExpiry),
+	}
+	if err := s.sessionRepo.Create(ctx, sess); err != nil {
+		return nil, err
+	}
+	return s.issuePair(ctx, u, sess.ID, amr)
+}
+
+// Rotate consumes a refresh token and returns it, so the caller can continue
+// its family. Presenting a token that has already been rotated is treated as
+// theft: the whole family is revoked.
+func (s *TokenService) Rotate(ctx context.Context, raw string) (*model.RefreshToken, error) {
+	t, err := s.refreshRepo.GetByHash(ctx, hashToken(raw))
+	if err != nil {
+		if errors.Is(err, repository.ErrTokenNotFound) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	if t.RevokedAt != nil || now.After(t.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+	if t.RotatedAt != nil {
+		return nil, s.revokeReused(ctx, t, now)
+	}
+
+	if err := s.refreshRepo.MarkRotated(ctx, t.ID, now); err != nil {
+		if errors.Is(err, repository.ErrTokenNotFound) {
+			// Lost a race with a concurrent refresh of the same token.
+			return nil, s.revokeReused(ctx, t, now)
+		}
+		return nil, err
+	}
+	return t, nil
+}
+
+// ContinueFamily issues a new token pair for u in the family of prev, keeping
+// the authentication methods of the original sign-in. The session lives on
+// for as long as the new refresh token.
+func (s *TokenService) ContinueFamily(ctx context.Context, u *model.User, prev *model.RefreshToken) (*model.AuthResponse, error) {
+	if err := s.continueSession(ctx, prev); err != nil {
+		return nil, err
+	}
+	return s.issuePair(ctx, u, prev.FamilyID, prev.AMR)
+}
+
+// continueSession extends the session of prev's family and notes it in use.
+// A family started before sessions were recorded gets one now.
+func (s *TokenService) continueSession(ctx context.Context, prev *model.RefreshToken) error {
+	client := reqctx.ClientFrom(ctx)
+	now := time.Now().UTC()
+	err := s.sessionRepo.Extend(ctx, prev.FamilyID, now.Add(s.cfg.RefreshExpiry))
+	if errors.Is(err, repository.ErrSessionNotFound) {
+		return s.sessionRepo.Create(ctx, &model.Session{
+			ID:         prev.FamilyID,
+			UserID:     prev.UserID,
+			UserAgent:  client.UserAgent,
+			IP:         client.IP,
+			CreatedAt:  prev.CreatedAt,
+			LastSeenAt: now,
+			ExpiresAt:  now.Add(s.cfg.RefreshExpiry),
+		})
+	}
+	if err != nil {
+		return err
+	}
+	return s.sessionRepo.Touch(ctx, prev.FamilyID, now, client.IP)
+}
+
+func (s *TokenService) revokeReused(ctx context.Context, t *model.RefreshToken, now time.Time) error {
+	if err := s.endSession(ctx, t.UserID, t.FamilyID, now); err != nil {
+		return err
+	}
+	return ErrInvalidRefreshToken
+}
+
+func (s *TokenService) issuePair(ctx context.Context, u *model.User, familyID uuid.UUID, amr []string) (*model.AuthResponse, error) {
+	claims, err := s.accessClaims(ctx, u, amr)
+	if err != nil {
+		return nil, err
+	}
+	claims["sid"] = familyID.String()
+	access, err := s.cfg.Keys.Sign(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := newOpaqueToken()
+	if err != nil {
+		return nil, fmt.Errorf("service.issuePair: %w", err)
+	}
+	now := time.Now().UTC()
+	rt := &model.RefreshToken{
+		ID:        uuid.New(),
+		UserID:    u.ID,
+		FamilyID:  familyID,
+		TokenHash: hashToken(raw),
+		ExpiresAt: now.Add(s.cfg.RefreshExpiry),
+		CreatedAt: now,
+		AMR:       amr,
+	}
+	if err := s.refreshRepo.Create(ctx, rt); err != nil {
+		return nil, err
+	}
+
+	return &model.AuthResponse{
+		Token:        access,
+		RefreshToken: raw,
+		ExpiresIn:    int64(s.cfg.JWTExpiry.Seconds()),
+		User:         u,
+	}, nil
+}
+
+// IssueClientToken mints an access token for an OAuth client. Client tokens
+// have no refresh token; the client simply asks for a new one.
+func (s *TokenService) IssueClientToken(c *model.OAuthClient, scopes []string) (*model.OAuthTokenResponse, error) {
+	scope := strings.Join(scopes, " ")
+	now := time.Now()
+	token, err := s.cfg.Keys.Sign(jwt.MapClaims{
+		"sub":       c.ClientID,
+		"client_id": c.ClientID,
+		"tid":       c.TenantID.String(),
+		"scope":     scope,
+		"jti":       uuid.NewString(),
+		"iat":       now.Unix(),
+		"exp":       now.Add(s.cfg.JWTExpiry).Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &model.OAuthTokenResponse{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(s.cfg.JWTExpiry.Seconds()),
+		Scope:       scope,
+	}, nil
+}
+
+// IssueDelegatedToken mints an access token for u that an OAuth client
+// holds on their behalf. Its azp claim names the client and its scope claim
+// caps it to what the user agreed to share. There is no refresh token.
+func (s *TokenService) IssueDelegatedToken(ctx context.Context, u *model.User, clientID string, scopes, amr []string) (string, error) {
+	claims, err := s.accessClaims(ctx, u, amr)
+	if err != nil {
+		return "", err
+	}
+	claims["azp"] = clientID
+	claims["scope"] = strings.Join(scopes, " ")
+	return s.cfg.Keys.Sign(claims)
+}
+
+// IssueImpersonationToken mints an access token for u held by actor. Its
+// act claim (RFC 8693) names the actor, it keeps the actor's amr, and it
+// lasts expiry. There is no refresh token or session.
+func (s *TokenService) IssueImpersonationToken(ctx context.Context, u *model.User, actor *model.Principal, expiry time.Duration) (string, error) {
+	claims, err := s.accessClaims(ctx, u, actor.AMR)
+	if err != nil {
+		return "", err
+	}
+	claims["act"] = map[string]any{"sub": actor.UserID.String()}
+	claims["exp"] = time.Now().Add(expiry).Unix()
+	return s.cfg.Keys.Sign(claims)
+}
+
+// IssueIDToken mints the OpenID Connect ID token for the sign-in recorded in
+// code. Profile and email claims are included as its scopes allow.
+func (s *TokenService) IssueIDToken(u *model.User, code *model.AuthorizationCode) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":       s.cfg.Issuer,
+		"sub":       u.ID.String(),
+		"aud":       code.ClientID,
+		"azp":       code.ClientID,
+		"iat":       now.Unix(),
+		"exp":       now.Add(s.cfg.JWTExpiry).Unix(),
+		"auth_time": code.AuthTime.Unix(),
+		"amr":       code.AMR,
+	}
+	if code.Nonce != "" {
+		claims["nonce"] = code.Nonce
+	}
+	for k, v := range userClaims(u, code.Scopes) {
+		claims[k] = v
+	}
+	return s.cfg.Keys.Sign(claims)
+}
+
+func (s *TokenService) accessClaims(ctx context.Context, u *model.User, amr []string) (jwt.MapClaims, error) {
+	roles, err := s.roleRepo.ForUser(ctx, u.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !model.MultiFactor(amr) {
+		roles = withoutRoles(roles, s.cfg.MFARequiredRoles)
+	}
+
+	now := time.Now()
+	return jwt.MapClaims{
+		"sub":   u.ID.String(),
+		"email": u.Email,
+		"tid":   u.TenantID.String(),
+		"roles": roles,
+		"amr":   amr,
+		"jti":   uuid.NewString(),
+		"iat":   now.Unix(),
+		"exp":   now.Add(s.cfg.JWTExpiry).Unix(),
+	}, nil
+}
+
+// --- helpers ---
+
+// checkTenant rejects a token whose tid claim names an organization other
+// than its subject's. Tokens minted before organizations existed have no tid.
+func checkTenant(claims jwt.MapClaims, tenantID uuid.UUID) error {
+	tid, ok := claims["tid"].(string)
+	if !ok {
+		return nil
+	}
+	if id, err := uuid.Parse(tid); err != nil || id != tenantID {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+// stringsClaim reads a JSON array of strings from claims, ignoring other types.
+func stringsClaim(claims jwt.MapClaims, name string) []string {
+	raw, _ := claims[name].([]any)
+	out := make([]string, 0, len(raw))
+	for _, v := range raw {
+		if s, ok := v.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// withoutRoles returns roles minus any listed in drop.
+func withoutRoles(roles, drop []string) []string {
+	out := make([]string, 0, len(roles))
+	for _, r := range roles {
+		if !slices.Contains(drop, r) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// newOpaqueToken returns 256 bits of randomness, base64url-encoded.
+func newOpaqueToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken is used for all opaque tokens we persist; they carry enough
+// entropy that a fast hash is sufficient.
+func hashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
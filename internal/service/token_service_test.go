@@ -0,0 +1,217 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"user-management-api/internal/model"
+	"user-management-api/internal/repository"
+	"user-management-api/internal/reqctx"
+	"user-management-api/internal/service"
+)
+
+func TestSessions_RecordDevice(t *testing.T) {
+	env := newTestEnv(t)
+	laptop := reqctx.WithClient(context.Background(), reqctx.Client{IP: "203.0.113.7", UserAgent: "Firefox on Linux"})
+	phone := reqctx.WithClient(context.Background(), reqctx.Client{IP: "198.51.100.1", UserAgent: "Safari on iPhone"})
+
+	reg, err := env.users.Register(laptop, &model.RegisterRequest{Name: "Alice", Email: "alice@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if _, err := env.users.SignIn(phone, &model.SignInRequest{Email: "alice@example.com", Password: "secret123"}); err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+
+	p, err := env.tokens.Authenticate(laptop, reg.Token)
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	sessions, err := env.tokens.ListSessions(laptop, reg.User.ID, p.SessionID)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("expected 2 sessions, got %d", len(sessions))
+	}
+	var current *model.Session
+	for _, s := range sessions {
+		if s.Current {
+			current = s
+		}
+	}
+	if current == nil || current.ID != p.SessionID || current.UserAgent != "Firefox on Linux" || current.IP != "203.0.113.7" {
+		t.Errorf("expected the laptop as the current session, got %+v", current)
+	}
+}
+
+func TestSessions_RefreshKeepsSession(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	reg, err := env.users.Register(ctx, &model.RegisterRequest{Name: "Alice", Email: "alice@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	refreshed, err := env.users.Refresh(ctx, &model.RefreshRequest{RefreshToken: reg.RefreshToken})
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+
+	before, err := env.tokens.Authenticate(ctx, reg.Token)
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	after, err := env.tokens.Authenticate(ctx, refreshed.Token)
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	if after.SessionID != before.SessionID {
+		t.Errorf("expected one session across a refresh, got %s and %s", before.SessionID, after.SessionID)
+	}
+	sessions, err := env.tokens.ListSessions(ctx, reg.User.ID, after.SessionID)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(sessions) != 1 {
+		t.Errorf("expected 1 session, got %d", len(sessions))
+	}
+}
+
+func TestSessions_RevokeSignsDeviceOut(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	reg, err := env.users.Register(ctx, &model.RegisterRequest{Name: "Alice", Email: "alice@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	stolen, err := env.users.SignIn(ctx, &model.SignInRequest{Email: "alice@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+	p, err := env.tokens.Authenticate(ctx, stolen.Token)
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+
+	if err := env.tokens.RevokeSession(ctx, reg.User.ID, p.SessionID); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	if _, err := env.tokens.Authenticate(ctx, stolen.Token); !errors.Is(err, service.ErrTokenRevoked) {
+		t.Errorf("access token: expected ErrTokenRevoked, got %v", err)
+	}
+	if _, err := env.users.Refresh(ctx, &model.RefreshRequest{RefreshToken: stolen.RefreshToken}); !errors.Is(err, service.ErrInvalidRefreshToken) {
+		t.Errorf("refresh token: expected ErrInvalidRefreshToken, got %v", err)
+	}
+	if _, err := env.tokens.Authenticate(ctx, reg.Token); err != nil {
+		t.Errorf("other session: expected it to survive, got %v", err)
+	}
+	if err := env.tokens.RevokeSession(ctx, reg.User.ID, p.SessionID); !errors.Is(err, repository.ErrSessionNotFound) {
+		t.Errorf("revoking twice: expected ErrSessionNotFound, got %v", err)
+	}
+
+	// Purged sessions stay revoked.
+	if err := env.tokens.PurgeExpired(ctx); err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+	if _, err := env.tokens.Authenticate(ctx, stolen.Token); !errors.Is(err, service.ErrTokenRevoked) {
+		t.Errorf("after purge: expected ErrTokenRevoked, got %v", err)
+	}
+}
+
+func TestSessions_RevokeOnlyOwn(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	alice, err := env.users.Register(ctx, &model.RegisterRequest{Name: "Alice", Email: "alice@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	bob, err := env.users.Register(ctx, &model.RegisterRequest{Name: "Bob", Email: "bob@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	p, err := env.tokens.Authenticate(ctx, bob.Token)
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+
+	if err := env.tokens.RevokeSession(ctx, alice.User.ID, p.SessionID); !errors.Is(err, repository.ErrSessionNotFound) {
+		t.Errorf("expected ErrSessionNotFound, got %v", err)
+	}
+	if _, err := env.tokens.Authenticate(ctx, bob.Token); err != nil {
+		t.Errorf("expected Bob's session to survive, got %v", err)
+	}
+}
+
+func TestSessions_SignOutEndsSession(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	reg, err := env.users.Register(ctx, &model.RegisterRequest{Name: "Alice", Email: "alice@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	other, err := env.users.SignIn(ctx, &model.SignInRequest{Email: "alice@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+	p, err := env.tokens.Authenticate(ctx, other.Token)
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	if err := env.users.SignOut(ctx, p, &model.SignOutRequest{RefreshToken: other.RefreshToken}); err != nil {
+		t.Fatalf("sign out: %v", err)
+	}
+
+	sessions, err := env.tokens.ListSessions(ctx, reg.User.ID, uuid.Nil)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].ID == p.SessionID {
+		t.Errorf("expected only the first session left, got %+v", sessions)
+	}
+
+	if err := env.tokens.RevokeAll(ctx, reg.User.ID); err != nil {
+		t.Fatalf("revoke all: %v", err)
+	}
+	if sessions, _ = env.tokens.ListSessions(ctx, reg.User.ID, uuid.Nil); len(sessions) != 0 {
+		t.Errorf("expected no sessions after signing out everywhere, got %d", len(sessions))
+	}
+}
+
+func TestSessions_TrackLastSeen(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	moved := reqctx.WithClient(ctx, reqctx.Client{IP: "198.51.100.1"})
+
+	reg, err := env.users.Register(reqctx.WithClient(ctx, reqctx.Client{IP: "203.0.113.7"}), &model.RegisterRequest{Name: "Alice", Email: "alice@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	p, err := env.tokens.Authenticate(moved, reg.Token)
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	sessions, _ := env.tokens.ListSessions(ctx, reg.User.ID, p.SessionID)
+	if len(sessions) != 1 || sessions[0].IP != "203.0.113.7" {
+		t.Fatalf("expected use within a minute of sign-in not to be recorded, got %+v", sessions)
+	}
+
+	stale := time.Now().Add(-2 * time.Minute).UTC().Format(time.RFC3339)
+	if _, err := env.db.Exec(`UPDATE sessions SET last_seen_at = ? WHERE id = ?`, stale, p.SessionID.String()); err != nil {
+		t.Fatalf("age session: %v", err)
+	}
+	if _, err := env.tokens.Authenticate(moved, reg.Token); err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	sessions, _ = env.tokens.ListSessions(ctx, reg.User.ID, p.SessionID)
+	if len(sessions) != 1 || sessions[0].IP != "198.51.100.1" || time.Since(sessions[0].LastSeenAt) > time.Minute {
+		t.Errorf("expected the use to be recorded, got %+v", sessions)
+	}
+}
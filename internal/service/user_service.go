@@ -3,34 +3,110 @@ package service
 import (
 	"context"
 	"errors"
-	"fmt"
+	"log"
 	"time"
 
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
 
 	"user-management-api/internal/model"
+	"user-management-api/internal/password"
 	"user-management-api/internal/repository"
+	"user-management-api/internal/reqctx"
 )
 
-// ErrInvalidCredentials is returned when email/password don't match.
-var ErrInvalidCredentials = errors.New("invalid credentials")
+var (
+	// ErrInvalidCredentials is returned when email/password don't match.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	// ErrPurgeTooEarly is returned when purging a user still inside the grace period.
+	ErrPurgeTooEarly = errors.New("purge grace period has not elapsed")
+	// ErrEmailNotVerified is returned by SignIn when verification is required and missing.
+	ErrEmailNotVerified = errors.New("email address not verified")
+)
+
+// UserConfig holds the tunables for UserService.
+type UserConfig struct {
+	// PurgeGrace is how long a soft-deleted user stays restorable.
+	PurgeGrace time.Duration
+	// RequireVerifiedEmail blocks sign-in until the user confirms their email.
+	RequireVerifiedEmail bool
+	// Policy is what passwords chosen at registration must meet.
+	Policy *password.Policy
+	Hasher password.Hasher
+	// Authenticator checks the password at sign-in, typically a
+	// LocalAuthenticator, possibly chained behind an LDAPAuthenticator.
+	Authenticator Authenticator
+}
 
 type UserService struct {
-	repo      *repository.UserRepository
-	jwtSecret string
-	jwtExpiry time.Duration
+	repo       *repository.UserRepository
+	roles      *repository.RoleRepository
+	tokens     *TokenService
+	verifier   *EmailVerificationService
+	mfa        *MFAService
+	passkeys   *WebAuthnService
+	lockout    *LockoutService
+	federation *FederationService
+	magicLinks *MagicLinkService
+	cfg        UserConfig
 }
 
-func NewUserService(repo *repository.UserRepository, jwtSecret string, jwtExpiry time.Duration) *UserService {
-	return &UserService{repo: repo, jwtSecret: jwtSecret, jwtExpiry: jwtExpiry}
+func NewUserService(
+	repo *repository.UserRepository,
+	roles *repository.RoleRepository,
+	tokens *TokenService,
+	verifier *EmailVerificationService,
+	mfa *MFAService,
+	passkeys *WebAuthnService,
+	lockout *LockoutService,
+	federation *FederationService,
+	magicLinks *MagicLinkService,
+	cfg UserConfig,
+) *UserService {
+	return &UserService{
+		repo:       repo,
+		roles:      roles,
+		tokens:     tokens,
+		verifier:   verifier,
+		mfa:        mfa,
+		passkeys:   passkeys,
+		lockout:    lockout,
+		federation: federation,
+		magicLinks: magicLinks,
+		cfg:        cfg,
+	}
 }
 
+// Register is self sign-up. The account always lands in the default
+// organization, whichever one the request names: only an organization's
+// administrators bring people into it, with CreateInOrganization or a
+// membership.
 func (s *UserService) Register(ctx context.Context, req *model.RegisterRequest) (*model.AuthResponse, error) {
-	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	ctx = reqctx.WithTenant(ctx, model.DefaultTenantID)
+	u, err := s.create(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if s.cfg.RequireVerifiedEmail {
+		return &model.AuthResponse{User: u}, nil
+	}
+	return s.tokens.IssuePair(ctx, u, []string{model.AMRPassword})
+}
+
+// CreateInOrganization creates an account in organization orgID for an
+// administrator. It mails the verification link as Register does, but
+// signs no one in.
+func (s *UserService) CreateInOrganization(ctx context.Context, orgID uuid.UUID, req *model.RegisterRequest) (*model.User, error) {
+	return s.create(reqctx.WithTenant(ctx, orgID), req)
+}
+
+// create adds a user with the user role to the organization ctx is scoped to.
+func (s *UserService) create(ctx context.Context, req *model.RegisterRequest) (*model.User, error) {
+	if err := s.cfg.Policy.Check(req.Password, req.Email, req.Name); err != nil {
+		return nil, err
+	}
+	hash, err := s.cfg.Hasher.Hash(req.Password)
 	if err != nil {
-		return nil, fmt.Errorf("service.Register: %w", err)
+		return nil, err
 	}
 
 	now := time.Now().UTC()
@@ -38,7 +114,7 @@ func (s *UserService) Register(ctx context.Context, req *model.RegisterRequest)
 		ID:           uuid.New(),
 		Name:         req.Name,
 		Email:        req.Email,
-		PasswordHash: string(hash),
+		PasswordHash: hash,
 		CreatedAt:    now,
 		UpdatedAt:    now,
 	}
@@ -46,32 +122,157 @@ func (s *UserService) Register(ctx context.Context, req *model.RegisterRequest)
 	if err := s.repo.Create(ctx, u); err != nil {
 		return nil, err // propagate ErrEmailTaken as-is
 	}
-
-	token, err := s.issueToken(u)
-	if err != nil {
+	if err := s.roles.Assign(ctx, u.ID, model.RoleUser); err != nil {
 		return nil, err
 	}
-	return &model.AuthResponse{Token: token, User: u}, nil
+
+	// The account exists either way; a mail outage must not fail registration.
+	// The user can ask for another link via /auth/email/resend.
+	if err := s.verifier.Send(ctx, u, u.Email); err != nil {
+		log.Printf("send verification email to user %s: %v", u.ID, err)
+	}
+	return u, nil
 }
 
+// SignIn checks the password with the configured Authenticator and starts
+// a session, or, for users with MFA enabled, returns a challenge to be
+// completed at /auth/mfa/verify. Failures count towards the lockout of both
+// the account and the client IP.
 func (s *UserService) SignIn(ctx context.Context, req *model.SignInRequest) (*model.AuthResponse, error) {
+	ip := reqctx.ClientFrom(ctx).IP
+	if err := s.lockout.CheckIP(ctx, ip); err != nil {
+		return nil, err
+	}
+
 	u, err := s.repo.GetByEmail(ctx, req.Email)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return nil, err
+	}
+	// A locked account refuses even the right password, so guessing stops
+	// paying off until the lock passes.
+	if u != nil {
+		if err := s.lockout.CheckAccount(u); err != nil {
+			return nil, err
+		}
+	}
+
+	signedIn, err := s.cfg.Authenticator.Authenticate(ctx, u, req.Email, req.Password)
+	if errors.Is(err, ErrInvalidCredentials) || errors.Is(err, ErrUnknownLogin) {
+		if err := s.lockout.RecordFailure(ctx, u, ip); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidCredentials
+	}
 	if err != nil {
-		if errors.Is(err, repository.ErrNotFound) {
-			return nil, ErrInvalidCredentials
+		return nil, err
+	}
+	// The directory may have signed in to an account other than the one
+	// the email named, after the address changed there.
+	if u == nil || signedIn.ID != u.ID {
+		if err := s.lockout.CheckAccount(signedIn); err != nil {
+			return nil, err
 		}
+	}
+	if err := s.lockout.RecordSuccess(ctx, signedIn); err != nil {
 		return nil, err
 	}
+	return s.startSession(ctx, signedIn, []string{model.AMRPassword})
+}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(req.Password)); err != nil {
-		return nil, ErrInvalidCredentials
+// SignInWithPasskey completes a passkey sign-in started at
+// /auth/webauthn/login/begin. The passkey is both factors, so no MFA
+// challenge follows.
+func (s *UserService) SignInWithPasskey(ctx context.Context, req *model.WebAuthnLoginRequest) (*model.AuthResponse, error) {
+	u, err := s.passkeys.FinishLogin(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return s.startSession(ctx, u, []string{model.AMRHardwareKey, model.AMRMultiFactor})
+}
+
+// SignInWithProvider redeems the ticket from an identity provider's
+// callback. The provider's sign-in counts as one factor, so users with MFA
+// enabled are still challenged.
+func (s *UserService) SignInWithProvider(ctx context.Context, req *model.FederatedTicketRequest) (*model.AuthResponse, error) {
+	u, err := s.federation.SignIn(ctx, req.Ticket)
+	if err != nil {
+		return nil, err
+	}
+	return s.startSession(ctx, u, []string{model.AMRFederated})
+}
+
+// SignInWithMagicLink redeems a link mailed by /auth/magic-link. The link
+// proves control of the mailbox, as a reset link does, so it also lifts a
+// sign-in lockout. It counts as one factor, so users with MFA enabled are
+// still challenged.
+func (s *UserService) SignInWithMagicLink(ctx context.Context, req *model.MagicLinkVerifyRequest) (*model.AuthResponse, error) {
+	u, err := s.magicLinks.Redeem(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.lockout.RecordSuccess(ctx, u); err != nil {
+		return nil, err
+	}
+	return s.startSession(ctx, u, []string{model.AMREmail})
+}
+
+// startSession applies the checks every sign-in method shares once u has
+// authenticated with amr, then issues tokens or an MFA challenge.
+func (s *UserService) startSession(ctx context.Context, u *model.User, amr []string) (*model.AuthResponse, error) {
+	if s.cfg.RequireVerifiedEmail && u.EmailVerifiedAt == nil {
+		return nil, ErrEmailNotVerified
+	}
+
+	if !model.MultiFactor(amr) {
+		enabled, err := s.mfa.Enabled(ctx, u.ID)
+		if err != nil {
+			return nil, err
+		}
+		if enabled {
+			return s.mfa.Challenge(ctx, u, amr)
+		}
+	}
+	return s.tokens.IssuePair(ctx, u, amr)
+}
+
+// Refresh exchanges a refresh token for a new token pair, rotating the refresh token.
+// The token names its user, so whichever organization the request is scoped
+// to plays no part; members send another organization's header throughout.
+func (s *UserService) Refresh(ctx context.Context, req *model.RefreshRequest) (*model.AuthResponse, error) {
+	ctx = reqctx.WithoutTenant(ctx)
+	prev, err := s.tokens.Rotate(ctx, req.RefreshToken)
+	if err != nil {
+		return nil, err
 	}
 
-	token, err := s.issueToken(u)
+	u, err := s.repo.GetByID(ctx, prev.UserID)
 	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrInvalidRefreshToken
+		}
 		return nil, err
 	}
-	return &model.AuthResponse{Token: token, User: u}, nil
+	return s.tokens.ContinueFamily(ctx, u, prev)
+}
+
+// SignOut revokes the caller's access token and, if supplied, the refresh
+// token family it was issued with.
+func (s *UserService) SignOut(ctx context.Context, p *model.Principal, req *model.SignOutRequest) error {
+	if err := s.tokens.RevokeAccessToken(ctx, p); err != nil {
+		return err
+	}
+	if req.RefreshToken != "" {
+		return s.tokens.RevokeRefreshToken(ctx, p.UserID, req.RefreshToken)
+	}
+	return nil
+}
+
+// SignOutAll ends every session the user has, including the caller's.
+func (s *UserService) SignOutAll(ctx context.Context, p *model.Principal) error {
+	if err := s.tokens.RevokeAll(ctx, p.UserID); err != nil {
+		return err
+	}
+	return s.tokens.RevokeAccessToken(ctx, p)
 }
 
 func (s *UserService) GetByID(ctx context.Context, id uuid.UUID) (*model.User, error) {
@@ -87,17 +288,92 @@ func (s *UserService) UpdateUser(ctx context.Context, id uuid.UUID, req *model.U
 	if req.Name != "" {
 		u.Name = req.Name
 	}
-	if req.Email != "" {
-		u.Email = req.Email
+	// A new email is held as pending until the user proves they own it.
+	newEmail := req.Email != "" && req.Email != u.Email
+	if newEmail {
+		taken, err := s.repo.EmailInUse(ctx, u.TenantID, req.Email)
+		if err != nil {
+			return nil, err
+		}
+		if taken {
+			return nil, repository.ErrEmailTaken
+		}
+		u.PendingEmail = req.Email
 	}
 	u.UpdatedAt = time.Now().UTC()
 
 	if err := s.repo.Update(ctx, u); err != nil {
 		return nil, err
 	}
+	if newEmail {
+		if err := s.verifier.Send(ctx, u, u.PendingEmail); err != nil {
+			return nil, err
+		}
+	}
 	return u, nil
 }
 
+// DeleteUser soft-deletes the user and ends all of their sessions.
+func (s *UserService) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	if err := s.repo.SoftDelete(ctx, id, time.Now().UTC()); err != nil {
+		return err
+	}
+	return s.tokens.RevokeAll(ctx, id)
+}
+
+// RestoreUser brings back a soft-deleted user. Sessions revoked at deletion
+// stay revoked; the user signs in again.
+func (s *UserService) RestoreUser(ctx context.Context, id uuid.UUID) (*model.User, error) {
+	if err := s.repo.Restore(ctx, id, time.Now().UTC()); err != nil {
+		return nil, err
+	}
+	return s.repo.GetByID(ctx, id)
+}
+
+// UnlockUser lifts a sign-in lockout early and clears the failure count.
+func (s *UserService) UnlockUser(ctx context.Context, id uuid.UUID) (*model.User, error) {
+	if _, err := s.repo.GetByID(ctx, id); err != nil {
+		return nil, err
+	}
+	if err := s.lockout.Unlock(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.repo.GetByID(ctx, id)
+}
+
+// PurgeUser permanently removes a soft-deleted user once the grace period has passed.
+func (s *UserService) PurgeUser(ctx context.Context, id uuid.UUID) error {
+	u, err := s.repo.GetDeletedByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if time.Since(*u.DeletedAt) < s.cfg.PurgeGrace {
+		return ErrPurgeTooEarly
+	}
+	return s.repo.Purge(ctx, id)
+}
+
+// PurgeDeleted purges every user whose grace period has passed and returns how many went.
+func (s *UserService) PurgeDeleted(ctx context.Context) (int, error) {
+	ids, err := s.repo.DeletedBefore(ctx, time.Now().UTC().Add(-s.cfg.PurgeGrace))
+	if err != nil {
+		return 0, err
+	}
+	purged := 0
+	for _, id := range ids {
+		err := s.repo.Purge(ctx, id)
+		switch {
+		case err == nil:
+			purged++
+		case errors.Is(err, repository.ErrNotFound):
+			// Restored or purged by someone else since DeletedBefore.
+		default:
+			return purged, err
+		}
+	}
+	return purged, nil
+}
+
 func (s *UserService) ListUsers(ctx context.Context, q *model.ListUsersQuery) ([]*model.User, error) {
 	if q.Limit <= 0 || q.Limit > 100 {
 		q.Limit = 20
@@ -105,13 +381,52 @@ func (s *UserService) ListUsers(ctx context.Context, q *model.ListUsersQuery) ([
 	return s.repo.List(ctx, q.Email, q.Limit, q.Offset)
 }
 
-func (s *UserService) issueToken(u *model.User) (string, error) {
-	claims := jwt.MapClaims{
-		"sub":   u.ID.String(),
-		"email": u.Email,
-		"iat":   time.Now().Unix(),
-		"exp":   time.Now().Add(s.jwtExpiry).Unix(),
+// GetRoles returns the roles granted to the user.
+func (s *UserService) GetRoles(ctx context.Context, id uuid.UUID) ([]string, error) {
+	if _, err := s.repo.GetByID(ctx, id); err != nil {
+		return nil, err
+	}
+	roles, err := s.roles.ForUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if roles == nil {
+		roles = []string{}
+	}
+	return roles, nil
+}
+
+// SetRoles replaces the user's roles. The user's live access tokens are
+// invalidated so the change applies on their next refresh rather than
+// whenever the current token happens to expire.
+func (s *UserService) SetRoles(ctx context.Context, id uuid.UUID, req *model.SetRolesRequest) ([]string, error) {
+	if _, err := s.repo.GetByID(ctx, id); err != nil {
+		return nil, err
+	}
+	if err := s.roles.SetForUser(ctx, id, req.Roles); err != nil {
+		return nil, err
+	}
+	if err := s.tokens.InvalidateAccessTokens(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.roles.ForUser(ctx, id)
+}
+
+// EnsureAdmins grants the admin role to existing users with the given emails.
+// Unknown emails are skipped; it is meant for bootstrapping a fresh install,
+// with ctx scoped to the organization whose users should be admins.
+func (s *UserService) EnsureAdmins(ctx context.Context, emails []string) error {
+	for _, email := range emails {
+		u, err := s.repo.GetByEmail(ctx, email)
+		if errors.Is(err, repository.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := s.roles.Assign(ctx, u.ID, model.RoleAdmin); err != nil {
+			return err
+		}
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(s.jwtSecret))
+	return nil
 }
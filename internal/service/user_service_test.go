@@ -2,48 +2,19 @@ package service_test
 
 import (
 	"context"
-	"database/sql"
 	"errors"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
-	_ "modernc.org/sqlite"
 
 	"user-management-api/internal/model"
+	"user-management-api/internal/password"
 	"user-management-api/internal/repository"
 	"user-management-api/internal/service"
 )
 
-// setupService spins up a real in-memory SQLite DB and returns a wired UserService.
-// The DB is closed automatically when the test ends.
-func setupService(t *testing.T) *service.UserService {
-	t.Helper()
-
-	db, err := sql.Open("sqlite", ":memory:")
-	if err != nil {
-		t.Fatalf("open db: %v", err)
-	}
-	t.Cleanup(func() { db.Close() })
-
-	_, err = db.Exec(`
-		CREATE TABLE users (
-			id            TEXT PRIMARY KEY,
-			name          TEXT NOT NULL,
-			email         TEXT NOT NULL UNIQUE,
-			password_hash TEXT NOT NULL,
-			created_at    TEXT NOT NULL,
-			updated_at    TEXT NOT NULL
-		)
-	`)
-	if err != nil {
-		t.Fatalf("migrate: %v", err)
-	}
-
-	repo := repository.NewUserRepository(db)
-	return service.NewUserService(repo, "test-secret", 24*time.Hour)
-}
-
 func TestRegister_Success(t *testing.T) {
 	svc := setupService(t)
 
@@ -78,6 +49,29 @@ func TestRegister_DuplicateEmail(t *testing.T) {
 	}
 }
 
+func TestRegister_EnforcesPolicy(t *testing.T) {
+	svc := setupService(t)
+
+	_, err := svc.Register(context.Background(), &model.RegisterRequest{
+		Name:     "Alice",
+		Email:    "alice@example.com",
+		Password: "aaaaaaaa",
+	})
+	var weak *password.PolicyError
+	if !errors.As(err, &weak) {
+		t.Fatalf("expected a policy error, got %v", err)
+	}
+	got := make(map[string]bool)
+	for _, v := range weak.Violations {
+		got[v.Code] = true
+	}
+	for _, code := range []string{password.CodeMissingClasses, password.CodeRepeatedChars, password.CodeTooWeak} {
+		if !got[code] {
+			t.Errorf("expected violation %s, got %v", code, weak.Violations)
+		}
+	}
+}
+
 func TestSignIn_Success(t *testing.T) {
 	svc := setupService(t)
 
@@ -101,6 +95,38 @@ func TestSignIn_Success(t *testing.T) {
 	}
 }
 
+func TestSignIn_UpgradesLegacyHash(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	reg, err := env.users.Register(ctx, &model.RegisterRequest{Name: "Alice", Email: "alice@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	legacy, err := password.Bcrypt{Cost: 4}.Hash("secret123")
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+	if _, err := env.db.Exec(`UPDATE users SET password_hash = ? WHERE id = ?`, legacy, reg.User.ID.String()); err != nil {
+		t.Fatalf("store legacy hash: %v", err)
+	}
+
+	if _, err := env.users.SignIn(ctx, &model.SignInRequest{Email: "alice@example.com", Password: "secret123"}); err != nil {
+		t.Fatalf("sign in with legacy hash: %v", err)
+	}
+
+	var stored string
+	if err := env.db.QueryRow(`SELECT password_hash FROM users WHERE id = ?`, reg.User.ID.String()).Scan(&stored); err != nil {
+		t.Fatalf("read hash: %v", err)
+	}
+	if !strings.HasPrefix(stored, "$argon2id$") {
+		t.Errorf("expected the hash to be upgraded to argon2id, got %q", stored)
+	}
+	if _, err := env.users.SignIn(ctx, &model.SignInRequest{Email: "alice@example.com", Password: "secret123"}); err != nil {
+		t.Errorf("sign in with upgraded hash: %v", err)
+	}
+}
+
 func TestSignIn_WrongPassword(t *testing.T) {
 	svc := setupService(t)
 
@@ -170,3 +196,316 @@ func TestUpdateUser_NotFound(t *testing.T) {
 		t.Errorf("expected ErrNotFound, got %v", err)
 	}
 }
+
+func TestRefresh_RotatesToken(t *testing.T) {
+	svc := setupService(t)
+
+	reg, err := svc.Register(context.Background(), &model.RegisterRequest{
+		Name:     "Alice",
+		Email:    "alice@example.com",
+		Password: "secret123",
+	})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if reg.RefreshToken == "" {
+		t.Fatal("expected a refresh token, got empty string")
+	}
+
+	resp, err := svc.Refresh(context.Background(), &model.RefreshRequest{RefreshToken: reg.RefreshToken})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.RefreshToken == "" || resp.RefreshToken == reg.RefreshToken {
+		t.Error("expected a new, different refresh token")
+	}
+	if resp.User.ID != reg.User.ID {
+		t.Errorf("expected user %s, got %s", reg.User.ID, resp.User.ID)
+	}
+}
+
+func TestRefresh_ReuseRevokesFamily(t *testing.T) {
+	svc := setupService(t)
+
+	reg, err := svc.Register(context.Background(), &model.RegisterRequest{
+		Name:     "Alice",
+		Email:    "alice@example.com",
+		Password: "secret123",
+	})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	rotated, err := svc.Refresh(context.Background(), &model.RefreshRequest{RefreshToken: reg.RefreshToken})
+	if err != nil {
+		t.Fatalf("first refresh: %v", err)
+	}
+
+	// Replaying the original token must fail...
+	_, err = svc.Refresh(context.Background(), &model.RefreshRequest{RefreshToken: reg.RefreshToken})
+	if !errors.Is(err, service.ErrInvalidRefreshToken) {
+		t.Errorf("expected ErrInvalidRefreshToken on reuse, got %v", err)
+	}
+
+	// ...and take the legitimately rotated token down with it.
+	_, err = svc.Refresh(context.Background(), &model.RefreshRequest{RefreshToken: rotated.RefreshToken})
+	if !errors.Is(err, service.ErrInvalidRefreshToken) {
+		t.Errorf("expected family to be revoked, got %v", err)
+	}
+}
+
+func TestRefresh_UnknownToken(t *testing.T) {
+	svc := setupService(t)
+
+	_, err := svc.Refresh(context.Background(), &model.RefreshRequest{RefreshToken: "not-a-real-token"})
+	if !errors.Is(err, service.ErrInvalidRefreshToken) {
+		t.Errorf("expected ErrInvalidRefreshToken, got %v", err)
+	}
+}
+
+func TestSignOut_RevokesAccessAndRefreshToken(t *testing.T) {
+	svc, tokens := setupServices(t)
+	ctx := context.Background()
+
+	reg, err := svc.Register(ctx, &model.RegisterRequest{Name: "Alice", Email: "alice@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	p, err := tokens.Authenticate(ctx, reg.Token)
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+
+	if err := svc.SignOut(ctx, p, &model.SignOutRequest{RefreshToken: reg.RefreshToken}); err != nil {
+		t.Fatalf("sign out: %v", err)
+	}
+
+	if _, err := tokens.Authenticate(ctx, reg.Token); !errors.Is(err, service.ErrTokenRevoked) {
+		t.Errorf("expected ErrTokenRevoked, got %v", err)
+	}
+	if _, err := svc.Refresh(ctx, &model.RefreshRequest{RefreshToken: reg.RefreshToken}); !errors.Is(err, service.ErrInvalidRefreshToken) {
+		t.Errorf("expected ErrInvalidRefreshToken, got %v", err)
+	}
+}
+
+func TestSignOutAll_RevokesOtherSessions(t *testing.T) {
+	svc, tokens := setupServices(t)
+	ctx := context.Background()
+
+	reg, err := svc.Register(ctx, &model.RegisterRequest{Name: "Alice", Email: "alice@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	other, err := svc.SignIn(ctx, &model.SignInRequest{Email: "alice@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+
+	// iat has second precision; make sure both sessions are strictly older
+	// than the cut-off.
+	time.Sleep(1100 * time.Millisecond)
+
+	p, err := tokens.Authenticate(ctx, other.Token)
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	if err := svc.SignOutAll(ctx, p); err != nil {
+		t.Fatalf("sign out all: %v", err)
+	}
+
+	if _, err := tokens.Authenticate(ctx, reg.Token); !errors.Is(err, service.ErrTokenRevoked) {
+		t.Errorf("expected first session to be revoked, got %v", err)
+	}
+	if _, err := tokens.Authenticate(ctx, other.Token); !errors.Is(err, service.ErrTokenRevoked) {
+		t.Errorf("expected caller's session to be revoked, got %v", err)
+	}
+	if _, err := svc.Refresh(ctx, &model.RefreshRequest{RefreshToken: reg.RefreshToken}); !errors.Is(err, service.ErrInvalidRefreshToken) {
+		t.Errorf("expected refresh tokens to be revoked, got %v", err)
+	}
+
+	// A fresh sign-in after the cut-off must work.
+	fresh, err := svc.SignIn(ctx, &model.SignInRequest{Email: "alice@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+	if _, err := tokens.Authenticate(ctx, fresh.Token); err != nil {
+		t.Errorf("expected new token to be valid, got %v", err)
+	}
+}
+
+func TestRegister_GrantsUserRole(t *testing.T) {
+	svc, tokens := setupServices(t)
+	ctx := context.Background()
+
+	reg, err := svc.Register(ctx, &model.RegisterRequest{Name: "Alice", Email: "alice@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	p, err := tokens.Authenticate(ctx, reg.Token)
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+
+	if len(p.Roles) != 1 || p.Roles[0] != model.RoleUser {
+		t.Errorf("expected roles [user], got %v", p.Roles)
+	}
+	if !p.Can(model.PermUsersRead) {
+		t.Error("expected users:read")
+	}
+	if p.Can(model.PermUsersWrite) {
+		t.Error("regular users must not have users:write")
+	}
+}
+
+func TestSetRoles_GrantsAdminPermissions(t *testing.T) {
+	svc, tokens := setupServices(t)
+	ctx := context.Background()
+
+	reg, err := svc.Register(ctx, &model.RegisterRequest{Name: "Alice", Email: "alice@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	roles, err := svc.SetRoles(ctx, reg.User.ID, &model.SetRolesRequest{Roles: []string{model.RoleAdmin, model.RoleUser}})
+	if err != nil {
+		t.Fatalf("set roles: %v", err)
+	}
+	if len(roles) != 2 {
+		t.Errorf("expected 2 roles, got %v", roles)
+	}
+
+	// Claims are fixed at issue time; a refreshed token carries the new roles.
+	resp, err := svc.Refresh(ctx, &model.RefreshRequest{RefreshToken: reg.RefreshToken})
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	p, err := tokens.Authenticate(ctx, resp.Token)
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	if !p.Can(model.PermUsersWrite) || !p.Can(model.PermRolesWrite) {
+		t.Errorf("expected admin permissions, got %v", p.Permissions)
+	}
+}
+
+func TestSetRoles_UnknownRole(t *testing.T) {
+	svc := setupService(t)
+
+	reg, err := svc.Register(context.Background(), &model.RegisterRequest{Name: "Alice", Email: "alice@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	_, err = svc.SetRoles(context.Background(), reg.User.ID, &model.SetRolesRequest{Roles: []string{"superuser"}})
+	if !errors.Is(err, repository.ErrUnknownRole) {
+		t.Errorf("expected ErrUnknownRole, got %v", err)
+	}
+}
+
+func TestDeleteUser_HidesUserAndRevokesTokens(t *testing.T) {
+	svc, tokens := setupServices(t)
+	ctx := context.Background()
+
+	reg, err := svc.Register(ctx, &model.RegisterRequest{Name: "Alice", Email: "alice@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if err := svc.DeleteUser(ctx, reg.User.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	if _, err := svc.GetByID(ctx, reg.User.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("expected ErrNotFound from GetByID, got %v", err)
+	}
+	users, err := svc.ListUsers(ctx, &model.ListUsersQuery{})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(users) != 0 {
+		t.Errorf("expected deleted user to be excluded from List, got %d users", len(users))
+	}
+	if _, err := svc.SignIn(ctx, &model.SignInRequest{Email: "alice@example.com", Password: "secret123"}); !errors.Is(err, service.ErrInvalidCredentials) {
+		t.Errorf("expected ErrInvalidCredentials, got %v", err)
+	}
+	if _, err := tokens.Authenticate(ctx, reg.Token); err == nil {
+		t.Error("expected the deleted user's token to be rejected")
+	}
+
+	// The email stays reserved while the account can still be restored.
+	_, err = svc.Register(ctx, &model.RegisterRequest{Name: "Alice 2", Email: "alice@example.com", Password: "secret123"})
+	if !errors.Is(err, repository.ErrEmailTaken) {
+		t.Errorf("expected ErrEmailTaken, got %v", err)
+	}
+}
+
+func TestRestoreUser(t *testing.T) {
+	svc := setupService(t)
+	ctx := context.Background()
+
+	reg, err := svc.Register(ctx, &model.RegisterRequest{Name: "Alice", Email: "alice@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if err := svc.DeleteUser(ctx, reg.User.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	u, err := svc.RestoreUser(ctx, reg.User.ID)
+	if err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if u.DeletedAt != nil {
+		t.Error("expected deleted_at to be cleared")
+	}
+	if _, err := svc.SignIn(ctx, &model.SignInRequest{Email: "alice@example.com", Password: "secret123"}); err != nil {
+		t.Errorf("expected sign-in to work after restore, got %v", err)
+	}
+
+	if _, err := svc.RestoreUser(ctx, reg.User.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("restoring a live user: expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestPurgeUser_RespectsGracePeriod(t *testing.T) {
+	svc := setupService(t)
+	ctx := context.Background()
+
+	reg, err := svc.Register(ctx, &model.RegisterRequest{Name: "Alice", Email: "alice@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	if err := svc.PurgeUser(ctx, reg.User.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("purging a live user: expected ErrNotFound, got %v", err)
+	}
+	if err := svc.DeleteUser(ctx, reg.User.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if err := svc.PurgeUser(ctx, reg.User.ID); !errors.Is(err, service.ErrPurgeTooEarly) {
+		t.Errorf("expected ErrPurgeTooEarly, got %v", err)
+	}
+}
+
+func TestPurgeUser_FreesEmail(t *testing.T) {
+	svc, _ := setupServicesWithConfig(t, service.UserConfig{PurgeGrace: 0})
+	ctx := context.Background()
+
+	reg, err := svc.Register(ctx, &model.RegisterRequest{Name: "Alice", Email: "alice@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if err := svc.DeleteUser(ctx, reg.User.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if err := svc.PurgeUser(ctx, reg.User.ID); err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+
+	if _, err := svc.RestoreUser(ctx, reg.User.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("expected purged user to be unrestorable, got %v", err)
+	}
+	if _, err := svc.Register(ctx, &model.RegisterRequest{Name: "Alice", Email: "alice@example.com", Password: "secret123"}); err != nil {
+		t.Errorf("expected email to be free after purge, got %v", err)
+	}
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"user-management-api/internal/mail"
+	"user-management-api/internal/model"
+	"user-management-api/internal/repository"
+)
+
+// ErrInvalidVerificationToken is returned for unknown, expired, used or stale verification tokens.
+var ErrInvalidVerificationToken = errors.New("invalid or expired verification token")
+
+// VerificationConfig holds the tunables for EmailVerificationService.
+type VerificationConfig struct {
+	TokenExpiry time.Duration
+	// VerifyURL is the page that receives the token as ?token=...
+	VerifyURL string
+	// Dispatch runs the lookup and mail of Resend; nil means InBackground.
+	Dispatch Dispatch
+}
+
+// EmailVerificationService proves that users control their email addresses,
+// both at registration and when changing address.
+type EmailVerificationService struct {
+	users   *repository.UserRepository
+	actions *repository.ActionTokenRepository
+	mailer  mail.Mailer
+	cfg     VerificationConfig
+}
+
+func NewEmailVerificationService(
+	users *repository.UserRepository,
+	actions *repository.ActionTokenRepository,
+	mailer mail.Mailer,
+	cfg VerificationConfig,
+) *EmailVerificationService {
+	if cfg.Dispatch == nil {
+		cfg.Dispatch = InBackground
+	}
+	return &EmailVerificationService{users: users, actions: actions, mailer: mailer, cfg: cfg}
+}
+
+// Send mails a verification link for email, which is either u.Email or the
+// pending address u is switching to.
+func (s *EmailVerificationService) Send(ctx context.Context, u *model.User, email string) error {
+	raw, err := issueActionToken(ctx, s.actions, u.ID, model.PurposeEmailVerification, email, s.cfg.TokenExpiry)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(ctx, mail.Message{
+		To:      email,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nPlease confirm that %s is your email address by opening the link below within %s:\n\n%s\n\n"+
+				"Or submit this token to POST /api/v1/auth/email/verify:\n\n%s\n\n"+
+				"If you didn't create an account or change your email, you can ignore this email.\n",
+			u.Name, email, s.cfg.TokenExpiry, withToken(s.cfg.VerifyURL, raw), raw,
+		),
+	})
+}
+
+// Resend mails a fresh link to whichever address of the account still needs
+// confirming. Like ForgotPassword it returns before looking the address up,
+// so unknown or already-verified accounts can't be told apart.
+func (s *EmailVerificationService) Resend(ctx context.Context, req *model.ResendVerificationRequest) {
+	email := req.Email
+	s.cfg.Dispatch(ctx, "resend verification", func(ctx context.Context) error {
+		return s.resend(ctx, email)
+	})
+}
+
+func (s *EmailVerificationService) resend(ctx context.Context, email string) error {
+	u, err := s.users.GetByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	switch {
+	case u.PendingEmail != "":
+		return s.Send(ctx, u, u.PendingEmail)
+	case u.EmailVerifiedAt == nil:
+		return s.Send(ctx, u, u.Email)
+	}
+	return nil
+}
+
+// Verify redeems a token. If it was issued for a pending address, that
+// address becomes the user's email.
+func (s *EmailVerificationService) Verify(ctx context.Context, req *model.VerifyEmailRequest) (*model.User, error) {
+	t, err := redeemActionToken(ctx, s.actions, model.PurposeEmailVerification, req.Token)
+	if err != nil {
+		if errors.Is(err, errActionTokenInvalid) {
+			return nil, ErrInvalidVerificationToken
+		}
+		return nil, err
+	}
+
+	u, err := s.users.GetByID(ctx, t.UserID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrInvalidVerificationToken
+		}
+		return nil, err
+	}
+	// The address must still be one the user wants; a token for an email
+	// they have since moved away from is stale.
+	if t.Email != u.Email && t.Email != u.PendingEmail {
+		return nil, ErrInvalidVerificationToken
+	}
+
+	if err := s.users.MarkEmailVerified(ctx, u.ID, t.Email, time.Now().UTC()); err != nil {
+		return nil, err
+	}
+	return s.users.GetByID(ctx, u.ID)
+}
@@ -0,0 +1,187 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"user-management-api/internal/model"
+	"user-management-api/internal/repository"
+	"user-management-api/internal/service"
+)
+
+func TestRegister_SendsVerificationEmail(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	reg, err := env.users.Register(ctx, &model.RegisterRequest{Name: "Alice", Email: "alice@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if reg.User.EmailVerifiedAt != nil {
+		t.Error("expected a new user to be unverified")
+	}
+	if to := env.mailer.last(t).To; to != "alice@example.com" {
+		t.Errorf("expected mail to alice@example.com, got %s", to)
+	}
+
+	u, err := env.verifier.Verify(ctx, &model.VerifyEmailRequest{Token: env.mailer.lastToken(t)})
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if u.EmailVerifiedAt == nil {
+		t.Error("expected email_verified_at to be set")
+	}
+
+	if _, err := env.verifier.Verify(ctx, &model.VerifyEmailRequest{Token: env.mailer.lastToken(t)}); !errors.Is(err, service.ErrInvalidVerificationToken) {
+		t.Errorf("second use: expected ErrInvalidVerificationToken, got %v", err)
+	}
+}
+
+func TestSignIn_RequiresVerifiedEmail(t *testing.T) {
+	env := newTestEnv(t, func(c *testConfig) { c.user.RequireVerifiedEmail = true })
+	ctx := context.Background()
+
+	reg, err := env.users.Register(ctx, &model.RegisterRequest{Name: "Alice", Email: "alice@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if reg.Token != "" || reg.RefreshToken != "" {
+		t.Error("expected no tokens before verification")
+	}
+
+	signIn := &model.SignInRequest{Email: "alice@example.com", Password: "secret123"}
+	if _, err := env.users.SignIn(ctx, signIn); !errors.Is(err, service.ErrEmailNotVerified) {
+		t.Fatalf("expected ErrEmailNotVerified, got %v", err)
+	}
+	// A wrong password must still look like a wrong password.
+	if _, err := env.users.SignIn(ctx, &model.SignInRequest{Email: "alice@example.com", Password: "wrong"}); !errors.Is(err, service.ErrInvalidCredentials) {
+		t.Errorf("expected ErrInvalidCredentials, got %v", err)
+	}
+
+	if _, err := env.verifier.Verify(ctx, &model.VerifyEmailRequest{Token: env.mailer.lastToken(t)}); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if _, err := env.users.SignIn(ctx, signIn); err != nil {
+		t.Errorf("expected sign-in after verification, got %v", err)
+	}
+}
+
+func TestUpdateUser_EmailChangeIsPendingUntilVerified(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	reg, err := env.users.Register(ctx, &model.RegisterRequest{Name: "Alice", Email: "alice@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	oldToken := env.mailer.lastToken(t)
+
+	u, err := env.users.UpdateUser(ctx, reg.User.ID, &model.UpdateUserRequest{Email: "alice@new.example.com"})
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if u.Email != "alice@example.com" || u.PendingEmail != "alice@new.example.com" {
+		t.Fatalf("expected email unchanged with a pending change, got email=%s pending=%s", u.Email, u.PendingEmail)
+	}
+	if to := env.mailer.last(t).To; to != "alice@new.example.com" {
+		t.Errorf("expected mail to the new address, got %s", to)
+	}
+
+	u, err = env.verifier.Verify(ctx, &model.VerifyEmailRequest{Token: env.mailer.lastToken(t)})
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if u.Email != "alice@new.example.com" || u.PendingEmail != "" || u.EmailVerifiedAt == nil {
+		t.Errorf("expected switched and verified email, got email=%s pending=%s verified=%v", u.Email, u.PendingEmail, u.EmailVerifiedAt)
+	}
+
+	// The registration link points at an address the user no longer has.
+	if _, err := env.verifier.Verify(ctx, &model.VerifyEmailRequest{Token: oldToken}); !errors.Is(err, service.ErrInvalidVerificationToken) {
+		t.Errorf("stale token: expected ErrInvalidVerificationToken, got %v", err)
+	}
+	if _, err := env.users.SignIn(ctx, &model.SignInRequest{Email: "alice@new.example.com", Password: "secret123"}); err != nil {
+		t.Errorf("sign in with new email: %v", err)
+	}
+}
+
+func TestUpdateUser_EmailChangeToTakenAddress(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	reg, err := env.users.Register(ctx, &model.RegisterRequest{Name: "Alice", Email: "alice@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if _, err := env.users.Register(ctx, &model.RegisterRequest{Name: "Bob", Email: "bob@example.com", Password: "secret123"}); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	_, err = env.users.UpdateUser(ctx, reg.User.ID, &model.UpdateUserRequest{Email: "bob@example.com"})
+	if !errors.Is(err, repository.ErrEmailTaken) {
+		t.Errorf("expected ErrEmailTaken, got %v", err)
+	}
+}
+
+func TestResendVerification(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	env.verifier.Resend(ctx, &model.ResendVerificationRequest{Email: "nobody@example.com"})
+	if n := env.mailer.count(); n != 0 {
+		t.Errorf("expected no email for an unknown address, got %d", n)
+	}
+
+	if _, err := env.users.Register(ctx, &model.RegisterRequest{Name: "Alice", Email: "alice@example.com", Password: "secret123"}); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	env.verifier.Resend(ctx, &model.ResendVerificationRequest{Email: "alice@example.com"})
+	if n := env.mailer.count(); n != 2 {
+		t.Fatalf("expected 2 emails, got %d", n)
+	}
+
+	if _, err := env.verifier.Verify(ctx, &model.VerifyEmailRequest{Token: env.mailer.lastToken(t)}); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	env.verifier.Resend(ctx, &model.ResendVerificationRequest{Email: "alice@example.com"})
+	if n := env.mailer.count(); n != 2 {
+		t.Errorf("expected no email once verified, got %d total", n)
+	}
+}
+
+func TestResendVerification_KnownAndUnknownEmailsDoTheSameWork(t *testing.T) {
+	jobs := &jobQueue{}
+	env := newTestEnv(t, func(c *testConfig) { c.verification.Dispatch = jobs.dispatch })
+	ctx := context.Background()
+	// Registration mails its own link through Send, not Resend.
+	newCustomer(t, env)
+	sent := env.mailer.count()
+	var issued int
+	if err := env.db.QueryRow(`SELECT COUNT(*) FROM action_tokens WHERE purpose = ?`, model.PurposeEmailVerification).Scan(&issued); err != nil {
+		t.Fatalf("count: %v", err)
+	}
+
+	for _, email := range []string{"bob@example.com", "nobody@example.com"} {
+		env.verifier.Resend(ctx, &model.ResendVerificationRequest{Email: email})
+	}
+	// Neither call looks anything up or mails before returning; each leaves
+	// one job behind.
+	if len(jobs.jobs) != 2 {
+		t.Fatalf("expected a job per request, got %v", jobs.names)
+	}
+	if n := env.mailer.count(); n != sent {
+		t.Errorf("expected no email before the jobs run, got %d", n-sent)
+	}
+	var now int
+	if err := env.db.QueryRow(`SELECT COUNT(*) FROM action_tokens WHERE purpose = ?`, model.PurposeEmailVerification).Scan(&now); err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	if now != issued {
+		t.Errorf("expected no verification token before the jobs run, got %d", now-issued)
+	}
+
+	jobs.run(t)
+	if n := env.mailer.count(); n != sent+1 || env.mailer.last(t).To != "bob@example.com" {
+		t.Errorf("expected one email, to bob@example.com, got %d", n-sent)
+	}
+}
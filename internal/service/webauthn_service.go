@@ -0,0 +1,251 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+
+	"user-management-api/internal/model"
+	"user-management-api/internal/repository"
+	"user-management-api/internal/webauthn"
+)
+
+var (
+	// ErrPasskeyRegistration is returned when a registration response fails verification.
+	ErrPasskeyRegistration = errors.New("passkey registration failed")
+	// ErrInvalidPasskey is returned when a sign-in assertion fails verification.
+	ErrInvalidPasskey = errors.New("passkey sign-in failed")
+	// ErrPasskeyCloned is returned when a passkey's signature counter did not
+	// increase, which suggests its key has been copied to another device.
+	ErrPasskeyCloned = errors.New("passkey signature counter did not increase")
+)
+
+// WebAuthnConfig describes the relying party passkeys are bound to.
+type WebAuthnConfig struct {
+	RPID    string
+	RPName  string
+	Origins []string
+	// Timeout bounds each ceremony, from begin to finish.
+	Timeout time.Duration
+}
+
+// WebAuthnService registers passkeys and verifies passkey sign-ins.
+// Challenges are single-use action tokens, so no other session state is kept
+// between the begin and finish steps.
+type WebAuthnService struct {
+	users   *repository.UserRepository
+	creds   *repository.WebAuthnRepository
+	actions *repository.ActionTokenRepository
+	rp      *webauthn.RelyingParty
+	cfg     WebAuthnConfig
+}
+
+func NewWebAuthnService(
+	users *repository.UserRepository,
+	creds *repository.WebAuthnRepository,
+	actions *repository.ActionTokenRepository,
+	cfg WebAuthnConfig,
+) *WebAuthnService {
+	return &WebAuthnService{
+		users:   users,
+		creds:   creds,
+		actions: actions,
+		rp:      &webauthn.RelyingParty{ID: cfg.RPID, Name: cfg.RPName, Origins: cfg.Origins},
+		cfg:     cfg,
+	}
+}
+
+// BeginRegistration returns creation options for a new passkey for the user.
+func (s *WebAuthnService) BeginRegistration(ctx context.Context, userID uuid.UUID) (*model.WebAuthnCreationOptions, error) {
+	u, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := s.creds.ListForUser(ctx, u.ID)
+	if err != nil {
+		return nil, err
+	}
+	challenge, err := issueActionToken(ctx, s.actions, u.ID, model.PurposeWebAuthnRegister, "", s.cfg.Timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &model.WebAuthnCreationOptions{
+		Challenge: challenge,
+		RP:        model.WebAuthnRelyingParty{ID: s.cfg.RPID, Name: s.cfg.RPName},
+		User: model.WebAuthnUserEntity{
+			ID:          webauthn.EncodeID(u.ID[:]),
+			Name:        u.Email,
+			DisplayName: u.Name,
+		},
+		Timeout:            s.cfg.Timeout.Milliseconds(),
+		ExcludeCredentials: descriptors(existing),
+		// Discoverable credentials with user verification: sign-in needs
+		// neither an email address nor a password.
+		AuthenticatorSelection: model.WebAuthnAuthenticatorSelection{
+			ResidentKey:        "required",
+			RequireResidentKey: true,
+			UserVerification:   "required",
+		},
+		Attestation: "none",
+	}
+	for _, alg := range webauthn.SupportedAlgorithms {
+		opts.PubKeyCredParams = append(opts.PubKeyCredParams, model.WebAuthnCredentialParam{Type: "public-key", Alg: alg})
+	}
+	return opts, nil
+}
+
+// FinishRegistration verifies the authenticator's response and stores the passkey.
+func (s *WebAuthnService) FinishRegistration(ctx context.Context, userID uuid.UUID, req *model.WebAuthnRegistrationRequest) (*model.WebAuthnCredential, error) {
+	clientData, err1 := webauthn.DecodeID(req.Response.ClientDataJSON)
+	attestation, err2 := webauthn.DecodeID(req.Response.AttestationObject)
+	if err := errors.Join(err1, err2); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPasskeyRegistration, err)
+	}
+
+	cd, err := webauthn.ParseClientData(clientData)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPasskeyRegistration, err)
+	}
+	t, err := redeemActionToken(ctx, s.actions, model.PurposeWebAuthnRegister, cd.Challenge)
+	if err != nil {
+		if errors.Is(err, errActionTokenInvalid) {
+			return nil, fmt.Errorf("%w: unknown or expired challenge", ErrPasskeyRegistration)
+		}
+		return nil, err
+	}
+	if t.UserID != userID {
+		return nil, fmt.Errorf("%w: challenge belongs to another user", ErrPasskeyRegistration)
+	}
+
+	cred, err := s.rp.VerifyRegistration(cd.Challenge, clientData, attestation)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPasskeyRegistration, err)
+	}
+	if id, err := webauthn.DecodeID(req.ID); err != nil || !bytes.Equal(id, cred.ID) {
+		return nil, fmt.Errorf("%w: credential ID does not match authenticator data", ErrPasskeyRegistration)
+	}
+
+	name := req.Name
+	if name == "" {
+		name = "Passkey"
+	}
+	c := &model.WebAuthnCredential{
+		ID:           uuid.New(),
+		UserID:       userID,
+		CredentialID: webauthn.EncodeID(cred.ID),
+		PublicKey:    cred.PublicKey,
+		SignCount:    cred.SignCount,
+		AAGUID:       uuid.UUID(cred.AAGUID).String(),
+		Name:         name,
+		CreatedAt:    time.Now().UTC(),
+	}
+	if err := s.creds.Create(ctx, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// BeginLogin returns request options for a passkey sign-in. The user is not
+// known yet; the authenticator offers its discoverable credentials.
+func (s *WebAuthnService) BeginLogin(ctx context.Context) (*model.WebAuthnRequestOptions, error) {
+	// Anonymous challenges aren't tied to a user, so they must not burn each other.
+	challenge, err := newActionToken(ctx, s.actions, uuid.Nil, model.PurposeWebAuthnLogin, "", s.cfg.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &model.WebAuthnRequestOptions{
+		Challenge:        challenge,
+		Timeout:          s.cfg.Timeout.Milliseconds(),
+		RPID:             s.cfg.RPID,
+		AllowCredentials: []model.WebAuthnCredentialDescriptor{},
+		UserVerification: "required",
+	}, nil
+}
+
+// FinishLogin verifies an assertion and returns the passkey's owner.
+// Starting the session is left to UserService so every sign-in method goes
+// through the same checks.
+func (s *WebAuthnService) FinishLogin(ctx context.Context, req *model.WebAuthnLoginRequest) (*model.User, error) {
+	credID, err1 := webauthn.DecodeID(req.ID)
+	clientData, err2 := webauthn.DecodeID(req.Response.ClientDataJSON)
+	authData, err3 := webauthn.DecodeID(req.Response.AuthenticatorData)
+	sig, err4 := webauthn.DecodeID(req.Response.Signature)
+	userHandle, err5 := webauthn.DecodeID(req.Response.UserHandle)
+	if err := errors.Join(err1, err2, err3, err4, err5); err != nil {
+		return nil, ErrInvalidPasskey
+	}
+
+	cd, err := webauthn.ParseClientData(clientData)
+	if err != nil {
+		return nil, ErrInvalidPasskey
+	}
+	if _, err := redeemActionToken(ctx, s.actions, model.PurposeWebAuthnLogin, cd.Challenge); err != nil {
+		if errors.Is(err, errActionTokenInvalid) {
+			return nil, ErrInvalidPasskey
+		}
+		return nil, err
+	}
+
+	cred, err := s.creds.GetByCredentialID(ctx, webauthn.EncodeID(credID))
+	if err != nil {
+		if errors.Is(err, repository.ErrCredentialNotFound) {
+			return nil, ErrInvalidPasskey
+		}
+		return nil, err
+	}
+	if len(userHandle) > 0 && !bytes.Equal(userHandle, cred.UserID[:]) {
+		return nil, ErrInvalidPasskey
+	}
+
+	res, err := s.rp.VerifyAssertion(cd.Challenge, cred.PublicKey, clientData, authData, sig)
+	if err != nil {
+		return nil, ErrInvalidPasskey
+	}
+	// Authenticators that keep no counter always report zero; otherwise it
+	// must grow with every use.
+	if (res.SignCount != 0 || cred.SignCount != 0) && res.SignCount <= cred.SignCount {
+		log.Printf("passkey %s of user %s: sign count %d not above stored %d, possible clone",
+			cred.ID, cred.UserID, res.SignCount, cred.SignCount)
+		return nil, ErrPasskeyCloned
+	}
+	if err := s.creds.UpdateSignCount(ctx, cred.ID, cred.SignCount, res.SignCount, time.Now().UTC()); err != nil {
+		if errors.Is(err, repository.ErrCredentialNotFound) {
+			// A concurrent sign-in moved the counter first.
+			return nil, ErrPasskeyCloned
+		}
+		return nil, err
+	}
+
+	u, err := s.users.GetByID(ctx, cred.UserID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrInvalidPasskey
+		}
+		return nil, err
+	}
+	return u, nil
+}
+
+func (s *WebAuthnService) ListCredentials(ctx context.Context, userID uuid.UUID) ([]*model.WebAuthnCredential, error) {
+	return s.creds.ListForUser(ctx, userID)
+}
+
+func (s *WebAuthnService) DeleteCredential(ctx context.Context, userID, id uuid.UUID) error {
+	return s.creds.Delete(ctx, userID, id)
+}
+
+// --- helpers ---
+
+func descriptors(creds []*model.WebAuthnCredential) []model.WebAuthnCredentialDescriptor {
+	out := make([]model.WebAuthnCredentialDescriptor, 0, len(creds))
+	for _, c := range creds {
+		out = append(out, model.WebAuthnCredentialDescriptor{Type: "public-key", ID: c.CredentialID})
+	}
+	return out
+}
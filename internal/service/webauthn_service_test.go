@@ -0,0 +1,188 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"user-management-api/internal/model"
+	"user-management-api/internal/service"
+	"user-management-api/internal/webauthn"
+	"user-management-api/internal/webauthn/webauthntest"
+)
+
+// registerPasskey runs a registration ceremony for userID with a fresh
+// software authenticator and returns it.
+func registerPasskey(t *testing.T, env *testEnv, userID uuid.UUID) *webauthntest.Authenticator {
+	t.Helper()
+	ctx := context.Background()
+	a := webauthntest.New("app.test", "https://app.test")
+
+	opts, err := env.passkeys.BeginRegistration(ctx, userID)
+	if err != nil {
+		t.Fatalf("begin registration: %v", err)
+	}
+	userHandle, _ := webauthn.DecodeID(opts.User.ID)
+	att, err := a.Create(opts.Challenge, userHandle)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	req := &model.WebAuthnRegistrationRequest{Name: "Laptop", ID: webauthn.EncodeID(att.CredentialID)}
+	req.Response.ClientDataJSON = webauthn.EncodeID(att.ClientDataJSON)
+	req.Response.AttestationObject = webauthn.EncodeID(att.AttestationObject)
+	if _, err := env.passkeys.FinishRegistration(ctx, userID, req); err != nil {
+		t.Fatalf("finish registration: %v", err)
+	}
+	return a
+}
+
+// passkeyLogin begins a sign-in and answers it with a.
+func passkeyLogin(t *testing.T, env *testEnv, a *webauthntest.Authenticator) *model.WebAuthnLoginRequest {
+	t.Helper()
+	opts, err := env.passkeys.BeginLogin(context.Background())
+	if err != nil {
+		t.Fatalf("begin login: %v", err)
+	}
+	as, err := a.Get(opts.Challenge)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+
+	req := &model.WebAuthnLoginRequest{ID: webauthn.EncodeID(as.CredentialID)}
+	req.Response.ClientDataJSON = webauthn.EncodeID(as.ClientDataJSON)
+	req.Response.AuthenticatorData = webauthn.EncodeID(as.AuthenticatorData)
+	req.Response.Signature = webauthn.EncodeID(as.Signature)
+	req.Response.UserHandle = webauthn.EncodeID(as.UserHandle)
+	return req
+}
+
+func TestPasskey_RegisterAndSignIn(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	reg, err := env.users.Register(ctx, &model.RegisterRequest{Name: "Alice", Email: "alice@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	a := registerPasskey(t, env, reg.User.ID)
+
+	resp, err := env.users.SignInWithPasskey(ctx, passkeyLogin(t, env, a))
+	if err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+	if resp.Token == "" || resp.RefreshToken == "" || resp.User.ID != reg.User.ID {
+		t.Fatalf("expected a token pair for alice, got %+v", resp)
+	}
+	p, err := env.tokens.Authenticate(ctx, resp.Token)
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	if !slices.Equal(p.AMR, []string{model.AMRHardwareKey, model.AMRMultiFactor}) {
+		t.Errorf("expected amr [hwk mfa], got %v", p.AMR)
+	}
+
+	creds, err := env.passkeys.ListCredentials(ctx, reg.User.ID)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(creds) != 1 || creds[0].Name != "Laptop" || creds[0].LastUsedAt == nil {
+		t.Errorf("expected one used credential named Laptop, got %+v", creds)
+	}
+}
+
+func TestPasskey_ChallengeIsSingleUse(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	reg, err := env.users.Register(ctx, &model.RegisterRequest{Name: "Alice", Email: "alice@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	a := registerPasskey(t, env, reg.User.ID)
+
+	req := passkeyLogin(t, env, a)
+	if _, err := env.users.SignInWithPasskey(ctx, req); err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+	if _, err := env.users.SignInWithPasskey(ctx, req); !errors.Is(err, service.ErrInvalidPasskey) {
+		t.Errorf("replayed assertion: expected ErrInvalidPasskey, got %v", err)
+	}
+}
+
+func TestPasskey_ClonedAuthenticatorIsRejected(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	reg, err := env.users.Register(ctx, &model.RegisterRequest{Name: "Alice", Email: "alice@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	a := registerPasskey(t, env, reg.User.ID)
+	if _, err := env.users.SignInWithPasskey(ctx, passkeyLogin(t, env, a)); err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+
+	clone := a.Clone()
+	if _, err := env.users.SignInWithPasskey(ctx, passkeyLogin(t, env, a)); err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+	if _, err := env.users.SignInWithPasskey(ctx, passkeyLogin(t, env, clone)); !errors.Is(err, service.ErrPasskeyCloned) {
+		t.Errorf("expected ErrPasskeyCloned, got %v", err)
+	}
+}
+
+func TestPasskey_RegistrationChallengeIsBoundToUser(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	alice, err := env.users.Register(ctx, &model.RegisterRequest{Name: "Alice", Email: "alice@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	bob, err := env.users.Register(ctx, &model.RegisterRequest{Name: "Bob", Email: "bob@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	opts, err := env.passkeys.BeginRegistration(ctx, alice.User.ID)
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	att, err := webauthntest.New("app.test", "https://app.test").Create(opts.Challenge, bob.User.ID[:])
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	req := &model.WebAuthnRegistrationRequest{ID: webauthn.EncodeID(att.CredentialID)}
+	req.Response.ClientDataJSON = webauthn.EncodeID(att.ClientDataJSON)
+	req.Response.AttestationObject = webauthn.EncodeID(att.AttestationObject)
+
+	if _, err := env.passkeys.FinishRegistration(ctx, bob.User.ID, req); !errors.Is(err, service.ErrPasskeyRegistration) {
+		t.Errorf("expected ErrPasskeyRegistration, got %v", err)
+	}
+}
+
+func TestPasskey_DeletedCredentialCannotSignIn(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	reg, err := env.users.Register(ctx, &model.RegisterRequest{Name: "Alice", Email: "alice@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	a := registerPasskey(t, env, reg.User.ID)
+	creds, err := env.passkeys.ListCredentials(ctx, reg.User.ID)
+	if err != nil || len(creds) != 1 {
+		t.Fatalf("list: %v, %d", err, len(creds))
+	}
+
+	if err := env.passkeys.DeleteCredential(ctx, reg.User.ID, creds[0].ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := env.users.SignInWithPasskey(ctx, passkeyLogin(t, env, a)); !errors.Is(err, service.ErrInvalidPasskey) {
+		t.Errorf("expected ErrInvalidPasskey, got %v", err)
+	}
+}
@@ -0,0 +1,286 @@
+// Package signing manages the keys used to sign and verify access tokens.
+//
+// A KeySet has exactly one active key, used for signing, and any number of
+// additional verification-only keys so that tokens signed before a rotation
+// stay valid until they expire. Asymmetric public keys are published as a
+// JWKS document; the legacy HS256 shared secret is never published.
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrUnknownKey is returned by Keyfunc when a token names a kid we don't hold.
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// Key is a single signing or verification key.
+type Key struct {
+	ID     string
+	Method jwt.SigningMethod
+
+	signKey   any // *rsa.PrivateKey, ed25519.PrivateKey or []byte; nil for verify-only keys
+	verifyKey any // *rsa.PublicKey, ed25519.PublicKey or []byte
+}
+
+// CanSign reports whether the key holds private material.
+func (k *Key) CanSign() bool {
+	return k.signKey != nil
+}
+
+// KeySet holds the active signing key plus the keys accepted for verification.
+type KeySet struct {
+	active *Key
+	keys   []*Key // active first, then verification keys in load order
+	byID   map[string]*Key
+}
+
+// NewHMACKeySet returns a KeySet that signs and verifies with an HS256 shared secret.
+func NewHMACKeySet(secret string) *KeySet {
+	sum := sha256.Sum256([]byte(secret))
+	k := &Key{
+		ID:        "hs256-" + base64.RawURLEncoding.EncodeToString(sum[:6]),
+		Method:    jwt.SigningMethodHS256,
+		signKey:   []byte(secret),
+		verifyKey: []byte(secret),
+	}
+	return &KeySet{active: k, keys: []*Key{k}, byID: map[string]*Key{k.ID: k}}
+}
+
+// NewKeySet builds a KeySet from an active key and optional verification-only keys.
+func NewKeySet(active *Key, verify ...*Key) (*KeySet, error) {
+	if active == nil || !active.CanSign() {
+		return nil, errors.New("signing: active key must hold a private key")
+	}
+	ks := &KeySet{active: active, keys: []*Key{active}, byID: map[string]*Key{active.ID: active}}
+	for _, k := range verify {
+		if _, dup := ks.byID[k.ID]; dup {
+			continue
+		}
+		ks.keys = append(ks.keys, k)
+		ks.byID[k.ID] = k
+	}
+	return ks, nil
+}
+
+// LoadKeySet reads the active private key and any verification keys from PEM files.
+func LoadKeySet(activeFile string, verifyFiles []string) (*KeySet, error) {
+	active, err := LoadKeyFile(activeFile)
+	if err != nil {
+		return nil, err
+	}
+	verify := make([]*Key, 0, len(verifyFiles))
+	for _, f := range verifyFiles {
+		k, err := LoadKeyFile(f)
+		if err != nil {
+			return nil, err
+		}
+		verify = append(verify, k)
+	}
+	return NewKeySet(active, verify...)
+}
+
+// LoadKeyFile parses a PEM-encoded RSA or Ed25519 key, private or public.
+func LoadKeyFile(path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("signing: read %s: %w", path, err)
+	}
+	k, err := ParseKeyPEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("signing: %s: %w", path, err)
+	}
+	return k, nil
+}
+
+// ParseKeyPEM parses a single PEM block. Supported block types are
+// PRIVATE KEY (PKCS#8), RSA PRIVATE KEY (PKCS#1), PUBLIC KEY (PKIX) and
+// RSA PUBLIC KEY (PKCS#1).
+func ParseKeyPEM(data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var parsed any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewKey(parsed)
+}
+
+// NewKey wraps an RSA or Ed25519 key (private or public). The kid is the
+// RFC 7638 thumbprint of the public key, so it is stable across restarts
+// and identical on every instance holding the same key.
+func NewKey(key any) (*Key, error) {
+	k := &Key{}
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		k.Method, k.signKey, k.verifyKey = jwt.SigningMethodRS256, key, &key.PublicKey
+	case *rsa.PublicKey:
+		k.Method, k.verifyKey = jwt.SigningMethodRS256, key
+	case ed25519.PrivateKey:
+		k.Method, k.signKey, k.verifyKey = jwt.SigningMethodEdDSA, key, key.Public()
+	case ed25519.PublicKey:
+		k.Method, k.verifyKey = jwt.SigningMethodEdDSA, key
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+	k.ID = thumbprint(k.publicJWK())
+	return k, nil
+}
+
+// Sign signs claims with the active key and stamps its kid in the header.
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.active.Method, claims)
+	token.Header["kid"] = ks.active.ID
+	return token.SignedString(ks.active.signKey)
+}
+
+// Keyfunc resolves the verification key for a parsed token by its kid header.
+// Tokens without a kid are only accepted when the active key is HMAC, which
+// covers tokens issued before kids were introduced.
+func (ks *KeySet) Keyfunc(t *jwt.Token) (any, error) {
+	kid, _ := t.Header["kid"].(string)
+	if kid == "" {
+		if _, ok := ks.active.Method.(*jwt.SigningMethodHMAC); ok {
+			return ks.active.verifyKey, nil
+		}
+		return nil, ErrUnknownKey
+	}
+
+	k, ok := ks.byID[kid]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	if t.Method.Alg() != k.Method.Alg() {
+		return nil, jwt.ErrTokenSignatureInvalid
+	}
+	return k.verifyKey, nil
+}
+
+// Algorithms lists the JWS algorithms of every key in the set.
+func (ks *KeySet) Algorithms() []string {
+	seen := map[string]bool{}
+	var algs []string
+	for _, k := range ks.keys {
+		if alg := k.Method.Alg(); !seen[alg] {
+			seen[alg] = true
+			algs = append(algs, alg)
+		}
+	}
+	return algs
+}
+
+// SigningAlgorithm returns the JWS algorithm new tokens are signed with.
+func (ks *KeySet) SigningAlgorithm() string {
+	return ks.active.Method.Alg()
+}
+
+// ActiveKeyID returns the kid of the signing key.
+func (ks *KeySet) ActiveKeyID() string {
+	return ks.active.ID
+}
+
+// --- JWKS ---
+
+// JWK is the public half of a key in RFC 7517 form.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+
+	// OKP (Ed25519)
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set document.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys in the set. HMAC secrets are never included.
+// The active key comes first.
+func (ks *KeySet) JWKS() JWKS {
+	doc := JWKS{Keys: []JWK{}}
+	for _, k := range ks.keys {
+		if jwk := k.publicJWK(); jwk.Kty != "" {
+			jwk.Kid, jwk.Use, jwk.Alg = k.ID, "sig", k.Method.Alg()
+			doc.Keys = append(doc.Keys, jwk)
+		}
+	}
+	return doc
+}
+
+func (k *Key) publicJWK() JWK {
+	switch pub := k.verifyKey.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			Kty: "RSA",
+			N:   b64(pub.N.Bytes()),
+			E:   b64(big.NewInt(int64(pub.E)).Bytes()),
+		}
+	case ed25519.PublicKey:
+		return JWK{Kty: "OKP", Crv: "Ed25519", X: b64(pub)}
+	}
+	return JWK{}
+}
+
+// thumbprint computes the RFC 7638 JWK thumbprint: SHA-256 over the required
+// members serialised with lexicographically ordered keys and no whitespace.
+func thumbprint(jwk JWK) string {
+	var members any
+	switch jwk.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	case "OKP":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X}
+	default:
+		return ""
+	}
+	b, _ := json.Marshal(members)
+	sum := sha256.Sum256(b)
+	return b64(sum[:])
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,145 @@
+package signing_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"user-management-api/internal/signing"
+)
+
+func newRSAKey(t *testing.T) *signing.Key {
+	t.Helper()
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate rsa: %v", err)
+	}
+	k, err := signing.NewKey(priv)
+	if err != nil {
+		t.Fatalf("new key: %v", err)
+	}
+	return k
+}
+
+func newEd25519Key(t *testing.T) *signing.Key {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate ed25519: %v", err)
+	}
+	k, err := signing.NewKey(priv)
+	if err != nil {
+		t.Fatalf("new key: %v", err)
+	}
+	return k
+}
+
+func sign(t *testing.T, ks *signing.KeySet) string {
+	t.Helper()
+	tok, err := ks.Sign(jwt.MapClaims{"sub": "abc", "exp": time.Now().Add(time.Minute).Unix()})
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return tok
+}
+
+func verify(ks *signing.KeySet, tok string) error {
+	_, err := jwt.Parse(tok, ks.Keyfunc, jwt.WithValidMethods(ks.Algorithms()))
+	return err
+}
+
+func TestKeySet_SignAndVerify(t *testing.T) {
+	for name, key := range map[string]*signing.Key{
+		"RS256": newRSAKey(t),
+		"EdDSA": newEd25519Key(t),
+	} {
+		t.Run(name, func(t *testing.T) {
+			ks, err := signing.NewKeySet(key)
+			if err != nil {
+				t.Fatalf("new key set: %v", err)
+			}
+			tok := sign(t, ks)
+
+			parsed, _, err := jwt.NewParser().ParseUnverified(tok, jwt.MapClaims{})
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			if parsed.Header["kid"] != key.ID {
+				t.Errorf("expected kid %s, got %v", key.ID, parsed.Header["kid"])
+			}
+			if parsed.Method.Alg() != name || ks.SigningAlgorithm() != name {
+				t.Errorf("expected alg %s, got %s (key set says %s)", name, parsed.Method.Alg(), ks.SigningAlgorithm())
+			}
+			if err := verify(ks, tok); err != nil {
+				t.Errorf("verify: %v", err)
+			}
+		})
+	}
+}
+
+func TestKeySet_RotationKeepsOldTokensValid(t *testing.T) {
+	oldKey, newKey := newRSAKey(t), newEd25519Key(t)
+
+	before, _ := signing.NewKeySet(oldKey)
+	tok := sign(t, before)
+
+	after, err := signing.NewKeySet(newKey, oldKey)
+	if err != nil {
+		t.Fatalf("new key set: %v", err)
+	}
+	if err := verify(after, tok); err != nil {
+		t.Errorf("token signed with the previous key should verify: %v", err)
+	}
+
+	jwks := after.JWKS()
+	if len(jwks.Keys) != 2 || jwks.Keys[0].Kid != newKey.ID || jwks.Keys[1].Kid != oldKey.ID {
+		t.Errorf("expected JWKS [%s %s], got %+v", newKey.ID, oldKey.ID, jwks.Keys)
+	}
+
+	// A key set that no longer holds the old kid must reject its tokens.
+	retired, _ := signing.NewKeySet(newRSAKey(t))
+	if err := verify(retired, tok); !errors.Is(err, signing.ErrUnknownKey) {
+		t.Errorf("expected ErrUnknownKey, got %v", err)
+	}
+}
+
+func TestKeySet_HMACNotPublished(t *testing.T) {
+	ks := signing.NewHMACKeySet("secret")
+	if err := verify(ks, sign(t, ks)); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if n := len(ks.JWKS().Keys); n != 0 {
+		t.Errorf("expected no published keys, got %d", n)
+	}
+}
+
+func TestParseKeyPEM_PublicKeyIsVerifyOnly(t *testing.T) {
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+	der, err := x509.MarshalPKIXPublicKey(priv.Public())
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	k, err := signing.ParseKeyPEM(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if k.CanSign() {
+		t.Error("public key should not be able to sign")
+	}
+	if _, err := signing.NewKeySet(k); err == nil {
+		t.Error("expected an error using a public key as the active key")
+	}
+
+	full, _ := signing.NewKey(priv)
+	if k.ID != full.ID {
+		t.Errorf("public and private halves should share a kid: %s vs %s", k.ID, full.ID)
+	}
+}
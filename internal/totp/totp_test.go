@@ -0,0 +1,74 @@
+package totp
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+// RFC 6238 appendix B, SHA-1 rows.
+func TestCode_RFC6238Vectors(t *testing.T) {
+	key := []byte("12345678901234567890")
+	vectors := []struct {
+		unix int64
+		want string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+	for _, v := range vectors {
+		if got := hotp(key, Step(time.Unix(v.unix, 0)), 8); got != v.want {
+			t.Errorf("t=%d: got %s, want %s", v.unix, got, v.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	now := time.Now()
+
+	c, err := Code(secret, now)
+	if err != nil {
+		t.Fatalf("code: %v", err)
+	}
+	step, ok := Validate(secret, c, now, 1)
+	if !ok || step != Step(now) {
+		t.Fatalf("expected current code to validate at step %d, got %d, %v", Step(now), step, ok)
+	}
+
+	prev, _ := Code(secret, now.Add(-Period))
+	if _, ok := Validate(secret, prev, now, 1); !ok {
+		t.Error("expected previous step to be accepted with skew 1")
+	}
+	if _, ok := Validate(secret, prev, now, 0); ok {
+		t.Error("expected previous step to be rejected with skew 0")
+	}
+
+	old, _ := Code(secret, now.Add(-3*Period))
+	if _, ok := Validate(secret, old, now, 1); ok {
+		t.Error("expected a code three steps old to be rejected")
+	}
+	if _, ok := Validate(secret, "12345", now, 1); ok {
+		t.Error("expected a short code to be rejected")
+	}
+}
+
+func TestURI(t *testing.T) {
+	u, err := url.Parse(URI("Acme", "alice@example.com", "JBSWY3DPEHPK3PXP"))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if u.Scheme != "otpauth" || u.Host != "totp" || u.Path != "/Acme:alice@example.com" {
+		t.Errorf("unexpected URI %s", u)
+	}
+	if q := u.Query(); q.Get("secret") != "JBSWY3DPEHPK3PXP" || q.Get("issuer") != "Acme" {
+		t.Errorf("unexpected query %s", u.RawQuery)
+	}
+}
@@ -0,0 +1,39 @@
+package webauthn
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestDecodeCBOR(t *testing.T) {
+	// {"a": 1, -2: h'0102', "b": [true, null, -500]}
+	in := []byte{0xa3, 0x61, 'a', 0x01, 0x21, 0x42, 0x01, 0x02, 0x61, 'b', 0x83, 0xf5, 0xf6, 0x39, 0x01, 0xf3}
+	v, rest, err := decodeCBOR(in)
+	if err != nil || len(rest) != 0 {
+		t.Fatalf("decode: %v, rest %x", err, rest)
+	}
+	m := v.(map[any]any)
+	if m["a"] != int64(1) || !bytes.Equal(m[int64(-2)].([]byte), []byte{1, 2}) {
+		t.Errorf("unexpected map %v", m)
+	}
+	arr := m["b"].([]any)
+	if arr[0] != true || arr[1] != nil || arr[2] != int64(-500) {
+		t.Errorf("unexpected array %v", arr)
+	}
+}
+
+func TestDecodeCBOR_Malformed(t *testing.T) {
+	for name, in := range map[string][]byte{
+		"empty":            {},
+		"truncated string": {0x45, 0x01},
+		"indefinite":       {0x9f, 0x01, 0xff},
+		"float":            {0xfa, 0, 0, 0, 0},
+		"huge array":       {0x9b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		"array key":        {0xa1, 0x80, 0x01},
+	} {
+		if _, _, err := decodeCBOR(in); !errors.Is(err, errCBOR) {
+			t.Errorf("%s: expected errCBOR, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package webauthn_test
+
+import (
+	"errors"
+	"testing"
+
+	"user-management-api/internal/webauthn"
+	"user-management-api/internal/webauthn/webauthntest"
+)
+
+var rp = &webauthn.RelyingParty{ID: "example.com", Name: "Example", Origins: []string{"https://example.com"}}
+
+func register(t *testing.T, a *webauthntest.Authenticator) *webauthn.Credential {
+	t.Helper()
+	att, err := a.Create("reg-challenge", []byte("user-1"))
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	cred, err := rp.VerifyRegistration("reg-challenge", att.ClientDataJSON, att.AttestationObject)
+	if err != nil {
+		t.Fatalf("verify registration: %v", err)
+	}
+	return cred
+}
+
+func TestRegistrationAndAssertion(t *testing.T) {
+	a := webauthntest.New("example.com", "https://example.com")
+	cred := register(t, a)
+	if len(cred.ID) == 0 || len(cred.PublicKey) == 0 {
+		t.Fatalf("expected credential ID and key, got %+v", cred)
+	}
+
+	for want := uint32(1); want <= 2; want++ {
+		as, err := a.Get("login-challenge")
+		if err != nil {
+			t.Fatalf("get: %v", err)
+		}
+		res, err := rp.VerifyAssertion("login-challenge", cred.PublicKey, as.ClientDataJSON, as.AuthenticatorData, as.Signature)
+		if err != nil {
+			t.Fatalf("verify assertion: %v", err)
+		}
+		if res.SignCount != want || !res.UserVerified {
+			t.Errorf("expected sign count %d with UV, got %+v", want, res)
+		}
+	}
+}
+
+func TestVerifyRegistration_Rejects(t *testing.T) {
+	tests := []struct {
+		name      string
+		setup     func(a *webauthntest.Authenticator)
+		challenge string
+	}{
+		{"wrong challenge", func(*webauthntest.Authenticator) {}, "other-challenge"},
+		{"wrong origin", func(a *webauthntest.Authenticator) { a.Origin = "https://evil.example" }, "reg-challenge"},
+		{"wrong RP ID", func(a *webauthntest.Authenticator) { a.RPID = "evil.example" }, "reg-challenge"},
+		{"no user verification", func(a *webauthntest.Authenticator) { a.UserVerified = false }, "reg-challenge"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := webauthntest.New("example.com", "https://example.com")
+			tt.setup(a)
+			att, err := a.Create("reg-challenge", []byte("user-1"))
+			if err != nil {
+				t.Fatalf("create: %v", err)
+			}
+			_, err = rp.VerifyRegistration(tt.challenge, att.ClientDataJSON, att.AttestationObject)
+			if !errors.Is(err, webauthn.ErrVerification) {
+				t.Errorf("expected ErrVerification, got %v", err)
+			}
+		})
+	}
+}
+
+func TestVerifyAssertion_RejectsTampering(t *testing.T) {
+	a := webauthntest.New("example.com", "https://example.com")
+	cred := register(t, a)
+
+	as, err := a.Get("login-challenge")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	// Bump the counter in the signed data without re-signing.
+	tampered := append([]byte(nil), as.AuthenticatorData...)
+	tampered[len(tampered)-1]++
+	if _, err := rp.VerifyAssertion("login-challenge", cred.PublicKey, as.ClientDataJSON, tampered, as.Signature); !errors.Is(err, webauthn.ErrVerification) {
+		t.Errorf("tampered data: expected ErrVerification, got %v", err)
+	}
+
+	other := register(t, webauthntest.New("example.com", "https://example.com"))
+	if _, err := rp.VerifyAssertion("login-challenge", other.PublicKey, as.ClientDataJSON, as.AuthenticatorData, as.Signature); !errors.Is(err, webauthn.ErrVerification) {
+		t.Errorf("wrong key: expected ErrVerification, got %v", err)
+	}
+	if _, err := rp.VerifyAssertion("login-challenge", cred.PublicKey, as.ClientDataJSON[:10], as.AuthenticatorData, as.Signature); !errors.Is(err, webauthn.ErrVerification) {
+		t.Errorf("truncated client data: expected ErrVerification, got %v", err)
+	}
+}
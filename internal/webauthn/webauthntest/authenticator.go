@@ -0,0 +1,212 @@
+// Package webauthntest provides a software authenticator for driving
+// WebAuthn ceremonies from tests, in the spirit of net/http/httptest.
+package webauthntest
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+)
+
+// Authenticator is a platform authenticator holding ES256 passkeys in memory.
+type Authenticator struct {
+	RPID   string
+	Origin string
+	// UserVerified controls the UV flag; real authenticators set it after a
+	// PIN or biometric check. Defaults to true.
+	UserVerified bool
+
+	creds []*credential
+}
+
+type credential struct {
+	id         []byte
+	key        *ecdsa.PrivateKey
+	userHandle []byte
+	signCount  uint32
+}
+
+// Attestation is the response to navigator.credentials.create().
+type Attestation struct {
+	CredentialID      []byte
+	ClientDataJSON    []byte
+	AttestationObject []byte
+}
+
+// Assertion is the response to navigator.credentials.get().
+type Assertion struct {
+	CredentialID      []byte
+	ClientDataJSON    []byte
+	AuthenticatorData []byte
+	Signature         []byte
+	UserHandle        []byte
+}
+
+func New(rpID, origin string) *Authenticator {
+	return &Authenticator{RPID: rpID, Origin: origin, UserVerified: true}
+}
+
+// Create makes a new discoverable credential for userHandle, answering a
+// creation request with the given base64url challenge, and returns a "none"
+// attestation.
+func (a *Authenticator) Create(challenge string, userHandle []byte) (*Attestation, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	c := &credential{id: id, key: key, userHandle: append([]byte(nil), userHandle...)}
+	a.creds = append(a.creds, c)
+
+	clientData, err := a.clientData("webauthn.create", challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	var ad bytes.Buffer
+	ad.Write(a.authDataHeader(0x40, 0))
+	ad.Write(make([]byte, 16))                           // AAGUID
+	binary.Write(&ad, binary.BigEndian, uint16(len(id))) //nolint:errcheck
+	ad.Write(id)
+	ad.Write(encode(pairs{
+		{1, 2},  // kty: EC2
+		{3, -7}, // alg: ES256
+		{-1, 1}, // crv: P-256
+		{-2, key.X.FillBytes(make([]byte, 32))},
+		{-3, key.Y.FillBytes(make([]byte, 32))},
+	}))
+
+	return &Attestation{
+		CredentialID:   id,
+		ClientDataJSON: clientData,
+		AttestationObject: encode(pairs{
+			{"fmt", "none"},
+			{"attStmt", pairs{}},
+			{"authData", ad.Bytes()},
+		}),
+	}, nil
+}
+
+// Get signs an assertion for challenge with the most recently created
+// credential, incrementing its signature counter.
+func (a *Authenticator) Get(challenge string) (*Assertion, error) {
+	if len(a.creds) == 0 {
+		return nil, errors.New("webauthntest: no credentials")
+	}
+	c := a.creds[len(a.creds)-1]
+	c.signCount++
+
+	clientData, err := a.clientData("webauthn.get", challenge)
+	if err != nil {
+		return nil, err
+	}
+	authData := a.authDataHeader(0, c.signCount)
+	hash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte(nil), authData...), hash[:]...))
+	sig, err := ecdsa.SignASN1(rand.Reader, c.key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+
+	return &Assertion{
+		CredentialID:      c.id,
+		ClientDataJSON:    clientData,
+		AuthenticatorData: authData,
+		Signature:         sig,
+		UserHandle:        c.userHandle,
+	}, nil
+}
+
+// Clone returns an independent copy holding the same keys and counters, as
+// an attacker who extracted them would.
+func (a *Authenticator) Clone() *Authenticator {
+	out := *a
+	out.creds = nil
+	for _, c := range a.creds {
+		cp := *c
+		out.creds = append(out.creds, &cp)
+	}
+	return &out
+}
+
+func (a *Authenticator) clientData(typ, challenge string) ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"type":        typ,
+		"challenge":   challenge,
+		"origin":      a.Origin,
+		"crossOrigin": false,
+	})
+}
+
+func (a *Authenticator) authDataHeader(extraFlags byte, signCount uint32) []byte {
+	rpIDHash := sha256.Sum256([]byte(a.RPID))
+	flags := byte(0x01) | extraFlags // UP
+	if a.UserVerified {
+		flags |= 0x04
+	}
+	b := append(rpIDHash[:], flags)
+	return binary.BigEndian.AppendUint32(b, signCount)
+}
+
+// --- minimal CBOR encoder ---
+
+// pairs is an ordered CBOR map.
+type pairs []struct{ k, v any }
+
+func encode(v any) []byte {
+	var buf bytes.Buffer
+	encodeTo(&buf, v)
+	return buf.Bytes()
+}
+
+func encodeTo(buf *bytes.Buffer, v any) {
+	switch v := v.(type) {
+	case int:
+		if v >= 0 {
+			head(buf, 0, uint64(v))
+		} else {
+			head(buf, 1, uint64(-1-v))
+		}
+	case []byte:
+		head(buf, 2, uint64(len(v)))
+		buf.Write(v)
+	case string:
+		head(buf, 3, uint64(len(v)))
+		buf.WriteString(v)
+	case pairs:
+		head(buf, 5, uint64(len(v)))
+		for _, p := range v {
+			encodeTo(buf, p.k)
+			encodeTo(buf, p.v)
+		}
+	default:
+		panic("webauthntest: cannot encode value")
+	}
+}
+
+func head(buf *bytes.Buffer, major byte, n uint64) {
+	switch {
+	case n < 24:
+		buf.WriteByte(major<<5 | byte(n))
+	case n <= 0xff:
+		buf.WriteByte(major<<5 | 24)
+		buf.WriteByte(byte(n))
+	case n <= 0xffff:
+		buf.WriteByte(major<<5 | 25)
+		buf.Write(binary.BigEndian.AppendUint16(nil, uint16(n)))
+	case n <= 0xffffffff:
+		buf.WriteByte(major<<5 | 26)
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(n)))
+	default:
+		buf.WriteByte(major<<5 | 27)
+		buf.Write(binary.BigEndian.AppendUint64(nil, n))
+	}
+}